@@ -0,0 +1,5 @@
+// Package gowatchrunv1 is the gRPC API served by gowatchrun --grpc-listen,
+// generated from gowatchrun.proto.
+package gowatchrunv1
+
+//go:generate protoc -I ../../.. --go_out=../../.. --go_opt=paths=source_relative --go-grpc_out=../../.. --go-grpc_opt=paths=source_relative api/gowatchrun/v1/gowatchrun.proto
@@ -0,0 +1,869 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: api/gowatchrun/v1/gowatchrun.proto
+
+package gowatchrunv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Event_Kind int32
+
+const (
+	Event_KIND_UNSPECIFIED  Event_Kind = 0
+	Event_KIND_RUN_STARTED  Event_Kind = 1
+	Event_KIND_RUN_FINISHED Event_Kind = 2
+	Event_KIND_PAUSED       Event_Kind = 3
+	Event_KIND_RESUMED      Event_Kind = 4
+	// An event that passed the job's filters.
+	Event_KIND_EVENT Event_Kind = 5
+)
+
+// Enum value maps for Event_Kind.
+var (
+	Event_Kind_name = map[int32]string{
+		0: "KIND_UNSPECIFIED",
+		1: "KIND_RUN_STARTED",
+		2: "KIND_RUN_FINISHED",
+		3: "KIND_PAUSED",
+		4: "KIND_RESUMED",
+		5: "KIND_EVENT",
+	}
+	Event_Kind_value = map[string]int32{
+		"KIND_UNSPECIFIED":  0,
+		"KIND_RUN_STARTED":  1,
+		"KIND_RUN_FINISHED": 2,
+		"KIND_PAUSED":       3,
+		"KIND_RESUMED":      4,
+		"KIND_EVENT":        5,
+	}
+)
+
+func (x Event_Kind) Enum() *Event_Kind {
+	p := new(Event_Kind)
+	*p = x
+	return p
+}
+
+func (x Event_Kind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Event_Kind) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_gowatchrun_v1_gowatchrun_proto_enumTypes[0].Descriptor()
+}
+
+func (Event_Kind) Type() protoreflect.EnumType {
+	return &file_api_gowatchrun_v1_gowatchrun_proto_enumTypes[0]
+}
+
+func (x Event_Kind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Event_Kind.Descriptor instead.
+func (Event_Kind) EnumDescriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{4, 0}
+}
+
+type StatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{0}
+}
+
+type StatusResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// False when a running job's event loop is unresponsive.
+	Healthy       bool                 `protobuf:"varint,1,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	Uptime        *durationpb.Duration `protobuf:"bytes,2,opt,name=uptime,proto3" json:"uptime,omitempty"`
+	Hostname      string               `protobuf:"bytes,3,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Jobs          []*JobStatus         `protobuf:"bytes,4,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusResponse) Reset() {
+	*x = StatusResponse{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusResponse) ProtoMessage() {}
+
+func (x *StatusResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusResponse.ProtoReflect.Descriptor instead.
+func (*StatusResponse) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StatusResponse) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *StatusResponse) GetUptime() *durationpb.Duration {
+	if x != nil {
+		return x.Uptime
+	}
+	return nil
+}
+
+func (x *StatusResponse) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *StatusResponse) GetJobs() []*JobStatus {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+type JobStatus struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Alive bool                   `protobuf:"varint,2,opt,name=alive,proto3" json:"alive,omitempty"`
+	// False once the job's watcher stopped.
+	Running bool `protobuf:"varint,3,opt,name=running,proto3" json:"running,omitempty"`
+	// Executing the command.
+	Busy   bool `protobuf:"varint,4,opt,name=busy,proto3" json:"busy,omitempty"`
+	Paused bool `protobuf:"varint,5,opt,name=paused,proto3" json:"paused,omitempty"`
+	// Watched directories.
+	Watches int32 `protobuf:"varint,6,opt,name=watches,proto3" json:"watches,omitempty"`
+	// Directories that couldn't be watched.
+	WatchFailures int32                  `protobuf:"varint,7,opt,name=watch_failures,json=watchFailures,proto3" json:"watch_failures,omitempty"`
+	LastEvent     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=last_event,json=lastEvent,proto3" json:"last_event,omitempty"`
+	LastRun       *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=last_run,json=lastRun,proto3" json:"last_run,omitempty"`
+	// "ok" or the error of the last run.
+	LastResult    string `protobuf:"bytes,10,opt,name=last_result,json=lastResult,proto3" json:"last_result,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobStatus) Reset() {
+	*x = JobStatus{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobStatus) ProtoMessage() {}
+
+func (x *JobStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobStatus.ProtoReflect.Descriptor instead.
+func (*JobStatus) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *JobStatus) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *JobStatus) GetAlive() bool {
+	if x != nil {
+		return x.Alive
+	}
+	return false
+}
+
+func (x *JobStatus) GetRunning() bool {
+	if x != nil {
+		return x.Running
+	}
+	return false
+}
+
+func (x *JobStatus) GetBusy() bool {
+	if x != nil {
+		return x.Busy
+	}
+	return false
+}
+
+func (x *JobStatus) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *JobStatus) GetWatches() int32 {
+	if x != nil {
+		return x.Watches
+	}
+	return 0
+}
+
+func (x *JobStatus) GetWatchFailures() int32 {
+	if x != nil {
+		return x.WatchFailures
+	}
+	return 0
+}
+
+func (x *JobStatus) GetLastEvent() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastEvent
+	}
+	return nil
+}
+
+func (x *JobStatus) GetLastRun() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastRun
+	}
+	return nil
+}
+
+func (x *JobStatus) GetLastResult() string {
+	if x != nil {
+		return x.LastResult
+	}
+	return ""
+}
+
+type StreamEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only stream the events of these jobs; all jobs when empty.
+	Jobs          []string `protobuf:"bytes,1,rep,name=jobs,proto3" json:"jobs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamEventsRequest) Reset() {
+	*x = StreamEventsRequest{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamEventsRequest) ProtoMessage() {}
+
+func (x *StreamEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamEventsRequest.ProtoReflect.Descriptor instead.
+func (*StreamEventsRequest) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamEventsRequest) GetJobs() []string {
+	if x != nil {
+		return x.Jobs
+	}
+	return nil
+}
+
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Kind  Event_Kind             `protobuf:"varint,1,opt,name=kind,proto3,enum=gowatchrun.v1.Event_Kind" json:"kind,omitempty"`
+	Job   string                 `protobuf:"bytes,2,opt,name=job,proto3" json:"job,omitempty"`
+	Time  *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	// The event type (e.g. WRITE, WEBHOOK or TRIGGER) of an accepted event or
+	// of the event that triggered a run, empty for runs without an event.
+	Event string `protobuf:"bytes,4,opt,name=event,proto3" json:"event,omitempty"`
+	// The file of an accepted event, or the files of a run, several for a
+	// batch.
+	Paths []string `protobuf:"bytes,5,rep,name=paths,proto3" json:"paths,omitempty"`
+	// How long a finished run took.
+	Duration *durationpb.Duration `protobuf:"bytes,6,opt,name=duration,proto3" json:"duration,omitempty"`
+	// The exit status of a finished run, 1 for failures other than a
+	// command's exit status.
+	ExitCode int32 `protobuf:"varint,7,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	// The error of a failed run.
+	Error string `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	// The ID of a run, which its output streamed by --health-listen on
+	// /output refers to.
+	Run           uint64 `protobuf:"varint,9,opt,name=run,proto3" json:"run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Event) GetKind() Event_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return Event_KIND_UNSPECIFIED
+}
+
+func (x *Event) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+func (x *Event) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Event) GetEvent() string {
+	if x != nil {
+		return x.Event
+	}
+	return ""
+}
+
+func (x *Event) GetPaths() []string {
+	if x != nil {
+		return x.Paths
+	}
+	return nil
+}
+
+func (x *Event) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *Event) GetExitCode() int32 {
+	if x != nil {
+		return x.ExitCode
+	}
+	return 0
+}
+
+func (x *Event) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Event) GetRun() uint64 {
+	if x != nil {
+		return x.Run
+	}
+	return 0
+}
+
+type TriggerRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The job to run. May be empty when only one job is running.
+	Job string `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	// A file to run for, available as {{.Path}} and the other file
+	// placeholders. It must be inside the job's watch directories and pass
+	// its filters.
+	Path          string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TriggerRequest) Reset() {
+	*x = TriggerRequest{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TriggerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TriggerRequest) ProtoMessage() {}
+
+func (x *TriggerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TriggerRequest.ProtoReflect.Descriptor instead.
+func (*TriggerRequest) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TriggerRequest) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+func (x *TriggerRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+type TriggerResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TriggerResponse) Reset() {
+	*x = TriggerResponse{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TriggerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TriggerResponse) ProtoMessage() {}
+
+func (x *TriggerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TriggerResponse.ProtoReflect.Descriptor instead.
+func (*TriggerResponse) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{6}
+}
+
+type PauseRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The job to pause; all jobs when empty.
+	Job           string `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseRequest) Reset() {
+	*x = PauseRequest{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseRequest) ProtoMessage() {}
+
+func (x *PauseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseRequest.ProtoReflect.Descriptor instead.
+func (*PauseRequest) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PauseRequest) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+type PauseResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PauseResponse) Reset() {
+	*x = PauseResponse{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PauseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PauseResponse) ProtoMessage() {}
+
+func (x *PauseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PauseResponse.ProtoReflect.Descriptor instead.
+func (*PauseResponse) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{8}
+}
+
+type ResumeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The job to resume; all jobs when empty.
+	Job           string `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeRequest) Reset() {
+	*x = ResumeRequest{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeRequest) ProtoMessage() {}
+
+func (x *ResumeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeRequest.ProtoReflect.Descriptor instead.
+func (*ResumeRequest) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ResumeRequest) GetJob() string {
+	if x != nil {
+		return x.Job
+	}
+	return ""
+}
+
+type ResumeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResumeResponse) Reset() {
+	*x = ResumeResponse{}
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResumeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResumeResponse) ProtoMessage() {}
+
+func (x *ResumeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_gowatchrun_v1_gowatchrun_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResumeResponse.ProtoReflect.Descriptor instead.
+func (*ResumeResponse) Descriptor() ([]byte, []int) {
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP(), []int{10}
+}
+
+var File_api_gowatchrun_v1_gowatchrun_proto protoreflect.FileDescriptor
+
+const file_api_gowatchrun_v1_gowatchrun_proto_rawDesc = "" +
+	"\n" +
+	"\"api/gowatchrun/v1/gowatchrun.proto\x12\rgowatchrun.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x0f\n" +
+	"\rStatusRequest\"\xa7\x01\n" +
+	"\x0eStatusResponse\x12\x18\n" +
+	"\ahealthy\x18\x01 \x01(\bR\ahealthy\x121\n" +
+	"\x06uptime\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x06uptime\x12\x1a\n" +
+	"\bhostname\x18\x03 \x01(\tR\bhostname\x12,\n" +
+	"\x04jobs\x18\x04 \x03(\v2\x18.gowatchrun.v1.JobStatusR\x04jobs\"\xcf\x02\n" +
+	"\tJobStatus\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05alive\x18\x02 \x01(\bR\x05alive\x12\x18\n" +
+	"\arunning\x18\x03 \x01(\bR\arunning\x12\x12\n" +
+	"\x04busy\x18\x04 \x01(\bR\x04busy\x12\x16\n" +
+	"\x06paused\x18\x05 \x01(\bR\x06paused\x12\x18\n" +
+	"\awatches\x18\x06 \x01(\x05R\awatches\x12%\n" +
+	"\x0ewatch_failures\x18\a \x01(\x05R\rwatchFailures\x129\n" +
+	"\n" +
+	"last_event\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tlastEvent\x125\n" +
+	"\blast_run\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\alastRun\x12\x1f\n" +
+	"\vlast_result\x18\n" +
+	" \x01(\tR\n" +
+	"lastResult\")\n" +
+	"\x13StreamEventsRequest\x12\x12\n" +
+	"\x04jobs\x18\x01 \x03(\tR\x04jobs\"\x9e\x03\n" +
+	"\x05Event\x12-\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\x19.gowatchrun.v1.Event.KindR\x04kind\x12\x10\n" +
+	"\x03job\x18\x02 \x01(\tR\x03job\x12.\n" +
+	"\x04time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x14\n" +
+	"\x05event\x18\x04 \x01(\tR\x05event\x12\x14\n" +
+	"\x05paths\x18\x05 \x03(\tR\x05paths\x125\n" +
+	"\bduration\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12\x1b\n" +
+	"\texit_code\x18\a \x01(\x05R\bexitCode\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error\x12\x10\n" +
+	"\x03run\x18\t \x01(\x04R\x03run\"|\n" +
+	"\x04Kind\x12\x14\n" +
+	"\x10KIND_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10KIND_RUN_STARTED\x10\x01\x12\x15\n" +
+	"\x11KIND_RUN_FINISHED\x10\x02\x12\x0f\n" +
+	"\vKIND_PAUSED\x10\x03\x12\x10\n" +
+	"\fKIND_RESUMED\x10\x04\x12\x0e\n" +
+	"\n" +
+	"KIND_EVENT\x10\x05\"6\n" +
+	"\x0eTriggerRequest\x12\x10\n" +
+	"\x03job\x18\x01 \x01(\tR\x03job\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\"\x11\n" +
+	"\x0fTriggerResponse\" \n" +
+	"\fPauseRequest\x12\x10\n" +
+	"\x03job\x18\x01 \x01(\tR\x03job\"\x0f\n" +
+	"\rPauseResponse\"!\n" +
+	"\rResumeRequest\x12\x10\n" +
+	"\x03job\x18\x01 \x01(\tR\x03job\"\x10\n" +
+	"\x0eResumeResponse2\xf4\x02\n" +
+	"\n" +
+	"Gowatchrun\x12E\n" +
+	"\x06Status\x12\x1c.gowatchrun.v1.StatusRequest\x1a\x1d.gowatchrun.v1.StatusResponse\x12J\n" +
+	"\fStreamEvents\x12\".gowatchrun.v1.StreamEventsRequest\x1a\x14.gowatchrun.v1.Event0\x01\x12H\n" +
+	"\aTrigger\x12\x1d.gowatchrun.v1.TriggerRequest\x1a\x1e.gowatchrun.v1.TriggerResponse\x12B\n" +
+	"\x05Pause\x12\x1b.gowatchrun.v1.PauseRequest\x1a\x1c.gowatchrun.v1.PauseResponse\x12E\n" +
+	"\x06Resume\x12\x1c.gowatchrun.v1.ResumeRequest\x1a\x1d.gowatchrun.v1.ResumeResponseB?Z=github.com/s0up4200/gowatchrun/api/gowatchrun/v1;gowatchrunv1b\x06proto3"
+
+var (
+	file_api_gowatchrun_v1_gowatchrun_proto_rawDescOnce sync.Once
+	file_api_gowatchrun_v1_gowatchrun_proto_rawDescData []byte
+)
+
+func file_api_gowatchrun_v1_gowatchrun_proto_rawDescGZIP() []byte {
+	file_api_gowatchrun_v1_gowatchrun_proto_rawDescOnce.Do(func() {
+		file_api_gowatchrun_v1_gowatchrun_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_gowatchrun_v1_gowatchrun_proto_rawDesc), len(file_api_gowatchrun_v1_gowatchrun_proto_rawDesc)))
+	})
+	return file_api_gowatchrun_v1_gowatchrun_proto_rawDescData
+}
+
+var file_api_gowatchrun_v1_gowatchrun_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_api_gowatchrun_v1_gowatchrun_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_api_gowatchrun_v1_gowatchrun_proto_goTypes = []any{
+	(Event_Kind)(0),               // 0: gowatchrun.v1.Event.Kind
+	(*StatusRequest)(nil),         // 1: gowatchrun.v1.StatusRequest
+	(*StatusResponse)(nil),        // 2: gowatchrun.v1.StatusResponse
+	(*JobStatus)(nil),             // 3: gowatchrun.v1.JobStatus
+	(*StreamEventsRequest)(nil),   // 4: gowatchrun.v1.StreamEventsRequest
+	(*Event)(nil),                 // 5: gowatchrun.v1.Event
+	(*TriggerRequest)(nil),        // 6: gowatchrun.v1.TriggerRequest
+	(*TriggerResponse)(nil),       // 7: gowatchrun.v1.TriggerResponse
+	(*PauseRequest)(nil),          // 8: gowatchrun.v1.PauseRequest
+	(*PauseResponse)(nil),         // 9: gowatchrun.v1.PauseResponse
+	(*ResumeRequest)(nil),         // 10: gowatchrun.v1.ResumeRequest
+	(*ResumeResponse)(nil),        // 11: gowatchrun.v1.ResumeResponse
+	(*durationpb.Duration)(nil),   // 12: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil), // 13: google.protobuf.Timestamp
+}
+var file_api_gowatchrun_v1_gowatchrun_proto_depIdxs = []int32{
+	12, // 0: gowatchrun.v1.StatusResponse.uptime:type_name -> google.protobuf.Duration
+	3,  // 1: gowatchrun.v1.StatusResponse.jobs:type_name -> gowatchrun.v1.JobStatus
+	13, // 2: gowatchrun.v1.JobStatus.last_event:type_name -> google.protobuf.Timestamp
+	13, // 3: gowatchrun.v1.JobStatus.last_run:type_name -> google.protobuf.Timestamp
+	0,  // 4: gowatchrun.v1.Event.kind:type_name -> gowatchrun.v1.Event.Kind
+	13, // 5: gowatchrun.v1.Event.time:type_name -> google.protobuf.Timestamp
+	12, // 6: gowatchrun.v1.Event.duration:type_name -> google.protobuf.Duration
+	1,  // 7: gowatchrun.v1.Gowatchrun.Status:input_type -> gowatchrun.v1.StatusRequest
+	4,  // 8: gowatchrun.v1.Gowatchrun.StreamEvents:input_type -> gowatchrun.v1.StreamEventsRequest
+	6,  // 9: gowatchrun.v1.Gowatchrun.Trigger:input_type -> gowatchrun.v1.TriggerRequest
+	8,  // 10: gowatchrun.v1.Gowatchrun.Pause:input_type -> gowatchrun.v1.PauseRequest
+	10, // 11: gowatchrun.v1.Gowatchrun.Resume:input_type -> gowatchrun.v1.ResumeRequest
+	2,  // 12: gowatchrun.v1.Gowatchrun.Status:output_type -> gowatchrun.v1.StatusResponse
+	5,  // 13: gowatchrun.v1.Gowatchrun.StreamEvents:output_type -> gowatchrun.v1.Event
+	7,  // 14: gowatchrun.v1.Gowatchrun.Trigger:output_type -> gowatchrun.v1.TriggerResponse
+	9,  // 15: gowatchrun.v1.Gowatchrun.Pause:output_type -> gowatchrun.v1.PauseResponse
+	11, // 16: gowatchrun.v1.Gowatchrun.Resume:output_type -> gowatchrun.v1.ResumeResponse
+	12, // [12:17] is the sub-list for method output_type
+	7,  // [7:12] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_api_gowatchrun_v1_gowatchrun_proto_init() }
+func file_api_gowatchrun_v1_gowatchrun_proto_init() {
+	if File_api_gowatchrun_v1_gowatchrun_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_gowatchrun_v1_gowatchrun_proto_rawDesc), len(file_api_gowatchrun_v1_gowatchrun_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_gowatchrun_v1_gowatchrun_proto_goTypes,
+		DependencyIndexes: file_api_gowatchrun_v1_gowatchrun_proto_depIdxs,
+		EnumInfos:         file_api_gowatchrun_v1_gowatchrun_proto_enumTypes,
+		MessageInfos:      file_api_gowatchrun_v1_gowatchrun_proto_msgTypes,
+	}.Build()
+	File_api_gowatchrun_v1_gowatchrun_proto = out.File
+	file_api_gowatchrun_v1_gowatchrun_proto_goTypes = nil
+	file_api_gowatchrun_v1_gowatchrun_proto_depIdxs = nil
+}
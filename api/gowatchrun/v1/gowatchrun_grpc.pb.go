@@ -0,0 +1,295 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: api/gowatchrun/v1/gowatchrun.proto
+
+package gowatchrunv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Gowatchrun_Status_FullMethodName       = "/gowatchrun.v1.Gowatchrun/Status"
+	Gowatchrun_StreamEvents_FullMethodName = "/gowatchrun.v1.Gowatchrun/StreamEvents"
+	Gowatchrun_Trigger_FullMethodName      = "/gowatchrun.v1.Gowatchrun/Trigger"
+	Gowatchrun_Pause_FullMethodName        = "/gowatchrun.v1.Gowatchrun/Pause"
+	Gowatchrun_Resume_FullMethodName       = "/gowatchrun.v1.Gowatchrun/Resume"
+)
+
+// GowatchrunClient is the client API for Gowatchrun service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Gowatchrun is served by `gowatchrun --grpc-listen`.
+type GowatchrunClient interface {
+	// Status reports the health of every job.
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	// StreamEvents streams accepted events, runs and pauses as they happen,
+	// until the client cancels. Events are dropped for clients that can't
+	// keep up.
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	// Trigger queues a run of a job, as if one of its event sources fired.
+	Trigger(ctx context.Context, in *TriggerRequest, opts ...grpc.CallOption) (*TriggerResponse, error)
+	// Pause makes a job ignore its events until it's resumed.
+	Pause(ctx context.Context, in *PauseRequest, opts ...grpc.CallOption) (*PauseResponse, error)
+	// Resume undoes Pause.
+	Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*ResumeResponse, error)
+}
+
+type gowatchrunClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGowatchrunClient(cc grpc.ClientConnInterface) GowatchrunClient {
+	return &gowatchrunClient{cc}
+}
+
+func (c *gowatchrunClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, Gowatchrun_Status_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gowatchrunClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Gowatchrun_ServiceDesc.Streams[0], Gowatchrun_StreamEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Gowatchrun_StreamEventsClient = grpc.ServerStreamingClient[Event]
+
+func (c *gowatchrunClient) Trigger(ctx context.Context, in *TriggerRequest, opts ...grpc.CallOption) (*TriggerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TriggerResponse)
+	err := c.cc.Invoke(ctx, Gowatchrun_Trigger_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gowatchrunClient) Pause(ctx context.Context, in *PauseRequest, opts ...grpc.CallOption) (*PauseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PauseResponse)
+	err := c.cc.Invoke(ctx, Gowatchrun_Pause_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gowatchrunClient) Resume(ctx context.Context, in *ResumeRequest, opts ...grpc.CallOption) (*ResumeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResumeResponse)
+	err := c.cc.Invoke(ctx, Gowatchrun_Resume_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GowatchrunServer is the server API for Gowatchrun service.
+// All implementations must embed UnimplementedGowatchrunServer
+// for forward compatibility.
+//
+// Gowatchrun is served by `gowatchrun --grpc-listen`.
+type GowatchrunServer interface {
+	// Status reports the health of every job.
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	// StreamEvents streams accepted events, runs and pauses as they happen,
+	// until the client cancels. Events are dropped for clients that can't
+	// keep up.
+	StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[Event]) error
+	// Trigger queues a run of a job, as if one of its event sources fired.
+	Trigger(context.Context, *TriggerRequest) (*TriggerResponse, error)
+	// Pause makes a job ignore its events until it's resumed.
+	Pause(context.Context, *PauseRequest) (*PauseResponse, error)
+	// Resume undoes Pause.
+	Resume(context.Context, *ResumeRequest) (*ResumeResponse, error)
+	mustEmbedUnimplementedGowatchrunServer()
+}
+
+// UnimplementedGowatchrunServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedGowatchrunServer struct{}
+
+func (UnimplementedGowatchrunServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedGowatchrunServer) StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedGowatchrunServer) Trigger(context.Context, *TriggerRequest) (*TriggerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Trigger not implemented")
+}
+func (UnimplementedGowatchrunServer) Pause(context.Context, *PauseRequest) (*PauseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Pause not implemented")
+}
+func (UnimplementedGowatchrunServer) Resume(context.Context, *ResumeRequest) (*ResumeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resume not implemented")
+}
+func (UnimplementedGowatchrunServer) mustEmbedUnimplementedGowatchrunServer() {}
+func (UnimplementedGowatchrunServer) testEmbeddedByValue()                    {}
+
+// UnsafeGowatchrunServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GowatchrunServer will
+// result in compilation errors.
+type UnsafeGowatchrunServer interface {
+	mustEmbedUnimplementedGowatchrunServer()
+}
+
+func RegisterGowatchrunServer(s grpc.ServiceRegistrar, srv GowatchrunServer) {
+	// If the following call pancis, it indicates UnimplementedGowatchrunServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Gowatchrun_ServiceDesc, srv)
+}
+
+func _Gowatchrun_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GowatchrunServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gowatchrun_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GowatchrunServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gowatchrun_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GowatchrunServer).StreamEvents(m, &grpc.GenericServerStream[StreamEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Gowatchrun_StreamEventsServer = grpc.ServerStreamingServer[Event]
+
+func _Gowatchrun_Trigger_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TriggerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GowatchrunServer).Trigger(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gowatchrun_Trigger_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GowatchrunServer).Trigger(ctx, req.(*TriggerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gowatchrun_Pause_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PauseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GowatchrunServer).Pause(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gowatchrun_Pause_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GowatchrunServer).Pause(ctx, req.(*PauseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gowatchrun_Resume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GowatchrunServer).Resume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gowatchrun_Resume_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GowatchrunServer).Resume(ctx, req.(*ResumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Gowatchrun_ServiceDesc is the grpc.ServiceDesc for Gowatchrun service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Gowatchrun_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gowatchrun.v1.Gowatchrun",
+	HandlerType: (*GowatchrunServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Status",
+			Handler:    _Gowatchrun_Status_Handler,
+		},
+		{
+			MethodName: "Trigger",
+			Handler:    _Gowatchrun_Trigger_Handler,
+		},
+		{
+			MethodName: "Pause",
+			Handler:    _Gowatchrun_Pause_Handler,
+		},
+		{
+			MethodName: "Resume",
+			Handler:    _Gowatchrun_Resume_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _Gowatchrun_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/gowatchrun/v1/gowatchrun.proto",
+}
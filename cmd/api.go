@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/s0up4200/gowatchrun/internal/audit"
+	"github.com/s0up4200/gowatchrun/internal/auth"
+	"github.com/s0up4200/gowatchrun/internal/problem"
+	"github.com/s0up4200/gowatchrun/internal/secret"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// apiJob is the configuration of a job as shown on the dashboard.
+type apiJob struct {
+	Name     string   `json:"name"`
+	Watch    []string `json:"watch,omitempty"`
+	Source   string   `json:"source,omitempty"`
+	Patterns []string `json:"patterns,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+	Events   []string `json:"events,omitempty"`
+	Runs     string   `json:"runs"` // The command, action, request or signal
+	Delay    string   `json:"delay,omitempty"`
+	Batch    bool     `json:"batch,omitempty"`
+	Paused   bool     `json:"paused"`
+}
+
+// apiStatus is the health status with the names of the paused jobs.
+type apiStatus struct {
+	watcher.HealthStatus
+	Paused []string `json:"paused"`
+}
+
+// serveAPI serves the dashboard and the HTTP API it uses on addr (e.g.
+// ":8087").
+func serveAPI(addr string, configs []watcher.Config, health *watcher.Health, control *watcher.Control, authConfig auth.Config, auditLog *audit.Log) error {
+	listener, err := authConfig.Listen(addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for API requests on %s: %w", addr, err)
+	}
+	log.Info().Msgf("Serving the dashboard on %s://%s/", authConfig.Scheme(), listener.Addr())
+	handler := authConfig.Require(apiHandler(configs, health, control, authConfig, auditLog))
+	go func() {
+		if err := http.Serve(listener, handler); err != nil {
+			log.Error().Msgf("API server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// serveControlSocket serves the HTTP API on the Unix socket at path, for
+// 'gowatchrun trigger'. Only the user running gowatchrun may connect, so no
+// token is required. A socket left behind by an instance that's gone is
+// replaced.
+func serveControlSocket(path string, configs []watcher.Config, health *watcher.Health, control *watcher.Control, auditLog *audit.Log) error {
+	if info, err := os.Lstat(path); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return fmt.Errorf("another gowatchrun is already listening on the control socket %s", path)
+		}
+		os.Remove(path)
+	}
+	listener, err := listenPrivate(path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on the control socket %s: %w", path, err)
+	}
+	log.Info().Msgf("Serving the control socket on %s", path)
+	go func() {
+		if err := http.Serve(listener, apiHandler(configs, health, control, auth.Config{}, auditLog)); err != nil {
+			log.Error().Msgf("Control socket server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// listenPrivate listens on a Unix socket at path that only the current user
+// can connect to. The socket is created in a new directory only the user can
+// enter and moved to path once its permissions are restricted, since it
+// would otherwise accept connections with the umask's permissions until
+// then.
+func listenPrivate(path string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".gowatchrun-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(dir)
+	tmp := filepath.Join(dir, "s")
+	listener, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(tmp, 0o600)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// apiHandler serves the dashboard and its HTTP API. Cross-origin requests
+// that change anything are rejected unless authConfig allows their origin,
+// and the others are recorded in auditLog.
+func apiHandler(configs []watcher.Config, health *watcher.Health, control *watcher.Control, authConfig auth.Config, auditLog *audit.Log) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(dashboardHTML)
+	})
+	mux.HandleFunc("GET /api/status", func(w http.ResponseWriter, r *http.Request) {
+		status := apiStatus{HealthStatus: health.Status(), Paused: []string{}}
+		for _, cfg := range configs {
+			if control.Paused(cfg.Name) {
+				status.Paused = append(status.Paused, cfg.Name)
+			}
+		}
+		writeJSON(w, status)
+	})
+	mux.HandleFunc("GET /api/jobs", func(w http.ResponseWriter, r *http.Request) {
+		jobs := make([]apiJob, len(configs))
+		for i, cfg := range configs {
+			jobs[i] = apiJob{
+				Name:     cfg.Name,
+				Watch:    cfg.WatchDirs,
+				Source:   cfg.Source.URL,
+				Patterns: cfg.Patterns,
+				Exclude:  cfg.ExcludeDirs,
+				Events:   cfg.EventTypes,
+				Runs:     secret.Redact(describeRun(cfg)),
+				Batch:    cfg.Batch,
+				Paused:   control.Paused(cfg.Name),
+			}
+			if cfg.DebounceDelay > 0 {
+				jobs[i].Delay = cfg.DebounceDelay.String()
+			}
+		}
+		writeJSON(w, jobs)
+	})
+	mux.HandleFunc("GET /api/runs", func(w http.ResponseWriter, r *http.Request) {
+		match := eventFilter(r)
+		runs := []eventFrame{}
+		for _, event := range control.History() {
+			if match(event) {
+				runs = append(runs, newEventFrame(event))
+			}
+		}
+		writeJSON(w, runs)
+	})
+	mux.HandleFunc("GET /api/diagnostics", func(w http.ResponseWriter, r *http.Request) {
+		match := eventFilter(r)
+		reports := []problem.Report{}
+		for _, run := range control.Diagnostics() {
+			if match(run) {
+				reports = append(reports, problem.NewReport(run.Job, run.Time, run.Result.ExitCode, redactDiagnostics(run.Result.Diagnostics)))
+			}
+		}
+		writeJSON(w, reports)
+	})
+	mux.HandleFunc("POST /api/pause", func(w http.ResponseWriter, r *http.Request) {
+		err := control.Pause(r.FormValue("job"))
+		auditLog.Request(audit.ActionPause, audit.HTTPActor(r), r.FormValue("job"), "", err)
+		writeResult(w, err, http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /api/resume", func(w http.ResponseWriter, r *http.Request) {
+		err := control.Resume(r.FormValue("job"))
+		auditLog.Request(audit.ActionResume, audit.HTTPActor(r), r.FormValue("job"), "", err)
+		writeResult(w, err, http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /api/trigger", func(w http.ResponseWriter, r *http.Request) {
+		err := control.Trigger(r.FormValue("job"), r.FormValue("path"))
+		auditLog.Request(audit.ActionTrigger, audit.HTTPActor(r), r.FormValue("job"), r.FormValue("path"), err)
+		writeResult(w, err, http.StatusAccepted)
+	})
+	mux.Handle("GET /events/ws", eventsWebSocket(control, authConfig))
+	mux.Handle("GET /output", outputStream(control))
+	return authConfig.CrossOriginProtection().Handler(mux)
+}
+
+// describeRun returns what cfg runs for an event.
+func describeRun(cfg watcher.Config) string {
+	switch {
+	case cfg.HTTPURL != "":
+		return cfg.HTTPMethod + " " + cfg.HTTPURL
+	case cfg.SignalPIDFile != "":
+		return "SIG" + strings.TrimPrefix(strings.ToUpper(cfg.Signal), "SIG") + " to the process in " + cfg.SignalPIDFile
+	case cfg.Action != "":
+		return cfg.Action + " " + cfg.ActionDest
+	case cfg.GoHandler != "":
+		return "Go handler " + cfg.GoHandler
+	case cfg.ActionPlugin != "":
+		return "plugin " + cfg.ActionPlugin
+	}
+	return cfg.CommandTmpl
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeResult answers a request that changes a job with status, or the
+// error.
+func writeResult(w http.ResponseWriter, err error, status int) {
+	switch {
+	case errors.Is(err, watcher.ErrUnknownJob):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, watcher.ErrRejectedPath):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		w.WriteHeader(status)
+	}
+}
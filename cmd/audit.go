@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/s0up4200/gowatchrun/internal/audit"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// auditStart records the start of gowatchrun in the audit log, with a hash
+// of the file its configuration came from, and what every job runs.
+func auditStart(auditLog *audit.Log, file string, configs []watcher.Config) {
+	if auditLog == nil {
+		return
+	}
+	details := map[string]string{"pid": strconv.Itoa(os.Getpid())}
+	if file != "" {
+		details["config"] = file
+		if data, err := os.ReadFile(file); err == nil {
+			sum := sha256.Sum256(data)
+			details["config_sha256"] = hex.EncodeToString(sum[:])
+		}
+	}
+	var auth []string
+	if authConfig.Token != "" {
+		auth = append(auth, "token")
+	}
+	if authConfig.ClientCAFile != "" {
+		auth = append(auth, "mtls")
+	}
+	if len(auth) > 0 {
+		details["auth"] = strings.Join(auth, ",")
+	}
+	auditLog.Record(audit.Entry{Action: audit.ActionStart, Details: details})
+
+	for _, cfg := range configs {
+		details := map[string]string{}
+		if len(cfg.WatchDirs) > 0 {
+			details["watch"] = strings.Join(cfg.WatchDirs, ",")
+		}
+		if len(cfg.Patterns) > 0 {
+			details["patterns"] = strings.Join(cfg.Patterns, ",")
+		}
+		if cfg.Source.URL != "" {
+			details["source"] = cfg.Source.URL
+		}
+		if cfg.WebhookAddr != "" {
+			details["webhook"] = cfg.WebhookAddr
+		}
+		auditLog.Record(audit.Entry{Action: audit.ActionJob, Job: cfg.Name, Command: describeRun(cfg), Details: details})
+	}
+}
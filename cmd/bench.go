@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+var (
+	benchFiles    int
+	benchChurn    string
+	benchDuration time.Duration
+	benchDelay    time.Duration
+	benchBatch    bool
+	benchDir      string
+)
+
+// benchFilesPerDir is how many files bench puts in each directory.
+const benchFilesPerDir = 1000
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure the watcher pipeline under synthetic file churn",
+	Long: `Creates --files files in a temporary directory, watches it recursively and
+rewrites random files at the --churn rate for --duration. It then reports the
+latency from each write to the execution it triggered, how many events were
+coalesced by --delay or --batch, and how many writes never triggered an
+execution (dropped, e.g. because the kernel's event queue overflowed).
+
+The executor only records the latency, so the numbers describe gowatchrun's
+own overhead rather than that of a command.`,
+	Example: `  gowatchrun bench --files 100000 --churn 500/s
+  gowatchrun bench --churn 2000/s --delay 100ms --batch`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rate, err := parseRate(benchChurn)
+		if err != nil {
+			return err
+		}
+		if benchFiles < 1 {
+			return fmt.Errorf("--files must be at least 1")
+		}
+		cmd.SilenceUsage = true
+		return runBench(cmd.Context(), rate)
+	},
+}
+
+// parseRate parses a --churn rate like "500/s", "30000/m" or "500" (per
+// second) into writes per second.
+func parseRate(s string) (float64, error) {
+	count, unit, _ := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --churn '%s' (expected e.g. 500/s)", s)
+	}
+	switch unit {
+	case "", "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	default:
+		return 0, fmt.Errorf("invalid --churn '%s' (expected a rate per s or m)", s)
+	}
+}
+
+// benchRecorder matches executions to the writes that caused them.
+type benchRecorder struct {
+	mu         sync.Mutex
+	written    map[string]time.Time // Writes not executed yet, by path
+	writes     int
+	executions int
+	latencies  []time.Duration
+}
+
+func (r *benchRecorder) write(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes++
+	if _, ok := r.written[path]; !ok {
+		r.written[path] = time.Now()
+	}
+}
+
+func (r *benchRecorder) execute(ctx context.Context, cfg watcher.Config, data *watcher.EventData) watcher.ExecutionResult {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.executions++
+	files := data.Files
+	if len(files) == 0 {
+		files = []watcher.EventData{*data}
+	}
+	for _, file := range files {
+		if at, ok := r.written[file.Path]; ok {
+			r.latencies = append(r.latencies, now.Sub(at))
+			delete(r.written, file.Path)
+		}
+	}
+	return watcher.ExecutionResult{}
+}
+
+func runBench(ctx context.Context, rate float64) error {
+	dir, err := os.MkdirTemp(benchDir, "gowatchrun-bench-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	fmt.Printf("Creating %d file(s) in %s...\n", benchFiles, dir)
+	paths := make([]string, benchFiles)
+	for i := range paths {
+		sub := filepath.Join(dir, fmt.Sprintf("d%04d", i/benchFilesPerDir))
+		if i%benchFilesPerDir == 0 {
+			if err := os.Mkdir(sub, 0o755); err != nil {
+				return err
+			}
+		}
+		paths[i] = filepath.Join(sub, fmt.Sprintf("f%06d.dat", i))
+		if err := os.WriteFile(paths[i], nil, 0o644); err != nil {
+			return err
+		}
+	}
+
+	// Per-event logging would dominate the measurement
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	stats := watcher.NewStats()
+	rec := &benchRecorder{written: make(map[string]time.Time)}
+	cfg := watcher.Config{
+		Name:          "bench",
+		WatchDirs:     []string{dir},
+		Patterns:      []string{"*"},
+		EventTypes:    []string{"write"},
+		CommandTmpl:   "bench",
+		Recursive:     true,
+		DebounceDelay: benchDelay,
+		Batch:         benchBatch,
+		Stats:         stats,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- watcher.Run(ctx, cfg, stats.Track(rec.execute)) }()
+	// Give the watcher time to add every directory before churning
+	time.Sleep(time.Second + time.Duration(benchFiles/benchFilesPerDir)*time.Millisecond)
+
+	fmt.Printf("Writing %.0f file(s)/s for %s...\n", rate, benchDuration)
+	interval := time.Duration(float64(time.Second) / rate)
+	start := time.Now()
+	next := start
+	content := []byte("x")
+	for time.Since(start) < benchDuration {
+		path := paths[rand.Intn(len(paths))]
+		rec.write(path)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return err
+		}
+		next = next.Add(interval)
+		if wait := time.Until(next); wait > 0 {
+			time.Sleep(wait)
+		}
+	}
+	elapsed := time.Since(start)
+
+	// Let the last events and the debounce timer drain
+	time.Sleep(time.Second + 2*benchDelay)
+	cancel()
+	if err := <-done; err != nil {
+		return err
+	}
+
+	sum := stats.Summary(0)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	dist := watcher.NewDistribution(rec.latencies)
+	round := func(m watcher.Millis) time.Duration { return time.Duration(m).Round(10 * time.Microsecond) }
+
+	fmt.Printf("\nWrites:      %d (%.0f/s achieved)\n", rec.writes, float64(rec.writes)/elapsed.Seconds())
+	fmt.Printf("Events:      %d observed, %d filtered, %d coalesced\n", sum.Observed, sum.Filtered, sum.Coalesced)
+	fmt.Printf("Executions:  %d\n", rec.executions)
+	fmt.Printf("Latency:     avg %s, p50 %s, p90 %s, p99 %s, max %s\n",
+		round(dist.Average), round(dist.P50), round(dist.P90), round(dist.P99), round(dist.Max))
+	fmt.Printf("Unexecuted:  %d file(s) written but never part of an execution\n", len(rec.written))
+	if benchDelay == 0 && !benchBatch {
+		// Without debouncing or batching nothing is coalesced, so every
+		// unexecuted write was lost on the way
+		fmt.Printf("Dropped:     %d\n", len(rec.written))
+	}
+	return nil
+}
+
+func init() {
+	f := benchCmd.Flags()
+	f.IntVar(&benchFiles, "files", 10000, "Number of files to create and churn.")
+	f.StringVar(&benchChurn, "churn", "500/s", "Rate of file writes, per second (500/s) or minute (500/m).")
+	f.DurationVar(&benchDuration, "duration", 10*time.Second, "How long to churn files.")
+	f.DurationVar(&benchDelay, "delay", 0, "Debounce delay of the watcher, as with --delay.")
+	f.BoolVar(&benchBatch, "batch", false, "Batch events, as with --batch.")
+	f.StringVar(&benchDir, "dir", "", "Directory to create the temporary files in (default: the system temp directory).")
+	rootCmd.AddCommand(benchCmd)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+var capsJSON bool
+
+// capsReport is the output of the caps subcommand.
+type capsReport struct {
+	OS         string                 `json:"os"`
+	Arch       string                 `json:"arch"`
+	Backend    string                 `json:"backend"`
+	Shell      string                 `json:"shell"`
+	WatchLimit string                 `json:"watch_limit,omitempty"`
+	Events     []watcher.EventSupport `json:"events"`
+}
+
+var capsCmd = &cobra.Command{
+	Use:   "caps",
+	Short: "Show which event types and features this platform supports",
+	Long: `Reports the file system notification backend, the shell commands run with,
+the watch limit where there is one, and for every event type whether it's
+accepted on this platform and whether it was actually reported when a file
+was created, written, read, changed, renamed and removed in a temporary
+directory.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		events, err := watcher.ProbeEvents("")
+		if err != nil {
+			return fmt.Errorf("failed to probe file system events: %w", err)
+		}
+		report := capsReport{
+			OS:         runtime.GOOS,
+			Arch:       runtime.GOARCH,
+			Backend:    watcher.Backend(),
+			Shell:      "sh -c",
+			WatchLimit: watchLimit(),
+			Events:     events,
+		}
+		if runtime.GOOS == "windows" {
+			report.Shell = "cmd.exe /C"
+		}
+		if report.Backend == "" {
+			report.Backend = "none (local directories can't be watched)"
+		}
+
+		if capsJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+
+		fmt.Printf("Platform:    %s/%s\n", report.OS, report.Arch)
+		fmt.Printf("Backend:     %s (recursive watching adds a watch per directory)\n", report.Backend)
+		fmt.Printf("Shell:       %s\n", report.Shell)
+		if report.WatchLimit != "" {
+			fmt.Printf("Watch limit: %s\n", report.WatchLimit)
+		}
+		fmt.Println()
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "EVENT\tACCEPTED\tDELIVERED\tNOTE")
+		for _, e := range report.Events {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Type, yesNo(e.Accepted), yesNo(e.Delivered), e.Note)
+		}
+		return tw.Flush()
+	},
+}
+
+// watchLimit returns the per-user inotify watch limit on Linux.
+func watchLimit() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	data, err := os.ReadFile("/proc/sys/fs/inotify/max_user_watches")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data)) + " (fs.inotify.max_user_watches)"
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func init() {
+	capsCmd.Flags().BoolVar(&capsJSON, "json", false, "Print the report as JSON.")
+	rootCmd.AddCommand(capsCmd)
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/s0up4200/gowatchrun/internal/problem"
+)
+
+var (
+	diagnosticsSocket string
+	diagnosticsAPI    string
+	diagnosticsJob    string
+	diagnosticsJSON   bool
+)
+
+var diagnosticsCmd = &cobra.Command{
+	Use:   "diagnostics",
+	Short: "Print the errors and warnings of a running gowatchrun's last runs",
+	Long: `Asks a running gowatchrun, through its --control-socket or its --api-listen
+address, for what the --problem-matcher of its jobs found in their last runs,
+and prints it one diagnostic per line, as file:line:column: severity: message,
+which editors parse like compiler output (e.g. Vim's :cexpr). With --json, the
+reports are printed as the API returns them.
+
+The command exits with status 1 when there are errors.`,
+	Example: `  gowatchrun diagnostics --socket /run/gowatchrun.sock
+  vim -c 'cexpr system("gowatchrun diagnostics --socket gowatchrun.sock")'`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (diagnosticsSocket == "") == (diagnosticsAPI == "") {
+			return fmt.Errorf("either --socket or --api is required")
+		}
+		cmd.SilenceUsage = true
+
+		client, base := apiClient(diagnosticsSocket, diagnosticsAPI)
+		if err := authConfig.Load(tokenFile); err != nil {
+			return err
+		}
+		query := url.Values{}
+		if diagnosticsJob != "" {
+			query.Set("job", diagnosticsJob)
+		}
+		req, err := http.NewRequest(http.MethodGet, base+"/api/diagnostics?"+query.Encode(), nil)
+		if err != nil {
+			return err
+		}
+		if authConfig.Token != "" && diagnosticsSocket == "" {
+			req.Header.Set("Authorization", "Bearer "+authConfig.Token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to reach gowatchrun: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			return fmt.Errorf("failed to get diagnostics: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		var reports []problem.Report
+		if err := json.NewDecoder(resp.Body).Decode(&reports); err != nil {
+			return fmt.Errorf("invalid diagnostics: %w", err)
+		}
+
+		errors := 0
+		for _, report := range reports {
+			n, _, _ := problem.Count(report.Diagnostics)
+			errors += n
+		}
+		if diagnosticsJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(reports); err != nil {
+				return err
+			}
+		} else {
+			for _, report := range reports {
+				for _, d := range report.Diagnostics {
+					fmt.Println(d)
+				}
+			}
+		}
+		if errors > 0 {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+func init() {
+	f := diagnosticsCmd.Flags()
+	f.StringVar(&diagnosticsSocket, "socket", "", "Control socket of the running gowatchrun (its --control-socket).")
+	f.StringVar(&diagnosticsAPI, "api", "", "URL of the running gowatchrun's --api-listen address, e.g. http://127.0.0.1:8087.")
+	f.StringVar(&authConfig.Token, "auth-token", "", "Token the --api requires. Prefer --auth-token-file.")
+	f.StringVar(&tokenFile, "auth-token-file", "", "File containing the token the --api requires.")
+	f.StringVar(&diagnosticsJob, "job", "", "Only print the diagnostics of this job.")
+	f.BoolVar(&diagnosticsJSON, "json", false, "Print the reports as JSON.")
+	rootCmd.AddCommand(diagnosticsCmd)
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/s0up4200/gowatchrun/internal/auth"
+	"github.com/s0up4200/gowatchrun/internal/problem"
+	"github.com/s0up4200/gowatchrun/internal/secret"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// eventFrame is the JSON form of a watcher.ControlEvent sent to event
+// stream clients.
+type eventFrame struct {
+	Kind       string    `json:"kind"`
+	Job        string    `json:"job,omitempty"`
+	Run        uint64    `json:"run,omitempty"` // ID of the run, for run_started and run_finished
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event,omitempty"`
+	Paths      []string  `json:"paths,omitempty"` // Several for a batch
+	DurationMs *int64    `json:"duration_ms,omitempty"`
+	ExitCode   *int      `json:"exit_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Skipped    bool      `json:"skipped,omitempty"` // Nothing ran, for run_finished
+	Stream     string    `json:"stream,omitempty"`  // stdout or stderr, for output
+	Text       string    `json:"text,omitempty"`
+
+	Diagnostics []problem.Diagnostic `json:"diagnostics,omitempty"` // What the job's problem matchers found, for run_finished
+}
+
+func newEventFrame(event watcher.ControlEvent) eventFrame {
+	frame := eventFrame{Kind: event.Kind, Job: event.Job, Run: event.Run, Time: event.Time, Stream: event.Stream, Text: event.Text}
+	if data := event.Data; data != nil {
+		frame.Event = data.Event
+		if len(data.Files) > 0 {
+			for _, file := range data.Files {
+				frame.Paths = append(frame.Paths, secret.Redact(file.Path))
+			}
+		} else if data.Path != "" {
+			frame.Paths = []string{secret.Redact(data.Path)}
+		}
+	}
+	if event.Kind == watcher.RunFinished {
+		duration := event.Result.Duration.Milliseconds()
+		code := event.Result.ExitCode
+		frame.DurationMs, frame.ExitCode, frame.Skipped = &duration, &code, event.Result.Skipped
+		if event.Result.Err != nil {
+			frame.Error = secret.Redact(event.Result.Err.Error())
+		}
+		frame.Diagnostics = redactDiagnostics(event.Result.Diagnostics)
+	}
+	return frame
+}
+
+// redactDiagnostics returns diagnostics with secrets in their messages
+// redacted, like the output they come from.
+func redactDiagnostics(diagnostics []problem.Diagnostic) []problem.Diagnostic {
+	redacted := slices.Clone(diagnostics)
+	for i := range redacted {
+		redacted[i].Message = secret.Redact(redacted[i].Message)
+	}
+	return redacted
+}
+
+// eventFilter returns whether an event matches the job, kind and run query
+// parameters of r, which may be repeated or comma-separated. Missing
+// parameters match everything.
+func eventFilter(r *http.Request) func(watcher.ControlEvent) bool {
+	values := func(key string) []string {
+		var list []string
+		for _, value := range r.URL.Query()[key] {
+			list = append(list, strings.Split(value, ",")...)
+		}
+		return list
+	}
+	jobs, kinds, runs := values("job"), values("kind"), values("run")
+	return func(event watcher.ControlEvent) bool {
+		return (len(jobs) == 0 || slices.Contains(jobs, event.Job)) &&
+			(len(kinds) == 0 || slices.Contains(kinds, event.Kind)) &&
+			(len(runs) == 0 || slices.Contains(runs, strconv.FormatUint(event.Run, 10)))
+	}
+}
+
+// eventsWebSocket streams the events control publishes to WebSocket
+// clients as JSON text frames. Browsers may only connect from pages of
+// origins authConfig allows, so other websites can't read the stream with
+// the user's cookie.
+func eventsWebSocket(control *watcher.Control, authConfig auth.Config) http.Handler {
+	handshake := func(config *websocket.Config, r *http.Request) (err error) {
+		if config.Origin, err = websocket.Origin(config, r); err != nil {
+			return err
+		}
+		if !authConfig.AllowsOrigin(config.Origin, r) {
+			return fmt.Errorf("origin %s is not allowed", config.Origin)
+		}
+		return nil
+	}
+	return websocket.Server{Handshake: handshake, Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		match := eventFilter(ws.Request())
+		events, stop := control.Subscribe()
+		defer stop()
+
+		// Nothing is expected from the client; reading notices when it
+		// goes away
+		closed := make(chan struct{})
+		go func() {
+			io.Copy(io.Discard, ws)
+			close(closed)
+		}()
+		for {
+			select {
+			case <-closed:
+				return
+			case event := <-events:
+				if !match(event) {
+					continue
+				}
+				if err := websocket.JSON.Send(ws, newEventFrame(event)); err != nil {
+					return
+				}
+			}
+		}
+	}}
+}
+
+// sseKeepAlive is how often an idle output stream sends a comment, so
+// proxies don't close it.
+const sseKeepAlive = 15 * time.Second
+
+// outputStream streams the output of commands as server-sent events: an
+// output event for every line, between the run_started and run_finished
+// events of its run.
+func outputStream(control *watcher.Control) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		match := eventFilter(r)
+		events, stop := control.SubscribeOutput()
+		defer stop()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				io.WriteString(w, ": keep-alive\n\n")
+			case event := <-events:
+				if !match(event) {
+					continue
+				}
+				data, _ := json.Marshal(newEventFrame(event))
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Kind, data)
+			}
+			flusher.Flush()
+		}
+	}
+}
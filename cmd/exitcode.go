@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// exitCodes records the exit status of every execution across all jobs so
+// --forward-exit-code can report it as gowatchrun's own.
+type exitCodes struct {
+	mu    sync.Mutex
+	last  int
+	worst int
+}
+
+// track wraps execFunc to record the exit status of each run.
+func (c *exitCodes) track(execFunc watcher.ExecutorFunc) watcher.ExecutorFunc {
+	return func(ctx context.Context, cfg watcher.Config, data *watcher.EventData) watcher.ExecutionResult {
+		result := execFunc(ctx, cfg, data)
+		if result.Skipped {
+			return result
+		}
+		c.mu.Lock()
+		c.last = result.ExitCode
+		if result.ExitCode > c.worst {
+			c.worst = result.ExitCode
+		}
+		c.mu.Unlock()
+		return result
+	}
+}
+
+// ExitError is returned by Execute when gowatchrun has to exit with Code,
+// e.g. after a signal. It's returned rather than exiting right away, so
+// deferred cleanup like closing the journal still runs.
+type ExitError struct {
+	Code int
+}
+
+func (e ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// code returns the status to exit with for mode "last" or "worst".
+func (c *exitCodes) code(mode string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if mode == "worst" {
+		return c.worst
+	}
+	return c.last
+}
+
+func validExitCodeMode(mode string) error {
+	switch mode {
+	case "", "last", "worst":
+		return nil
+	default:
+		return fmt.Errorf("invalid --forward-exit-code '%s' (expected last or worst)", mode)
+	}
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/s0up4200/gowatchrun/internal/config"
+)
+
+var (
+	explainJob   string
+	explainPath  string
+	explainEvent string
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Show which filters accept or reject a path for the jobs in a config file",
+	Long: `Evaluates the watch directory, exclude, event type and pattern filters of
+every job in the config file (or only the one selected with --job) against
+the given path and event, and reports the outcome of each filter. Nothing is
+watched or executed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if configPath == "" {
+			return fmt.Errorf("--config is required")
+		}
+		if explainPath == "" {
+			return fmt.Errorf("--path is required")
+		}
+		cmd.SilenceUsage = true
+
+		file, err := config.Load(configPath)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for _, job := range file.Jobs {
+			if explainJob != "" && job.Name != explainJob {
+				continue
+			}
+			found = true
+			cfg, err := job.Build()
+			if err != nil {
+				return err
+			}
+
+			e := cfg.Explain(explainPath, explainEvent)
+			verdict := "rejected"
+			if e.Accepted {
+				verdict = "accepted"
+			}
+			fmt.Printf("[%s] %s %s: %s\n", job.Name, explainEvent, explainPath, verdict)
+			for _, step := range e.Steps {
+				mark := "reject"
+				if step.Passed {
+					mark = "pass  "
+				}
+				fmt.Printf("  %s %s\n", mark, step.Reason)
+			}
+		}
+		if !found {
+			return fmt.Errorf("no job named '%s' in %s", explainJob, configPath)
+		}
+		return nil
+	},
+}
+
+func init() {
+	f := explainCmd.Flags()
+	f.StringVar(&configPath, "config", "", "Config file defining the jobs to explain.")
+	f.StringVar(&explainJob, "job", "", "Only explain the job with this name.")
+	f.StringVar(&explainPath, "path", "", "Path of the file to explain.")
+	f.StringVar(&explainEvent, "event", "write", "Event type to explain: write, create, remove, rename, chmod, open, read, closewrite or closeread.")
+	rootCmd.AddCommand(explainCmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/s0up4200/gowatchrun/internal/auth"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// serveHealth answers GET requests on the path of spec (e.g.
+// ":8086/healthz") with the health status as JSON: 200 while all watchers
+// are healthy and 503 otherwise. When authConfig authenticates clients, it
+// also streams the events of control on /events/ws and the output of
+// commands on /output, which, unlike the health check, require
+// authentication. Health endpoints are often reachable by anyone who can
+// probe them, so without authentication the streams are only served by
+// --api-listen.
+func serveHealth(spec string, health *watcher.Health, control *watcher.Control, authConfig auth.Config) error {
+	addr, path, err := watcher.ParseListenAddr(spec)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		status := health.Status()
+		w.Header().Set("Content-Type", "application/json")
+		if !status.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(status)
+	})
+	if authConfig.Authenticates() {
+		mux.Handle("/events/ws", authConfig.Require(eventsWebSocket(control, authConfig)))
+		mux.Handle("/output", authConfig.Require(outputStream(control)))
+	} else {
+		log.Debug().Msg("Not streaming events and output on --health-listen without --auth-token or --tls-client-ca; --api-listen serves them")
+	}
+
+	listener, err := authConfig.Listen(addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for health checks on %s: %w", addr, err)
+	}
+	log.Info().Msgf("Serving health checks on %s://%s%s", authConfig.Scheme(), listener.Addr(), path)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			log.Error().Msgf("Health check server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// writeHeartbeat rewrites path with the health status every interval while
+// all watchers are healthy, so a stale modification time means gowatchrun is
+// wedged or gone.
+func writeHeartbeat(path string, interval time.Duration, health *watcher.Health) {
+	write := func() {
+		status := health.Status()
+		if !status.Healthy {
+			log.Warn().Msgf("Not updating heartbeat file %s: a watcher is unresponsive", path)
+			return
+		}
+		data, _ := json.Marshal(status)
+		if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			log.Warn().Msgf("Failed to write heartbeat file %s: %v", path, err)
+		}
+	}
+	write()
+	go func() {
+		for range time.Tick(interval) {
+			write()
+		}
+	}()
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/s0up4200/gowatchrun/internal/config"
+)
+
+var (
+	initOutput string
+	initForce  bool
+)
+
+// projectMarkers maps files that identify a project type to the preset used
+// for it, in the order they're checked.
+var projectMarkers = []struct {
+	files  []string
+	preset string
+}{
+	{[]string{"go.mod"}, "go"},
+	{[]string{"package.json"}, "npm"},
+	{[]string{"pyproject.toml", "setup.py", "requirements.txt"}, "python"},
+	{[]string{"Cargo.toml"}, "rust"},
+	{[]string{"hugo.toml", "hugo.yaml", "hugo.json"}, "hugo"},
+	{[]string{"Dockerfile"}, "docker-build"},
+}
+
+// initJob is the subset of job settings written by init, in the order they
+// should appear in the file.
+type initJob struct {
+	Name       string   `yaml:"name"`
+	Recursive  bool     `yaml:"recursive,omitempty"`
+	Exclude    []string `yaml:"exclude,omitempty,flow"`
+	Patterns   []string `yaml:"patterns,flow"`
+	Events     []string `yaml:"events,omitempty,flow"`
+	Delay      string   `yaml:"delay,omitempty"`
+	Command    string   `yaml:"command"`
+	Restart    string   `yaml:"restart,omitempty"`
+	RunOnStart bool     `yaml:"run_on_start,omitempty"`
+}
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a starter config file for the project in the current directory",
+	Long: `Inspects the current directory for project files (go.mod, package.json,
+pyproject.toml, Cargo.toml, hugo.toml, Dockerfile, ...) and writes a config
+file with a job for every project type found.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if _, err := os.Stat(initOutput); err == nil && !initForce {
+			return fmt.Errorf("%s already exists; use --force to overwrite it", initOutput)
+		}
+
+		var jobs []initJob
+		for _, marker := range projectMarkers {
+			found := ""
+			for _, file := range marker.files {
+				if _, err := os.Stat(file); err == nil {
+					found = file
+					break
+				}
+			}
+			if found == "" {
+				continue
+			}
+			preset, _ := config.LookupPreset(marker.preset)
+			log.Info().Msgf("Found %s, adding a %s job", found, marker.preset)
+			jobs = append(jobs, initJob{
+				Name:       marker.preset,
+				Recursive:  preset.Recursive,
+				Exclude:    preset.Exclude,
+				Patterns:   preset.Patterns,
+				Delay:      preset.Delay,
+				Command:    preset.Command,
+				Restart:    preset.Restart,
+				RunOnStart: preset.RunOnStart,
+			})
+		}
+		if len(jobs) == 0 {
+			log.Info().Msg("No known project files found, writing a generic job")
+			jobs = append(jobs, initJob{
+				Name:      "main",
+				Recursive: true,
+				Patterns:  []string{"*.*"},
+				Events:    []string{"write", "create"},
+				Delay:     "300ms",
+				Command:   `echo "{{.Event}} {{.Path}}"`,
+			})
+		}
+
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "# gowatchrun config, generated by 'gowatchrun init'.\n# Run it with: gowatchrun --config %s\n\n", initOutput)
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(struct {
+			Jobs []initJob `yaml:"jobs"`
+		}{jobs}); err != nil {
+			return err
+		}
+		if err := os.WriteFile(initOutput, buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+		log.Info().Msgf("Wrote %s", initOutput)
+		return nil
+	},
+}
+
+func init() {
+	initCmd.Flags().StringVarP(&initOutput, "output", "o", "gowatchrun.yaml", "Path of the config file to write.")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Overwrite the config file if it already exists.")
+	rootCmd.AddCommand(initCmd)
+}
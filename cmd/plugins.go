@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/s0up4200/gowatchrun/internal/plugin"
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "List the plugins found on PATH",
+	Long: `Lists the gowatchrun-<name> executables on PATH, which can be used as
+--filter-plugin, --action-plugin or --notify-plugin by their name.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plugins := plugin.List()
+		if len(plugins) == 0 {
+			fmt.Printf("No %s<name> executables found on PATH\n", plugin.Prefix)
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPATH")
+		for _, p := range plugins {
+			fmt.Fprintf(w, "%s\t%s\n", p.Name, p.Path)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// maxPreflightWarnings is how many inaccessible directories are logged
+// individually before the rest are summarized.
+const maxPreflightWarnings = 10
+
+// preflight reports the directories below cfg's watch roots that can't be
+// read or traversed. With --strict they're an error, otherwise a warning.
+func preflight(cfg watcher.Config) error {
+	problems := cfg.Preflight()
+	if len(problems) == 0 {
+		return nil
+	}
+
+	if strict {
+		var b strings.Builder
+		if cfg.Name != "" {
+			fmt.Fprintf(&b, "job '%s': ", cfg.Name)
+		}
+		fmt.Fprintf(&b, "%d watched director(ies) can't be accessed:", len(problems))
+		for _, p := range problems {
+			b.WriteString("\n  - ")
+			b.WriteString(p.Error())
+		}
+		return fmt.Errorf("%s", b.String())
+	}
+
+	logger := cfg.Logger()
+	for i, p := range problems {
+		if i == maxPreflightWarnings {
+			logger.Warn().Msgf("... and %d more inaccessible director(ies)", len(problems)-i)
+			break
+		}
+		logger.Warn().Msgf("Events in inaccessible directory will be missed: %v", p)
+	}
+	return nil
+}
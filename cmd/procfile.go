@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/audit"
+	"github.com/s0up4200/gowatchrun/internal/config"
+	"github.com/s0up4200/gowatchrun/internal/procfile"
+	"github.com/s0up4200/gowatchrun/internal/supervisor"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// runProcfile supervises every process declared in the Procfile at path and
+// restarts a process whenever one of the files matching its watch patterns
+// changes. base supplies the watch settings shared by all processes.
+// Process starts and exits are recorded in auditLog.
+func runProcfile(path string, base config.Job, auditLog *audit.Log) error {
+	entries, err := procfile.Load(path)
+	if err != nil {
+		return fmt.Errorf("loading %s: %w", path, err)
+	}
+
+	width := 0
+	for _, entry := range entries {
+		if len(entry.Name) > width {
+			width = len(entry.Name)
+		}
+	}
+
+	processes := make([]*supervisor.Process, len(entries))
+	configs := make([]watcher.Config, len(entries))
+	for i, entry := range entries {
+		job := base
+		job.Name = entry.Name
+		job.Command = entry.Command
+		job.Action = ""
+		job.S3Upload = ""
+		if len(entry.Patterns) > 0 {
+			job.Patterns = entry.Patterns
+		}
+		cfg, err := job.Build()
+		if err != nil {
+			return err
+		}
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		if err := preflight(cfg); err != nil {
+			return err
+		}
+		cfg.Why = why
+		cfg.Strict = strict
+		if eventsOnly {
+			cfg.EventLog = os.Stderr
+		}
+		configs[i] = cfg
+
+		proc := supervisor.New(entry.Name, entry.Command, cfg.Logger())
+		proc.Prefix = fmt.Sprintf("%-*s | ", width, entry.Name)
+		proc.Audit = auditLog
+		proc.Sandbox = cfg.Sandbox
+		proc.Shell = cfg.Shell
+		proc.WSLInterop = cfg.WSLInterop
+		processes[i] = proc
+	}
+
+	auditStart(auditLog, path, configs)
+	ctx, signalled := stopOnSignal(context.Background(), processes)
+
+	for _, proc := range processes {
+		if err := proc.Start(); err != nil {
+			return err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range entries {
+		wg.Add(1)
+		go func(cfg watcher.Config, proc *supervisor.Process) {
+			defer wg.Done()
+			err := watcher.Run(ctx, cfg, func(ctx context.Context, cfg watcher.Config, data *watcher.EventData) watcher.ExecutionResult {
+				start := time.Now()
+				err := proc.Restart()
+				return watcher.Result(err, time.Since(start))
+			})
+			if err != nil {
+				logger := cfg.Logger()
+				logger.Error().Err(err).Msg("Watcher exited with error")
+			}
+		}(configs[i], processes[i])
+	}
+	wg.Wait()
+	if code := signalled(); code != 0 {
+		auditLog.Record(audit.Entry{Action: audit.ActionStop, Details: map[string]string{"reason": "signal"}})
+		return ExitError{Code: code}
+	}
+	return nil
+}
@@ -1,28 +1,70 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
+	"github.com/s0up4200/gowatchrun/internal/audit"
+	"github.com/s0up4200/gowatchrun/internal/auth"
+	"github.com/s0up4200/gowatchrun/internal/config"
 	"github.com/s0up4200/gowatchrun/internal/executor"
+	"github.com/s0up4200/gowatchrun/internal/gobuild"
+	"github.com/s0up4200/gowatchrun/internal/grpcapi"
+	"github.com/s0up4200/gowatchrun/internal/journal"
+	"github.com/s0up4200/gowatchrun/internal/manifest"
+	"github.com/s0up4200/gowatchrun/internal/scheduler"
+	"github.com/s0up4200/gowatchrun/internal/secret"
+	"github.com/s0up4200/gowatchrun/internal/supervisor"
+	"github.com/s0up4200/gowatchrun/internal/tracing"
 	"github.com/s0up4200/gowatchrun/internal/watcher"
+	"github.com/s0up4200/gowatchrun/internal/worker"
+	"github.com/s0up4200/gowatchrun/middleware"
 )
 
 var (
-	watchDirs     []string
-	excludeDirs   []string
-	patterns      []string
-	eventTypes    []string
-	commandTmpl   string
-	recursive     bool
-	logLevel      string
-	delayStr      string
-	clearTerminal bool
-	runOnStart    bool
+	configPath   string
+	procfilePath string
+	logLevel     string
+	quiet        bool
+	verbose      bool
+	eventsOnly   bool
+	summaryJSON  string
+	journalPath  string
+	auditPath    string
+	otlpEndpoint string
+	healthListen string
+	grpcListen   string
+	apiListen    string
+	controlSock  string
+	pauseState   string
+	startPaused  bool
+	authConfig   auth.Config
+	tokenFile    string
+	secretEnv    []string
+	heartbeat    string
+	heartbeatInt time.Duration
+	busyTimeout  time.Duration
+	watchdog     time.Duration
+	watchdogExec bool
+	why          bool
+	strict       bool
+	once         bool
+	forwardExit  string
+	bell         bool
+	setTitle     bool
+	statusLine   bool
+	ignoreNoise  bool
+	flagJob      config.Job
 )
 
 var rootCmd = &cobra.Command{
@@ -37,65 +79,529 @@ substituting placeholders with event details.`,
 			log.Warn().Msgf("Invalid log level '%s', defaulting to 'info'. Error: %v", logLevel, err)
 			level = zerolog.InfoLevel
 		}
+		switch {
+		case quiet, eventsOnly:
+			level = zerolog.Disabled
+		case verbose:
+			level = zerolog.DebugLevel
+		}
 		zerolog.SetGlobalLevel(level)
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339})
+		if statusLine {
+			executor.EnableStatusLine()
+		}
+		log.Logger = log.Output(zerolog.ConsoleWriter{
+			Out:           executor.StatusWriter(secret.Writer(os.Stderr)),
+			TimeFormat:    time.RFC3339,
+			FormatPrepare: prefixJobName,
+		})
 		log.Debug().Msgf("Log level set to: %s", level.String())
 	},
-	Run: func(cmd *cobra.Command, args []string) {
-		debounceDelay, parseErr := time.ParseDuration(delayStr)
-		if parseErr != nil {
-			log.Warn().Msgf("Invalid --delay duration '%s', defaulting to 0s. Error: %v", delayStr, parseErr)
-			debounceDelay = 0
-		} else if debounceDelay < 0 {
-			log.Warn().Msgf("--delay duration '%s' is negative, defaulting to 0s.", delayStr)
-			debounceDelay = 0
-		}
-
-		config := watcher.Config{
-			WatchDirs:     watchDirs,
-			ExcludeDirs:   excludeDirs,
-			Patterns:      patterns,
-			EventTypes:    eventTypes,
-			CommandTmpl:   commandTmpl,
-			Recursive:     recursive,
-			DebounceDelay: debounceDelay,
-			ClearTerminal: clearTerminal,
-		}
-
-		if runOnStart {
-			log.Info().Msg("Executing command on start due to --run-on-start flag...")
-			// execute with nil EventData as there's no file event
-			executor.Execute(config, nil)
-			log.Info().Msg("Initial command execution finished.")
-		}
-
-		log.Info().Msg("Starting file watcher...")
-		err := watcher.Run(config, executor.Execute)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cmd.Flags().Changed("watch") {
+			// Let Build decide whether to default to "." based on the other sources
+			flagJob.Watch = nil
+		}
+
+		if err := validExitCodeMode(forwardExit); err != nil {
+			return err
+		}
+		if err := authConfig.Load(tokenFile); err != nil {
+			return err
+		}
+		secret.Add(authConfig.Token)
+		if short := secret.AddEnv(secretEnv); len(short) > 0 {
+			log.Warn().Msgf("Not masking %v: secret values need at least %d characters", short, secret.MinLength)
+		}
+		var auditLog *audit.Log
+		if auditPath != "" {
+			var err error
+			if auditLog, err = audit.Open(auditPath); err != nil {
+				return fmt.Errorf("failed to open audit log: %w", err)
+			}
+			defer auditLog.Close()
+		}
+		flagJob.IgnoreCommonNoise = &ignoreNoise
+		if once {
+			flagJob.MaxTriggers = 1
+		}
+
+		if flagJob.Preset != "" {
+			// Flag defaults must not shadow the preset's settings
+			if !cmd.Flags().Changed("pattern") {
+				flagJob.Patterns = nil
+			}
+			if !cmd.Flags().Changed("delay") {
+				flagJob.Delay = ""
+			}
+			if err := flagJob.ApplyPreset(); err != nil {
+				return err
+			}
+		}
+
+		if procfilePath != "" {
+			cmd.SilenceUsage = true
+			err := runProcfile(procfilePath, flagJob, auditLog)
+			// A signal's exit status isn't worth printing
+			cmd.SilenceErrors = errors.As(err, new(ExitError))
+			return err
+		}
+
+		var jobs []config.Job
+		if configPath != "" {
+			file, err := config.Load(configPath)
+			if err != nil {
+				return err
+			}
+			jobs = file.Jobs
+		} else {
+			jobs = []config.Job{flagJob}
+		}
+
+		stats := watcher.NewStats()
+		health := watcher.NewHealth(busyTimeout)
+		var control *watcher.Control
+		if grpcListen != "" || healthListen != "" || apiListen != "" || controlSock != "" {
+			control = watcher.NewControl()
+		}
+		if (pauseState != "" || startPaused) && grpcListen == "" && apiListen == "" && controlSock == "" {
+			return fmt.Errorf("--pause-state and --start-paused require --api-listen, --grpc-listen or --control-socket to resume jobs")
+		}
+		configs := make([]watcher.Config, len(jobs))
+		nodes := make([]scheduler.Job, len(jobs))
+		for i, job := range jobs {
+			cfg, err := job.Build()
+			if err != nil {
+				return err
+			}
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			if err := executor.Compile(cfg); err != nil {
+				return err
+			}
+			if err := preflight(cfg); err != nil {
+				return err
+			}
+			cfg.Why = why
+			cfg.Strict = strict
+			if eventsOnly {
+				cfg.EventLog = os.Stderr
+			}
+			cfg.Stats = stats
+			cfg.Health = health
+			cfg.Control = control
+			cfg.Auth = authConfig
+			cfg.Audit = auditLog
+			configs[i] = cfg
+			nodes[i] = scheduler.Job{Config: cfg, DependsOn: job.DependsOn, RunAlways: job.RunIf == "always"}
+		}
+		names := make([]string, len(configs))
+		for i, cfg := range configs {
+			names[i] = cfg.Name
+		}
+		if pauseState != "" {
+			if err := control.LoadPauseState(pauseState, names); err != nil {
+				return err
+			}
+		}
+		if startPaused {
+			control.StartPaused(names)
+		}
+		auditStart(auditLog, configPath, configs)
+		// Jobs in --stdin-paths and --worker mode hand their events to a
+		// single long-running process instead of running the command per event
+		stdinProcs := make(map[string]*supervisor.Process)
+		workers := make(map[string]*worker.Worker)
+		for i, job := range jobs {
+			switch {
+			case job.StdinPaths:
+				proc := supervisor.New(configs[i].Name, configs[i].CommandTmpl, configs[i].Logger())
+				proc.PipeStdin = true
+				proc.Audit = auditLog
+				proc.Sandbox = configs[i].Sandbox
+				proc.Shell = configs[i].Shell
+				proc.WSLInterop = configs[i].WSLInterop
+				stdinProcs[configs[i].Name] = proc
+			case job.Worker:
+				w := worker.New(configs[i].Name, configs[i].CommandTmpl, configs[i].WorkerTimeout, configs[i].Logger())
+				workers[configs[i].Name] = w
+				w.Process().Audit = auditLog
+				w.Process().Sandbox = configs[i].Sandbox
+				w.Process().Shell = configs[i].Shell
+				w.Process().WSLInterop = configs[i].WSLInterop
+				stdinProcs[configs[i].Name] = w.Process()
+			case job.GoHandler != "":
+				h, err := gobuild.New(configs[i].GoHandler, configs[i].Logger())
+				if err != nil {
+					return err
+				}
+				binary, err := h.Build(context.Background())
+				if err != nil {
+					return err
+				}
+				w := worker.New(configs[i].Name, "\""+binary+"\"", configs[i].WorkerTimeout, configs[i].Logger())
+				workers[configs[i].Name] = w
+				w.Process().Audit = auditLog
+				w.Process().Sandbox = configs[i].Sandbox
+				w.Process().Shell = configs[i].Shell
+				stdinProcs[configs[i].Name] = w.Process()
+				go h.Watch(context.Background(), func(binary string) error {
+					return w.Process().Replace("\"" + binary + "\"")
+				})
+			}
+		}
+		deliver := func(ctx context.Context, cfg watcher.Config, data *watcher.EventData) watcher.ExecutionResult {
+			start := time.Now()
+			if w, ok := workers[cfg.Name]; ok {
+				return watcher.Result(callWorker(w, cfg, data), time.Since(start))
+			}
+			return watcher.Result(writePaths(stdinProcs[cfg.Name], cfg, data), time.Since(start))
+		}
+		// --middleware wraps the runs of each job, including the ones
+		// dependencies start. The executor applies it around the command
+		// itself, so routing files and releasing locks happen once per run
+		chains := make(map[string]watcher.ExecutorFunc, len(configs))
+		for _, cfg := range configs {
+			chains[cfg.Name] = watcher.Chain(deliver, cfg.Middleware...)
+		}
+		sched, err := scheduler.New(nodes, func(ctx context.Context, cfg watcher.Config, data *watcher.EventData) watcher.ExecutionResult {
+			if _, ok := stdinProcs[cfg.Name]; ok {
+				return chains[cfg.Name](ctx, cfg, data)
+			}
+			return executor.Execute(ctx, cfg, data)
+		})
 		if err != nil {
-			log.Error().Err(err).Msg("Watcher exited with error")
-			os.Exit(1)
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		flushTraces := func() {}
+		if tracing.Enabled(otlpEndpoint) {
+			shutdown, err := tracing.Setup(context.Background(), otlpEndpoint)
+			if err != nil {
+				return fmt.Errorf("failed to set up tracing: %w", err)
+			}
+			flushTraces = func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := shutdown(ctx); err != nil {
+					log.Warn().Msgf("Failed to flush traces: %v", err)
+				}
+			}
+		}
+
+		var runJournal *journal.Journal
+		if journalPath != "" {
+			if runJournal, err = journal.Open(journalPath); err != nil {
+				return fmt.Errorf("failed to open journal: %w", err)
+			}
+			defer runJournal.Close()
+		}
+
+		var codes exitCodes
+		term := executor.NewTerminal(bell, setTitle)
+		trends := watcher.NewTrends()
+		if journalPath != "" && slices.ContainsFunc(configs, func(cfg watcher.Config) bool { return cfg.SlowFactor > 0 }) {
+			// Earlier runs give --slow-factor a median to start with
+			entries, err := journal.Read(journalPath)
+			if err != nil && !os.IsNotExist(err) {
+				log.Warn().Msgf("Failed to read the journal for --slow-factor: %v", err)
+			}
+			for _, entry := range entries {
+				if entry.ExitCode == 0 {
+					trends.Seed(entry.Job, entry.Duration())
+				}
+			}
+		}
+		execFuncs := make([]watcher.ExecutorFunc, len(jobs))
+		var processes []*supervisor.Process
+		for i, job := range jobs {
+			execFuncs[i] = watcher.Chain(sched.ExecutorFor(configs[i].Name), control.Track, health.Track, stats.Track, codes.track, term.Track, trends.Track)
+			if runJournal != nil {
+				execFuncs[i] = runJournal.Track(execFuncs[i])
+			}
+			if job.Restart != "" {
+				proc := supervisor.New(configs[i].Name, job.Restart, configs[i].Logger())
+				proc.Audit = auditLog
+				proc.Sandbox = configs[i].Sandbox
+				proc.Shell = configs[i].Shell
+				proc.WSLInterop = configs[i].WSLInterop
+				execFuncs[i] = restartAfter(execFuncs[i], proc)
+				processes = append(processes, proc)
+			}
+		}
+		for _, proc := range stdinProcs {
+			if err := proc.Start(); err != nil {
+				return fmt.Errorf("failed to start '%s': %w", proc.Command, err)
+			}
+			processes = append(processes, proc)
+		}
+		if healthListen != "" {
+			if err := serveHealth(healthListen, health, control, authConfig); err != nil {
+				return err
+			}
+		}
+		if apiListen != "" {
+			if err := serveAPI(apiListen, configs, health, control, authConfig, auditLog); err != nil {
+				return err
+			}
+		}
+		if controlSock != "" {
+			if err := serveControlSocket(controlSock, configs, health, control, auditLog); err != nil {
+				return err
+			}
+		}
+		if grpcListen != "" {
+			if err := grpcapi.Serve(grpcListen, health, control, authConfig, auditLog); err != nil {
+				return err
+			}
+		}
+		if heartbeat != "" {
+			if heartbeatInt <= 0 {
+				return fmt.Errorf("--heartbeat-interval must be positive")
+			}
+			writeHeartbeat(heartbeat, heartbeatInt, health)
+		}
+
+		if watchdog > 0 {
+			if watchdog < 10*time.Second {
+				return fmt.Errorf("--watchdog must be at least 10s")
+			}
+			startWatchdog(watchdog, watchdogExec, health, func() {
+				stopProcesses(processes)
+				flushTraces()
+				auditLog.Record(audit.Entry{Action: audit.ActionStop, Details: map[string]string{"reason": "watchdog"}})
+			})
+		}
+
+		ctx, signalled := stopOnSignal(cmd.Context(), processes)
+
+		// In strict mode a job that fails to start stops all the others
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		var failed bool
+		var mu sync.Mutex
+		for i := range jobs {
+			wg.Add(1)
+			go func(job config.Job, cfg watcher.Config, execFunc watcher.ExecutorFunc) {
+				defer wg.Done()
+				if err := runJob(ctx, job, cfg, execFunc); err != nil {
+					mu.Lock()
+					failed = true
+					mu.Unlock()
+					if strict {
+						cancel()
+					}
+				}
+			}(jobs[i], configs[i], execFuncs[i])
+		}
+		wg.Wait()
+		term.Restore()
+		printSummary(stats)
+		flushTraces()
+		reason := "finished"
+		if signalled() != 0 {
+			reason = "signal"
+		}
+		auditLog.Record(audit.Entry{Action: audit.ActionStop, Details: map[string]string{"reason": reason}})
+
+		// The error is only the exit status, which isn't worth printing
+		cmd.SilenceErrors = true
+		if code := signalled(); code != 0 {
+			return ExitError{Code: code}
+		}
+		if forwardExit != "" {
+			if code := codes.code(forwardExit); code != 0 {
+				log.Info().Msgf("gowatchrun finished, exiting with command status %d.", code)
+				return ExitError{Code: code}
+			}
+		}
+		if failed {
+			return ExitError{Code: 1}
 		}
 		log.Info().Msg("gowatchrun finished.")
+		return nil
 	},
 }
 
+// runJob runs a single job until its watcher stops.
+func runJob(ctx context.Context, job config.Job, cfg watcher.Config, execFunc watcher.ExecutorFunc) error {
+	logger := cfg.Logger()
+
+	if job.RunOnStart {
+		logger.Info().Msg("Executing command on start due to --run-on-start flag...")
+		// execute with nil EventData as there's no file event
+		execFunc(ctx, cfg, nil)
+		logger.Info().Msg("Initial command execution finished.")
+	}
+
+	if !cfg.HasSources() {
+		logger.Info().Msgf("No event sources configured; running only after %v", job.DependsOn)
+		return nil
+	}
+
+	logger.Info().Msg("Starting file watcher...")
+	if err := watcher.Run(ctx, cfg, execFunc); err != nil {
+		logger.Error().Err(err).Msg("Watcher exited with error")
+		return err
+	}
+	return nil
+}
+
+// prefixJobName moves the job field of a log line in front of its message.
+func prefixJobName(evt map[string]interface{}) error {
+	job, ok := evt["job"]
+	if !ok {
+		return nil
+	}
+	delete(evt, "job")
+	evt[zerolog.MessageFieldName] = fmt.Sprintf("[%v] %v", job, evt[zerolog.MessageFieldName])
+	return nil
+}
+
 func Execute() error {
 	return rootCmd.Execute()
 }
 
 func init() {
-	rootCmd.Flags().StringSliceVarP(&watchDirs, "watch", "w", []string{"."}, "Directory(ies) to watch. Can be specified multiple times.")
-	rootCmd.Flags().StringSliceVarP(&excludeDirs, "exclude", "x", []string{}, "Directory path(s) to exclude when watching recursively. Can be specified multiple times.")
-	rootCmd.Flags().StringSliceVarP(&patterns, "pattern", "p", []string{"*.*"}, "Glob pattern(s) for files to watch. Can be specified multiple times.")
-	rootCmd.Flags().StringSliceVarP(&eventTypes, "event", "e", []string{"all"}, "Event type(s) to trigger on. Valid types: write, create, remove, rename, chmod, open, read, closewrite, closeread, all. Can be specified multiple times.")
-	rootCmd.Flags().StringVarP(&commandTmpl, "command", "c", "", "Command template to execute. This flag is required.")
-	rootCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "Watch directories recursively.")
-	rootCmd.Flags().StringVar(&logLevel, "log-level", "info", "Set the logging level (e.g., debug, info, warn, error).")
-	rootCmd.Flags().StringVar(&delayStr, "delay", "0s", "Debounce delay before executing the command after a change (e.g., 300ms, 1s). Waits for a period of inactivity.")
-	rootCmd.Flags().BoolVarP(&clearTerminal, "clear", "C", false, "Clear terminal before executing command.")
-	rootCmd.Flags().BoolVar(&runOnStart, "run-on-start", false, "Execute the command once immediately on startup.")
-
-	if err := rootCmd.MarkFlagRequired("command"); err != nil {
-		log.Fatal().Err(err).Msg("Failed to mark 'command' flag as required")
-	}
+	f := rootCmd.Flags()
+	f.BoolVar(&ignoreNoise, "ignore-common-noise", true, "Ignore chmod-only events and editor/OS junk files (*.swp, 4913, *~, .DS_Store, *.tmp, ...). Use --ignore-common-noise=false to disable.")
+	f.BoolVar(&why, "why", false, "Log why every file event was accepted or ignored by the event type and pattern filters.")
+	f.BoolVar(&once, "once", false, "Exit after the first triggered execution. Same as --max-triggers 1.")
+	f.IntVar(&flagJob.MaxTriggers, "max-triggers", 0, "Stop watching after this many triggered executions. 0 means no limit.")
+	f.IntSliceVar(&flagJob.OkExitCodes, "ok-exit-codes", nil, "Command exit codes to treat as success (e.g., 130).")
+	f.Float64Var(&flagJob.SlowFactor, "slow-factor", 0, "Warn when a successful run takes this many times longer than the median of the job's last 20 successful runs (e.g., 2), to catch build time regressions.")
+	f.BoolVar(&flagJob.Sandbox, "sandbox", false, "Run commands with no new privileges and a seccomp filter that denies dangerous syscalls (mount, ptrace, module loading, namespaces, ...). Linux only.")
+	f.BoolVar(&flagJob.SandboxReadOnly, "sandbox-read-only", false, "With --sandbox, deny commands writes to the filesystem, except below --sandbox-writable paths and to devices. Requires Landlock.")
+	f.StringSliceVar(&flagJob.SandboxWritable, "sandbox-writable", nil, "Path sandboxed commands may still write below. Implies --sandbox-read-only. Can be specified multiple times.")
+	f.StringVar(&flagJob.Chroot, "chroot", "", "Run commands with this directory as their root directory, in a private mount namespace, so they can't read outside of it. Linux only; uses a user namespace unless gowatchrun runs as root.")
+	f.StringSliceVar(&flagJob.ChrootBind, "chroot-bind", nil, "Host path mounted read-only at the same path inside the --chroot directory, e.g. /usr or /lib. Can be specified multiple times.")
+	f.StringVar(&forwardExit, "forward-exit-code", "", "Exit with the command's exit status once all watchers stop: 'last' (the default when given without a value) or 'worst'.")
+	f.Lookup("forward-exit-code").NoOptDefVal = "last"
+	f.BoolVar(&bell, "bell", false, "Ring the terminal bell when a run fails.")
+	f.BoolVar(&statusLine, "status-line", false, "While a command runs on a terminal, show a line with the command and the time it has been running, which is erased before the command's output.")
+	f.BoolVar(&setTitle, "set-title", false, "Show the state of the runs in the terminal title: watching, building, or ✓/✗ with the duration of the last run.")
+	f.StringVar(&configPath, "config", "", "Config file defining one or more jobs. When set, the job flags below are ignored.")
+	f.StringVar(&procfilePath, "procfile", "", "Supervise the processes declared in this Procfile, restarting each one when its watched files change.")
+	f.StringSliceVarP(&flagJob.Watch, "watch", "w", []string{"."}, "Directory(ies) to watch. Can be specified multiple times. Append :<pattern> to apply a pattern only below that directory (e.g., ./src:*.go).")
+	f.StringSliceVarP(&flagJob.Exclude, "exclude", "x", []string{}, "Directory path(s) or glob(s) to exclude when watching recursively, e.g. vendor, ./build or '**/node_modules'. Relative paths and globs apply below each watch directory. Can be specified multiple times.")
+	f.StringSliceVarP(&flagJob.Patterns, "pattern", "p", []string{"*.*"}, "Glob pattern(s) for files to watch. Can be specified multiple times. Prefix with ! to exclude matching files; the last matching pattern wins.")
+	f.StringSliceVarP(&flagJob.Events, "event", "e", []string{"all"}, "Event type(s) to trigger on. Valid types: write, create, remove, rename, chmod, open, read, closewrite, closeread, all. Can be specified multiple times.")
+	f.StringVarP(&flagJob.Command, "command", "c", "", "Command template to execute. Either this, --script, --make, --task, --action, --s3-upload, --signal-pid-file or --http-action is required.")
+	f.StringVar(&flagJob.CommandFile, "command-file", "", "File holding the command template, for long or multi-line commands. Replaces --command.")
+	f.StringVar(&flagJob.Templates, "templates", "", "Directory of *.tmpl files that templates can include with {{template \"name\" .}}, where name is the file name without .tmpl.")
+	f.StringVar(&flagJob.Script, "script", "", "Script template to run instead of a command: it's rendered to a temporary file and run with the interpreter on its #! line, or with --shell.")
+	f.StringVar(&flagJob.Shell, "shell", "", "Shell to run commands with, e.g. bash or pwsh. PowerShell runs commands with -NoProfile -Command, cmd.exe with /S /C and other shells with -c. (Default: sh, or cmd.exe on Windows)")
+	f.BoolVar(&flagJob.WSLInterop, "wsl-interop", false, "Run commands on the other side of WSL, with the paths of the event translated: inside WSL when gowatchrun runs on Windows, and on Windows when it runs inside WSL.")
+	f.StringVar(&flagJob.Preset, "preset", "", fmt.Sprintf("Use ready-made settings for a project type (%s). Other flags override the preset.", strings.Join(config.PresetNames(), ", ")))
+	f.BoolVar(&flagJob.StdinPaths, "stdin-paths", false, "Start the command once and write the path of every event as a line to its stdin, restarting it if it exits.")
+	f.BoolVar(&flagJob.Worker, "worker", false, "Start the command once as a handler and send it every event as a JSON-RPC request on stdin, reading its success or failure response from stdout.")
+	f.StringVar(&flagJob.GoHandler, "go-handler", "", "Go file with a Handle(ctx, event) function to compile into a --worker handler, compiled again and restarted whenever it changes.")
+	f.StringVar(&flagJob.WorkerTimeout, "worker-timeout", "0s", "How long to wait for the --worker handler to respond to an event before treating it as failed. 0 waits indefinitely.")
+	f.StringVar(&flagJob.Restart, "restart", "", "Keep this command running and restart it after every successful run of the command (e.g., './tmp/app').")
+	f.StringVar(&flagJob.Sidecar, "require-sidecar", "", "Template for a completion marker file (e.g., '{{.BaseName}}.done') that must exist before a file's event runs the command; events wait until it appears.")
+	f.StringVar(&flagJob.When, "when", "", "Template that must render to 'true' for an event to run the command (e.g., '{{ gt .Size 1024 }}').")
+	f.StringVar(&flagJob.Handler, "handler", "", "Starlark script whose handle(event) function decides for every run whether it goes ahead, and may change its path, command and environment.")
+	f.StringVar(&flagJob.Delims, "template-delims", "", "Template delimiters to use instead of {{ and }}, as 'left,right' (e.g., '[[,]]'), so commands can contain literal {{ }}.")
+	f.StringVar(&flagJob.Make, "make", "", "Run this make target instead of a command template.")
+	f.StringVar(&flagJob.Task, "task", "", "Run this Task (taskfile.dev) target instead of a command template.")
+	f.BoolVar(&flagJob.DerivePatterns, "derive-patterns", false, "Watch the source files of the --make or --task target instead of --pattern.")
+	f.StringVar(&flagJob.Action, "action", "", "Built-in action to run instead of a command. Valid actions: copy, move, delete, zip, targz.")
+	f.StringVar(&flagJob.Dest, "dest", "", "Destination path template for the copy, move, zip and targz actions (e.g., '{{.Dir}}/processed/{{.Name}}').")
+	f.StringVar(&flagJob.SignalPIDFile, "signal-pid-file", "", "Send --signal to the process whose ID is in this file instead of running a command, e.g. to make nginx reload its config.")
+	f.StringVar(&flagJob.Signal, "signal", "", "Signal sent to the process in --signal-pid-file, with or without the SIG prefix. (Default: HUP)")
+	f.StringVar(&flagJob.HTTPAction, "http-action", "", "Send an HTTP request to this URL template instead of running a command (e.g., 'http://localhost:8080/-/reload').")
+	f.StringVar(&flagJob.HTTPMethod, "http-method", "", "Method of --http-action requests. (Default: POST)")
+	f.StringArrayVar(&flagJob.HTTPHeaders, "http-header", nil, "Header template for --http-action requests as 'Name: value'. Can be specified multiple times.")
+	f.StringVar(&flagJob.HTTPBody, "http-body", "", "Body template for --http-action requests. (Default: the event as JSON)")
+	f.StringArrayVar(&flagJob.FilterPlugins, "filter-plugin", nil, "Plugin (a gowatchrun-<name> executable on PATH) that decides whether a run goes ahead. Can be specified multiple times.")
+	f.StringVar(&flagJob.ActionPlugin, "action-plugin", "", "Plugin (a gowatchrun-<name> executable on PATH) to run instead of a command.")
+	f.StringArrayVar(&flagJob.Middleware, "middleware", nil, fmt.Sprintf("Middleware to wrap every run in, as name or name=argument, the first one outermost (%s). Can be specified multiple times.", strings.Join(middleware.Names(), ", ")))
+	f.StringArrayVar(&flagJob.NotifyPlugins, "notify-plugin", nil, "Plugin (a gowatchrun-<name> executable on PATH) told about the outcome of every run. Can be specified multiple times.")
+	f.StringVar(&flagJob.S3Upload, "s3-upload", "", "Upload matched files to an S3-compatible bucket, given as 'bucket/prefix'.")
+	f.StringVar(&flagJob.S3Key, "s3-key", "{{.Name}}", "Object key template for --s3-upload, appended to the prefix.")
+	f.StringVar(&flagJob.S3.Endpoint, "s3-endpoint", "s3.amazonaws.com", "S3 endpoint host (and optional port) for --s3-upload.")
+	f.StringVar(&flagJob.S3.Region, "s3-region", "", "S3 region for --s3-upload. Detected automatically when empty.")
+	f.StringVar(&flagJob.S3.AccessKey, "s3-access-key", "", "S3 access key. Falls back to AWS_ACCESS_KEY_ID/MINIO_ACCESS_KEY, ~/.aws/credentials and instance metadata.")
+	f.StringVar(&flagJob.S3.SecretKey, "s3-secret-key", "", "S3 secret key. Falls back to AWS_SECRET_ACCESS_KEY/MINIO_SECRET_KEY, ~/.aws/credentials and instance metadata.")
+	f.BoolVar(&flagJob.S3.Insecure, "s3-insecure", false, "Connect to the S3 endpoint over plain HTTP.")
+	f.StringVar(&flagJob.Source, "source", "", "Poll a remote location for new or changed files instead of (or, with --watch, in addition to) watching local directories: s3://bucket/prefix or sftp://user@host[:port]/path.")
+	f.StringVar(&flagJob.PollInterval, "poll-interval", "30s", "How often to poll the --source location.")
+	f.StringVar(&flagJob.SFTP.Password, "sftp-password", "", "Password for sftp:// sources (a password in the URL takes precedence).")
+	f.StringVar(&flagJob.SFTP.KeyFile, "sftp-key", "", "Private key file for sftp:// sources. The SSH agent is also used when SSH_AUTH_SOCK is set.")
+	f.StringVar(&flagJob.SFTP.KnownHostsFile, "sftp-known-hosts", "", "known_hosts file used to verify sftp:// hosts. (Default: ~/.ssh/known_hosts)")
+	f.BoolVar(&flagJob.SFTP.InsecureIgnoreHostKey, "sftp-insecure-ignore-host-key", false, "Skip host key verification for sftp:// sources.")
+	f.StringVar(&flagJob.ListenWebhook, "listen-webhook", "", "Listen for HTTP POST requests on this address and path (e.g., ':8085/hook') and trigger the command for each one.")
+	f.StringVar(&flagJob.Every, "every", "0s", "Also trigger the command on a fixed interval (e.g., 5m).")
+	f.StringVar(&flagJob.Cron, "cron", "", "Also trigger the command on a cron schedule (e.g., '0 * * * *' or '@daily').")
+	f.StringArrayVar(&flagJob.PauseDuring, "pause-during", nil, "Hold events in this window and run them once it ends: HH:MM-HH:MM in local time (e.g., 02:00-03:00), or a cron expression followed by a duration (e.g., '0 2 * * 0 2h'). Can be specified multiple times.")
+	f.StringVar(&flagJob.PauseMode, "pause-mode", "queue", "What to do with events in a --pause-during window: queue or drop.")
+	f.StringVar(&flagJob.Settle, "settle", "0s", "Wait until the triggering file's size and modification time are unchanged for this long before executing (e.g., 2s).")
+	f.BoolVarP(&flagJob.Recursive, "recursive", "r", false, "Watch directories recursively.")
+	f.IntVar(&flagJob.MaxWatches, "max-watches", 0, "Stop adding directory watches once this many are in place, and warn about the subtrees that were skipped. 0 means no limit.")
+	f.IntVar(&flagJob.LazyWatch, "lazy-watch", 0, "With --recursive, only watch this many levels below each watch directory at startup, and watch deeper directories once there's activity above them. 0 watches the whole tree.")
+	f.StringVar(&flagJob.FSEventsState, "fsevents-state", "", "macOS: keep the last FSEvents event ID in this file and, on startup, replay the changes below the watch directories that were made while gowatchrun wasn't running. Requires a cgo build.")
+	f.StringVar(&flagJob.MinSize, "min-size", "", "Ignore files smaller than this size (e.g., 1, 10KB, 1MiB).")
+	f.StringVar(&flagJob.MaxSize, "max-size", "", "Ignore files larger than this size (e.g., 500MB, 2GiB).")
+	f.StringVar(&flagJob.MinAge, "min-age", "", "Ignore files modified more recently than this (e.g., 30s).")
+	f.StringSliceVar(&flagJob.Mime, "mime", nil, "Only trigger for files whose content-sniffed media type matches one of these patterns (e.g., 'image/*,video/*').")
+	f.StringVar(&flagJob.Unicode, "unicode-normalize", "", "Unicode normalization applied to event paths and patterns before matching and templating: nfc, nfd or none. (Default: nfc on macOS, none elsewhere)")
+	f.BoolVar(&flagJob.K8sConfigMap, "k8s-configmap", false, "Watch a mounted Kubernetes ConfigMap or Secret: turn its atomic ..data symlink swaps into one WRITE event per changed file.")
+	f.StringVar(&flagJob.Target, "target", "", "Trigger on files, dirs or both. dirs also reports directories created in recursive mode. (Default: both, except new directories in recursive mode)")
+	f.BoolVar(&flagJob.IncludeHidden, "include-hidden", false, "Watch dot-directories (.git, .idea, .cache, ...) and match dotfiles.")
+	f.BoolVar(&strict, "strict", false, "Fail fast on setup problems: refuse to start when a directory below the watch directories can't be read, traversed or watched (including when --max-watches is reached), and stop all jobs when one fails to start.")
+	f.StringVar(&logLevel, "log-level", "info", "Set the logging level (e.g., debug, info, warn, error).")
+	f.BoolVarP(&quiet, "quiet", "q", false, "Suppress all gowatchrun logging and only show the output of the commands.")
+	f.BoolVarP(&verbose, "verbose", "v", false, "Log debug details. Same as --log-level debug.")
+	f.BoolVar(&eventsOnly, "log-events-only", false, "Instead of the regular logging, print one compact line per triggering event.")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose", "log-events-only")
+	f.StringVar(&journalPath, "journal", "", "Append every run (triggering files, duration and exit status) to this file as JSON lines, for 'gowatchrun stats'. (Default when given without a value: "+journal.DefaultPath+")")
+	f.Lookup("journal").NoOptDefVal = journal.DefaultPath
+	f.StringVar(&auditPath, "audit-log", "", "Append an audit trail to this file as JSON lines: the configuration gowatchrun started with, pauses, resumes and triggers by API clients, and every execution with its rendered command.")
+	f.StringVar(&otlpEndpoint, "otlp-endpoint", "", "Export a trace per event (filtering, debounce wait, queueing and execution) to this OTLP/HTTP endpoint (e.g., http://localhost:4318). The standard OTEL_EXPORTER_OTLP_* variables also enable it.")
+	f.StringVar(&healthListen, "health-listen", "", "Serve the watchers' health as JSON on this address and path (e.g., ':8086/healthz'), with status 503 when a watcher is wedged. With --auth-token or --tls-client-ca, it also streams events on /events/ws and command output on /output.")
+	f.StringVar(&apiListen, "api-listen", "", "Serve a web dashboard and its HTTP API (status, configuration, live events, run history, pause, resume and trigger) on this address (e.g., '127.0.0.1:8087').")
+	f.StringVar(&controlSock, "control-socket", "", "Serve the HTTP API of --api-listen on this Unix socket, which only the current user can connect to, for 'gowatchrun trigger' and 'gowatchrun diagnostics'.")
+	f.StringVar(&pauseState, "pause-state", "", "Keep which jobs are paused, and the events they ignore while paused, in this file, so a restart doesn't resume them. Their ignored events run once they're resumed.")
+	f.BoolVar(&startPaused, "start-paused", false, "Start with every job paused, until it's resumed through the API.")
+	f.StringVar(&grpcListen, "grpc-listen", "", "Serve the gRPC API (status, event stream, trigger, pause and resume) on this address (e.g., ':9090'). See api/gowatchrun/v1/gowatchrun.proto.")
+	f.StringVar(&authConfig.Token, "auth-token", "", "Token clients of --api-listen, --grpc-listen, --health-listen (except the health check itself) and --listen-webhook must send as 'Authorization: Bearer <token>'. Prefer --auth-token-file, since arguments are visible to other users.")
+	f.StringVar(&tokenFile, "auth-token-file", "", "Read the --auth-token from this file.")
+	f.StringSliceVar(&secretEnv, "secret-env", nil, "Mask the values of these environment variables (names or globs like '*_TOKEN') in logs, the audit log and API responses. Commands still see the real values.")
+	f.StringVar(&authConfig.CertFile, "tls-cert", "", "Serve all listening endpoints over TLS with this certificate (requires --tls-key).")
+	f.StringVar(&authConfig.KeyFile, "tls-key", "", "Private key of --tls-cert.")
+	f.StringVar(&authConfig.ClientCAFile, "tls-client-ca", "", "Require clients of all listening endpoints to present a certificate signed by a CA in this file (mTLS).")
+	f.StringSliceVar(&authConfig.Origins, "allowed-origin", nil, "Let browser pages from these origins (e.g., 'https://dash.example.com') open /events/ws and send requests that change anything to --api-listen. By default only pages served by gowatchrun itself may.")
+	f.StringVar(&heartbeat, "heartbeat-file", "", "Rewrite this file with the watchers' health every --heartbeat-interval while they're healthy, for liveness probes that check its age.")
+	f.DurationVar(&heartbeatInt, "heartbeat-interval", 10*time.Second, "How often to rewrite the --heartbeat-file.")
+	f.DurationVar(&busyTimeout, "health-busy-timeout", watcher.DefaultBusyTimeout, "Report a watcher as not alive on --health-listen and --heartbeat-file once it has been executing a command for this long, e.g. because the command hangs. 0 lets commands run for any time.")
+	f.DurationVar(&watchdog, "watchdog", 0, "Log a goroutine dump when a watcher makes no progress for this long, e.g. because a command never returns (e.g., 30m). 0 disables the watchdog.")
+	f.BoolVar(&watchdogExec, "watchdog-restart", false, "Restart gowatchrun when the --watchdog detects a stuck watcher.")
+	f.StringVar(&summaryJSON, "summary-json", "", "Also write the summary printed on exit (event, run and duration statistics) to this file as JSON.")
+	f.BoolVar(&flagJob.Batch, "batch", false, "Collect the events that arrive during --delay and run the command once for all of them, available as {{.Files}}.")
+	f.StringVar(&flagJob.GrepOutput, "grep-output", "", "Only show the lines of command output that match this regular expression (e.g., '(?i)error|warn').")
+	f.StringArrayVar(&flagJob.Highlight, "highlight", nil, "Highlight matches of this regular expression in command output shown on a terminal (e.g., '[\\w./-]+\\.go:\\d+'). Can be specified multiple times.")
+	f.StringArrayVar(&flagJob.ProblemMatchers, "problem-matcher", nil, "Extract errors and warnings from command output with this problem matcher, a built-in one (go, gcc, tsc, eslint-compact, rustc, python) or a JSON file, and summarize them after every run. Can be specified multiple times.")
+	f.StringVar(&flagJob.DiagnosticsFile, "diagnostics-file", "", "Write the diagnostics --problem-matcher finds in every run to this JSON file, for editors to pick up.")
+	f.StringVar(&flagJob.FailFastOutput, "fail-fast-output", "", "Stop showing command output shortly after the first line that marks a failure, so it stays at the bottom of the screen: 'default' (when given without a value) for common test runner and compiler markers, or a regular expression.")
+	f.Lookup("fail-fast-output").NoOptDefVal = "default"
+	f.StringVar(&flagJob.OnFailure, "on-failure", "", "Command template to run after every failed run, e.g. to send an alert; {{.ExitCode}} and {{.OutputTail}} hold the exit code and the end of the command's output.")
+	f.StringVar(&flagJob.OutputTail, "output-tail", "", "How much of the end of every command's output to keep for {{.OutputTail}} (e.g., 8KB), even when the output goes to a terminal. (Default: 64KiB when it doesn't, or when --on-failure, --notify-plugin or --middleware use it)")
+	f.StringVar(&flagJob.OnSuccessMove, "on-success-move", "", "Move the files of a successful run to this directory (e.g., ./done/).")
+	f.StringVar(&flagJob.OnFailureMove, "on-failure-move", "", "Move the files of a failed run to this directory (e.g., ./errors/).")
+	f.StringVar(&flagJob.LockDir, "lock-dir", "", "Directory for lock files that keep several instances watching a shared folder from processing the same file; a file locked by another instance is skipped.")
+	f.StringVar(&flagJob.Coordinator, "coordinator", "", "Redis URL (redis://host:6379/0) where a pool of instances on different hosts claims files, so each file is processed once. Alternative to --lock-dir.")
+	f.BoolVar(&flagJob.LockWait, "lock-wait", false, "Wait for a file locked by another instance (--lock-dir or --coordinator) to be released, then process it, instead of skipping it.")
+	f.BoolVar(&flagJob.Manifest, "manifest", false, "Treat matched files as manifests: wait until every payload file they list exists and is stable, then run with the payloads as {{.Payloads}}.")
+	f.StringVar(&flagJob.ManifestKey, "manifest-key", manifest.DefaultKey, "JSON key, or XML element or attribute name, holding the payload paths in --manifest files.")
+	f.StringVar(&flagJob.ManifestTimeout, "manifest-timeout", "10m", "Give up on a --manifest whose payloads aren't complete after this long (0 waits forever).")
+	f.StringVar(&flagJob.GroupBy, "group-by", "", "Set to dir to run a batch once per directory, with {{.Dir}} set and {{.Files}} holding that directory's files. Implies --batch.")
+	f.IntVar(&flagJob.BatchSize, "batch-size", 0, "Like xargs -n: run the command once per chunk of at most this many files of a batch. Implies --batch.")
+	f.StringVar(&flagJob.Delay, "delay", "0s", "Debounce delay before executing the command after a change (e.g., 300ms, 1s). Waits for a period of inactivity.")
+	f.StringVar(&flagJob.DedupeWindow, "dedupe-window", "", "Drop events of the same type for the same path that repeat within this window, which some platforms deliver for a single save (e.g., 50ms; 0 disables). (Default: 10ms)")
+	f.StringVarP(&flagJob.Clear, "clear", "C", "", "Clear the terminal before executing the command: 'always' (the default when given without a value), 'on-success' (keep the output of a failed run) or 'on-change' (only for file changes).")
+	f.Lookup("clear").NoOptDefVal = watcher.ClearAlways
+	f.BoolVar(&flagJob.RunOnStart, "run-on-start", false, "Execute the command once immediately on startup.")
 }
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/s0up4200/gowatchrun/internal/journal"
+)
+
+var (
+	statsJournal string
+	statsBy      string
+	statsSort    string
+	statsJob     string
+	statsTop     int
+)
+
+// pathStats is one row of the stats table.
+type pathStats struct {
+	path     string
+	runs     int
+	failures int
+	time     time.Duration
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show which files or directories trigger the most runs and command time",
+	Long: `Reads the journal written by gowatchrun --journal and lists the files (or,
+with --by dir, directories) that triggered the most runs, with the command
+time they consumed, to help find noisy paths worth excluding. The time of a
+batch run is split evenly across its files.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if statsBy != "file" && statsBy != "dir" {
+			return fmt.Errorf("invalid --by '%s' (expected file or dir)", statsBy)
+		}
+		if statsSort != "runs" && statsSort != "time" {
+			return fmt.Errorf("invalid --sort '%s' (expected runs or time)", statsSort)
+		}
+		cmd.SilenceUsage = true
+
+		entries, err := journal.Read(statsJournal)
+		if err != nil {
+			return err
+		}
+
+		byPath := make(map[string]*pathStats)
+		var total time.Duration
+		runs := 0
+		for _, entry := range entries {
+			if statsJob != "" && entry.Job != statsJob {
+				continue
+			}
+			runs++
+			total += entry.Duration()
+			if len(entry.Files) == 0 {
+				continue
+			}
+			share := entry.Duration() / time.Duration(len(entry.Files))
+			for _, file := range entry.Files {
+				key := file
+				if statsBy == "dir" {
+					key = filepath.Dir(file)
+				}
+				row, ok := byPath[key]
+				if !ok {
+					row = &pathStats{path: key}
+					byPath[key] = row
+				}
+				row.runs++
+				row.time += share
+				if entry.ExitCode != 0 {
+					row.failures++
+				}
+			}
+		}
+
+		rows := make([]*pathStats, 0, len(byPath))
+		for _, row := range byPath {
+			rows = append(rows, row)
+		}
+		sort.Slice(rows, func(i, j int) bool {
+			a, b := rows[i], rows[j]
+			if statsSort == "time" && a.time != b.time {
+				return a.time > b.time
+			}
+			if a.runs != b.runs {
+				return a.runs > b.runs
+			}
+			return a.path < b.path
+		})
+		if statsTop > 0 && len(rows) > statsTop {
+			rows = rows[:statsTop]
+		}
+
+		fmt.Printf("%d run(s), %s of command time in %s\n\n", runs, total.Round(time.Millisecond), statsJournal)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(w, "RUNS\tFAILED\tTIME\tSHARE\t\n")
+		for _, row := range rows {
+			share := 0.0
+			if total > 0 {
+				share = 100 * float64(row.time) / float64(total)
+			}
+			fmt.Fprintf(w, "%d\t%d\t%s\t%.1f%%\t  %s\n", row.runs, row.failures, row.time.Round(time.Millisecond), share, row.path)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	f := statsCmd.Flags()
+	f.StringVar(&statsJournal, "journal", journal.DefaultPath, "Journal file to read.")
+	f.StringVar(&statsBy, "by", "file", "Group by 'file' or 'dir'.")
+	f.StringVar(&statsSort, "sort", "runs", "Sort by number of 'runs' or command 'time'.")
+	f.StringVar(&statsJob, "job", "", "Only include runs of this job.")
+	f.IntVar(&statsTop, "top", 20, "Show at most this many paths. 0 shows all.")
+	rootCmd.AddCommand(statsCmd)
+}
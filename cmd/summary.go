@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// topFiles is how many of the most frequently triggering files the summary
+// lists.
+const topFiles = 5
+
+// printSummary logs the execution summary and, with --summary-json, writes it
+// to a file.
+func printSummary(stats *watcher.Stats) {
+	sum := stats.Summary(topFiles)
+
+	log.Info().Msgf("Summary: %d event(s) observed, %d filtered, %d coalesced; %d run(s), %d succeeded, %d failed",
+		sum.Observed, sum.Filtered, sum.Coalesced, sum.Runs, sum.Succeeded, sum.Failed)
+	if sum.Runs > 0 {
+		log.Info().Msgf("Durations: avg %s, p50 %s, p90 %s, p99 %s, max %s", sum.Average, sum.P50, sum.P90, sum.P99, sum.Max)
+	}
+	if len(sum.TopFiles) > 0 {
+		files := make([]string, len(sum.TopFiles))
+		for i, file := range sum.TopFiles {
+			files[i] = fmt.Sprintf("%s (%d)", file.Path, file.Count)
+		}
+		log.Info().Msgf("Top triggering files: %s", strings.Join(files, ", "))
+	}
+
+	if summaryJSON == "" {
+		return
+	}
+	data, err := json.MarshalIndent(sum, "", "  ")
+	if err == nil {
+		err = os.WriteFile(summaryJSON, append(data, '\n'), 0o644)
+	}
+	if err != nil {
+		log.Error().Msgf("Failed to write summary to %s: %v", summaryJSON, err)
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/s0up4200/gowatchrun/internal/supervisor"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+	"github.com/s0up4200/gowatchrun/internal/worker"
+)
+
+// restartAfter wraps execFunc so proc is (re)started after every successful
+// run. A failed or skipped run leaves the previous instance running.
+func restartAfter(execFunc watcher.ExecutorFunc, proc *supervisor.Process) watcher.ExecutorFunc {
+	return func(ctx context.Context, cfg watcher.Config, data *watcher.EventData) watcher.ExecutionResult {
+		result := execFunc(ctx, cfg, data)
+		if !result.OK() || result.Skipped {
+			return result
+		}
+		if err := proc.Restart(); err != nil {
+			result.ExitCode, result.Err = watcher.ExitCode(err), err
+		}
+		return result
+	}
+}
+
+// stopOnSignal returns a context derived from parent that's cancelled once
+// gowatchrun receives SIGINT or SIGTERM, after all processes were stopped,
+// so the watchers return and gowatchrun exits through its normal path. The
+// function returned gives the status to exit with for the signal, 128 plus
+// its number like shells report it, or 0 before one arrived. A second
+// signal kills gowatchrun right away. Supervised processes run in their own
+// process group, so they don't see a Ctrl-C on the terminal themselves.
+func stopOnSignal(parent context.Context, processes []*supervisor.Process) (context.Context, func() int) {
+	ctx, cancel := context.WithCancel(parent)
+	var code atomic.Int32
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		if len(processes) > 0 {
+			log.Info().Msgf("Received %s, stopping processes...", sig)
+		} else {
+			log.Info().Msgf("Received %s, exiting...", sig)
+		}
+		stopProcesses(processes)
+		if number, ok := sig.(syscall.Signal); ok {
+			code.Store(128 + int32(number))
+		}
+		cancel()
+	}()
+	return ctx, func() int { return int(code.Load()) }
+}
+
+// writePaths passes an event to a --stdin-paths process by writing its path
+// (every path of a batch) as a line to the process's stdin.
+func writePaths(proc *supervisor.Process, cfg watcher.Config, data *watcher.EventData) error {
+	if data == nil {
+		return nil // Nothing to report for --run-on-start
+	}
+	logger := cfg.Logger()
+	files := data.Files
+	if len(files) == 0 {
+		files = []watcher.EventData{*data}
+	}
+	for _, file := range files {
+		logger.Debug().Msgf("Writing %s to stdin of: %s", file.Path, proc.Command)
+		if err := proc.WriteLine(file.Path); err != nil {
+			logger.Error().Msgf("Failed to pass %s to '%s': %v", file.Path, proc.Command, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// callWorker sends an event to a --worker handler and waits for its
+// response.
+func callWorker(w *worker.Worker, cfg watcher.Config, data *watcher.EventData) error {
+	if data == nil {
+		return nil // Nothing to report for --run-on-start
+	}
+	logger := cfg.Logger()
+	startTime := time.Now()
+	event := *data
+	event.Rule = cfg.Name
+	err := w.Call(event)
+	duration := time.Since(startTime)
+	if err != nil {
+		logger.Error().
+			Str("event_path", data.Path).
+			Str("event_type", data.Event).
+			Dur("duration", duration.Round(time.Microsecond)).
+			Err(err).
+			Msg("Handler failed")
+		return err
+	}
+	logger.Debug().
+		Str("event_path", data.Path).
+		Str("event_type", data.Event).
+		Dur("duration", duration.Round(time.Microsecond)).
+		Msg("Handler acknowledged event")
+	return nil
+}
+
+// stopProcesses stops all processes concurrently and waits for them to exit.
+func stopProcesses(processes []*supervisor.Process) {
+	var wg sync.WaitGroup
+	for _, proc := range processes {
+		wg.Add(1)
+		go func(proc *supervisor.Process) {
+			defer wg.Done()
+			proc.Stop()
+		}(proc)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	triggerSocket string
+	triggerAPI    string
+	triggerJob    string
+	triggerPath   string
+)
+
+var triggerCmd = &cobra.Command{
+	Use:   "trigger",
+	Short: "Run a job of a running gowatchrun, optionally for a given file",
+	Long: `Asks a running gowatchrun to run a job, through its --control-socket or
+its --api-listen address. With --path, the run is for that file: it's passed
+to the command as a TRIGGER event with the file's placeholders filled in, as
+if the file had changed. The file must be inside the job's watch directories
+and pass its excludes, patterns and other filters, like the file's own
+events; other paths are rejected. The job runs even while it's paused.`,
+	Example: `  gowatchrun trigger --socket /run/gowatchrun.sock --path ./inbox/report.csv
+  gowatchrun trigger --api http://127.0.0.1:8087 --auth-token-file token --job thumbnails`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (triggerSocket == "") == (triggerAPI == "") {
+			return fmt.Errorf("either --socket or --api is required")
+		}
+		cmd.SilenceUsage = true
+
+		form := url.Values{}
+		if triggerJob != "" {
+			form.Set("job", triggerJob)
+		}
+		if triggerPath != "" {
+			// The running instance may have another working directory
+			path, err := filepath.Abs(triggerPath)
+			if err != nil {
+				return err
+			}
+			form.Set("path", path)
+		}
+
+		client, base := apiClient(triggerSocket, triggerAPI)
+		if err := authConfig.Load(tokenFile); err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, base+"/api/trigger", strings.NewReader(form.Encode()))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if authConfig.Token != "" && triggerSocket == "" {
+			req.Header.Set("Authorization", "Bearer "+authConfig.Token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to reach gowatchrun: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			return fmt.Errorf("trigger failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		if path := form.Get("path"); path != "" {
+			fmt.Printf("Triggered a run for %s\n", path)
+		} else {
+			fmt.Println("Triggered a run")
+		}
+		return nil
+	},
+}
+
+// apiClient returns a client for a running gowatchrun's control socket, or
+// else its API, and the base URL of its requests.
+func apiClient(socket, api string) (*http.Client, string) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if socket == "" {
+		return client, strings.TrimSuffix(api, "/")
+	}
+	client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return client, "http://gowatchrun"
+}
+
+func init() {
+	f := triggerCmd.Flags()
+	f.StringVar(&triggerSocket, "socket", "", "Control socket of the running gowatchrun (its --control-socket).")
+	f.StringVar(&triggerAPI, "api", "", "URL of the running gowatchrun's --api-listen address, e.g. http://127.0.0.1:8087.")
+	f.StringVar(&authConfig.Token, "auth-token", "", "Token the --api requires. Prefer --auth-token-file.")
+	f.StringVar(&tokenFile, "auth-token-file", "", "File containing the token the --api requires.")
+	f.StringVar(&triggerJob, "job", "", "Name of the job to run; required when several jobs are running.")
+	f.StringVar(&triggerPath, "path", "", "File to run the job for.")
+	rootCmd.AddCommand(triggerCmd)
+}
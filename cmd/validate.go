@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/s0up4200/gowatchrun/internal/config"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a config file for errors without running it",
+	Long: `Parses the config file given with --config, compiles all templates and
+patterns, resolves watch directories, checks event types against the current
+platform and validates job dependencies. Every problem is reported with the
+line it was found on, and the exit status is non-zero if there are any.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if configPath == "" {
+			return fmt.Errorf("--config is required")
+		}
+		cmd.SilenceUsage = true
+
+		problems, err := config.Validate(configPath)
+		if err != nil {
+			return err
+		}
+		if len(problems) == 0 {
+			fmt.Printf("%s: OK\n", configPath)
+			return nil
+		}
+
+		raw, _ := os.ReadFile(configPath)
+		lines := strings.Split(string(raw), "\n")
+		for _, p := range problems {
+			if p.Line == 0 {
+				fmt.Printf("%s: %v\n", configPath, p.Err)
+				continue
+			}
+			fmt.Printf("%s:%d: %v\n", configPath, p.Line, p.Err)
+			if p.Line <= len(lines) {
+				fmt.Printf("  %4d | %s\n", p.Line, lines[p.Line-1])
+			}
+		}
+		return fmt.Errorf("found %d problem(s) in %s", len(problems), configPath)
+	},
+}
+
+func init() {
+	validateCmd.Flags().StringVar(&configPath, "config", "", "Config file to validate.")
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"os"
+	"runtime/pprof"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// startWatchdog checks that every watcher's event loop keeps making progress.
+// When one has been stuck for longer than timeout (e.g. a command that never
+// returns), it logs a goroutine dump and, with restart, calls beforeRestart
+// and restarts gowatchrun with the same arguments.
+func startWatchdog(timeout time.Duration, restart bool, health *watcher.Health, beforeRestart func()) {
+	interval := max(timeout/4, time.Second)
+	go func() {
+		reported := make(map[string]bool)
+		for range time.Tick(interval) {
+			stalled := health.Stalled(timeout)
+			for job := range reported {
+				if _, ok := stalled[job]; !ok {
+					delete(reported, job) // Made progress again
+				}
+			}
+			if len(stalled) == 0 {
+				continue
+			}
+
+			dump := false
+			for job, since := range stalled {
+				if reported[job] {
+					continue
+				}
+				reported[job] = true
+				dump = true
+				if job == "" {
+					job = "watcher"
+				}
+				log.Error().Msgf("Watchdog: %s made no progress for %s", job, since.Round(time.Second))
+			}
+			if !dump {
+				continue
+			}
+			log.Error().Msg("Watchdog: goroutine dump follows")
+			pprof.Lookup("goroutine").WriteTo(os.Stderr, 2)
+
+			if restart {
+				log.Warn().Msg("Watchdog: restarting gowatchrun...")
+				beforeRestart()
+				if err := restartSelf(); err != nil {
+					log.Error().Msgf("Watchdog: failed to restart: %v", err)
+					os.Exit(1)
+				}
+			}
+		}
+	}()
+}
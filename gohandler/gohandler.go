@@ -0,0 +1,129 @@
+// Package gohandler is what a --go-handler file builds on. The file is a
+// main package without a main function that defines
+//
+//	func Handle(ctx context.Context, event gohandler.Event) error
+//
+// gowatchrun compiles it together with a main function that calls Serve,
+// runs it once, and sends it the events of the job. A non-nil error fails
+// the run. When the file changes, it's compiled again and the new handler
+// replaces the running one.
+//
+//	package main
+//
+//	import (
+//		"context"
+//		"fmt"
+//		"os"
+//
+//		"github.com/s0up4200/gowatchrun/gohandler"
+//	)
+//
+//	func Handle(ctx context.Context, event gohandler.Event) error {
+//		if event.Size == 0 {
+//			return fmt.Errorf("%s is empty", event.Name)
+//		}
+//		fmt.Fprintf(os.Stderr, "processing %s\n", event.Path)
+//		return nil
+//	}
+//
+// Handlers communicate with gowatchrun over stdin and stdout, so they should
+// log to stderr.
+package gohandler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Event describes a file system event, or a batch of them, with the
+// placeholders of command templates.
+type Event struct {
+	Path      string            `json:"path"`
+	PathSlash string            `json:"path_slash"`
+	Name      string            `json:"name"`
+	Event     string            `json:"event"` // e.g. WRITE
+	Ext       string            `json:"ext"`
+	Dir       string            `json:"dir"`
+	BaseName  string            `json:"base_name"`
+	Size      int64             `json:"size"`
+	Mime      string            `json:"mime,omitempty"`
+	Remote    string            `json:"remote,omitempty"`
+	Files     []Event           `json:"files,omitempty"` // The events of a batch, oldest first
+	RunNumber int               `json:"run_number,omitempty"`
+	Hostname  string            `json:"hostname,omitempty"`
+	Payload   any               `json:"payload,omitempty"` // Webhook events: the request body decoded as JSON
+	Body      string            `json:"body,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+
+	MatchedPattern string `json:"matched_pattern,omitempty"` // The pattern the file name matched, e.g. *.go
+	Rule           string `json:"rule,omitempty"`            // The name of the job
+}
+
+// HandlerFunc handles an event. The context is cancelled when gowatchrun
+// stops the handler.
+type HandlerFunc func(ctx context.Context, event Event) error
+
+type request struct {
+	ID     int    `json:"id"`
+	Method string `json:"method"`
+	Params Event  `json:"params"`
+}
+
+type response struct {
+	JSONRPC string     `json:"jsonrpc"`
+	ID      int        `json:"id"`
+	Result  string     `json:"result,omitempty"`
+	Error   *respError `json:"error,omitempty"`
+}
+
+type respError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve calls handle for every event gowatchrun sends on stdin, one at a
+// time, and reports the results on stdout, until stdin is closed or the
+// process is told to stop.
+func Serve(handle HandlerFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	out := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			fmt.Fprintf(os.Stderr, "gohandler: invalid request: %v\n", err)
+			continue
+		}
+		resp := response{JSONRPC: "2.0", ID: req.ID, Result: "ok"}
+		if err := call(ctx, handle, req.Params); err != nil {
+			resp.Result = ""
+			resp.Error = &respError{Code: 1, Message: err.Error()}
+		}
+		if err := out.Encode(resp); err != nil {
+			fmt.Fprintf(os.Stderr, "gohandler: %v\n", err)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// call calls handle, turning a panic into an error so one bad event doesn't
+// take the handler down.
+func call(ctx context.Context, handle HandlerFunc, event Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handle(ctx, event)
+}
@@ -0,0 +1,163 @@
+package action
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	KindCopy   = "copy"
+	KindMove   = "move"
+	KindDelete = "delete"
+	KindZip    = "zip"
+	KindTarGz  = "targz"
+)
+
+// Kinds lists the built-in actions accepted by --action.
+var Kinds = []string{KindCopy, KindMove, KindDelete, KindZip, KindTarGz}
+
+// Valid reports whether kind names a built-in action.
+func Valid(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsDest reports whether the action requires a destination path.
+func NeedsDest(kind string) bool {
+	return kind != KindDelete
+}
+
+// Run performs the built-in action on src. dst is ignored for actions that don't need it.
+func Run(kind, src, dst string) error {
+	switch kind {
+	case KindCopy:
+		return Copy(src, dst)
+	case KindMove:
+		return Move(src, dst)
+	case KindDelete:
+		return Delete(src)
+	case KindZip:
+		return Zip(src, dst)
+	case KindTarGz:
+		return TarGz(src, dst)
+	default:
+		return fmt.Errorf("unknown action %q", kind)
+	}
+}
+
+// Copy copies the regular file src to dst, creating any missing parent
+// directories. The file mode and modification time are preserved.
+func Copy(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", src)
+	}
+
+	return writeAtomic(dst, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	}, func(tmpName string) error {
+		if err := os.Chmod(tmpName, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(tmpName, info.ModTime(), info.ModTime())
+	})
+}
+
+// Move moves src to dst, creating any missing parent directories. When a
+// plain rename isn't possible (e.g. across filesystems) it falls back to
+// copying and removing the source.
+func Move(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := Copy(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// Delete removes the file at path. A file that is already gone is not an error.
+func Delete(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// WaitStable blocks until the size and modification time of path have not
+// changed for the given window, which guards against acting on files that are
+// still being written. It returns an error if the file disappears while waiting.
+func WaitStable(path string, window time.Duration) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	stableSince := time.Now()
+	poll := window / 4
+	if poll < 50*time.Millisecond {
+		poll = 50 * time.Millisecond
+	}
+	for time.Since(stableSince) < window {
+		time.Sleep(poll)
+		current, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if current.Size() != info.Size() || !current.ModTime().Equal(info.ModTime()) {
+			info = current
+			stableSince = time.Now()
+		}
+	}
+	return nil
+}
+
+// writeAtomic creates dst by writing to a temporary file in the same directory
+// and renaming it into place, so a partially written file is never visible
+// under its final name. Missing parent directories are created. The optional
+// finish func runs on the closed temporary file before the rename.
+func writeAtomic(dst string, write func(w io.Writer) error, finish func(tmpName string) error) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if finish != nil {
+		if err := finish(tmpName); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmpName, dst)
+}
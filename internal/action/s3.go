@@ -0,0 +1,30 @@
+package action
+
+import (
+	"context"
+	"mime"
+	"path/filepath"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/s0up4200/gowatchrun/internal/remote"
+)
+
+// KindS3 is set by --s3-upload rather than --action, since it needs its own
+// connection settings.
+const KindS3 = "s3"
+
+// UploadS3 uploads the file at src to the bucket described by cfg under
+// cfg.Prefix + key.
+func UploadS3(ctx context.Context, cfg remote.S3Config, src, key string) error {
+	client, err := remote.NewS3Client(cfg)
+	if err != nil {
+		return err
+	}
+
+	opts := minio.PutObjectOptions{
+		ContentType: mime.TypeByExtension(filepath.Ext(src)),
+	}
+	_, err = client.FPutObject(ctx, cfg.Bucket, cfg.Prefix+key, src, opts)
+	return err
+}
@@ -0,0 +1,26 @@
+package action
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Signal sends sig to the process whose ID is in pidFile, as used to make
+// a daemon like nginx reload its configuration.
+func Signal(pidFile string, sig os.Signal) (int, error) {
+	raw, err := os.ReadFile(pidFile)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%s does not contain a process ID", pidFile)
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return pid, err
+	}
+	return pid, process.Signal(sig)
+}
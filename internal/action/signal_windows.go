@@ -0,0 +1,19 @@
+//go:build windows
+
+package action
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ParseSignal returns the signal named name. Windows can only kill
+// processes, so KILL (or 9) is the only one available.
+func ParseSignal(name string) (os.Signal, error) {
+	switch strings.TrimPrefix(strings.ToUpper(name), "SIG") {
+	case "KILL", "9":
+		return os.Kill, nil
+	}
+	return nil, fmt.Errorf("signal '%s' is not supported on Windows (only KILL is)", name)
+}
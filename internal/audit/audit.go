@@ -0,0 +1,180 @@
+// Package audit writes an append-only log of who did what and when: API
+// requests that pause, resume or trigger jobs, the configuration gowatchrun
+// started with, and every command it executed.
+package audit
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"os"
+	"os/user"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/s0up4200/gowatchrun/internal/secret"
+)
+
+// Actions of entries.
+const (
+	ActionStart        = "start"         // gowatchrun started
+	ActionJob          = "job"           // A job's configuration, at start
+	ActionStop         = "stop"          // gowatchrun is exiting
+	ActionPause        = "pause"         // An API client paused a job
+	ActionResume       = "resume"        // An API client resumed a job
+	ActionTrigger      = "trigger"       // An API client triggered a run
+	ActionExecStarted  = "exec_started"  // A command, request, signal or action was started
+	ActionExecFinished = "exec_finished" // It finished
+)
+
+// Actor is who caused an entry.
+type Actor struct {
+	Via  string `json:"via"`            // "process" for gowatchrun itself and its jobs, or the API: "http" or "grpc"
+	Addr string `json:"addr,omitempty"` // Address of the API client
+	User string `json:"user,omitempty"` // The OS user running gowatchrun, or the subject of an API client's certificate
+}
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time       time.Time         `json:"time"`
+	Action     string            `json:"action"`
+	Actor      Actor             `json:"actor"`
+	Job        string            `json:"job,omitempty"`
+	Exec       uint64            `json:"exec,omitempty"` // Pairs exec_started with exec_finished
+	Run        uint64            `json:"run,omitempty"`  // Run ID shown by the APIs
+	PID        int               `json:"pid,omitempty"`
+	Command    string            `json:"command,omitempty"` // As rendered for the execution
+	Event      string            `json:"event,omitempty"`
+	Files      []string          `json:"files,omitempty"`
+	ExitCode   *int              `json:"exit_code,omitempty"`
+	DurationMS *float64          `json:"duration_ms,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Details    map[string]string `json:"details,omitempty"`
+}
+
+// Log appends entries to a file as JSON lines, syncing every entry to disk.
+// A nil *Log records nothing.
+type Log struct {
+	mu    sync.Mutex
+	file  *os.File
+	execs atomic.Uint64
+	local Actor
+}
+
+// Open opens the audit log at path for appending, creating it if needed.
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	local := Actor{Via: "process"}
+	if u, err := user.Current(); err == nil {
+		local.User = u.Username
+	}
+	return &Log{file: file, local: local}, nil
+}
+
+// Close closes the audit log file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// Record appends entry, filling in the time and, when it has no actor,
+// gowatchrun itself, with secrets masked. Failures are logged and returned.
+func (l *Log) Record(entry Entry) error {
+	if l == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if entry.Actor.Via == "" {
+		entry.Actor = l.local
+	}
+	entry.Command = secret.Redact(entry.Command)
+	entry.Error = secret.Redact(entry.Error)
+	if entry.Files != nil {
+		entry.Files = slices.Clone(entry.Files)
+		for i, file := range entry.Files {
+			entry.Files[i] = secret.Redact(file)
+		}
+	}
+	if entry.Details != nil {
+		entry.Details = maps.Clone(entry.Details)
+		for key, value := range entry.Details {
+			entry.Details[key] = secret.Redact(value)
+		}
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err = l.file.Write(append(line, '\n')); err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		log.Error().Msgf("Failed to write audit log: %v", err)
+	}
+	return err
+}
+
+// Exec records entry as the start of an execution and returns the function
+// that records its end with the exit code and error.
+func (l *Log) Exec(entry Entry) func(exitCode int, err error) {
+	if l == nil {
+		return func(int, error) {}
+	}
+	entry.Action = ActionExecStarted
+	entry.Exec = l.execs.Add(1)
+	start := time.Now()
+	entry.Time = start
+	l.Record(entry)
+	return func(exitCode int, err error) {
+		duration := float64(time.Since(start).Microseconds()) / 1000
+		finished := Entry{
+			Action:     ActionExecFinished,
+			Job:        entry.Job,
+			Exec:       entry.Exec,
+			Run:        entry.Run,
+			PID:        entry.PID,
+			ExitCode:   &exitCode,
+			DurationMS: &duration,
+		}
+		if err != nil {
+			finished.Error = err.Error()
+		}
+		l.Record(finished)
+	}
+}
+
+// Request records a change an API client asked for: to pause, resume or
+// trigger job, with the file the run is for, and whether it failed.
+func (l *Log) Request(action string, actor Actor, job, path string, err error) {
+	entry := Entry{Action: action, Actor: actor, Job: job}
+	if path != "" {
+		entry.Files = []string{path}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	l.Record(entry)
+}
+
+// HTTPActor returns the client of r. Its user is the subject of its TLS
+// client certificate, if it sent one.
+func HTTPActor(r *http.Request) Actor {
+	actor := Actor{Via: "http", Addr: r.RemoteAddr}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		actor.User = r.TLS.PeerCertificates[0].Subject.String()
+	}
+	return actor
+}
@@ -0,0 +1,180 @@
+// Package auth authenticates the clients of gowatchrun's listening
+// endpoints with a bearer token, TLS client certificates (mTLS) or both.
+package auth
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// cookieName is the cookie a browser keeps the token in after it passed it
+// as ?token=, so pages and their WebSockets stay authenticated.
+const cookieName = "gowatchrun_token"
+
+// Config is how listening endpoints authenticate clients. The zero value
+// accepts everyone over plain text.
+type Config struct {
+	Token        string // Bearer token clients must send; empty disables token authentication
+	CertFile     string // Serve TLS with this certificate and key
+	KeyFile      string
+	ClientCAFile string   // Require client certificates signed by these CAs
+	Origins      []string // Other origins browser pages may use the endpoints from, as scheme://host[:port]
+
+	tls *tls.Config // Loaded by Load
+}
+
+// Load reads the token from tokenFile, if set, and the TLS certificates,
+// and checks that the settings fit together.
+func (c *Config) Load(tokenFile string) error {
+	if tokenFile != "" {
+		if c.Token != "" {
+			return errors.New("an auth token and an auth token file are mutually exclusive")
+		}
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read auth token: %w", err)
+		}
+		if c.Token = strings.TrimSpace(string(data)); c.Token == "" {
+			return fmt.Errorf("auth token file %s is empty", tokenFile)
+		}
+	}
+
+	for _, origin := range c.Origins {
+		if err := http.NewCrossOriginProtection().AddTrustedOrigin(origin); err != nil {
+			return fmt.Errorf("invalid allowed origin: %w", err)
+		}
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("a TLS certificate and key must be given together")
+	}
+	if c.CertFile == "" {
+		if c.ClientCAFile != "" {
+			return errors.New("verifying client certificates requires a TLS certificate and key")
+		}
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	c.tls = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA file %s", c.ClientCAFile)
+		}
+		c.tls.ClientCAs = pool
+		c.tls.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return nil
+}
+
+// TLSConfig returns the TLS configuration to serve with, or nil for plain
+// text.
+func (c Config) TLSConfig() *tls.Config {
+	if c.tls == nil {
+		return nil
+	}
+	return c.tls.Clone()
+}
+
+// Scheme returns "https" when serving TLS and "http" otherwise.
+func (c Config) Scheme() string {
+	if c.tls != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Listen listens for HTTP requests on addr, over TLS when configured.
+func (c Config) Listen(addr string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil || c.tls == nil {
+		return listener, err
+	}
+	tlsConfig := c.TLSConfig()
+	tlsConfig.NextProtos = []string{"http/1.1"}
+	return tls.NewListener(listener, tlsConfig), nil
+}
+
+// CrossOriginProtection returns protection against cross-origin requests
+// that trusts the configured origins.
+func (c Config) CrossOriginProtection() *http.CrossOriginProtection {
+	protection := http.NewCrossOriginProtection()
+	for _, origin := range c.Origins {
+		protection.AddTrustedOrigin(origin) // Checked by Load
+	}
+	return protection
+}
+
+// AllowsOrigin reports whether a browser page from origin may use the
+// endpoint r was sent to: when it's the endpoint's own origin or one of the
+// configured ones. Clients that send no origin aren't browsers, so a nil
+// origin is allowed.
+func (c Config) AllowsOrigin(origin *url.URL, r *http.Request) bool {
+	if origin == nil || strings.EqualFold(origin.Host, r.Host) {
+		return true
+	}
+	return slices.ContainsFunc(c.Origins, func(allowed string) bool {
+		return strings.EqualFold(allowed, origin.Scheme+"://"+origin.Host)
+	})
+}
+
+// Authenticates reports whether clients must authenticate, with the token
+// or a client certificate.
+func (c Config) Authenticates() bool {
+	return c.Token != "" || c.ClientCAFile != ""
+}
+
+// Valid reports whether token is the configured token. It's always true
+// without one.
+func (c Config) Valid(token string) bool {
+	return c.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) == 1
+}
+
+// Require wraps next to answer 401 Unauthorized to requests without the
+// token, sent as "Authorization: Bearer <token>", the token query parameter
+// or the cookie set when a browser passes the latter.
+func (c Config) Require(next http.Handler) http.Handler {
+	if c.Token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && c.Valid(bearer) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cookie, err := r.Cookie(cookieName); err == nil && c.Valid(cookie.Value) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token := r.URL.Query().Get("token"); token != "" && c.Valid(token) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookieName,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gowatchrun"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,191 @@
+package buildtool
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// lookPath returns the first of the given executables found on PATH.
+func lookPath(names ...string) (string, error) {
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("none of %s found in PATH", strings.Join(names, ", "))
+}
+
+// MakeCommand returns the shell command that builds target with make,
+// preferring GNU make when it's installed as gmake.
+func MakeCommand(target string) (string, error) {
+	path, err := lookPath("gmake", "make")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(path) + " " + strconv.Quote(target), nil
+}
+
+// TaskCommand returns the shell command that runs target with Task
+// (https://taskfile.dev), which some distributions ship as go-task.
+func TaskCommand(target string) (string, error) {
+	path, err := lookPath("task", "go-task")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(path) + " " + strconv.Quote(target), nil
+}
+
+// MakePrerequisites returns the source files target depends on, following
+// intermediate targets down to files that have no prerequisites of their own.
+// It reads make's database (make -p -n), so variables and pattern rules are
+// already expanded.
+func MakePrerequisites(target string) ([]string, error) {
+	path, err := lookPath("gmake", "make")
+	if err != nil {
+		return nil, err
+	}
+	// make exits non-zero with -q style checks; the database is printed regardless
+	out, _ := exec.Command(path, "-p", "-n", target).Output()
+	if len(out) == 0 {
+		return nil, fmt.Errorf("make printed no rule database for target '%s'", target)
+	}
+
+	rules := parseMakeRules(out)
+	if _, ok := rules[target]; !ok {
+		return nil, fmt.Errorf("no rule for make target '%s'", target)
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	var walk func(name string)
+	walk = func(name string) {
+		for _, prereq := range rules[name] {
+			if seen[prereq] {
+				continue
+			}
+			seen[prereq] = true
+			if len(rules[prereq]) > 0 {
+				walk(prereq)
+			} else if _, err := os.Stat(prereq); err == nil {
+				files = append(files, prereq)
+			}
+		}
+	}
+	walk(target)
+	return files, nil
+}
+
+// parseMakeRules extracts "target: prerequisites" lines from make's database.
+func parseMakeRules(db []byte) map[string][]string {
+	rules := make(map[string][]string)
+	scanner := bufio.NewScanner(bytes.NewReader(db))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == '\t' || line[0] == '#' || line[0] == ' ' || line[0] == '.' {
+			continue
+		}
+		idx := strings.IndexByte(line, ':')
+		if idx <= 0 {
+			continue
+		}
+		rest := line[idx+1:]
+		if strings.HasPrefix(rest, ":") || strings.HasPrefix(rest, "=") || strings.Contains(line[:idx], "=") {
+			continue // Double-colon rule or variable assignment
+		}
+		var prereqs []string
+		for _, field := range strings.Fields(rest) {
+			if field == "|" {
+				continue // Order-only prerequisites follow
+			}
+			prereqs = append(prereqs, field)
+		}
+		for _, target := range strings.Fields(line[:idx]) {
+			rules[target] = append(rules[target], prereqs...)
+		}
+	}
+	return rules
+}
+
+type taskfile struct {
+	Tasks map[string]struct {
+		Sources []string      `yaml:"sources"`
+		Deps    []interface{} `yaml:"deps"`
+	} `yaml:"tasks"`
+}
+
+// TaskSources returns the source globs declared for target (and the tasks it
+// depends on) in the Taskfile in the current directory.
+func TaskSources(target string) ([]string, error) {
+	var raw []byte
+	var err error
+	for _, name := range []string{"Taskfile.yml", "Taskfile.yaml", "taskfile.yml", "taskfile.yaml"} {
+		if raw, err = os.ReadFile(name); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("no Taskfile found in the current directory")
+	}
+
+	var tf taskfile
+	if err := yaml.Unmarshal(raw, &tf); err != nil {
+		return nil, fmt.Errorf("parsing Taskfile: %w", err)
+	}
+	if _, ok := tf.Tasks[target]; !ok {
+		return nil, fmt.Errorf("no task named '%s' in Taskfile", target)
+	}
+
+	var sources []string
+	seen := make(map[string]bool)
+	var walk func(name string)
+	walk = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		task := tf.Tasks[name]
+		sources = append(sources, task.Sources...)
+		for _, dep := range task.Deps {
+			switch d := dep.(type) {
+			case string:
+				walk(d)
+			case map[string]interface{}:
+				if name, ok := d["task"].(string); ok {
+					walk(name)
+				}
+			}
+		}
+	}
+	walk(target)
+	return sources, nil
+}
+
+// PatternsFor turns prerequisite files or source globs into file name
+// patterns, since patterns are matched against base names. It also reports
+// whether any of them lives below the current directory, in which case the
+// watch has to be recursive.
+func PatternsFor(paths []string) (patterns []string, nested bool) {
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		p = filepath.ToSlash(p)
+		if strings.Contains(strings.TrimPrefix(p, "./"), "/") {
+			nested = true
+		}
+		base := p[strings.LastIndexByte(p, '/')+1:]
+		if base == "" || seen[base] {
+			continue
+		}
+		seen[base] = true
+		patterns = append(patterns, base)
+	}
+	return patterns, nested
+}
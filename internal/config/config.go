@@ -0,0 +1,754 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/s0up4200/gowatchrun/internal/action"
+	"github.com/s0up4200/gowatchrun/internal/buildtool"
+	"github.com/s0up4200/gowatchrun/internal/gobuild"
+	"github.com/s0up4200/gowatchrun/internal/handler"
+	"github.com/s0up4200/gowatchrun/internal/manifest"
+	"github.com/s0up4200/gowatchrun/internal/plugin"
+	"github.com/s0up4200/gowatchrun/internal/problem"
+	"github.com/s0up4200/gowatchrun/internal/remote"
+	"github.com/s0up4200/gowatchrun/internal/sandbox"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+	"github.com/s0up4200/gowatchrun/internal/wsl"
+	"github.com/s0up4200/gowatchrun/middleware"
+)
+
+// defaultFailureMarkers is what fail_fast_output "default" matches: the
+// failure lines of go test, panics, Python tracebacks, the failure markers
+// of JavaScript test runners and compiler errors.
+const defaultFailureMarkers = `^\s*(--- FAIL|FAIL\b|panic: |fatal error: |Traceback \(most recent call last\)|(✕|✗|×|●) )|\b(error|Error|ERROR)(\[\w+\])?: `
+
+// File is the layout of a gowatchrun config file.
+type File struct {
+	Jobs []Job `yaml:"jobs"`
+}
+
+// Job holds the settings of one watch job. The command line flags populate a
+// single Job; a config file can define several.
+type Job struct {
+	Name          string   `yaml:"name"`
+	Preset        string   `yaml:"preset"`
+	Watch         []string `yaml:"watch"`
+	Exclude       []string `yaml:"exclude"`
+	Patterns      []string `yaml:"patterns"`
+	Events        []string `yaml:"events"`
+	Command       string   `yaml:"command"`
+	CommandFile   string   `yaml:"command_file"`    // File holding the command template, instead of command
+	Templates     string   `yaml:"templates"`       // Directory of *.tmpl files commands can include
+	Script        string   `yaml:"script"`          // File holding a script template, run from a temporary file
+	InlineScript  string   `yaml:"inline_script"`   // Script template, instead of script
+	Handler       string   `yaml:"handler"`         // Starlark script deciding what runs do with events
+	Shell         string   `yaml:"shell"`           // e.g. bash or pwsh; sh or cmd.exe by default
+	WSLInterop    bool     `yaml:"wsl_interop"`     // Run commands inside WSL on Windows, or on Windows inside WSL
+	Delims        string   `yaml:"template_delims"` // e.g. "[[,]]"
+	When          string   `yaml:"when"`            // Template that must render to "true" to run
+	Sidecar       string   `yaml:"require_sidecar"` // Template for a marker file that must exist before running
+	Make          string   `yaml:"make"`
+	Task          string   `yaml:"task"`
+	Restart       string   `yaml:"restart"`     // Long-running command restarted after every successful run
+	StdinPaths    bool     `yaml:"stdin_paths"` // Start the command once and write event paths to its stdin
+	Worker        bool     `yaml:"worker"`      // Start the command once and send it events over JSON-RPC
+	GoHandler     string   `yaml:"go_handler"`  // Go file compiled into a handler that is sent the events
+	WorkerTimeout string   `yaml:"worker_timeout"`
+	Action        string   `yaml:"action"`
+	Dest          string   `yaml:"dest"`
+	Signal        string   `yaml:"signal"` // Signal sent to the process in SignalPIDFile; HUP by default
+	SignalPIDFile string   `yaml:"signal_pid_file"`
+	HTTPAction    string   `yaml:"http_action"` // URL template
+	HTTPMethod    string   `yaml:"http_method"`
+	HTTPHeaders   []string `yaml:"http_headers"` // "Name: value" templates
+	HTTPBody      string   `yaml:"http_body"`
+	Recursive     bool     `yaml:"recursive"`
+	MaxWatches    int      `yaml:"max_watches"`
+	LazyWatch     int      `yaml:"lazy_watch"`     // Levels to watch at startup; deeper ones are watched on activity
+	FSEventsState string   `yaml:"fsevents_state"` // macOS: file keeping the FSEvents position between runs
+	IncludeHidden bool     `yaml:"include_hidden"`
+	K8sConfigMap  bool     `yaml:"k8s_configmap"`
+	Target        string   `yaml:"target"` // files, dirs or both
+	MinSize       string   `yaml:"min_size"`
+	MaxSize       string   `yaml:"max_size"`
+	MinAge        string   `yaml:"min_age"`
+	Mime          []string `yaml:"mime"`
+	Unicode       string   `yaml:"unicode_normalize"` // nfc, nfd or none; NFC on macOS by default
+	Delay         string   `yaml:"delay"`
+	DedupeWindow  string   `yaml:"dedupe_window"` // Repeats of an event within it are dropped; 0 disables
+	Settle        string   `yaml:"settle"`
+	Clear         string   `yaml:"clear"` // always, on-success or on-change
+	RunOnStart    bool     `yaml:"run_on_start"`
+
+	// IgnoreCommonNoise drops chmod-only events and editor/OS junk files.
+	// Unset means enabled.
+	IgnoreCommonNoise *bool `yaml:"ignore_common_noise"`
+
+	// Directories the files of a run are moved to after it succeeded or
+	// failed.
+	OnSuccessMove string `yaml:"on_success_move"`
+	OnFailureMove string `yaml:"on_failure_move"`
+
+	OnFailure   string `yaml:"on_failure"`  // Command template run after a failed run
+	OutputTail  string `yaml:"output_tail"` // e.g. 8KB; output kept for {{.OutputTail}}
+	LockDir     string `yaml:"lock_dir"`
+	Coordinator string `yaml:"coordinator"` // redis://host:port/db
+	LockWait    bool   `yaml:"lock_wait"`
+
+	GrepOutput string   `yaml:"grep_output"` // Regular expression output lines must match to be shown
+	Highlight  []string `yaml:"highlight"`   // Regular expressions whose matches are highlighted in output
+
+	FailFastOutput string `yaml:"fail_fast_output"` // "default" or a regular expression for the lines that mark a failure
+
+	ProblemMatchers []string `yaml:"problem_matchers"` // Built-in matcher names or JSON matcher files
+	DiagnosticsFile string   `yaml:"diagnostics_file"` // JSON file the diagnostics of every run are written to
+
+	// Manifest treats matched files as manifests listing payload files,
+	// which must all exist and be stable before the command runs.
+	Manifest        bool   `yaml:"manifest"`
+	ManifestKey     string `yaml:"manifest_key"`
+	ManifestTimeout string `yaml:"manifest_timeout"`
+
+	Batch     bool   `yaml:"batch"`
+	BatchSize int    `yaml:"batch_size"`
+	GroupBy   string `yaml:"group_by"` // "dir" batches events per directory
+
+	MaxTriggers int   `yaml:"max_triggers"`
+	OkExitCodes []int `yaml:"ok_exit_codes"` // Command exit codes treated as success, e.g. 130
+
+	SlowFactor float64 `yaml:"slow_factor"` // Warn about runs this many times slower than the median, e.g. 2
+
+	// Sandbox runs commands with no new privileges and a seccomp filter, and
+	// with SandboxReadOnly or SandboxWritable, a read-only filesystem except
+	// for the SandboxWritable paths (Linux only).
+	Sandbox         bool     `yaml:"sandbox"`
+	SandboxReadOnly bool     `yaml:"sandbox_read_only"`
+	SandboxWritable []string `yaml:"sandbox_writable"`
+
+	// Chroot runs commands with this directory as their root directory, in
+	// a private mount namespace where the ChrootBind paths are mounted
+	// read-only at the same path (Linux only).
+	Chroot     string   `yaml:"chroot"`
+	ChrootBind []string `yaml:"chroot_bind"`
+
+	// Plugins are gowatchrun-<name> executables on PATH: filters deciding
+	// whether a run goes ahead, an action run instead of a command, and
+	// notifiers told about the outcome of every run.
+	FilterPlugins []string `yaml:"filter_plugins"`
+	ActionPlugin  string   `yaml:"action_plugin"`
+	NotifyPlugins []string `yaml:"notify_plugins"`
+	Middleware    []string `yaml:"middleware"` // e.g. retry=3,2s; see package middleware
+
+	S3Upload string          `yaml:"s3_upload"`
+	S3Key    string          `yaml:"s3_key"`
+	S3       remote.S3Config `yaml:"s3"`
+
+	Source       string            `yaml:"source"`
+	PollInterval string            `yaml:"poll_interval"`
+	SFTP         remote.SFTPConfig `yaml:"sftp"`
+
+	ListenWebhook string `yaml:"listen_webhook"`
+	Every         string `yaml:"every"`
+	Cron          string `yaml:"cron"`
+
+	// PauseDuring holds events in these windows ("02:00-03:00" or a cron
+	// expression and a duration) until they end, or drops them when
+	// PauseMode is "drop".
+	PauseDuring []string `yaml:"pause_during"`
+	PauseMode   string   `yaml:"pause_mode"`
+
+	// DerivePatterns replaces the patterns with the source files of the make
+	// or task target.
+	DerivePatterns bool `yaml:"derive_patterns"`
+
+	DependsOn []string `yaml:"depends_on"`
+	RunIf     string   `yaml:"run_if"` // "success" (default) or "always"
+}
+
+// Load reads the config file at path and fills in defaults for every job.
+func Load(path string) (*File, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file File
+	if err := yaml.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(file.Jobs) == 0 {
+		return nil, fmt.Errorf("%s does not define any jobs", path)
+	}
+
+	names := make(map[string]bool)
+	for i := range file.Jobs {
+		job := &file.Jobs[i]
+		if err := job.prepare(i); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("%s: duplicate job name '%s'", path, job.Name)
+		}
+		names[job.Name] = true
+	}
+	return &file, nil
+}
+
+// prepare names the i-th job of a config file if it's unnamed and fills in
+// its preset and defaults.
+func (j *Job) prepare(i int) error {
+	if j.Name == "" {
+		j.Name = fmt.Sprintf("job%d", i+1)
+	}
+	if err := j.ApplyPreset(); err != nil {
+		return err
+	}
+	j.applyDefaults()
+	if j.RunIf != "success" && j.RunIf != "always" {
+		return j.errorf("invalid run_if '%s' (expected success or always)", j.RunIf)
+	}
+	return nil
+}
+
+// applyDefaults sets the same defaults the command line flags use for any
+// setting left empty in a config file.
+func (j *Job) applyDefaults() {
+	if len(j.Patterns) == 0 {
+		j.Patterns = []string{"*.*"}
+	}
+	if len(j.Events) == 0 {
+		j.Events = []string{"all"}
+	}
+	if j.S3Key == "" {
+		j.S3Key = "{{.Name}}"
+	}
+	if j.S3.Endpoint == "" {
+		j.S3.Endpoint = "s3.amazonaws.com"
+	}
+	if j.RunIf == "" {
+		j.RunIf = "success"
+	}
+}
+
+// Build validates the job and turns it into a watcher configuration.
+// When no watch directories are set, the current directory is watched unless
+// another event source (remote, webhook or schedule) is configured or the job
+// is only triggered by its dependencies.
+func (j Job) Build() (watcher.Config, error) {
+	command, err := j.commandTemplate()
+	if err != nil {
+		return watcher.Config{}, err
+	}
+	j.Command = command
+	script, err := j.scriptTemplate()
+	if err != nil {
+		return watcher.Config{}, err
+	}
+
+	cfg := watcher.Config{
+		Name:          j.Name,
+		ExcludeDirs:   j.Exclude,
+		Patterns:      j.Patterns,
+		EventTypes:    j.Events,
+		CommandTmpl:   j.Command,
+		Shell:         j.Shell,
+		WSLInterop:    j.WSLInterop,
+		When:          j.When,
+		SidecarTmpl:   j.Sidecar,
+		Action:        j.Action,
+		ActionDest:    j.Dest,
+		S3:            j.S3,
+		Recursive:     j.Recursive,
+		LazyWatch:     j.LazyWatch,
+		MaxWatches:    j.MaxWatches,
+		FSEventsState: j.FSEventsState,
+		WebhookAddr:   j.ListenWebhook,
+		IncludeHidden: j.IncludeHidden,
+		K8sConfigMap:  j.K8sConfigMap,
+		Target:        j.Target,
+		MimeTypes:     j.Mime,
+		SniffMime:     j.Worker,
+		IgnoreNoise:   j.IgnoreCommonNoise == nil || *j.IgnoreCommonNoise,
+		Batch:         j.Batch || j.BatchSize > 0 || j.GroupBy != "",
+		BatchSize:     j.BatchSize,
+		GroupBy:       j.GroupBy,
+		Manifest:      j.Manifest,
+		OnSuccessMove: j.OnSuccessMove,
+		OnFailureMove: j.OnFailureMove,
+		OnFailure:     j.OnFailure,
+		LockDir:       j.LockDir,
+		Coordinator:   j.Coordinator,
+		LockWait:      j.LockWait,
+		ManifestKey:   j.ManifestKey,
+		MaxTriggers:   j.MaxTriggers,
+		OkExitCodes:   j.OkExitCodes,
+		SlowFactor:    j.SlowFactor,
+		Cron:          j.Cron,
+		Source: remote.SourceConfig{
+			URL:  j.Source,
+			S3:   j.S3,
+			SFTP: j.SFTP,
+		},
+	}
+
+	set := 0
+	for _, v := range []string{j.Command, script, j.Make, j.Task, j.Action, j.S3Upload, j.SignalPIDFile, j.HTTPAction, j.ActionPlugin, j.GoHandler} {
+		if v != "" {
+			set++
+		}
+	}
+	if set == 0 {
+		return cfg, j.errorf("a command, script, make or task target, action, S3 upload target, signal PID file, HTTP action, action plugin or Go handler is required")
+	}
+	if set > 1 {
+		return cfg, j.errorf("command, script, make, task, action, S3 upload, signal, HTTP action, action plugin and Go handler are mutually exclusive")
+	}
+	if script != "" {
+		if j.WSLInterop || j.Chroot != "" {
+			return cfg, j.errorf("scripts can't run with wsl interop or in a chroot")
+		}
+		cfg.CommandTmpl = script
+		cfg.Script = true
+	}
+	if j.HTTPAction != "" {
+		cfg.HTTPURL = j.HTTPAction
+		cfg.HTTPMethod = strings.ToUpper(j.HTTPMethod)
+		if cfg.HTTPMethod == "" {
+			cfg.HTTPMethod = http.MethodPost
+		}
+		for _, header := range j.HTTPHeaders {
+			if name, _, ok := strings.Cut(header, ":"); !ok || strings.TrimSpace(name) == "" {
+				return cfg, j.errorf("invalid HTTP header '%s' (expected 'Name: value')", header)
+			}
+		}
+		cfg.HTTPHeaders = j.HTTPHeaders
+		cfg.HTTPBody = j.HTTPBody
+	} else if j.HTTPMethod != "" || len(j.HTTPHeaders) > 0 || j.HTTPBody != "" {
+		return cfg, j.errorf("HTTP method, headers and body require an HTTP action")
+	}
+	for _, name := range slices.Concat(j.FilterPlugins, j.NotifyPlugins, []string{j.ActionPlugin}) {
+		if name == "" {
+			continue
+		}
+		if _, err := plugin.Find(name); err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+	}
+	if j.ActionPlugin != "" && (j.Sandbox || j.Chroot != "") {
+		return cfg, j.errorf("action plugins don't run in the sandbox or chroot")
+	}
+	cfg.FilterPlugins = j.FilterPlugins
+	cfg.ActionPlugin = j.ActionPlugin
+	cfg.NotifyPlugins = j.NotifyPlugins
+	for _, spec := range j.Middleware {
+		m, err := middleware.Parse(spec)
+		if err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+		cfg.Middleware = append(cfg.Middleware, m)
+	}
+	if j.Signal != "" && j.SignalPIDFile == "" {
+		return cfg, j.errorf("signal requires a PID file to send it to")
+	}
+	if j.SignalPIDFile != "" {
+		cfg.Signal = j.Signal
+		if cfg.Signal == "" {
+			cfg.Signal = "HUP"
+		}
+		if _, err := action.ParseSignal(cfg.Signal); err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+		cfg.SignalPIDFile = j.SignalPIDFile
+	}
+	if j.BatchSize < 0 {
+		return cfg, j.errorf("batch size must not be negative")
+	}
+	if j.MaxWatches < 0 {
+		return cfg, j.errorf("max watches must not be negative")
+	}
+	if j.FSEventsState != "" {
+		if err := watcher.CheckFSEvents(); err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+	}
+	if j.LazyWatch < 0 {
+		return cfg, j.errorf("lazy watch depth must not be negative")
+	}
+	if j.MaxTriggers < 0 {
+		return cfg, j.errorf("max triggers must not be negative")
+	}
+	if j.SlowFactor != 0 && j.SlowFactor <= 1 {
+		return cfg, j.errorf("slow factor must be greater than 1")
+	}
+	if j.StdinPaths && j.Command == "" {
+		return cfg, j.errorf("stdin paths requires a command")
+	}
+	if j.StdinPaths && j.Restart != "" {
+		return cfg, j.errorf("stdin paths and restart are mutually exclusive")
+	}
+	if j.Worker && j.Command == "" {
+		return cfg, j.errorf("worker requires a command")
+	}
+	if j.Worker && (j.StdinPaths || j.Restart != "") {
+		return cfg, j.errorf("worker, stdin paths and restart are mutually exclusive")
+	}
+	if j.GrepOutput != "" {
+		if cfg.GrepOutput, err = regexp.Compile(j.GrepOutput); err != nil {
+			return cfg, j.errorf("invalid grep output expression: %v", err)
+		}
+	}
+	if len(j.Highlight) > 0 {
+		alternatives := make([]string, len(j.Highlight))
+		for i, expr := range j.Highlight {
+			if _, err := regexp.Compile(expr); err != nil {
+				return cfg, j.errorf("invalid highlight expression '%s': %v", expr, err)
+			}
+			alternatives[i] = "(?:" + expr + ")"
+		}
+		cfg.Highlight = regexp.MustCompile(strings.Join(alternatives, "|"))
+	}
+	if marker := j.FailFastOutput; marker != "" {
+		if marker == "default" {
+			marker = defaultFailureMarkers
+		}
+		if cfg.FailFastOutput, err = regexp.Compile(marker); err != nil {
+			return cfg, j.errorf("invalid fail fast output expression: %v", err)
+		}
+	}
+	for _, name := range j.ProblemMatchers {
+		matchers, err := problem.Load(name)
+		if err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+		cfg.ProblemMatchers = append(cfg.ProblemMatchers, matchers...)
+	}
+	if j.DiagnosticsFile != "" && len(j.ProblemMatchers) == 0 {
+		return cfg, j.errorf("diagnostics file requires a problem matcher")
+	}
+	if len(j.ProblemMatchers) > 0 && (j.StdinPaths || j.Worker || j.GoHandler != "") {
+		return cfg, j.errorf("problem matchers don't apply to stdin paths, worker or Go handler jobs")
+	}
+	cfg.DiagnosticsFile = j.DiagnosticsFile
+	if j.OnFailure != "" && (j.StdinPaths || j.Worker || j.GoHandler != "") {
+		return cfg, j.errorf("on failure hooks don't run for stdin paths, worker or Go handler jobs")
+	}
+	if j.GoHandler != "" {
+		if j.Handler != "" || j.Restart != "" || j.WSLInterop || j.Chroot != "" {
+			return cfg, j.errorf("a Go handler can't be combined with a handler script, restart, wsl interop or chroot")
+		}
+		if err := gobuild.Check(); err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+		cfg.GoHandler = j.GoHandler
+	}
+	if j.DerivePatterns && j.Make == "" && j.Task == "" {
+		return cfg, j.errorf("derive patterns requires a make or task target")
+	}
+	if j.WSLInterop {
+		if err := wsl.Check(); err != nil {
+			return cfg, j.errorf("wsl interop: %v", err)
+		}
+	} else if j.Shell != "" {
+		if _, err := exec.LookPath(j.Shell); err != nil {
+			return cfg, j.errorf("shell: %v", err)
+		}
+	}
+	if j.Sandbox || j.Chroot != "" {
+		profile, err := j.sandboxProfile()
+		if err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+		if err := sandbox.Check(profile); err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+		cfg.Sandbox = &profile
+	}
+	if !j.Sandbox && (j.SandboxReadOnly || len(j.SandboxWritable) > 0) {
+		return cfg, j.errorf("sandbox read-only and writable paths require the sandbox")
+	}
+	if j.Chroot == "" && len(j.ChrootBind) > 0 {
+		return cfg, j.errorf("chroot bind paths require a chroot")
+	}
+
+	if j.Make != "" || j.Task != "" {
+		if err := j.buildTool(&cfg); err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+	}
+
+	if j.S3Upload != "" {
+		bucket, prefix, err := remote.ParseS3Target(j.S3Upload)
+		if err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+		cfg.S3.Bucket = bucket
+		cfg.S3.Prefix = prefix
+		cfg.Action = action.KindS3
+		cfg.ActionDest = j.S3Key
+	} else if j.Action != "" {
+		if !action.Valid(j.Action) {
+			return cfg, j.errorf("invalid action '%s'; valid actions: %s", j.Action, strings.Join(action.Kinds, ", "))
+		}
+		if action.NeedsDest(j.Action) && j.Dest == "" {
+			return cfg, j.errorf("action %s requires a destination", j.Action)
+		}
+	}
+
+	if j.ListenWebhook != "" {
+		if _, _, err := watcher.ParseListenAddr(j.ListenWebhook); err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+	}
+	if j.Cron != "" {
+		if _, err := watcher.ParseCron(j.Cron); err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+	}
+	for _, spec := range j.PauseDuring {
+		window, err := watcher.ParsePauseWindow(spec)
+		if err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+		cfg.PauseDuring = append(cfg.PauseDuring, window)
+	}
+	switch j.PauseMode {
+	case "", "queue":
+	case "drop":
+		cfg.PauseDrop = true
+	default:
+		return cfg, j.errorf("invalid pause mode %q: use queue or drop", j.PauseMode)
+	}
+
+	for _, size := range []struct {
+		name  string
+		value string
+		dst   *int64
+	}{{"min-size", j.MinSize, &cfg.MinSize}, {"max-size", j.MaxSize, &cfg.MaxSize}, {"output-tail", j.OutputTail, &cfg.OutputTail}} {
+		if size.value == "" {
+			continue
+		}
+		n, err := parseSize(size.value)
+		if err != nil {
+			return cfg, j.errorf("--%s: %v", size.name, err)
+		}
+		*size.dst = n
+	}
+	if cfg.MaxSize > 0 && cfg.MinSize > cfg.MaxSize {
+		return cfg, j.errorf("--min-size is larger than --max-size")
+	}
+
+	if j.Handler != "" {
+		if j.StdinPaths || j.Worker {
+			return cfg, j.errorf("a handler can't be combined with stdin paths or worker")
+		}
+		if cfg.Handler, err = handler.Load(j.Handler); err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+	}
+
+	delims, err := j.templateDelims()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.TemplateDelims = delims
+	if j.Templates != "" {
+		if cfg.Templates, err = watcher.LoadTemplates(j.Templates); err != nil {
+			return cfg, j.errorf("%v", err)
+		}
+		cfg.TemplatesDir = j.Templates
+	}
+
+	if cfg.Clear, err = watcher.ParseClearMode(j.Clear); err != nil {
+		return cfg, j.errorf("%v", err)
+	}
+
+	form, err := watcher.ParseUnicodeForm(j.Unicode)
+	if err != nil {
+		return cfg, j.errorf("%v", err)
+	}
+	cfg.UnicodeForm = form
+
+	for _, spec := range j.Watch {
+		dir, pattern := watcher.ParseWatchDir(spec)
+		if !slices.Contains(cfg.WatchDirs, dir) {
+			cfg.WatchDirs = append(cfg.WatchDirs, dir)
+		}
+		if pattern != "" {
+			if cfg.RootPatterns == nil {
+				cfg.RootPatterns = make(map[string][]string)
+			}
+			cfg.RootPatterns[dir] = append(cfg.RootPatterns[dir], pattern)
+		}
+	}
+
+	// Files moved after a run, and lock files, must not trigger it again
+	for _, dir := range []string{j.OnSuccessMove, j.OnFailureMove, j.LockDir} {
+		if abs, err := filepath.Abs(dir); err == nil && dir != "" && !slices.Contains(cfg.ExcludeDirs, abs) {
+			cfg.ExcludeDirs = append(cfg.ExcludeDirs, abs)
+		}
+	}
+
+	cfg.DebounceDelay = j.duration("delay", j.Delay, 0)
+	cfg.DedupeWindow = j.duration("dedupe-window", j.DedupeWindow, watcher.DefaultDedupeWindow)
+	cfg.MinAge = j.duration("min-age", j.MinAge, 0)
+	cfg.SettleDelay = j.duration("settle", j.Settle, 0)
+	cfg.WorkerTimeout = j.duration("worker-timeout", j.WorkerTimeout, 0)
+	cfg.ManifestTimeout = j.duration("manifest-timeout", j.ManifestTimeout, manifest.DefaultTimeout)
+	cfg.Every = j.duration("every", j.Every, 0)
+	cfg.Source.PollInterval = j.duration("poll-interval", j.PollInterval, 30*time.Second)
+
+	if len(cfg.WatchDirs) == 0 && len(j.DependsOn) == 0 && cfg.Source.URL == "" && cfg.WebhookAddr == "" && cfg.Every == 0 && cfg.Cron == "" {
+		cfg.WatchDirs = []string{"."}
+	}
+	return cfg, nil
+}
+
+// buildTool sets the command for a make or task target and, when requested,
+// derives the watch patterns from the target's sources.
+func (j Job) buildTool(cfg *watcher.Config) error {
+	var (
+		sources []string
+		err     error
+	)
+	if j.Make != "" {
+		if cfg.CommandTmpl, err = buildtool.MakeCommand(j.Make); err != nil {
+			return err
+		}
+		if j.DerivePatterns {
+			sources, err = buildtool.MakePrerequisites(j.Make)
+		}
+	} else {
+		if cfg.CommandTmpl, err = buildtool.TaskCommand(j.Task); err != nil {
+			return err
+		}
+		if j.DerivePatterns {
+			sources, err = buildtool.TaskSources(j.Task)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !j.DerivePatterns {
+		return nil
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("target has no source files to derive patterns from")
+	}
+
+	patterns, nested := buildtool.PatternsFor(sources)
+	log.Debug().Msgf("Derived patterns %v from target sources %v", patterns, sources)
+	cfg.Patterns = patterns
+	cfg.Recursive = cfg.Recursive || nested
+	return nil
+}
+
+// sandboxProfile returns the profile for the job's sandbox and chroot
+// settings, with absolute paths.
+func (j Job) sandboxProfile() (sandbox.Profile, error) {
+	profile := sandbox.Profile{
+		Filter:   j.Sandbox,
+		ReadOnly: j.Sandbox && (j.SandboxReadOnly || len(j.SandboxWritable) > 0),
+	}
+	var err error
+	for _, path := range j.SandboxWritable {
+		if path, err = filepath.Abs(path); err != nil {
+			return profile, err
+		}
+		profile.Writable = append(profile.Writable, path)
+	}
+	if j.Chroot != "" {
+		if profile.Root, err = filepath.Abs(j.Chroot); err != nil {
+			return profile, err
+		}
+	}
+	for _, path := range j.ChrootBind {
+		if path, err = filepath.Abs(path); err != nil {
+			return profile, err
+		}
+		profile.Binds = append(profile.Binds, path)
+	}
+	return profile, nil
+}
+
+// commandTemplate returns the command template, read from the command file
+// when one is set. The file's trailing newline isn't part of the command.
+func (j Job) commandTemplate() (string, error) {
+	if j.CommandFile == "" {
+		return j.Command, nil
+	}
+	if j.Command != "" {
+		return "", j.errorf("command and command file are mutually exclusive")
+	}
+	raw, err := os.ReadFile(j.CommandFile)
+	if err != nil {
+		return "", j.errorf("failed to read the command file: %v", err)
+	}
+	return strings.TrimRight(string(raw), "\r\n"), nil
+}
+
+// scriptTemplate returns the script template, read from the script file
+// when one is set.
+func (j Job) scriptTemplate() (string, error) {
+	if j.Script == "" {
+		return j.InlineScript, nil
+	}
+	if j.InlineScript != "" {
+		return "", j.errorf("script and inline script are mutually exclusive")
+	}
+	raw, err := os.ReadFile(j.Script)
+	if err != nil {
+		return "", j.errorf("failed to read the script: %v", err)
+	}
+	return string(raw), nil
+}
+
+// templateDelims parses the template_delims setting, "left,right". The zero
+// value selects the default {{ and }}.
+func (j Job) templateDelims() ([2]string, error) {
+	if j.Delims == "" {
+		return [2]string{}, nil
+	}
+	left, right, ok := strings.Cut(j.Delims, ",")
+	left, right = strings.TrimSpace(left), strings.TrimSpace(right)
+	if !ok || left == "" || right == "" {
+		return [2]string{}, j.errorf("invalid template delimiters '%s' (expected 'left,right', e.g. '[[,]]')", j.Delims)
+	}
+	return [2]string{left, right}, nil
+}
+
+func (j Job) errorf(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if j.Name == "" {
+		return fmt.Errorf("%s", msg)
+	}
+	return fmt.Errorf("job '%s': %s", j.Name, msg)
+}
+
+// duration parses a duration setting, falling back to def (with a warning)
+// when it's empty, invalid or negative.
+func (j Job) duration(name, value string, def time.Duration) time.Duration {
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Warn().Msgf("Invalid --%s duration '%s', defaulting to %s. Error: %v", name, value, def, err)
+		return def
+	}
+	if d < 0 {
+		log.Warn().Msgf("--%s duration '%s' is negative, defaulting to %s.", name, value, def)
+		return def
+	}
+	return d
+}
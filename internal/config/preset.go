@@ -0,0 +1,70 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+//go:embed presets.yaml
+var presetsYAML []byte
+
+// presets holds ready-made job settings for common project types, loaded
+// from the embedded presets.yaml.
+var presets = func() map[string]Job {
+	var m map[string]Job
+	if err := yaml.Unmarshal(presetsYAML, &m); err != nil {
+		panic(fmt.Sprintf("invalid embedded presets: %v", err))
+	}
+	return m
+}()
+
+// PresetNames returns the names of the available presets.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyPreset fills the settings the job leaves empty from its preset.
+// Recursive and RunOnStart are switched on when the preset enables them.
+func (j *Job) ApplyPreset() error {
+	if j.Preset == "" {
+		return nil
+	}
+	preset, ok := presets[j.Preset]
+	if !ok {
+		return j.errorf("unknown preset '%s'; available presets: %s", j.Preset, strings.Join(PresetNames(), ", "))
+	}
+
+	if len(j.Patterns) == 0 {
+		j.Patterns = preset.Patterns
+	}
+	if len(j.Exclude) == 0 {
+		j.Exclude = preset.Exclude
+	}
+	j.Recursive = j.Recursive || preset.Recursive
+	j.RunOnStart = j.RunOnStart || preset.RunOnStart
+	if j.Command == "" && j.CommandFile == "" && j.Script == "" && j.InlineScript == "" && j.Make == "" && j.Task == "" && j.Action == "" && j.S3Upload == "" && j.SignalPIDFile == "" && j.HTTPAction == "" && j.ActionPlugin == "" && j.GoHandler == "" {
+		j.Command = preset.Command
+	}
+	if j.Delay == "" {
+		j.Delay = preset.Delay
+	}
+	if j.Restart == "" {
+		j.Restart = preset.Restart
+	}
+	return nil
+}
+
+// LookupPreset returns the settings of the named preset.
+func LookupPreset(name string) (Job, bool) {
+	preset, ok := presets[name]
+	return preset, ok
+}
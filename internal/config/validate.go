@@ -0,0 +1,226 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/s0up4200/gowatchrun/internal/scheduler"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// Problem is an issue found by Validate. Line is the line of the config file
+// the problem was found on, or 0 when it doesn't belong to a single line.
+type Problem struct {
+	Line int
+	Err  error
+}
+
+// Validate checks the config file at path without running anything: it
+// parses the file, compiles all templates and patterns, resolves watch
+// directories, checks event types against the current platform and
+// validates the job dependency graph. Unlike Load it reports every problem
+// it finds instead of stopping at the first one. The returned error is only
+// set when the file can't be read.
+func Validate(path string) ([]Problem, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return []Problem{{Line: yamlErrorLine(err), Err: err}}, nil
+	}
+
+	var problems []Problem
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	var strict File
+	if err := dec.Decode(&strict); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			for _, e := range typeErr.Errors {
+				line := yamlErrorLine(errors.New(e))
+				problems = append(problems, Problem{Line: line, Err: errors.New(strings.TrimPrefix(e, fmt.Sprintf("line %d: ", line)))})
+			}
+		} else {
+			problems = append(problems, Problem{Err: err})
+		}
+	}
+
+	var file File
+	if err := root.Decode(&file); err != nil {
+		return problems, nil // Already reported by the strict decode
+	}
+	if len(file.Jobs) == 0 {
+		return append(problems, Problem{Line: 1, Err: errors.New("no jobs defined")}), nil
+	}
+	jobNodes := findJobNodes(&root)
+
+	names := make(map[string]bool)
+	var nodes []scheduler.Job
+	for i := range file.Jobs {
+		job := &file.Jobs[i]
+		node := nodeAt(jobNodes, i)
+		add := func(key string, err error) {
+			problems = append(problems, Problem{Line: keyLine(node, key), Err: err})
+		}
+
+		if err := job.prepare(i); err != nil {
+			add("", err)
+			continue
+		}
+		if names[job.Name] {
+			add("name", job.errorf("duplicate job name"))
+		}
+		names[job.Name] = true
+
+		delims, err := job.templateDelims()
+		if err != nil {
+			add("template_delims", err)
+		}
+		templates := watcher.Config{TemplateDelims: delims}
+		if job.Templates != "" {
+			if templates.Templates, err = watcher.LoadTemplates(job.Templates); err != nil {
+				add("templates", job.errorf("%v", err))
+			}
+		}
+		command, err := job.commandTemplate()
+		if err != nil {
+			add("command_file", err)
+		}
+		script, err := job.scriptTemplate()
+		if err != nil {
+			add("script", err)
+		}
+		for key, text := range map[string]string{"command": command, "script": script, "dest": job.Dest, "s3_key": job.S3Key, "when": job.When, "require_sidecar": job.Sidecar, "http_action": job.HTTPAction, "http_body": job.HTTPBody, "on_failure": job.OnFailure} {
+			if text == "" {
+				continue
+			}
+			tmpl, err := watcher.ParseTemplate(templates, key, text)
+			if err == nil {
+				err = watcher.CheckTemplate(tmpl)
+			}
+			if err != nil {
+				add(key, job.errorf("invalid %s template: %v", key, err))
+			}
+		}
+		for _, pattern := range job.Patterns {
+			if err := watcher.CheckPattern(pattern); err != nil {
+				add("patterns", job.errorf("invalid pattern '%s': %v", pattern, err))
+			}
+		}
+		for _, pattern := range job.Mime {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				add("mime", job.errorf("invalid media type pattern '%s': %v", pattern, err))
+			}
+		}
+		for _, t := range job.Events {
+			if err := watcher.CheckEventType(t); err != nil {
+				add("events", job.errorf("%v", err))
+			}
+		}
+		for _, spec := range job.Watch {
+			dir, pattern := watcher.ParseWatchDir(spec)
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				add("watch", job.errorf("invalid pattern '%s' for %s: %v", pattern, dir, err))
+			}
+			if info, err := os.Stat(dir); err != nil {
+				add("watch", job.errorf("watch directory '%s': %v", dir, err))
+			} else if !info.IsDir() {
+				add("watch", job.errorf("watch path '%s' is not a directory", dir))
+			}
+		}
+		for key, value := range map[string]string{"delay": job.Delay, "settle": job.Settle, "every": job.Every, "poll_interval": job.PollInterval, "min_age": job.MinAge, "manifest_timeout": job.ManifestTimeout} {
+			if value == "" {
+				continue
+			}
+			if d, err := time.ParseDuration(value); err != nil {
+				add(key, job.errorf("invalid %s duration '%s'", key, value))
+			} else if d < 0 {
+				add(key, job.errorf("%s duration '%s' is negative", key, value))
+			}
+		}
+
+		cfg, err := job.Build()
+		if err != nil {
+			add("", err)
+			continue
+		}
+		nodes = append(nodes, scheduler.Job{Config: cfg, DependsOn: job.DependsOn})
+	}
+
+	unknownDeps := false
+	for i, job := range file.Jobs {
+		for _, dep := range job.DependsOn {
+			if !names[dep] {
+				unknownDeps = true
+				problems = append(problems, Problem{Line: keyLine(nodeAt(jobNodes, i), "depends_on"), Err: job.errorf("depends on unknown job '%s'", dep)})
+			}
+		}
+	}
+	if !unknownDeps && len(nodes) == len(file.Jobs) {
+		if _, err := scheduler.New(nodes, nil); err != nil {
+			problems = append(problems, Problem{Err: err})
+		}
+	}
+	sort.SliceStable(problems, func(a, b int) bool {
+		return problems[a].Line != 0 && (problems[b].Line == 0 || problems[a].Line < problems[b].Line)
+	})
+	return problems, nil
+}
+
+// findJobNodes returns the mapping node of every entry in the jobs list.
+func findJobNodes(root *yaml.Node) []*yaml.Node {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil
+	}
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value == "jobs" && doc.Content[i+1].Kind == yaml.SequenceNode {
+			return doc.Content[i+1].Content
+		}
+	}
+	return nil
+}
+
+func nodeAt(nodes []*yaml.Node, i int) *yaml.Node {
+	if i < len(nodes) {
+		return nodes[i]
+	}
+	return nil
+}
+
+// keyLine returns the line of key in the mapping node, falling back to the
+// line of the node itself.
+func keyLine(node *yaml.Node, key string) int {
+	if node == nil {
+		return 0
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i].Line
+		}
+	}
+	return node.Line
+}
+
+// yamlErrorLine extracts the line number from a yaml error message.
+func yamlErrorLine(err error) int {
+	var line int
+	msg := err.Error()
+	for i := 0; i < len(msg); i++ {
+		if n, _ := fmt.Sscanf(msg[i:], "line %d", &line); n == 1 {
+			return line
+		}
+	}
+	return 0
+}
@@ -0,0 +1,26 @@
+package executor
+
+import (
+	"os"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// shouldClear reports whether the terminal is cleared before a run of job.
+func shouldClear(mode, job string, data *watcher.EventData) bool {
+	switch mode {
+	case watcher.ClearAlways:
+		return true
+	case watcher.ClearOnSuccess:
+		return !lastFailed(job)
+	case watcher.ClearOnChange:
+		return data != nil && data.Event != "WEBHOOK" && data.Event != "TIMER"
+	}
+	return false
+}
+
+// clearTerminal clears the screen and scrollback and moves the cursor home.
+func clearTerminal() {
+	enableVirtualTerminal()
+	os.Stdout.WriteString("\033[H\033[2J\033[3J")
+}
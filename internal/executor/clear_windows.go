@@ -0,0 +1,24 @@
+//go:build windows
+
+package executor
+
+import (
+	"os"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+var enableVTOnce sync.Once
+
+// enableVirtualTerminal turns on ANSI escape code processing for the console,
+// which older Windows consoles don't enable by default.
+func enableVirtualTerminal() {
+	enableVTOnce.Do(func() {
+		handle := windows.Handle(os.Stdout.Fd())
+		var mode uint32
+		if windows.GetConsoleMode(handle, &mode) == nil {
+			windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/problem"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// maxReported is how many diagnostics the summary after a run lists.
+const maxReported = 20
+
+// problemCollector returns the collector for cfg's --problem-matcher, or
+// nil without one.
+func problemCollector(cfg watcher.Config) *problem.Collector {
+	if len(cfg.ProblemMatchers) == 0 {
+		return nil
+	}
+	return problem.NewCollector(cfg.ProblemMatchers)
+}
+
+// reportDiagnostics summarizes what problems found in the output of the run
+// that ended with result, sets result's diagnostics and writes them to
+// cfg's --diagnostics-file.
+func reportDiagnostics(cfg watcher.Config, problems *problem.Collector, result *watcher.ExecutionResult) {
+	logger := cfg.Logger()
+	diagnostics, dropped := problems.Diagnostics()
+	// Editors open files by absolute path; commands run in the working directory
+	for i := range diagnostics {
+		if abs, err := filepath.Abs(diagnostics[i].File); err == nil {
+			diagnostics[i].File = abs
+		}
+	}
+	result.Diagnostics = diagnostics
+
+	if len(diagnostics) > 0 {
+		errors, warnings, infos := problem.Count(diagnostics)
+		event := logger.Info()
+		if errors > 0 {
+			event = logger.Error()
+		} else if warnings > 0 {
+			event = logger.Warn()
+		}
+		event.Int("errors", errors).Int("warnings", warnings).Int("infos", infos).
+			Msgf("Found %d error(s), %d warning(s)", errors, warnings)
+		for i, d := range diagnostics {
+			if i == maxReported {
+				logger.Info().Msgf("... and %d more", len(diagnostics)-maxReported+dropped)
+				break
+			}
+			logger.Info().Msgf("  %s", d)
+		}
+	}
+
+	if cfg.DiagnosticsFile == "" {
+		return
+	}
+	report := problem.NewReport(cfg.Name, time.Now(), result.ExitCode, diagnostics)
+	report.Dropped = dropped
+	raw, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		logger.Error().Msgf("Failed to encode diagnostics: %v", err)
+		return
+	}
+	if err := watcher.WriteFileAtomic(cfg.DiagnosticsFile, append(raw, '\n')); err != nil {
+		logger.Error().Msgf("Failed to write diagnostics file: %v", err)
+	}
+}
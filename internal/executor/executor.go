@@ -2,72 +2,354 @@ package executor
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"runtime"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"text/template"
 	"time"
 
-	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/s0up4200/gowatchrun/internal/action"
+	"github.com/s0up4200/gowatchrun/internal/audit"
+	"github.com/s0up4200/gowatchrun/internal/manifest"
+	"github.com/s0up4200/gowatchrun/internal/problem"
+	"github.com/s0up4200/gowatchrun/internal/sandbox"
+	"github.com/s0up4200/gowatchrun/internal/secret"
+	"github.com/s0up4200/gowatchrun/internal/shell"
 	"github.com/s0up4200/gowatchrun/internal/watcher"
+	"github.com/s0up4200/gowatchrun/internal/worker"
+	"github.com/s0up4200/gowatchrun/internal/wsl"
 )
 
-func Execute(cfg watcher.Config, data *watcher.EventData) {
-	var templateData interface{}
+// errSkipped is returned by execute when nothing ran for the event.
+var errSkipped = errors.New("skipped")
+
+// Execute runs the configured command or action for data (nil for runs that
+// weren't triggered by an event) and returns its result. Failures are also
+// logged.
+func Execute(ctx context.Context, cfg watcher.Config, data *watcher.EventData) watcher.ExecutionResult {
+	start := time.Now()
+	output, problems := outputTail(cfg), problemCollector(cfg)
+	err := execute(ctx, cfg, data, output, problems)
+	if errors.Is(err, errSkipped) {
+		return watcher.ExecutionResult{Duration: time.Since(start), Skipped: true}
+	}
+	result := watcher.ExecutionResult{ExitCode: watcher.ExitCode(err), Duration: time.Since(start), Output: output.Bytes(), Err: err}
+	if problems != nil {
+		reportDiagnostics(cfg, problems, &result)
+	}
+	if err != nil && cfg.OnFailure != "" {
+		runFailureHook(ctx, cfg, data, result)
+	}
+	return result
+}
+
+// runFailureHook runs cfg's --on-failure command for the failed run of
+// data, which ended with result.
+func runFailureHook(ctx context.Context, cfg watcher.Config, data *watcher.EventData, result watcher.ExecutionResult) {
+	logger := cfg.Logger()
+	hookData := &watcher.EventData{}
 	if data != nil {
-		templateData = data
+		*hookData = *data
+	}
+	hookData.RunNumber, hookData.LastRunAt = peekRun(cfg.Name)
+	hookData.Hostname = hostname
+	hookData.Rule = cfg.Name
+	hookData.ExitCode = result.ExitCode
+	hookData.OutputTail = string(result.Output)
+	if cfg.WSLInterop {
+		hookData = wslData(hookData)
+	}
+	command, err := render(cfg, "on_failure", cfg.OnFailure, hookData)
+	if err != nil {
+		logger.Error().Msgf("Error rendering --on-failure template: %v", err)
+		return
+	}
+	logger.Info().Msgf("Executing --on-failure: %s", command)
+	var cmd *exec.Cmd
+	if cfg.WSLInterop {
+		cmd = wsl.CommandContext(context.WithoutCancel(ctx), cfg.Shell, command)
 	} else {
-		templateData = struct{}{}
+		cmd = shell.CommandContext(context.WithoutCancel(ctx), cfg.Shell, command)
+	}
+	// The output is also passed in the environment, where it needs no quoting
+	cmd.Env = append(os.Environ(), "GOWATCHRUN_EXIT_CODE="+strconv.Itoa(result.ExitCode), "GOWATCHRUN_OUTPUT_TAIL="+hookData.OutputTail)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if cfg.Sandbox != nil {
+		if err := sandbox.Wrap(cmd, *cfg.Sandbox); err != nil {
+			logger.Error().Msgf("Failed to sandbox --on-failure command: %v", err)
+			return
+		}
+	}
+	finish := audited(ctx, cfg, data, command)
+	err = shell.Run(cmd)
+	finish(err)
+	if err != nil {
+		logger.Error().Msgf("--on-failure command failed: %v", err)
 	}
+}
+
+// execute runs a command or action for Execute, writing the command's
+// output to output and problems as well when they're set. It returns
+// errSkipped when nothing ran.
+func execute(ctx context.Context, cfg watcher.Config, data *watcher.EventData, output *tail, problems *problem.Collector) (err error) {
+	logger := cfg.Logger()
 
-	if cfg.ClearTerminal {
-		var clearCmd *exec.Cmd
-		if runtime.GOOS == "windows" {
-			clearCmd = exec.Command("cmd", "/c", "cls")
-		} else {
-			clearCmd = exec.Command("clear")
+	ctx, span := watcher.Tracer.Start(ctx, "execute", trace.WithAttributes(attribute.String("gowatchrun.job", cfg.Name)))
+	startTime := time.Now()
+	defer func() {
+		span.SetAttributes(attribute.Int64("gowatchrun.duration_ms", time.Since(startTime).Milliseconds()))
+		switch {
+		case errors.Is(err, errSkipped):
+			span.SetAttributes(attribute.Bool("gowatchrun.skipped", true))
+		case err != nil:
+			span.SetAttributes(attribute.Int("process.exit.code", watcher.ExitCode(err)))
+			span.SetStatus(codes.Error, err.Error())
+		default:
+			span.SetAttributes(attribute.Int("process.exit.code", 0))
 		}
-		clearCmd.Stdout = os.Stdout
-		clearCmd.Stderr = os.Stderr
-		if err := clearCmd.Run(); err != nil {
-			log.Warn().Err(err).Msg("Failed to clear terminal")
+		span.End()
+	}()
+
+	if data != nil {
+		logger.Debug().Msgf("Executing command for event: %s on %s", data.Event, data.Path)
+	} else {
+		logger.Debug().Msg("Executing command for initial run (--run-on-start)")
+	}
+
+	if store, storeErr := claimStoreFor(cfg); storeErr != nil {
+		logger.Error().Msgf("Skipping %s: %v", data.Path, storeErr)
+		return storeErr
+	} else if store != nil && hasFile(data) {
+		claimed, locks, lockErr := lockFiles(ctx, cfg, store, data)
+		if lockErr != nil {
+			logger.Error().Msgf("Skipping %s: %v", data.Path, lockErr)
+			return lockErr
+		}
+		if claimed == nil {
+			return errSkipped
+		}
+		// Only a successful run marks the files done for other instances
+		defer func() { releaseLocks(locks, err == nil) }()
+		data = claimed
+	}
+
+	if cfg.SettleDelay > 0 && hasFile(data) {
+		logger.Debug().Msgf("Waiting for %s to settle for %s", data.Path, cfg.SettleDelay)
+		if err := action.WaitStable(data.Path, cfg.SettleDelay); err != nil {
+			logger.Warn().Msgf("Skipping %s: file did not settle: %v", data.Path, err)
+			return err
+		}
+	}
+
+	var payloads []watcher.EventData
+	if cfg.Manifest && hasFile(data) {
+		if payloads, err = waitForPayloads(ctx, cfg, data); err != nil {
+			logger.Warn().Msgf("Skipping manifest %s: %v", data.Path, err)
+			return err
 		}
 	}
 
+	// Template data is a copy of the event data (empty for runs without an
+	// event) with the run counters filled in
+	templateData := &watcher.EventData{}
 	if data != nil {
-		log.Debug().Msgf("Executing command for event: %s on %s", data.Event, data.Path)
-	} else {
-		log.Debug().Msg("Executing command for initial run (--run-on-start)")
+		*templateData = *data
+	}
+	if payloads != nil {
+		templateData.Payloads = payloads
+	}
+	templateData.RunNumber, templateData.LastRunAt = peekRun(cfg.Name)
+	if !templateData.LastRunAt.IsZero() {
+		templateData.SinceLastRun = time.Since(templateData.LastRunAt).Round(time.Millisecond)
 	}
+	templateData.Hostname = hostname
+	templateData.Rule = cfg.Name
 
-	tmpl, err := template.New("command").Parse(cfg.CommandTmpl)
-	if err != nil {
-		log.Error().Msgf("Error parsing command template: %v", err)
-		return
+	commandTmpl := cfg.CommandTmpl
+	var env []string
+	if cfg.Handler != nil {
+		result, err := cfg.Handler.Handle(ctx, worker.Params(*templateData), logger)
+		if err != nil {
+			logger.Error().Msgf("Handler failed: %v", err)
+			return err
+		}
+		if result.Skip {
+			logger.Debug().Msgf("Skipping %s: the handler returned False", templateData.Path)
+			return errSkipped
+		}
+		if result.Path != "" {
+			setPath(templateData, result.Path)
+		}
+		if result.Command != "" {
+			if cfg.SignalPIDFile != "" || cfg.HTTPURL != "" || cfg.Action != "" || cfg.ActionPlugin != "" {
+				err := errors.New("the handler returned a command, but the job doesn't run one")
+				logger.Error().Msgf("Handler failed: %v", err)
+				return err
+			}
+			commandTmpl = result.Command
+		}
+		env = result.EnvList()
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, templateData); err != nil {
-		log.Error().Msgf("Error executing command template with data %+v: %v", templateData, err)
-		return
+	if len(cfg.FilterPlugins) > 0 {
+		accepted, err := filterPlugins(ctx, cfg, templateData)
+		if err != nil {
+			logger.Error().Msgf("Filter plugin failed: %v", err)
+			return err
+		}
+		if !accepted {
+			return errSkipped
+		}
+	}
+
+	if shouldClear(cfg.Clear, cfg.Name, data) {
+		clearTerminal()
 	}
+	recordRun(cfg.Name)
+	defer func() { recordResult(cfg.Name, err) }()
+	defer func() { routeFiles(cfg, templateData, err) }()
+	defer func() { notifyPlugins(cfg, templateData, err, startTime, output) }()
 
-	cmdString := buf.String()
-	log.Info().Msgf("Executing: %s", cmdString)
+	// --middleware wraps the command, so a retry runs it again without
+	// routing files, notifying or releasing locks more than once
+	attempt := func(ctx context.Context, cfg watcher.Config, _ *watcher.EventData) watcher.ExecutionResult {
+		start := time.Now()
+		err := runCommand(ctx, cfg, data, templateData, commandTmpl, env, output, problems, span)
+		return watcher.ExecutionResult{ExitCode: watcher.ExitCode(err), Duration: time.Since(start), Output: output.Bytes(), Err: err}
+	}
+	return watcher.Chain(attempt, cfg.Middleware...)(ctx, cfg, templateData).Err
+}
+
+// runCommand sends the signal or HTTP request, or runs the action, plugin or
+// command of cfg for execute, for the event data (nil without an event),
+// with templateData for the templates.
+func runCommand(ctx context.Context, cfg watcher.Config, data, templateData *watcher.EventData, commandTmpl string, env []string, output *tail, problems *problem.Collector, span trace.Span) (err error) {
+	logger := cfg.Logger()
+
+	if cfg.SignalPIDFile != "" {
+		finish := audited(ctx, cfg, data, "kill -"+strings.TrimPrefix(strings.ToUpper(cfg.Signal), "SIG")+" $(cat "+cfg.SignalPIDFile+")")
+		defer func() { finish(err) }()
+		return sendSignal(cfg)
+	}
+	if cfg.HTTPURL != "" {
+		return sendHTTP(ctx, cfg, templateData)
+	}
+	if cfg.Action != "" {
+		if data == nil {
+			return runAction(ctx, cfg, nil)
+		}
+		return runAction(ctx, cfg, templateData)
+	}
+
+	if cfg.ActionPlugin != "" {
+		return runPlugin(ctx, cfg, templateData)
+	}
+
+	cmdData := templateData
+	if cfg.WSLInterop {
+		cmdData = wslData(templateData)
+	}
+	cmdString, err := render(cfg, "command", commandTmpl, cmdData)
+	if err != nil {
+		logger.Error().Msgf("Error rendering command template: %v", err)
+		return err
+	}
+	if cfg.Script {
+		logger.Info().Msg("Executing script")
+		logger.Debug().Msgf("Script:\n%s", cmdString)
+	} else {
+		logger.Info().Msgf("Executing: %s", cmdString)
+	}
+	span.SetAttributes(attribute.String("process.command_line", secret.Redact(cmdString)))
+	finish := audited(ctx, cfg, data, cmdString)
+	defer func() { finish(err) }()
 
 	// TODO: Consider adding process management here later (kill/queue/ignore)
-	cmdExec := exec.Command("sh", "-c", cmdString)
-	cmdExec.Stdout = os.Stdout
-	cmdExec.Stderr = os.Stderr
+	var cmdExec *exec.Cmd
+	switch {
+	case cfg.Script:
+		var remove func()
+		if cmdExec, remove, err = shell.ScriptContext(ctx, cfg.Shell, cmdString); err != nil {
+			logger.Error().Msgf("Failed to write script: %v", err)
+			return err
+		}
+		defer remove()
+	case cfg.WSLInterop:
+		cmdExec = wsl.CommandContext(ctx, cfg.Shell, cmdString)
+	default:
+		cmdExec = shell.CommandContext(ctx, cfg.Shell, cmdString)
+	}
+	stdout, stderr := []io.Writer{os.Stdout}, []io.Writer{os.Stderr}
+	if status != nil {
+		label := cmdString
+		if cfg.Script {
+			label = "script"
+		}
+		defer status.begin(label)()
+		stdout, stderr = []io.Writer{status.writer(os.Stdout)}, []io.Writer{status.writer(os.Stderr)}
+	}
+	// --grep-output, --highlight and --fail-fast-output only change what's shown
+	failFast := newFailFast(cfg)
+	outFilter, errFilter := newLineFilter(cfg, os.Stdout, failFast), newLineFilter(cfg, os.Stderr, failFast)
+	if outFilter != nil {
+		outFilter.out, errFilter.out = stdout[0], stderr[0]
+		stdout, stderr = []io.Writer{outFilter}, []io.Writer{errFilter}
+	}
+	if output != nil {
+		stdout, stderr = append(stdout, output), append(stderr, output)
+	}
+	if problems != nil {
+		stdout, stderr = append(stdout, problems.Writer()), append(stderr, problems.Writer())
+	}
 	cmdExec.Stdin = os.Stdin
+	if len(env) > 0 {
+		cmdExec.Env = append(os.Environ(), env...)
+	}
+	if cfg.Sandbox != nil {
+		if err = sandbox.Wrap(cmdExec, *cfg.Sandbox); err != nil {
+			logger.Error().Msgf("Failed to sandbox command: %v", err)
+			return err
+		}
+	}
+	if cfg.Control != nil {
+		// Also stream the output to API clients
+		apiStdout, apiStderr := cfg.Control.Output(ctx, cfg.Name, "stdout"), cfg.Control.Output(ctx, cfg.Name, "stderr")
+		defer apiStdout.Close()
+		defer apiStderr.Close()
+		stdout, stderr = append(stdout, apiStdout), append(stderr, apiStderr)
+	}
+	cmdExec.Stdout, cmdExec.Stderr = writer(stdout), writer(stderr)
 
-	startTime := time.Now()
-	err = cmdExec.Run()
-	duration := time.Since(startTime)
+	cmdStart := time.Now()
+	err = shell.Run(cmdExec)
+	duration := time.Since(cmdStart)
+	if outFilter != nil {
+		outFilter.Flush()
+		errFilter.Flush()
+	}
+	if hidden := failFast.hiddenLines(); hidden > 0 {
+		logger.Warn().Msgf("Hid %d line(s) of output after the first failure (--fail-fast-output)", hidden)
+	}
 
+	if code := watcher.ExitCode(err); err != nil && slices.Contains(cfg.OkExitCodes, code) {
+		logger.Info().Msgf("Command exited with status %d, which is configured as ok", code)
+		err = nil
+	}
 	if err != nil {
-		logEntry := log.Error().
+		logEntry := logger.Error().
 			Str("command", cmdString).
 			Dur("duration", duration.Round(time.Millisecond)).
 			Err(err)
@@ -75,13 +357,227 @@ func Execute(cfg watcher.Config, data *watcher.EventData) {
 			logEntry = logEntry.Str("event_path", data.Path).Str("event_type", data.Event)
 		}
 		logEntry.Msg("Command execution failed")
+		return err
+	}
+	logEntry := logger.Trace().
+		Str("command", cmdString).
+		Dur("duration", duration.Round(time.Millisecond))
+	if data != nil {
+		logEntry = logEntry.Str("event_path", data.Path).Str("event_type", data.Event)
+	}
+	logEntry.Msg("Command executed successfully")
+	return nil
+}
+
+// sendSignal sends the configured signal to the process in the PID file,
+// which is read again every time since the process may have restarted.
+func sendSignal(cfg watcher.Config) error {
+	logger := cfg.Logger()
+	sig, err := action.ParseSignal(cfg.Signal)
+	if err == nil {
+		var pid int
+		pid, err = action.Signal(cfg.SignalPIDFile, sig)
+		if err == nil {
+			logger.Info().Msgf("Sent %s (%s) to process %d from %s", cfg.Signal, sig, pid, cfg.SignalPIDFile)
+			return nil
+		}
+	}
+	logger.Error().Msgf("Failed to send signal %s: %v", cfg.Signal, err)
+	return err
+}
+
+func runAction(ctx context.Context, cfg watcher.Config, data *watcher.EventData) error {
+	logger := cfg.Logger()
+	if data == nil {
+		logger.Warn().Msgf("Skipping '%s' action: no file event to act on", cfg.Action)
+		return nil
+	}
+
+	var dest string
+	if action.NeedsDest(cfg.Action) {
+		var err error
+		dest, err = render(cfg, "dest", cfg.ActionDest, data)
+		if err != nil {
+			logger.Error().Msgf("Error rendering destination template: %v", err)
+			return err
+		}
+		target := dest
+		if cfg.Action == action.KindS3 {
+			target = "s3://" + cfg.S3.Bucket + "/" + cfg.S3.Prefix + dest
+		}
+		logger.Info().Msgf("Running action: %s %s -> %s", cfg.Action, data.Path, target)
 	} else {
-		logEntry := log.Trace().
-			Str("command", cmdString).
-			Dur("duration", duration.Round(time.Millisecond))
-		if data != nil {
-			logEntry = logEntry.Str("event_path", data.Path).Str("event_type", data.Event)
+		logger.Info().Msgf("Running action: %s %s", cfg.Action, data.Path)
+	}
+
+	description := cfg.Action + " " + data.Path
+	if dest != "" {
+		description += " " + dest
+	}
+	finish := audited(ctx, cfg, data, description)
+
+	startTime := time.Now()
+	var err error
+	if cfg.Action == action.KindS3 {
+		err = action.UploadS3(ctx, cfg.S3, data.Path, dest)
+	} else {
+		err = action.Run(cfg.Action, data.Path, dest)
+	}
+	duration := time.Since(startTime)
+	finish(err)
+
+	if err != nil {
+		logger.Error().
+			Str("action", cfg.Action).
+			Str("event_path", data.Path).
+			Str("event_type", data.Event).
+			Dur("duration", duration.Round(time.Millisecond)).
+			Err(err).
+			Msg("Action failed")
+		return err
+	}
+	logger.Trace().
+		Str("action", cfg.Action).
+		Str("event_path", data.Path).
+		Str("event_type", data.Event).
+		Dur("duration", duration.Round(time.Millisecond)).
+		Msg("Action completed successfully")
+	return nil
+}
+
+// audited records the start of an execution of command, as rendered for
+// data, in the audit log and returns the function that records its end.
+func audited(ctx context.Context, cfg watcher.Config, data *watcher.EventData, command string) func(error) {
+	if cfg.Audit == nil {
+		return func(error) {}
+	}
+	entry := audit.Entry{Job: cfg.Name, Run: watcher.RunID(ctx), Command: command}
+	if data != nil {
+		entry.Event = data.Event
+		for _, file := range data.Files {
+			entry.Files = append(entry.Files, file.Path)
+		}
+		if len(entry.Files) == 0 && data.Path != "" {
+			entry.Files = []string{data.Path}
+		}
+	}
+	finish := cfg.Audit.Exec(entry)
+	return func(err error) { finish(watcher.ExitCode(err), err) }
+}
+
+// manifestSettle is how long payloads must be unchanged before a manifest's
+// command runs, unless --settle is set.
+const manifestSettle = time.Second
+
+// waitForPayloads parses the manifest data refers to and waits until every
+// payload it lists exists and is stable.
+func waitForPayloads(ctx context.Context, cfg watcher.Config, data *watcher.EventData) ([]watcher.EventData, error) {
+	paths, err := manifest.Parse(data.Path, cfg.ManifestKey)
+	if err != nil {
+		return nil, err
+	}
+	// Payloads are read and moved like the files the job watches, so they
+	// must be among them
+	dirs := append([]string{filepath.Dir(data.Path)}, cfg.WatchDirs...)
+	if err := manifest.Confine(paths, dirs); err != nil {
+		return nil, err
+	}
+	window := cfg.SettleDelay
+	if window == 0 {
+		window = manifestSettle
+	}
+	logger := cfg.Logger()
+	logger.Info().Msgf("Waiting for %d payload(s) listed in %s", len(paths), data.Path)
+	if err := manifest.Wait(ctx, paths, window, cfg.ManifestTimeout); err != nil {
+		return nil, err
+	}
+	// Symlinks to elsewhere may only have appeared while waiting
+	if err := manifest.Confine(paths, dirs); err != nil {
+		return nil, err
+	}
+	payloads := make([]watcher.EventData, len(paths))
+	for i, path := range paths {
+		payloads[i] = watcher.FileData(path, data.Event)
+	}
+	return payloads, nil
+}
+
+// setPath makes data refer to the file at path, as a --handler asked.
+func setPath(data *watcher.EventData, path string) {
+	file := watcher.FileData(path, data.Event)
+	data.Path, data.PathSlash, data.Name, data.Dir = file.Path, file.PathSlash, file.Name, file.Dir
+	data.Ext, data.BaseName, data.Size, data.Mime = file.Ext, file.BaseName, file.Size, file.Mime
+}
+
+// hasFile reports whether data refers to a file that should exist on disk.
+func hasFile(data *watcher.EventData) bool {
+	if data == nil || data.Path == "" {
+		return false
+	}
+	switch data.Event {
+	case "REMOVE", "RENAME", "WEBHOOK", "TIMER":
+		return false
+	}
+	return true
+}
+
+// templates caches parsed templates by delimiters, included templates and
+// text, so each template is parsed once instead of on every execution.
+var templates sync.Map // templateKey -> *template.Template
+
+type templateKey struct {
+	delims   [2]string
+	includes string // The directory the included templates were loaded from
+	text     string
+}
+
+// Compile parses cfg's templates ahead of the first execution, so a broken
+// template is reported at startup.
+func Compile(cfg watcher.Config) error {
+	templates := map[string]string{"command": cfg.CommandTmpl, "when": cfg.When, "dest": cfg.ActionDest, "sidecar": cfg.SidecarTmpl, "http_action": cfg.HTTPURL, "http_body": cfg.HTTPBody, "on_failure": cfg.OnFailure}
+	for i, header := range cfg.HTTPHeaders {
+		templates[fmt.Sprintf("http_header[%d]", i)] = header
+	}
+	for name, text := range templates {
+		if text == "" {
+			continue
+		}
+		tmpl, err := parse(cfg, name, text)
+		if err == nil {
+			err = watcher.CheckTemplate(tmpl)
 		}
-		logEntry.Msg("Command executed successfully")
+		if err != nil {
+			if cfg.Name != "" {
+				return fmt.Errorf("job '%s': invalid %s template: %w", cfg.Name, name, err)
+			}
+			return fmt.Errorf("invalid %s template: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func parse(cfg watcher.Config, name, text string) (*template.Template, error) {
+	key := templateKey{delims: cfg.TemplateDelims, includes: cfg.TemplatesDir, text: text}
+	if tmpl, ok := templates.Load(key); ok {
+		return tmpl.(*template.Template), nil
+	}
+	tmpl, err := watcher.ParseTemplate(cfg, name, text)
+	if err != nil {
+		return nil, err
+	}
+	templates.Store(key, tmpl)
+	return tmpl, nil
+}
+
+func render(cfg watcher.Config, name, text string, data interface{}) (string, error) {
+	tmpl, err := parse(cfg, name, text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
 	}
+	return buf.String(), nil
 }
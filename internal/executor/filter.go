@@ -0,0 +1,158 @@
+package executor
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// Escape codes highlighted matches are wrapped in: bold yellow, then reset.
+const (
+	highlightStart = "\033[1;33m"
+	highlightEnd   = "\033[0m"
+)
+
+// maxFilteredLine is the length at which a line of output without a newline
+// is filtered anyway, so progress bars and the like don't pile up.
+const maxFilteredLine = 64 << 10
+
+// failFastContext is how many lines --fail-fast-output still shows after
+// the first failure marker, for the details that follow it.
+const failFastContext = 20
+
+// lineFilter passes the output of a command on to out line by line, leaving
+// out lines that don't match grep (--grep-output) and highlighting matches
+// of highlight (--highlight) when out is a terminal.
+type lineFilter struct {
+	out       io.Writer
+	grep      *regexp.Regexp
+	highlight *regexp.Regexp
+	failFast  *failFast
+	pending   []byte // Start of a line that hasn't ended yet
+}
+
+// failFast is the state of --fail-fast-output, shared by the filters of a
+// command's stdout and stderr.
+type failFast struct {
+	marker *regexp.Regexp
+
+	mu      sync.Mutex
+	failed  bool
+	context int // Lines still shown after the marker
+	hidden  int // Lines not shown since
+}
+
+// newFailFast returns the state for cfg's --fail-fast-output, or nil when
+// it isn't set.
+func newFailFast(cfg watcher.Config) *failFast {
+	if cfg.FailFastOutput == nil {
+		return nil
+	}
+	return &failFast{marker: cfg.FailFastOutput}
+}
+
+// marks reports whether line is the first failure marker, and from then on
+// hides the lines after the context. Lines are checked before --grep-output,
+// so a marker it leaves out still counts.
+func (f *failFast) marks(line []byte) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failed || !f.marker.Match(line) {
+		return false
+	}
+	f.failed, f.context = true, failFastContext
+	return true
+}
+
+// show reports whether a line that isn't the marker is still shown, before
+// or within the context after the first failure marker.
+func (f *failFast) show() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case !f.failed:
+		return true
+	case f.context > 0:
+		f.context--
+		return true
+	}
+	f.hidden++
+	return false
+}
+
+// hiddenLines returns how many lines weren't shown after the first failure.
+func (f *failFast) hiddenLines() int {
+	if f == nil {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hidden
+}
+
+// newLineFilter returns the filter cfg's --grep-output, --highlight and
+// --fail-fast-output (failFast) ask for in front of out, or nil when they
+// aren't set.
+func newLineFilter(cfg watcher.Config, out *os.File, failFast *failFast) *lineFilter {
+	if cfg.GrepOutput == nil && cfg.Highlight == nil && failFast == nil {
+		return nil
+	}
+	f := &lineFilter{out: out, grep: cfg.GrepOutput, failFast: failFast}
+	if info, err := out.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == "" {
+		enableVirtualTerminal()
+		f.highlight = cfg.Highlight
+	}
+	return f
+}
+
+func (f *lineFilter) Write(p []byte) (int, error) {
+	f.pending = append(f.pending, p...)
+	for {
+		i := bytes.IndexByte(f.pending, '\n')
+		if i < 0 {
+			break
+		}
+		if err := f.line(f.pending[:i+1]); err != nil {
+			return len(p), err
+		}
+		f.pending = f.pending[i+1:]
+	}
+	if len(f.pending) >= maxFilteredLine {
+		err := f.line(f.pending)
+		f.pending = nil
+		return len(p), err
+	}
+	return len(p), nil
+}
+
+// Flush passes on a last line without a newline.
+func (f *lineFilter) Flush() {
+	if len(f.pending) > 0 {
+		f.line(f.pending)
+		f.pending = nil
+	}
+}
+
+// line passes on a single line, including its line ending.
+func (f *lineFilter) line(line []byte) error {
+	text := bytes.TrimRight(line, "\r\n")
+	marker := f.failFast != nil && f.failFast.marks(text)
+	if f.grep != nil && !f.grep.Match(text) {
+		return nil
+	}
+	if f.failFast != nil && !marker && !f.failFast.show() {
+		return nil
+	}
+	if f.highlight != nil {
+		text = f.highlight.ReplaceAllFunc(text, func(match []byte) []byte {
+			return append(append([]byte(highlightStart), match...), highlightEnd...)
+		})
+		line = append(text, line[len(bytes.TrimRight(line, "\r\n")):]...)
+	}
+	_, err := f.out.Write(line)
+	return err
+}
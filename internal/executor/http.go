@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+	"github.com/s0up4200/gowatchrun/internal/worker"
+)
+
+// httpActionTimeout limits a single --http-action request.
+const httpActionTimeout = time.Minute
+
+var httpClient = &http.Client{Timeout: httpActionTimeout}
+
+// sendHTTP sends the --http-action request for data. Without --http-body,
+// the event is sent as JSON, the way --worker handlers receive it. Any
+// status other than 2xx is an error.
+func sendHTTP(ctx context.Context, cfg watcher.Config, data *watcher.EventData) (err error) {
+	logger := cfg.Logger()
+	url, err := render(cfg, "http_action", cfg.HTTPURL, data)
+	if err != nil {
+		logger.Error().Msgf("Error rendering --http-action URL: %v", err)
+		return err
+	}
+
+	var body []byte
+	contentType := ""
+	if cfg.HTTPBody != "" {
+		rendered, err := render(cfg, "http_body", cfg.HTTPBody, data)
+		if err != nil {
+			logger.Error().Msgf("Error rendering --http-body template: %v", err)
+			return err
+		}
+		body = []byte(rendered)
+	} else if cfg.HTTPMethod != http.MethodGet && cfg.HTTPMethod != http.MethodHead {
+		if body, err = json.Marshal(worker.Params(*data)); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, cfg.HTTPMethod, url, bytes.NewReader(body))
+	if err != nil {
+		logger.Error().Msgf("Invalid --http-action request: %v", err)
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, header := range cfg.HTTPHeaders {
+		rendered, err := render(cfg, "http_header", header, data)
+		if err != nil {
+			logger.Error().Msgf("Error rendering --http-header template: %v", err)
+			return err
+		}
+		name, value, _ := strings.Cut(rendered, ":")
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	logger.Info().Msgf("Sending: %s %s", cfg.HTTPMethod, url)
+	finish := audited(ctx, cfg, data, cfg.HTTPMethod+" "+url)
+	defer func() { finish(err) }()
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logger.Error().Msgf("HTTP request failed: %v", err)
+		return err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("%s %s: %s", cfg.HTTPMethod, url, resp.Status)
+		if text := strings.TrimSpace(string(snippet)); text != "" {
+			err = fmt.Errorf("%w: %s", err, text)
+		}
+		logger.Error().Msgf("HTTP request failed: %v", err)
+		return err
+	}
+	logger.Debug().Msgf("%s %s: %s in %s", cfg.HTTPMethod, url, resp.Status, time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,332 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+const (
+	lockPoll    = 500 * time.Millisecond // How often --lock-wait checks a held lock
+	lockRefresh = 30 * time.Second       // How often a held lock is refreshed
+	lockStale   = 2 * time.Minute        // A lock not refreshed for this long was left by a crashed instance
+)
+
+// claimStore holds the locks and done records instances use to divide the
+// files of a shared folder between them: a directory (--lock-dir) or a
+// Redis server (--coordinator). Records are "<host> <pid> <details>".
+type claimStore interface {
+	// lock creates the lock for key with record, replacing a stale one. It
+	// reports false when another instance holds the lock.
+	lock(ctx context.Context, key, record string) (bool, error)
+	// refresh keeps the lock for key from going stale.
+	refresh(ctx context.Context, key, record string) error
+	// unlock removes the lock for key, writing done first when it's set.
+	unlock(ctx context.Context, key, record, done string) error
+	// lockRecord and doneRecord return the records for key, or "".
+	lockRecord(ctx context.Context, key string) string
+	doneRecord(ctx context.Context, key string) string
+}
+
+// claimStoreFor returns the store cfg's jobs claim files in, or nil.
+func claimStoreFor(cfg watcher.Config) (claimStore, error) {
+	switch {
+	case cfg.Coordinator != "":
+		return redisStoreFor(cfg.Coordinator)
+	case cfg.LockDir != "":
+		if err := os.MkdirAll(cfg.LockDir, 0o755); err != nil {
+			return nil, fmt.Errorf("creating lock directory: %w", err)
+		}
+		return dirStore(cfg.LockDir), nil
+	}
+	return nil, nil
+}
+
+// fileLock is a lock claiming a file for this instance. Once the run is
+// over, a done record remembers the size and modification time of the file
+// that was processed, so instances that see the same event later skip it
+// unless the file changed since.
+type fileLock struct {
+	store  claimStore
+	key    string
+	record string
+	state  string // Size and modification time of the claimed file
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// claimKey returns the name of the lock for file. Instances may mount a
+// shared folder in different places, so it's derived from the file's path
+// relative to its watch directory.
+func claimKey(cfg watcher.Config, file string) string {
+	key := filepath.ToSlash(file)
+	if abs, err := filepath.Abs(file); err == nil {
+		key = filepath.ToSlash(abs)
+		for _, dir := range cfg.WatchDirs {
+			absDir, err := filepath.Abs(dir)
+			if err != nil {
+				continue
+			}
+			if rel, err := filepath.Rel(absDir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				key = filepath.ToSlash(rel)
+				break
+			}
+		}
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8]) + "-" + filepath.Base(file)
+}
+
+// tryLock claims key. It returns nil when another instance holds it.
+func tryLock(ctx context.Context, store claimStore, key string) (*fileLock, error) {
+	record := fmt.Sprintf("%s %d %s", hostname, os.Getpid(), time.Now().Format(time.RFC3339Nano))
+	ok, err := store.lock(ctx, key, record)
+	if err != nil || !ok {
+		return nil, err
+	}
+	l := &fileLock{store: store, key: key, record: record, stop: make(chan struct{}), done: make(chan struct{})}
+	go l.refresh()
+	return l, nil
+}
+
+// refresh keeps the lock from going stale until it's released.
+func (l *fileLock) refresh() {
+	defer close(l.done)
+	ticker := time.NewTicker(lockRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.store.refresh(context.Background(), l.key, l.record)
+		}
+	}
+}
+
+// release removes the lock, writing the done record first when the file was
+// processed.
+func (l *fileLock) release(processed bool) {
+	close(l.stop)
+	<-l.done
+	done := ""
+	if processed {
+		done = fmt.Sprintf("%s %d %s", hostname, os.Getpid(), l.state)
+	}
+	l.store.unlock(context.Background(), l.key, l.record, done)
+}
+
+func fileState(info os.FileInfo) string {
+	return fmt.Sprintf("%d %d", info.Size(), info.ModTime().UnixNano())
+}
+
+// processedBy returns the instance that already processed the file behind
+// key in the state given, according to its done record.
+func processedBy(ctx context.Context, store claimStore, key, state string) (string, bool) {
+	fields := strings.Fields(store.doneRecord(ctx, key))
+	if len(fields) != 4 || fields[2]+" "+fields[3] != state {
+		return "", false
+	}
+	return fmt.Sprintf("%s (pid %s)", fields[0], fields[1]), true
+}
+
+// lockOwner describes the instance holding the lock for key.
+func lockOwner(ctx context.Context, store claimStore, key string) string {
+	fields := strings.Fields(store.lockRecord(ctx, key))
+	if len(fields) < 2 {
+		return "another instance"
+	}
+	return fmt.Sprintf("%s (pid %s)", fields[0], fields[1])
+}
+
+// lockFiles claims the files of data. Files another instance holds are
+// waited for with --lock-wait and skipped otherwise; it returns the event
+// data for the files that were claimed (nil when there are none) along with
+// the locks to release once the run is over.
+func lockFiles(ctx context.Context, cfg watcher.Config, store claimStore, data *watcher.EventData) (*watcher.EventData, []*fileLock, error) {
+	logger := cfg.Logger()
+	files := data.Files
+	if len(files) == 0 {
+		files = []watcher.EventData{*data}
+	}
+
+	var locks []*fileLock
+	var claimed []watcher.EventData
+	for _, file := range files {
+		key := claimKey(cfg, file.Path)
+		for {
+			l, err := tryLock(ctx, store, key)
+			if err != nil {
+				releaseLocks(locks, false)
+				return nil, nil, fmt.Errorf("locking %s: %w", file.Path, err)
+			}
+			if l != nil {
+				info, err := os.Stat(file.Path)
+				if err != nil {
+					l.release(false)
+					logger.Info().Msgf("Skipping %s: no longer exists", file.Path)
+					break
+				}
+				l.state = fileState(info)
+				if owner, ok := processedBy(ctx, store, key, l.state); ok {
+					l.release(false)
+					logger.Info().Msgf("Skipping %s: already processed by %s", file.Path, owner)
+					break
+				}
+				locks = append(locks, l)
+				claimed = append(claimed, file)
+				break
+			}
+			if !cfg.LockWait {
+				logger.Info().Msgf("Skipping %s: locked by %s", file.Path, lockOwner(ctx, store, key))
+				break
+			}
+			logger.Debug().Msgf("Waiting for %s: locked by %s", file.Path, lockOwner(ctx, store, key))
+			select {
+			case <-ctx.Done():
+				releaseLocks(locks, false)
+				return nil, nil, ctx.Err()
+			case <-time.After(lockPoll):
+			}
+		}
+	}
+
+	switch {
+	case len(claimed) == 0:
+		return nil, nil, nil
+	case len(data.Files) == 0:
+		return data, locks, nil
+	}
+	// The other fields of a batch describe its last file
+	batch := claimed[len(claimed)-1]
+	batch.Files = claimed
+	return &batch, locks, nil
+}
+
+func releaseLocks(locks []*fileLock, processed bool) {
+	for _, l := range locks {
+		l.release(processed)
+	}
+}
+
+// dirStore keeps locks as files in a directory (--lock-dir). A lock file's
+// modification time is its last refresh.
+type dirStore string
+
+func (d dirStore) path(key, ext string) string {
+	return filepath.Join(string(d), key+ext)
+}
+
+// lock writes record to a temporary file and links it to the lock file,
+// which fails while the lock exists, so the lock never exists without its
+// record (on file systems without hard links, the lock file is created
+// exclusively instead). A stale lock is renamed away before it's replaced: only one
+// instance can rename it, and one that renamed a lock that was replaced
+// meanwhile puts it back instead of taking it over.
+func (d dirStore) lock(_ context.Context, key, record string) (bool, error) {
+	path := d.path(key, ".lock")
+	tmp, err := os.CreateTemp(string(d), "."+key+"-*")
+	if err != nil {
+		return false, err
+	}
+	_, err = fmt.Fprintln(tmp, record)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	defer os.Remove(tmp.Name())
+	if err != nil {
+		return false, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		err := os.Link(tmp.Name(), path)
+		if err != nil && !os.IsExist(err) {
+			// The file system has no hard links
+			err = createLock(path, record)
+		}
+		if err == nil {
+			return true, nil
+		}
+		if !os.IsExist(err) {
+			return false, err
+		}
+		stale, ok := d.staleRecord(path)
+		if !ok {
+			return false, nil
+		}
+		taken := tmp.Name() + ".stale"
+		if err := os.Rename(path, taken); err != nil {
+			return false, nil // Another instance took it over first
+		}
+		if current, ok := d.staleRecord(taken); !ok || current != stale {
+			// Replaced by another instance since it was checked
+			os.Link(taken, path)
+			os.Remove(taken)
+			return false, nil
+		}
+		os.Remove(taken)
+	}
+	return false, nil
+}
+
+// createLock creates the lock file at path with record, failing when it
+// exists.
+func createLock(path, record string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, record)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// staleRecord returns the record of the lock file at path when it hasn't
+// been refreshed for lockStale.
+func (d dirStore) staleRecord(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) < lockStale {
+		return "", false
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(raw), true
+}
+
+func (d dirStore) refresh(_ context.Context, key, _ string) error {
+	now := time.Now()
+	return os.Chtimes(d.path(key, ".lock"), now, now)
+}
+
+func (d dirStore) unlock(_ context.Context, key, record, done string) error {
+	if done != "" {
+		if err := os.WriteFile(d.path(key, ".done"), []byte(done+"\n"), 0o644); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(d.lockRecord(context.Background(), key)) != record {
+		return nil // Taken over by another instance after it went stale
+	}
+	return os.Remove(d.path(key, ".lock"))
+}
+
+func (d dirStore) lockRecord(_ context.Context, key string) string {
+	raw, _ := os.ReadFile(d.path(key, ".lock"))
+	return string(raw)
+}
+
+func (d dirStore) doneRecord(_ context.Context, key string) string {
+	raw, _ := os.ReadFile(d.path(key, ".done"))
+	return string(raw)
+}
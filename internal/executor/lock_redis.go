@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// doneTTL is how long a Redis coordinator remembers processed files.
+const doneTTL = 7 * 24 * time.Hour
+
+// redisStores caches the Redis clients by --coordinator URL.
+var redisStores sync.Map
+
+// redisStore keeps locks as keys that expire unless they're refreshed
+// (--coordinator redis://...).
+type redisStore struct {
+	client *redis.Client
+}
+
+func redisStoreFor(url string) (claimStore, error) {
+	if store, ok := redisStores.Load(url); ok {
+		return store.(*redisStore), nil
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	store, _ := redisStores.LoadOrStore(url, &redisStore{client: redis.NewClient(opts)})
+	return store.(*redisStore), nil
+}
+
+// Locks are only refreshed or removed by the instance holding them.
+var (
+	refreshScript = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) end return 0`)
+	unlockScript  = redis.NewScript(`if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) end return 0`)
+)
+
+func lockKey(key string) string { return "gowatchrun:lock:" + key }
+func doneKey(key string) string { return "gowatchrun:done:" + key }
+
+func (r *redisStore) lock(ctx context.Context, key, record string) (bool, error) {
+	return r.client.SetNX(ctx, lockKey(key), record, lockStale).Result()
+}
+
+func (r *redisStore) refresh(ctx context.Context, key, record string) error {
+	return refreshScript.Run(ctx, r.client, []string{lockKey(key)}, record, lockStale.Milliseconds()).Err()
+}
+
+func (r *redisStore) unlock(ctx context.Context, key, record, done string) error {
+	if done != "" {
+		if err := r.client.Set(ctx, doneKey(key), done, doneTTL).Err(); err != nil {
+			return err
+		}
+	}
+	return unlockScript.Run(ctx, r.client, []string{lockKey(key)}, record).Err()
+}
+
+func (r *redisStore) lockRecord(ctx context.Context, key string) string {
+	return r.client.Get(ctx, lockKey(key)).Val()
+}
+
+func (r *redisStore) doneRecord(ctx context.Context, key string) string {
+	return r.client.Get(ctx, doneKey(key)).Val()
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// maxOutput is how much of a command's output its result keeps by default.
+const maxOutput = 64 << 10
+
+// tail is a ring buffer that keeps the last bytes written to it. Commands
+// write their stdout and stderr to it concurrently.
+type tail struct {
+	mu   sync.Mutex
+	buf  []byte
+	next int  // Where the next byte goes
+	full bool // buf has wrapped around
+}
+
+func newTail(size int) *tail {
+	return &tail{buf: make([]byte, size)}
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := len(p)
+	if len(p) >= len(t.buf) {
+		p = p[len(p)-len(t.buf):]
+	}
+	for len(p) > 0 {
+		copied := copy(t.buf[t.next:], p)
+		p = p[copied:]
+		t.next += copied
+		if t.next == len(t.buf) {
+			t.next, t.full = 0, true
+		}
+	}
+	return n, nil
+}
+
+// Bytes returns what the tail kept, oldest first, and nil for a nil tail.
+func (t *tail) Bytes() []byte {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.full {
+		return append([]byte(nil), t.buf[:t.next]...)
+	}
+	return append(append([]byte(nil), t.buf[t.next:]...), t.buf[:t.next]...)
+}
+
+// outputTail returns the tail the output of cfg's commands is kept in for
+// their results, or nil. Output that goes to a terminal is only kept when
+// --output-tail asks for it or something uses it: an --on-failure hook,
+// notify plugins or middleware. Otherwise it's left alone, as commands that
+// write to a pipe instead often disable colors, except when it's piped
+// anyway for API clients.
+func outputTail(cfg watcher.Config) *tail {
+	if cfg.OutputTail > 0 {
+		return newTail(int(cfg.OutputTail))
+	}
+	if cfg.OnFailure != "" || len(cfg.NotifyPlugins) > 0 || len(cfg.Middleware) > 0 || cfg.Control != nil || !toTerminal() {
+		return newTail(maxOutput)
+	}
+	return nil
+}
+
+// toTerminal reports whether stdout or stderr is a terminal.
+func toTerminal() bool {
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// writer returns a writer for all of writers, which writes to a single one
+// directly so a command can inherit a terminal.
+func writer(writers []io.Writer) io.Writer {
+	if len(writers) == 1 {
+		return writers[0]
+	}
+	return io.MultiWriter(writers...)
+}
@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/plugin"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+	"github.com/s0up4200/gowatchrun/internal/worker"
+)
+
+// filterPlugins asks cfg's filter plugins whether data's run goes ahead.
+// The first one that rejects it decides.
+func filterPlugins(ctx context.Context, cfg watcher.Config, data *watcher.EventData) (bool, error) {
+	logger := cfg.Logger()
+	for _, name := range cfg.FilterPlugins {
+		resp, err := callPlugin(ctx, cfg, name, plugin.RoleFilter, data, nil)
+		if err != nil {
+			return false, err
+		}
+		if resp.Accept != nil && !*resp.Accept {
+			if resp.Message != "" {
+				logger.Debug().Msgf("Skipping %s: plugin '%s' rejected it: %s", data.Path, name, resp.Message)
+			} else {
+				logger.Debug().Msgf("Skipping %s: plugin '%s' rejected it", data.Path, name)
+			}
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// runPlugin runs cfg's action plugin for data instead of a command.
+func runPlugin(ctx context.Context, cfg watcher.Config, data *watcher.EventData) error {
+	logger := cfg.Logger()
+	name := cfg.ActionPlugin
+	logger.Info().Msgf("Running plugin: %s %s", name, data.Path)
+	finish := audited(ctx, cfg, data, plugin.Prefix+name+" "+plugin.RoleAction)
+
+	startTime := time.Now()
+	resp, err := callPlugin(ctx, cfg, name, plugin.RoleAction, data, nil)
+	duration := time.Since(startTime)
+	finish(err)
+
+	if err != nil {
+		logger.Error().
+			Str("plugin", name).
+			Str("event_path", data.Path).
+			Str("event_type", data.Event).
+			Dur("duration", duration.Round(time.Millisecond)).
+			Err(err).
+			Msg("Plugin failed")
+		return err
+	}
+	if resp.Message != "" {
+		logger.Info().Msgf("Plugin '%s': %s", name, resp.Message)
+	}
+	logger.Trace().
+		Str("plugin", name).
+		Str("event_path", data.Path).
+		Str("event_type", data.Event).
+		Dur("duration", duration.Round(time.Millisecond)).
+		Msg("Plugin completed successfully")
+	return nil
+}
+
+// notifyPlugins tells cfg's notify plugins about the outcome of data's run,
+// which started at startTime and failed with runErr when it's set. The
+// output of failed runs is passed along when it was kept.
+func notifyPlugins(cfg watcher.Config, data *watcher.EventData, runErr error, startTime time.Time, output *tail) {
+	if len(cfg.NotifyPlugins) == 0 {
+		return
+	}
+	result := &plugin.Result{
+		Success:    runErr == nil,
+		ExitCode:   watcher.ExitCode(runErr),
+		DurationMs: time.Since(startTime).Milliseconds(),
+	}
+	if runErr != nil {
+		result.Error = runErr.Error()
+		result.OutputTail = string(output.Bytes())
+	}
+	logger := cfg.Logger()
+	for _, name := range cfg.NotifyPlugins {
+		resp, err := callPlugin(context.Background(), cfg, name, plugin.RoleNotify, data, result)
+		if err != nil {
+			logger.Warn().Msgf("Failed to notify plugin '%s': %v", name, err)
+		} else if resp.Message != "" {
+			logger.Info().Msgf("Plugin '%s': %s", name, resp.Message)
+		}
+	}
+}
+
+// callPlugin looks up the plugin called name and calls it in role. Filters
+// and notifiers get plugin.Timeout to respond.
+func callPlugin(ctx context.Context, cfg watcher.Config, name, role string, data *watcher.EventData, result *plugin.Result) (plugin.Response, error) {
+	p, err := plugin.Find(name)
+	if err != nil {
+		return plugin.Response{}, err
+	}
+	if role != plugin.RoleAction {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, plugin.Timeout)
+		defer cancel()
+	}
+	resp, err := p.Call(ctx, plugin.Request{Role: role, Job: cfg.Name, Event: worker.Params(*data), Result: result})
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return resp, fmt.Errorf("plugin '%s' didn't respond within %s", name, plugin.Timeout)
+	}
+	return resp, err
+}
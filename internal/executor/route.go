@@ -0,0 +1,85 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/s0up4200/gowatchrun/internal/action"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// routeFiles moves the files of a finished run to --on-success-move or
+// --on-failure-move, depending on runErr. A batch moves all of its files
+// that still exist, and a manifest or sidecar moves along with the files it belongs to.
+func routeFiles(cfg watcher.Config, data *watcher.EventData, runErr error) {
+	dir := cfg.OnSuccessMove
+	if runErr != nil {
+		dir = cfg.OnFailureMove
+	}
+	if dir == "" || data == nil {
+		return
+	}
+	if len(data.Files) == 0 && !hasFile(data) {
+		// A batch is routed by its files, whatever its last event was
+		return
+	}
+	logger := cfg.Logger()
+	for _, path := range routedPaths(data) {
+		if _, err := os.Lstat(path); err != nil {
+			continue // Already moved or removed by the command
+		}
+		dst, err := freeName(filepath.Join(dir, filepath.Base(path)))
+		if err == nil {
+			err = action.Move(path, dst)
+		}
+		if err != nil {
+			logger.Error().Msgf("Failed to move %s to %s: %v", path, dir, err)
+			continue
+		}
+		logger.Info().Msgf("Moved %s to %s", path, dst)
+	}
+}
+
+// routedPaths returns the files a run covered, without duplicates.
+func routedPaths(data *watcher.EventData) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	if len(data.Files) > 0 {
+		for _, f := range data.Files {
+			add(f.Path)
+			add(f.Sidecar)
+		}
+	} else {
+		add(data.Path)
+		add(data.Sidecar)
+	}
+	for _, p := range data.Payloads {
+		add(p.Path)
+	}
+	return paths
+}
+
+// freeName returns path, or path with a numeric suffix before its
+// extension (report-1.csv) when something already exists there.
+func freeName(path string) (string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 0; i < 10000; i++ {
+		candidate := path
+		if i > 0 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free name for %s", path)
+}
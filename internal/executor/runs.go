@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// hostname is exposed to templates as {{.Hostname}}.
+var hostname, _ = os.Hostname()
+
+type runState struct {
+	count  int
+	last   time.Time
+	failed bool // Whether the last finished run failed
+}
+
+var (
+	runsMu sync.Mutex
+	runs   = make(map[string]*runState) // By job name
+)
+
+// peekRun returns the number the next run of job will have and when the
+// previous one started (zero before the first run).
+func peekRun(job string) (number int, last time.Time) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	if state, ok := runs[job]; ok {
+		return state.count + 1, state.last
+	}
+	return 1, time.Time{}
+}
+
+// recordRun counts a run of job starting now.
+func recordRun(job string) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	state, ok := runs[job]
+	if !ok {
+		state = &runState{}
+		runs[job] = state
+	}
+	state.count++
+	state.last = time.Now()
+}
+
+// recordResult stores whether the last run of job failed.
+func recordResult(job string, err error) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	if state, ok := runs[job]; ok {
+		state.failed = err != nil
+	}
+}
+
+// lastFailed reports whether the previous run of job failed.
+func lastFailed(job string) bool {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	state, ok := runs[job]
+	return ok && state.failed
+}
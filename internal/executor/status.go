@@ -0,0 +1,150 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// statusInterval is how often the status line is redrawn.
+const statusInterval = 100 * time.Millisecond
+
+// maxStatusCommand is how much of a command the status line shows.
+const maxStatusCommand = 50
+
+// eraseLine moves the cursor to the start of the line and clears it.
+const eraseLine = "\r\033[K"
+
+// status is the --status-line, or nil without it.
+var status *statusLine
+
+// statusLine is a line at the bottom of the terminal that shows the
+// commands that are running and for how long, e.g. "running go test… 12.3s".
+// It's erased before anything else is written to the terminal, and drawn
+// again once the output is at the start of a line.
+type statusLine struct {
+	mu          sync.Mutex
+	out         *os.File
+	runs        []*statusRun
+	drawn       bool // The status line is on the screen
+	atLineStart bool // The last output ended with a newline
+	stop        chan struct{}
+}
+
+type statusRun struct {
+	label string
+	start time.Time
+}
+
+// EnableStatusLine turns on the status line for the commands of all jobs,
+// when stdout and stderr are terminals, and reports whether it did.
+func EnableStatusLine() bool {
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	enableVirtualTerminal()
+	status = &statusLine{out: os.Stdout, atLineStart: true}
+	return true
+}
+
+// StatusWriter returns w, or a writer that keeps the status line out of
+// what's written to w while it's enabled, e.g. for the log.
+func StatusWriter(w io.Writer) io.Writer {
+	if status == nil {
+		return w
+	}
+	return status.writer(w)
+}
+
+// begin shows command on the status line until the returned function is
+// called.
+func (s *statusLine) begin(command string) func() {
+	label, _, _ := strings.Cut(strings.TrimSpace(command), "\n")
+	if utf8.RuneCountInString(label) > maxStatusCommand {
+		label = string([]rune(label)[:maxStatusCommand-1]) + "…"
+	}
+	run := &statusRun{label: label, start: time.Now()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs = append(s.runs, run)
+	if s.stop == nil {
+		s.stop = make(chan struct{})
+		go s.refresh(s.stop)
+	}
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, r := range s.runs {
+			if r == run {
+				s.runs = append(s.runs[:i], s.runs[i+1:]...)
+				break
+			}
+		}
+		if len(s.runs) == 0 {
+			close(s.stop)
+			s.stop = nil
+			s.erase()
+		}
+	}
+}
+
+// refresh redraws the status line until stop is closed.
+func (s *statusLine) refresh(stop chan struct{}) {
+	ticker := time.NewTicker(statusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		if len(s.runs) > 0 && s.atLineStart {
+			run := s.runs[0]
+			text := fmt.Sprintf("running %s %.1fs", run.label, time.Since(run.start).Seconds())
+			if len(s.runs) > 1 {
+				text += fmt.Sprintf(" (+%d more)", len(s.runs)-1)
+			}
+			s.out.WriteString(eraseLine + text)
+			s.drawn = true
+		}
+		s.mu.Unlock()
+	}
+}
+
+// erase removes the status line from the screen. s.mu must be held.
+func (s *statusLine) erase() {
+	if s.drawn {
+		s.out.WriteString(eraseLine)
+		s.drawn = false
+	}
+}
+
+// writer returns a writer that erases the status line before it writes to
+// w.
+func (s *statusLine) writer(w io.Writer) io.Writer {
+	return &statusWriter{status: s, out: w}
+}
+
+type statusWriter struct {
+	status *statusLine
+	out    io.Writer
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.status.mu.Lock()
+	defer w.status.mu.Unlock()
+	w.status.erase()
+	if len(p) > 0 {
+		w.status.atLineStart = bytes.HasSuffix(p, []byte("\n"))
+	}
+	return w.out.Write(p)
+}
@@ -0,0 +1,142 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// Escape codes for the terminal title: xterm's push and pop of the title
+// stack, and OSC 0, which sets the title.
+const (
+	pushTitle = "\033[22;0t"
+	popTitle  = "\033[23;0t"
+	setTitle  = "\033]0;%s\007"
+)
+
+// Terminal shows the state of runs on the terminal: it rings the bell when
+// one fails (--bell) and keeps the title up to date (--set-title), so a
+// minimized terminal still tells whether the last build passed. A nil
+// *Terminal does nothing.
+type Terminal struct {
+	bell  bool
+	title bool
+
+	mu      sync.Mutex
+	running []string // Jobs with a run in progress, in the order they started
+	outcome string   // Title showing the outcome of the last run, put back after a skipped one
+}
+
+// NewTerminal returns a Terminal for bell and title, or nil when neither is
+// set or stdout isn't a terminal. With title, the current title is saved and
+// replaced with "watching" until the first run.
+func NewTerminal(bell, title bool) *Terminal {
+	if !bell && !title {
+		return nil
+	}
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	enableVirtualTerminal()
+	t := &Terminal{bell: bell, title: title}
+	if title {
+		os.Stdout.WriteString(pushTitle)
+		t.outcome = "gowatchrun: watching"
+		t.setTitle(t.outcome)
+	}
+	return t
+}
+
+// Track wraps execFunc to show the state of every run.
+func (t *Terminal) Track(execFunc watcher.ExecutorFunc) watcher.ExecutorFunc {
+	if t == nil {
+		return execFunc
+	}
+	return func(ctx context.Context, cfg watcher.Config, data *watcher.EventData) watcher.ExecutionResult {
+		job := cfg.Name
+		t.mu.Lock()
+		t.running = append(t.running, job)
+		t.showRunning()
+		t.mu.Unlock()
+
+		result := execFunc(ctx, cfg, data)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if i := slices.Index(t.running, job); i >= 0 {
+			t.running = slices.Delete(t.running, i, i+1)
+		}
+		if !result.OK() && t.bell {
+			os.Stdout.WriteString("\a")
+		}
+		duration := result.Duration.Round(100 * time.Millisecond)
+		switch {
+		case result.Skipped:
+		case result.OK():
+			t.outcome = strings.Join(nonEmpty("✓", job, "("+duration.String()+")"), " ")
+		default:
+			t.outcome = strings.Join(nonEmpty("✗", job, fmt.Sprintf("(%s, exit %d)", duration, result.ExitCode)), " ")
+		}
+		if len(t.running) > 0 {
+			// The title keeps showing the runs in progress
+			t.showRunning()
+			return result
+		}
+		t.setTitle(t.outcome)
+		return result
+	}
+}
+
+// Restore puts back the title the terminal had before NewTerminal.
+func (t *Terminal) Restore() {
+	if t == nil || !t.title {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	os.Stdout.WriteString(popTitle)
+}
+
+// showRunning sets the title to the jobs that are running. t.mu must be
+// held.
+func (t *Terminal) showRunning() {
+	var jobs []string
+	for _, job := range nonEmpty(t.running...) {
+		if !slices.Contains(jobs, job) {
+			jobs = append(jobs, job)
+		}
+	}
+	t.setTitle(strings.Join(nonEmpty("… building", strings.Join(jobs, ", ")), " "))
+}
+
+func (t *Terminal) setTitle(title string) {
+	if !t.title {
+		return
+	}
+	// Control characters would end the escape sequence early
+	title = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, title)
+	fmt.Fprintf(os.Stdout, setTitle, title)
+}
+
+// nonEmpty returns the strings of parts that aren't empty; jobs run with
+// flags have no name.
+func nonEmpty(parts ...string) []string {
+	var list []string
+	for _, part := range parts {
+		if part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
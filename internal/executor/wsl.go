@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+	"github.com/s0up4200/gowatchrun/internal/wsl"
+)
+
+// wslData returns a copy of data with its paths translated for a command
+// that runs on the other side of WSL (--wsl-interop).
+func wslData(data *watcher.EventData) *watcher.EventData {
+	translated := *data
+	if data.Path != "" {
+		translated.Path = wsl.Translate(data.Path)
+		translated.PathSlash = strings.ReplaceAll(translated.Path, `\`, "/")
+	}
+	if data.Dir != "" {
+		translated.Dir = wsl.Translate(data.Dir)
+	}
+	if data.Sidecar != "" {
+		translated.Sidecar = wsl.Translate(data.Sidecar)
+	}
+	translated.Files = wslFiles(data.Files)
+	translated.Payloads = wslFiles(data.Payloads)
+	return &translated
+}
+
+func wslFiles(files []watcher.EventData) []watcher.EventData {
+	if files == nil {
+		return nil
+	}
+	translated := make([]watcher.EventData, len(files))
+	for i := range files {
+		translated[i] = *wslData(&files[i])
+	}
+	return translated
+}
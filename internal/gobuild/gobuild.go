@@ -0,0 +1,148 @@
+// Package gobuild compiles --go-handler files into handler executables and
+// compiles them again when they change.
+package gobuild
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// mainSource is the main function compiled into every handler.
+const mainSource = `// Code generated by gowatchrun for --go-handler. DO NOT EDIT.
+
+package main
+
+import "github.com/s0up4200/gowatchrun/gohandler"
+
+func main() {
+	gohandler.Serve(Handle)
+}
+`
+
+// mainName is the name the generated main file appears under next to the
+// handler file, through the go command's -overlay.
+const mainName = "gowatchrun_handler_main.go"
+
+// pollInterval is how often the handler file is checked for changes.
+const pollInterval = time.Second
+
+// Handler is a --go-handler file and the executables built from it.
+type Handler struct {
+	file    string // Absolute path of the source file
+	dir     string // Cache directory for the overlay and the executables
+	overlay string
+	logger  zerolog.Logger
+
+	builds  int
+	modTime time.Time
+	size    int64
+}
+
+// Check reports whether Go handlers can be compiled: the go command must be
+// on PATH.
+func Check() error {
+	if _, err := exec.LookPath("go"); err != nil {
+		return fmt.Errorf("--go-handler needs the go command on PATH: %w", err)
+	}
+	return nil
+}
+
+// New prepares the compilation of file. Executables are kept in the user's
+// cache directory, in a directory of their own for every handler file.
+func New(file string, logger zerolog.Logger) (*Handler, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(abs); err != nil {
+		return nil, fmt.Errorf("failed to read the Go handler: %w", err)
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		cache = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(abs))
+	dir := filepath.Join(cache, "gowatchrun", "go-handler", hex.EncodeToString(sum[:8]))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	mainPath := filepath.Join(dir, mainName)
+	if err := os.WriteFile(mainPath, []byte(mainSource), 0o600); err != nil {
+		return nil, err
+	}
+	overlay, err := json.Marshal(map[string]map[string]string{
+		"Replace": {filepath.Join(filepath.Dir(abs), mainName): mainPath},
+	})
+	if err != nil {
+		return nil, err
+	}
+	h := &Handler{file: abs, dir: dir, overlay: filepath.Join(dir, "overlay.json"), logger: logger}
+	if err := os.WriteFile(h.overlay, overlay, 0o600); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Build compiles the handler and returns the path of the executable. Builds
+// alternate between two executables, so a build never replaces the one
+// that's running.
+func (h *Handler) Build(ctx context.Context) (string, error) {
+	if info, err := os.Stat(h.file); err == nil {
+		h.modTime, h.size = info.ModTime(), info.Size()
+	}
+	h.builds++
+	out := filepath.Join(h.dir, fmt.Sprintf("handler-%d", h.builds%2))
+	if runtime.GOOS == "windows" {
+		out += ".exe"
+	}
+
+	start := time.Now()
+	src := filepath.Dir(h.file)
+	cmd := exec.CommandContext(ctx, "go", "build", "-overlay", h.overlay, "-o", out, h.file, filepath.Join(src, mainName))
+	cmd.Dir = src
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("failed to compile %s: %w\n%s", h.file, err, strings.TrimSpace(string(output)))
+	}
+	h.logger.Info().Msgf("Compiled %s in %s", h.file, time.Since(start).Round(time.Millisecond))
+	return out, nil
+}
+
+// Watch compiles the handler again whenever its file changes and passes
+// the new executable to reload, until ctx is done. A handler that doesn't
+// compile is logged, and the previous executable keeps running.
+func (h *Handler) Watch(ctx context.Context, reload func(binary string) error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(h.file)
+		if err != nil || (info.ModTime().Equal(h.modTime) && info.Size() == h.size) {
+			continue
+		}
+		h.logger.Info().Msgf("%s changed, compiling", h.file)
+		binary, err := h.Build(ctx)
+		if err != nil {
+			h.logger.Error().Msgf("%v", err)
+			continue
+		}
+		if err := reload(binary); err != nil {
+			h.logger.Error().Msgf("Failed to start the new handler: %v", err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package grpcapi serves the gRPC API defined in api/gowatchrun/v1, so
+// tooling can follow and control a fleet of gowatchrun agents.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	gowatchrunv1 "github.com/s0up4200/gowatchrun/api/gowatchrun/v1"
+	"github.com/s0up4200/gowatchrun/internal/audit"
+	"github.com/s0up4200/gowatchrun/internal/auth"
+	"github.com/s0up4200/gowatchrun/internal/secret"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// Serve listens on addr (e.g. ":9090") and serves the API in the
+// background, to the clients authConfig accepts. Changes clients make are
+// recorded in auditLog.
+func Serve(addr string, health *watcher.Health, control *watcher.Control, authConfig auth.Config, auditLog *audit.Log) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC clients on %s: %w", addr, err)
+	}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := authorize(ctx, authConfig); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := authorize(stream.Context(), authConfig); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	}
+	if tlsConfig := authConfig.TLSConfig(); tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	srv := grpc.NewServer(opts...)
+	gowatchrunv1.RegisterGowatchrunServer(srv, &server{health: health, control: control, audit: auditLog})
+	reflection.Register(srv) // For grpcurl and similar tools
+	log.Info().Msgf("Serving the gRPC API on %s", listener.Addr())
+	go func() {
+		if err := srv.Serve(listener); err != nil {
+			log.Error().Msgf("gRPC server stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+type server struct {
+	gowatchrunv1.UnimplementedGowatchrunServer
+	health  *watcher.Health
+	control *watcher.Control
+	audit   *audit.Log
+}
+
+func (s *server) Status(ctx context.Context, req *gowatchrunv1.StatusRequest) (*gowatchrunv1.StatusResponse, error) {
+	health := s.health.Status()
+	uptime, _ := time.ParseDuration(health.Uptime)
+	hostname, _ := os.Hostname()
+	resp := &gowatchrunv1.StatusResponse{
+		Healthy:  health.Healthy,
+		Uptime:   durationpb.New(uptime),
+		Hostname: hostname,
+	}
+	for _, job := range health.Jobs {
+		resp.Jobs = append(resp.Jobs, &gowatchrunv1.JobStatus{
+			Name:          job.Name,
+			Alive:         job.Alive,
+			Running:       job.Running,
+			Busy:          job.Busy,
+			Paused:        s.control.Paused(job.Name),
+			Watches:       int32(job.Watches),
+			WatchFailures: int32(job.WatchFailures),
+			LastEvent:     timestamp(job.LastEvent),
+			LastRun:       timestamp(job.LastRun),
+			LastResult:    job.LastResult,
+		})
+	}
+	return resp, nil
+}
+
+func (s *server) StreamEvents(req *gowatchrunv1.StreamEventsRequest, stream grpc.ServerStreamingServer[gowatchrunv1.Event]) error {
+	events, stop := s.control.Subscribe()
+	defer stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			if len(req.Jobs) > 0 && !slices.Contains(req.Jobs, event.Job) {
+				continue
+			}
+			if err := stream.Send(convertEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *server) Trigger(ctx context.Context, req *gowatchrunv1.TriggerRequest) (*gowatchrunv1.TriggerResponse, error) {
+	err := s.control.Trigger(req.Job, req.Path)
+	s.audit.Request(audit.ActionTrigger, actor(ctx), req.Job, req.Path, err)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &gowatchrunv1.TriggerResponse{}, nil
+}
+
+func (s *server) Pause(ctx context.Context, req *gowatchrunv1.PauseRequest) (*gowatchrunv1.PauseResponse, error) {
+	err := s.control.Pause(req.Job)
+	s.audit.Request(audit.ActionPause, actor(ctx), req.Job, "", err)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &gowatchrunv1.PauseResponse{}, nil
+}
+
+func (s *server) Resume(ctx context.Context, req *gowatchrunv1.ResumeRequest) (*gowatchrunv1.ResumeResponse, error) {
+	err := s.control.Resume(req.Job)
+	s.audit.Request(audit.ActionResume, actor(ctx), req.Job, "", err)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &gowatchrunv1.ResumeResponse{}, nil
+}
+
+// authorize checks the "authorization: Bearer <token>" metadata of a call.
+func authorize(ctx context.Context, authConfig auth.Config) error {
+	if authConfig.Token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok && authConfig.Valid(token) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid token")
+}
+
+// actor returns the client of a call, with the subject of its certificate
+// when it sent one.
+func actor(ctx context.Context) audit.Actor {
+	actor := audit.Actor{Via: "grpc"}
+	if p, ok := peer.FromContext(ctx); ok {
+		actor.Addr = p.Addr.String()
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.PeerCertificates) > 0 {
+			actor.User = info.State.PeerCertificates[0].Subject.String()
+		}
+	}
+	return actor
+}
+
+func statusError(err error) error {
+	if errors.Is(err, watcher.ErrUnknownJob) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if errors.Is(err, watcher.ErrRejectedPath) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.FailedPrecondition, err.Error())
+}
+
+var eventKinds = map[string]gowatchrunv1.Event_Kind{
+	watcher.EventAccepted: gowatchrunv1.Event_KIND_EVENT,
+	watcher.RunStarted:    gowatchrunv1.Event_KIND_RUN_STARTED,
+	watcher.RunFinished:   gowatchrunv1.Event_KIND_RUN_FINISHED,
+	watcher.JobPaused:     gowatchrunv1.Event_KIND_PAUSED,
+	watcher.JobResumed:    gowatchrunv1.Event_KIND_RESUMED,
+}
+
+func convertEvent(event watcher.ControlEvent) *gowatchrunv1.Event {
+	msg := &gowatchrunv1.Event{
+		Kind: eventKinds[event.Kind],
+		Job:  event.Job,
+		Run:  event.Run,
+		Time: timestamppb.New(event.Time),
+	}
+	if data := event.Data; data != nil {
+		msg.Event = data.Event
+		if len(data.Files) > 0 {
+			for _, file := range data.Files {
+				msg.Paths = append(msg.Paths, secret.Redact(file.Path))
+			}
+		} else if data.Path != "" {
+			msg.Paths = []string{secret.Redact(data.Path)}
+		}
+	}
+	if event.Kind == watcher.RunFinished {
+		msg.Duration = durationpb.New(event.Result.Duration)
+		msg.ExitCode = int32(event.Result.ExitCode)
+		if event.Result.Err != nil {
+			msg.Error = secret.Redact(event.Result.Err.Error())
+		}
+	}
+	return msg
+}
+
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,207 @@
+// Package handler runs Starlark scripts (--handler) that decide what a run
+// does with an event. The script defines a function
+//
+//	def handle(event):
+//	    ...
+//
+// which receives the event as a struct with the fields --worker handlers get
+// (path, name, event, ext, dir, base_name, size, ...; files and payload are
+// lists and dicts) and returns:
+//
+//   - None or True to run as configured,
+//   - False to skip the run,
+//   - a string, a command template to run instead of the configured one,
+//   - or a dict with any of "command" (as above), "path" (replaces the
+//     event's path) and "env" (a dict of environment variables for the
+//     command).
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+
+	"github.com/rs/zerolog"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkjson"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// maxSteps bounds the work a single call of handle may do, so a script that
+// loops forever fails instead of blocking the job.
+const maxSteps = 100_000_000
+
+// Handler is a loaded handler script.
+type Handler struct {
+	handle starlark.Callable
+}
+
+// Result is what handle decided for an event.
+type Result struct {
+	Skip    bool
+	Command string            // Replaces the command template when set
+	Path    string            // Replaces the event's path when set
+	Env     map[string]string // Environment variables added for the command
+}
+
+// Load runs the script at path once and returns its handle function.
+func Load(path string) (*Handler, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read handler: %w", err)
+	}
+	thread := &starlark.Thread{Name: "load", Print: func(*starlark.Thread, string) {}}
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{Set: true, While: true, TopLevelControl: true}, thread, path, src, predeclared())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load handler: %w", err)
+	}
+	globals.Freeze()
+	handle, ok := globals["handle"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("handler %s doesn't define a handle(event) function", path)
+	}
+	return &Handler{handle: handle}, nil
+}
+
+// Handle calls handle with event, a value that encodes to a JSON object.
+// Output of print goes to logger. The call is aborted when ctx is done.
+func (h *Handler) Handle(ctx context.Context, event any, logger zerolog.Logger) (Result, error) {
+	var result Result
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return result, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return result, err
+	}
+	dict := make(starlark.StringDict, len(fields))
+	for name, value := range fields {
+		dict[name] = toStarlark(value)
+	}
+
+	thread := &starlark.Thread{
+		Name:  "handle",
+		Print: func(_ *starlark.Thread, msg string) { logger.Info().Msgf("handler: %s", msg) },
+	}
+	thread.SetMaxExecutionSteps(maxSteps)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+	value, err := starlark.Call(thread, h.handle, starlark.Tuple{starlarkstruct.FromStringDict(starlarkstruct.Default, dict)}, nil)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return result, fmt.Errorf("%s", evalErr.Backtrace())
+		}
+		return result, err
+	}
+
+	switch v := value.(type) {
+	case starlark.NoneType:
+	case starlark.Bool:
+		result.Skip = !bool(v)
+	case starlark.String:
+		result.Command = string(v)
+	case *starlark.Dict:
+		for _, item := range v.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return result, fmt.Errorf("handle returned a dict with a non-string key %s", item[0])
+			}
+			switch key {
+			case "command", "path":
+				s, ok := starlark.AsString(item[1])
+				if !ok {
+					return result, fmt.Errorf("handle returned a %s for %q, expected a string", item[1].Type(), key)
+				}
+				if key == "command" {
+					result.Command = s
+				} else {
+					result.Path = s
+				}
+			case "env":
+				env, ok := item[1].(*starlark.Dict)
+				if !ok {
+					return result, fmt.Errorf("handle returned a %s for \"env\", expected a dict", item[1].Type())
+				}
+				result.Env = make(map[string]string, env.Len())
+				for _, pair := range env.Items() {
+					name, ok := starlark.AsString(pair[0])
+					if !ok || name == "" {
+						return result, fmt.Errorf("handle returned an invalid environment variable name %s", pair[0])
+					}
+					if s, ok := starlark.AsString(pair[1]); ok {
+						result.Env[name] = s
+					} else {
+						result.Env[name] = pair[1].String()
+					}
+				}
+			default:
+				return result, fmt.Errorf("handle returned a dict with an unknown key %q (expected command, path or env)", key)
+			}
+		}
+	default:
+		return result, fmt.Errorf("handle returned a %s, expected None, a bool, a string or a dict", value.Type())
+	}
+	return result, nil
+}
+
+// EnvList returns the environment variables of r as NAME=value, sorted by
+// name.
+func (r Result) EnvList() []string {
+	env := make([]string, 0, len(r.Env))
+	for name, value := range r.Env {
+		env = append(env, name+"="+value)
+	}
+	slices.Sort(env)
+	return env
+}
+
+// predeclared are the names available to scripts besides the Starlark
+// built-ins: the json module (json.decode, json.encode) and struct.
+func predeclared() starlark.StringDict {
+	return starlark.StringDict{
+		"json":   starlarkjson.Module,
+		"struct": starlark.NewBuiltin("struct", starlarkstruct.Make),
+	}
+}
+
+// toStarlark converts a value decoded from JSON to Starlark.
+func toStarlark(value any) starlark.Value {
+	switch v := value.(type) {
+	case nil:
+		return starlark.None
+	case bool:
+		return starlark.Bool(v)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return starlark.MakeInt64(int64(v))
+		}
+		return starlark.Float(v)
+	case string:
+		return starlark.String(v)
+	case []any:
+		list := make([]starlark.Value, len(v))
+		for i, elem := range v {
+			list[i] = toStarlark(elem)
+		}
+		return starlark.NewList(list)
+	case map[string]any:
+		dict := starlark.NewDict(len(v))
+		for key, elem := range v {
+			dict.SetKey(starlark.String(key), toStarlark(elem))
+		}
+		return dict
+	}
+	return starlark.String(fmt.Sprint(value))
+}
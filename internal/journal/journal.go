@@ -0,0 +1,123 @@
+// Package journal records every execution as a line of JSON, so runs can be
+// analyzed after the fact with `gowatchrun stats`.
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// DefaultPath is where the journal is written when --journal is given
+// without a file.
+const DefaultPath = ".gowatchrun-journal.jsonl"
+
+// Entry is one execution.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Job        string    `json:"job,omitempty"`
+	Event      string    `json:"event,omitempty"`
+	Files      []string  `json:"files,omitempty"` // Triggering files, several for a batch
+	DurationMS float64   `json:"duration_ms"`
+	ExitCode   int       `json:"exit_code"`
+}
+
+// Duration returns how long the execution took.
+func (e Entry) Duration() time.Duration {
+	return time.Duration(e.DurationMS * float64(time.Millisecond))
+}
+
+// Journal appends entries to a file.
+type Journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Open opens the journal at path for appending, creating it if needed.
+func Open(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{file: file}, nil
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	return j.file.Close()
+}
+
+// Track wraps execFunc to record every execution in the journal. Skipped
+// runs aren't recorded.
+func (j *Journal) Track(execFunc watcher.ExecutorFunc) watcher.ExecutorFunc {
+	return func(ctx context.Context, cfg watcher.Config, data *watcher.EventData) watcher.ExecutionResult {
+		start := time.Now()
+		result := execFunc(ctx, cfg, data)
+		if result.Skipped {
+			return result
+		}
+
+		entry := Entry{
+			Time:       start,
+			Job:        cfg.Name,
+			DurationMS: float64(result.Duration.Microseconds()) / 1000,
+			ExitCode:   result.ExitCode,
+		}
+		if data != nil {
+			entry.Event = data.Event
+			if len(data.Files) > 0 {
+				for _, file := range data.Files {
+					entry.Files = append(entry.Files, file.Path)
+				}
+			} else if data.Path != "" {
+				entry.Files = []string{data.Path}
+			}
+		}
+		if writeErr := j.write(entry); writeErr != nil {
+			logger := cfg.Logger()
+			logger.Warn().Msgf("Failed to write journal entry: %v", writeErr)
+		}
+		return result
+	}
+}
+
+func (j *Journal) write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	_, err = j.file.Write(append(line, '\n'))
+	return err
+}
+
+// Read returns all entries of the journal at path.
+func Read(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,268 @@
+// Package manifest reads the payload lists of manifest files and waits for
+// the payloads they list to arrive.
+package manifest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultKey is the JSON key, XML element or attribute that holds payload
+// paths when none is given.
+const DefaultKey = "path"
+
+// DefaultTimeout is how long Wait waits for the payloads of a manifest when
+// no timeout is given, so a payload that never arrives doesn't block the job
+// forever.
+const DefaultTimeout = 10 * time.Minute
+
+// Parse returns the payload paths listed in the manifest at path, resolved
+// against the manifest's directory, in order and without duplicates.
+//
+// JSON manifests may be an array of paths, and any string (or array of
+// strings) under key is taken as a path, however deeply nested. In XML
+// manifests, the text of every element named key and the value of every
+// attribute named key are paths. Any other file lists one path per line;
+// empty lines and lines starting with # are skipped.
+func Parse(path, key string) ([]string, error) {
+	if key == "" {
+		key = DefaultKey
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var listed []string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		listed, err = parseJSON(f, key)
+	case ".xml":
+		listed, err = parseXML(f, key)
+	default:
+		listed, err = parseLines(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	seen := make(map[string]bool)
+	var payloads []string
+	for _, p := range listed {
+		p = filepath.FromSlash(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			payloads = append(payloads, p)
+		}
+	}
+	if len(payloads) == 0 {
+		return nil, fmt.Errorf("manifest %s lists no payload files (key '%s')", path, key)
+	}
+	return payloads, nil
+}
+
+func parseJSON(r io.Reader, key string) ([]string, error) {
+	var doc any
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var paths []string
+	var walk func(v any, listed bool)
+	walk = func(v any, listed bool) {
+		switch v := v.(type) {
+		case string:
+			if listed {
+				paths = append(paths, v)
+			}
+		case []any:
+			for _, item := range v {
+				walk(item, listed)
+			}
+		case map[string]any:
+			for k, item := range v {
+				walk(item, k == key)
+			}
+		}
+	}
+	// A top-level array lists paths directly
+	_, isArray := doc.([]any)
+	walk(doc, isArray)
+	return paths, nil
+}
+
+func parseXML(r io.Reader, key string) ([]string, error) {
+	var paths []string
+	decoder := xml.NewDecoder(r)
+	depth := 0 // Nesting inside an element named key
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return paths, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				if attr.Name.Local == key {
+					paths = append(paths, attr.Value)
+				}
+			}
+			if t.Name.Local == key {
+				if depth == 0 {
+					text.Reset()
+				}
+				depth++
+			}
+		case xml.CharData:
+			if depth > 0 {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local == key && depth > 0 {
+				depth--
+				if depth == 0 {
+					paths = append(paths, text.String())
+				}
+			}
+		}
+	}
+}
+
+func parseLines(r io.Reader) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			paths = append(paths, line)
+		}
+	}
+	return paths, scanner.Err()
+}
+
+// Confine returns an error naming the first of payloads that isn't inside
+// any of dirs, such as ../../etc/passwd, so a manifest can't make the job
+// read or move files elsewhere. Symlinks in the part of a path that exists
+// are resolved first.
+func Confine(payloads, dirs []string) error {
+	resolved := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			resolved = append(resolved, resolve(abs))
+		}
+	}
+	for _, p := range payloads {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		abs = resolve(abs)
+		inside := false
+		for _, dir := range resolved {
+			if rel, err := filepath.Rel(dir, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return fmt.Errorf("payload %s is outside the manifest's directory and the watched directories", p)
+		}
+	}
+	return nil
+}
+
+// resolve returns the absolute path with the symlinks in its longest
+// existing prefix resolved.
+func resolve(path string) string {
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest)
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// Wait blocks until every payload exists and its size and modification
+// time have been unchanged for window. It fails once timeout (when set)
+// has passed or ctx is cancelled, naming a payload that is missing or
+// still changing.
+func Wait(ctx context.Context, payloads []string, window, timeout time.Duration) error {
+	type state struct {
+		size        int64
+		modTime     time.Time
+		stableSince time.Time
+		exists      bool
+	}
+	states := make([]state, len(payloads))
+	poll := max(window/4, 50*time.Millisecond)
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		pending := -1
+		for i, p := range payloads {
+			s := &states[i]
+			info, err := os.Stat(p)
+			switch {
+			case err != nil:
+				*s = state{}
+			case !s.exists || info.Size() != s.size || !info.ModTime().Equal(s.modTime):
+				*s = state{size: info.Size(), modTime: info.ModTime(), stableSince: now, exists: true}
+			}
+			if pending < 0 && (!s.exists || now.Sub(s.stableSince) < window) {
+				pending = i
+			}
+		}
+		if pending < 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			var missing []string
+			for i, s := range states {
+				if !s.exists {
+					missing = append(missing, payloads[i])
+				}
+			}
+			if len(missing) > 0 {
+				return fmt.Errorf("timed out after %s: %d of %d payload(s) missing, e.g. %s", timeout, len(missing), len(payloads), missing[0])
+			}
+			return fmt.Errorf("timed out after %s: %s is still changing", timeout, payloads[pending])
+		case <-ticker.C:
+		}
+	}
+}
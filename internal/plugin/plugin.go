@@ -0,0 +1,163 @@
+// Package plugin runs external executables named gowatchrun-<name>, found
+// on PATH, as filters, actions or notifiers, so gowatchrun can be extended
+// without changing it.
+//
+// A plugin is started for every call with its role (filter, action or
+// notify) as its only argument. It reads one JSON request from stdin:
+//
+//	{"version":1,"role":"filter","job":"thumbnails","event":{"path":"./a.png","event":"CREATE",...}}
+//
+// Notifiers also get the outcome of the run as "result". The plugin may
+// write one JSON response to stdout, and logs to stderr:
+//
+//	{"accept":false,"message":"already processed"}
+//
+// A filter rejects the run with "accept": false. An action fails with
+// "error", and any plugin fails when it exits with a non-zero status.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/worker"
+)
+
+// Prefix is what the names of plugin executables start with.
+const Prefix = "gowatchrun-"
+
+// Version is the version of the protocol, sent with every request.
+const Version = 1
+
+// Roles of a plugin.
+const (
+	RoleFilter = "filter"
+	RoleAction = "action"
+	RoleNotify = "notify"
+)
+
+// Timeout is how long filters and notifiers may take. Actions run as long as
+// the run does.
+const Timeout = 30 * time.Second
+
+// Request is what a plugin reads from stdin.
+type Request struct {
+	Version int                `json:"version"`
+	Role    string             `json:"role"`
+	Job     string             `json:"job,omitempty"`
+	Event   worker.EventParams `json:"event"`
+	Result  *Result            `json:"result,omitempty"` // Notifiers only
+}
+
+// Result is the outcome of a run, for notifiers.
+type Result struct {
+	Success    bool   `json:"success"`
+	ExitCode   int    `json:"exit_code"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	OutputTail string `json:"output_tail,omitempty"` // Failed runs: the end of the command's output
+}
+
+// Response is what a plugin may write to stdout.
+type Response struct {
+	Accept  *bool  `json:"accept,omitempty"`  // Filters: false rejects the run
+	Error   string `json:"error,omitempty"`   // Fails the call
+	Message string `json:"message,omitempty"` // Logged
+}
+
+// Plugin is a plugin executable.
+type Plugin struct {
+	Name string
+	Path string
+}
+
+// Find looks up the plugin called name on PATH.
+func Find(name string) (Plugin, error) {
+	path, err := exec.LookPath(Prefix + name)
+	if err != nil {
+		return Plugin{}, fmt.Errorf("plugin '%s' not found: no %s%s executable on PATH", name, Prefix, name)
+	}
+	return Plugin{Name: name, Path: path}, nil
+}
+
+// List returns the plugins on PATH, by name. When several directories
+// hold a plugin of the same name, the first one wins, as with Find.
+func List() []Plugin {
+	var plugins []Plugin
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), Prefix)
+			if !ok || entry.IsDir() {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				ext := filepath.Ext(name)
+				if !slices.Contains([]string{".exe", ".bat", ".cmd", ".com"}, strings.ToLower(ext)) {
+					continue
+				}
+				name = strings.TrimSuffix(name, ext)
+			} else if info, err := entry.Info(); err != nil || info.Mode()&0o111 == 0 {
+				continue
+			}
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, Plugin{Name: name, Path: filepath.Join(dir, entry.Name())})
+		}
+	}
+	slices.SortFunc(plugins, func(a, b Plugin) int { return strings.Compare(a.Name, b.Name) })
+	return plugins
+}
+
+// Call runs the plugin for req and returns its response. The plugin is
+// killed when ctx is done.
+func (p Plugin) Call(ctx context.Context, req Request) (Response, error) {
+	var resp Response
+	req.Version = Version
+	input, err := json.Marshal(req)
+	if err != nil {
+		return resp, err
+	}
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path, req.Role)
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+
+	output := bytes.TrimSpace(stdout.Bytes())
+	if len(output) > 0 {
+		if err := json.Unmarshal(output, &resp); err != nil {
+			return resp, fmt.Errorf("plugin '%s' wrote an invalid response: %w", p.Name, err)
+		}
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(runErr, &exitErr):
+		if resp.Error != "" {
+			return resp, fmt.Errorf("plugin '%s': %s (%w)", p.Name, resp.Error, runErr)
+		}
+		return resp, fmt.Errorf("plugin '%s': %w", p.Name, runErr)
+	case runErr != nil:
+		return resp, fmt.Errorf("failed to run plugin '%s': %w", p.Name, runErr)
+	case resp.Error != "":
+		return resp, fmt.Errorf("plugin '%s': %s", p.Name, resp.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,319 @@
+// Package problem extracts diagnostics (file, line, severity and message)
+// from the output of compilers, linters and test runners with problem
+// matchers: regular expressions whose groups hold the parts of a
+// diagnostic, as in VS Code's problem matchers.
+//
+// A matcher is one of the built-in ones (see Builtins) or a JSON file:
+//
+//	{
+//	  "name": "mylint",
+//	  "severity": "warning",
+//	  "pattern": {"regexp": "^(.+):(\\d+):(\\d+) \\[(\\w+)\\] (.+)$", "file": 1, "line": 2, "column": 3, "severity": 4, "message": 5}
+//	}
+//
+// where the numbers are the groups of the parts, 0 for parts the output
+// doesn't have, and "severity" is used for diagnostics without one. A file
+// may also hold a list of matchers.
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Severities of diagnostics.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// maxDiagnostics is how many diagnostics a run keeps; further ones are
+// counted, but dropped.
+const maxDiagnostics = 1000
+
+// maxLine is the length at which a line of output without a newline is
+// matched anyway.
+const maxLine = 64 << 10
+
+// Diagnostic is a problem a matcher found in the output of a run.
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"` // error, warning or info
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+	Source   string `json:"source"` // Name of the matcher
+}
+
+// String formats d like a compiler would, as file:line:column: severity:
+// message.
+func (d Diagnostic) String() string {
+	location := d.File
+	if d.Line > 0 {
+		location += ":" + strconv.Itoa(d.Line)
+		if d.Column > 0 {
+			location += ":" + strconv.Itoa(d.Column)
+		}
+	}
+	if d.Message == "" {
+		return location + ": " + d.Severity
+	}
+	return fmt.Sprintf("%s: %s: %s", location, d.Severity, d.Message)
+}
+
+// Report is the diagnostics of a run, as written to --diagnostics-file and
+// served by the API.
+type Report struct {
+	Job         string       `json:"job,omitempty"`
+	Time        time.Time    `json:"time"` // When the run finished
+	ExitCode    int          `json:"exit_code"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Dropped     int          `json:"dropped,omitempty"` // Diagnostics beyond the limit a run keeps
+}
+
+// NewReport returns the report of job's run that finished at t.
+func NewReport(job string, t time.Time, exitCode int, diagnostics []Diagnostic) Report {
+	if diagnostics == nil {
+		// Clients shouldn't have to tell null from no problems
+		diagnostics = []Diagnostic{}
+	}
+	return Report{Job: job, Time: t.UTC(), ExitCode: exitCode, Diagnostics: diagnostics}
+}
+
+// Pattern is the regular expression of a matcher and the groups that hold
+// the parts of a diagnostic.
+type Pattern struct {
+	Regexp   string `json:"regexp"`
+	File     int    `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity int    `json:"severity"`
+	Code     int    `json:"code"`
+	Message  int    `json:"message"`
+}
+
+// Matcher finds diagnostics in lines of output.
+type Matcher struct {
+	Name     string  `json:"name"`
+	Severity string  `json:"severity"` // For diagnostics without one; error by default
+	Pattern  Pattern `json:"pattern"`
+
+	re *regexp.Regexp
+}
+
+// Builtins are the matchers that can be used by name.
+var Builtins = map[string]Matcher{
+	// go build, go vet and go test: main.go:12:3: undefined: x
+	"go": {Pattern: Pattern{Regexp: `^\s*((?:[A-Za-z]:)?[^\s:]+\.go):(\d+)(?::(\d+))?: (.+)$`, File: 1, Line: 2, Column: 3, Message: 4}},
+	// gcc and clang: main.c:3:5: error: expected ';'
+	"gcc": {Pattern: Pattern{Regexp: `^((?:[A-Za-z]:)?[^:\s][^:]*):(\d+):(?:(\d+):)?\s+(?:fatal\s+)?(error|warning|note):\s+(.+)$`, File: 1, Line: 2, Column: 3, Severity: 4, Message: 5}},
+	// tsc: src/a.ts(3,5): error TS2322: Type 'string' is not assignable
+	"tsc": {Pattern: Pattern{Regexp: `^([^\s].*)[(:](\d+)[,:](\d+)(?:\):\s+|\s+-\s+)(error|warning|info)\s+(TS\d+)\s*:\s*(.+)$`, File: 1, Line: 2, Column: 3, Severity: 4, Code: 5, Message: 6}},
+	// eslint --format compact: /src/a.js: line 3, col 5, Error - 'x' is not defined. (no-undef)
+	"eslint-compact": {Pattern: Pattern{Regexp: `^(.+):\sline\s(\d+),\scol\s(\d+),\s(Error|Warning|Info)\s-\s(.+?)(?:\s\((.+)\))?$`, File: 1, Line: 2, Column: 3, Severity: 4, Message: 5, Code: 6}},
+	// rustc and cargo, the location line only: --> src/main.rs:4:9
+	"rustc": {Pattern: Pattern{Regexp: `^\s*--> ((?:[A-Za-z]:)?[^:]+):(\d+):(\d+)$`, File: 1, Line: 2, Column: 3}},
+	// Python tracebacks: File "app.py", line 12, in main
+	"python": {Pattern: Pattern{Regexp: `^\s*File "(.+)", line (\d+)(?:, in (.+))?$`, File: 1, Line: 2, Message: 3}},
+}
+
+// Load returns the built-in matcher called name, or else the matchers in
+// the JSON file at name.
+func Load(name string) ([]Matcher, error) {
+	if m, ok := Builtins[name]; ok {
+		m.Name = name
+		if err := m.compile(); err != nil {
+			return nil, err
+		}
+		return []Matcher{m}, nil
+	}
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		if os.IsNotExist(err) && filepath.Ext(name) == "" {
+			return nil, fmt.Errorf("unknown problem matcher '%s' (built-in: %s, or a JSON file)", name, strings.Join(BuiltinNames(), ", "))
+		}
+		return nil, fmt.Errorf("failed to read problem matcher: %w", err)
+	}
+	var matchers []Matcher
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(raw, &matchers)
+	} else {
+		var m Matcher
+		err = json.Unmarshal(raw, &m)
+		matchers = []Matcher{m}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid problem matcher %s: %w", name, err)
+	}
+	for i := range matchers {
+		if matchers[i].Name == "" {
+			matchers[i].Name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+		}
+		if err := matchers[i].compile(); err != nil {
+			return nil, fmt.Errorf("invalid problem matcher %s: %w", name, err)
+		}
+	}
+	return matchers, nil
+}
+
+// BuiltinNames returns the names of the built-in matchers, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(Builtins))
+	for name := range Builtins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (m *Matcher) compile() error {
+	re, err := regexp.Compile(m.Pattern.Regexp)
+	if err != nil {
+		return fmt.Errorf("matcher '%s': %w", m.Name, err)
+	}
+	p := m.Pattern
+	if p.File <= 0 {
+		return fmt.Errorf("matcher '%s' has no file group", m.Name)
+	}
+	for _, group := range []int{p.File, p.Line, p.Column, p.Severity, p.Code, p.Message} {
+		if group < 0 || group > re.NumSubexp() {
+			return fmt.Errorf("matcher '%s' refers to group %d, but its expression has %d", m.Name, group, re.NumSubexp())
+		}
+	}
+	if m.Severity == "" {
+		m.Severity = SeverityError
+	} else if m.Severity = severity(m.Severity); m.Severity == "" {
+		return fmt.Errorf("matcher '%s' has an invalid severity (expected error, warning or info)", m.Name)
+	}
+	m.re = re
+	return nil
+}
+
+// match returns the diagnostic m finds in line, if any.
+func (m *Matcher) match(line string) (Diagnostic, bool) {
+	groups := m.re.FindStringSubmatch(line)
+	if groups == nil {
+		return Diagnostic{}, false
+	}
+	group := func(i int) string {
+		if i <= 0 {
+			return ""
+		}
+		return strings.TrimSpace(groups[i])
+	}
+	d := Diagnostic{File: group(m.Pattern.File), Code: group(m.Pattern.Code), Message: group(m.Pattern.Message), Source: m.Name}
+	d.Line, _ = strconv.Atoi(group(m.Pattern.Line))
+	d.Column, _ = strconv.Atoi(group(m.Pattern.Column))
+	if d.Severity = severity(group(m.Pattern.Severity)); d.Severity == "" {
+		d.Severity = m.Severity
+	}
+	return d, d.File != ""
+}
+
+// severity normalizes the severity a tool reported, or returns "" for an
+// unknown one.
+func severity(s string) string {
+	switch strings.ToLower(s) {
+	case "error", "err", "fatal", "e":
+		return SeverityError
+	case "warning", "warn", "w":
+		return SeverityWarning
+	case "info", "information", "note", "hint", "i":
+		return SeverityInfo
+	}
+	return ""
+}
+
+// Collector collects the diagnostics matchers find in the output of a run.
+type Collector struct {
+	matchers []Matcher
+
+	mu          sync.Mutex
+	diagnostics []Diagnostic
+	dropped     int
+}
+
+// NewCollector returns a collector that runs matchers on every line, in
+// order; the first one that matches a line wins.
+func NewCollector(matchers []Matcher) *Collector {
+	return &Collector{matchers: matchers}
+}
+
+// Writer returns a writer for one stream of output, e.g. stdout.
+func (c *Collector) Writer() io.Writer {
+	return &streamWriter{collector: c}
+}
+
+// Diagnostics returns the diagnostics found so far, in the order of the
+// output, and how many more were dropped.
+func (c *Collector) Diagnostics() ([]Diagnostic, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.diagnostics), c.dropped
+}
+
+func (c *Collector) line(line []byte) {
+	text := string(bytes.TrimRight(line, "\r\n"))
+	for i := range c.matchers {
+		if d, ok := c.matchers[i].match(text); ok {
+			c.mu.Lock()
+			if len(c.diagnostics) < maxDiagnostics {
+				c.diagnostics = append(c.diagnostics, d)
+			} else {
+				c.dropped++
+			}
+			c.mu.Unlock()
+			return
+		}
+	}
+}
+
+// streamWriter splits a stream of output into lines for its collector.
+type streamWriter struct {
+	collector *Collector
+	pending   []byte
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		w.collector.line(w.pending[:i])
+		w.pending = w.pending[i+1:]
+	}
+	if len(w.pending) >= maxLine {
+		w.collector.line(w.pending)
+		w.pending = nil
+	}
+	return len(p), nil
+}
+
+// Count returns the number of diagnostics of every severity.
+func Count(diagnostics []Diagnostic) (errors, warnings, infos int) {
+	for _, d := range diagnostics {
+		switch d.Severity {
+		case SeverityError:
+			errors++
+		case SeverityWarning:
+			warnings++
+		default:
+			infos++
+		}
+	}
+	return errors, warnings, infos
+}
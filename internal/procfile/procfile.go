@@ -0,0 +1,74 @@
+package procfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Entry is one process declared in a Procfile.
+type Entry struct {
+	Name     string
+	Command  string
+	Patterns []string // From a "# watch:" comment directly above the entry
+}
+
+var entryRe = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+
+// Load parses the Procfile at path.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads Procfile entries of the form "name: command". A comment of the
+// form "# watch: *.go *.tmpl" sets the watch patterns of the entry that
+// follows it.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var pending []string
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			pending = nil
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			comment := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			if rest, ok := strings.CutPrefix(comment, "watch:"); ok {
+				pending = strings.Fields(rest)
+			}
+			continue
+		}
+
+		m := entryRe.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: expected 'name: command', got %q", lineNo, line)
+		}
+		if seen[m[1]] {
+			return nil, fmt.Errorf("line %d: duplicate process name '%s'", lineNo, m[1])
+		}
+		seen[m[1]] = true
+		entries = append(entries, Entry{Name: m[1], Command: m[2], Patterns: pending})
+		pending = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no processes defined")
+	}
+	return entries, nil
+}
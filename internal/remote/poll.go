@@ -0,0 +1,187 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Object is a file found in a remote listing.
+type Object struct {
+	Key     string // Path relative to the polled prefix or directory
+	Size    int64
+	ModTime time.Time
+	ETag    string
+}
+
+// Lister lists and downloads files from a remote location.
+type Lister interface {
+	List(ctx context.Context) ([]Object, error)
+	Download(ctx context.Context, key, dst string) error
+	Close() error
+}
+
+// Op describes what happened to a remote object between two polls.
+type Op int
+
+const (
+	OpCreate Op = iota
+	OpWrite
+	OpRemove
+)
+
+// Change is emitted by a Poller for every object that appeared, changed or
+// disappeared. LocalPath is where the object was downloaded to; for removals
+// it is the path the file used to have.
+type Change struct {
+	Op        Op
+	Key       string
+	LocalPath string
+}
+
+// SourceConfig describes a remote event source.
+type SourceConfig struct {
+	URL          string // s3://bucket/prefix or sftp://user@host[:port]/path
+	PollInterval time.Duration
+	S3           S3Config
+	SFTP         SFTPConfig
+}
+
+// NewLister creates the Lister matching the scheme of cfg.URL.
+func NewLister(cfg SourceConfig) (Lister, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source URL %q: %w", cfg.URL, err)
+	}
+	switch u.Scheme {
+	case "s3":
+		s3cfg := cfg.S3
+		s3cfg.Bucket, s3cfg.Prefix, err = ParseS3Target(u.Host + u.Path)
+		if err != nil {
+			return nil, err
+		}
+		return newS3Lister(s3cfg)
+	case "sftp":
+		return newSFTPLister(u, cfg.SFTP)
+	default:
+		return nil, fmt.Errorf("unsupported source scheme %q (expected s3 or sftp)", u.Scheme)
+	}
+}
+
+// Poller periodically lists a remote location and downloads new or changed
+// objects into a local temporary directory.
+type Poller struct {
+	lister   Lister
+	interval time.Duration
+	dir      string
+	seen     map[string]Object
+	logger   zerolog.Logger
+}
+
+// NewPoller creates a Poller for cfg. Downloads are stored in a fresh
+// temporary directory that is removed when the poller stops.
+func NewPoller(cfg SourceConfig, logger zerolog.Logger) (*Poller, error) {
+	lister, err := NewLister(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "gowatchrun-remote-")
+	if err != nil {
+		lister.Close()
+		return nil, err
+	}
+	interval := cfg.PollInterval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Poller{lister: lister, interval: interval, dir: dir, logger: logger}, nil
+}
+
+// Run polls until ctx is cancelled, sending a Change for every difference
+// between consecutive listings. Objects present in the first listing are
+// recorded but not reported.
+func (p *Poller) Run(ctx context.Context, changes chan<- Change) {
+	defer os.RemoveAll(p.dir)
+	defer p.lister.Close()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.poll(ctx, changes)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Poller) poll(ctx context.Context, changes chan<- Change) {
+	objects, err := p.lister.List(ctx)
+	if err != nil {
+		p.logger.Error().Msgf("Failed to list remote source: %v", err)
+		return
+	}
+
+	current := make(map[string]Object, len(objects))
+	for _, obj := range objects {
+		current[obj.Key] = obj
+	}
+
+	if p.seen == nil {
+		p.logger.Debug().Msgf("Initial remote listing found %d objects", len(current))
+		p.seen = current
+		return
+	}
+
+	for key, obj := range current {
+		prev, existed := p.seen[key]
+		if existed && prev.Size == obj.Size && prev.ModTime.Equal(obj.ModTime) && prev.ETag == obj.ETag {
+			continue
+		}
+
+		localPath := p.localPath(key)
+		if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+			p.logger.Error().Msgf("Failed to create download directory for %s: %v", key, err)
+			continue
+		}
+		if err := p.lister.Download(ctx, key, localPath); err != nil {
+			p.logger.Error().Msgf("Failed to download remote object %s: %v", key, err)
+			// Leave it out of seen so the download is retried next poll
+			delete(current, key)
+			if existed {
+				current[key] = prev
+			}
+			continue
+		}
+
+		op := OpWrite
+		if !existed {
+			op = OpCreate
+		}
+		changes <- Change{Op: op, Key: key, LocalPath: localPath}
+	}
+
+	for key := range p.seen {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		localPath := p.localPath(key)
+		os.Remove(localPath)
+		changes <- Change{Op: OpRemove, Key: key, LocalPath: localPath}
+	}
+
+	p.seen = current
+}
+
+func (p *Poller) localPath(key string) string {
+	clean := filepath.FromSlash(strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+key)), "/"))
+	return filepath.Join(p.dir, clean)
+}
@@ -0,0 +1,64 @@
+package remote
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config describes how to reach an S3-compatible bucket.
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"-"`
+	Prefix    string `yaml:"-"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	Insecure  bool   `yaml:"insecure"` // Use plain HTTP instead of HTTPS
+}
+
+// ParseS3Target splits a "bucket/prefix" string into its bucket and prefix.
+// The returned prefix never has a leading slash and, when non-empty, always
+// ends with one.
+func ParseS3Target(target string) (bucket, prefix string, err error) {
+	target = strings.TrimPrefix(target, "s3://")
+	bucket, prefix, _ = strings.Cut(target, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid S3 target %q: missing bucket name", target)
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return bucket, prefix, nil
+}
+
+// NewS3Client creates a client for cfg. When no static keys are configured,
+// credentials are looked up from the usual AWS/MinIO environment variables,
+// credential files and the EC2/ECS metadata service.
+func NewS3Client(cfg S3Config) (*minio.Client, error) {
+	var creds *credentials.Credentials
+	if cfg.AccessKey != "" || cfg.SecretKey != "" {
+		creds = credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, "")
+	} else {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		})
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+
+	return minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	})
+}
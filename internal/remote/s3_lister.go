@@ -0,0 +1,49 @@
+package remote
+
+import (
+	"context"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+type s3Lister struct {
+	client *minio.Client
+	cfg    S3Config
+}
+
+func newS3Lister(cfg S3Config) (*s3Lister, error) {
+	client, err := NewS3Client(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Lister{client: client, cfg: cfg}, nil
+}
+
+func (l *s3Lister) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	opts := minio.ListObjectsOptions{Prefix: l.cfg.Prefix, Recursive: true}
+	for info := range l.client.ListObjects(ctx, l.cfg.Bucket, opts) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		if strings.HasSuffix(info.Key, "/") {
+			continue // Directory marker
+		}
+		objects = append(objects, Object{
+			Key:     strings.TrimPrefix(info.Key, l.cfg.Prefix),
+			Size:    info.Size,
+			ModTime: info.LastModified,
+			ETag:    info.ETag,
+		})
+	}
+	return objects, nil
+}
+
+func (l *s3Lister) Download(ctx context.Context, key, dst string) error {
+	return l.client.FGetObject(ctx, l.cfg.Bucket, l.cfg.Prefix+key, dst, minio.GetObjectOptions{})
+}
+
+func (l *s3Lister) Close() error {
+	return nil
+}
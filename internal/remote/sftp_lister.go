@@ -0,0 +1,158 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SFTPConfig holds the authentication settings for SFTP sources. A password
+// embedded in the source URL takes precedence over Password.
+type SFTPConfig struct {
+	Password              string `yaml:"password"`
+	KeyFile               string `yaml:"key"`
+	KnownHostsFile        string `yaml:"known_hosts"`
+	InsecureIgnoreHostKey bool   `yaml:"insecure_ignore_host_key"`
+}
+
+type sftpLister struct {
+	ssh    *ssh.Client
+	client *sftp.Client
+	root   string
+}
+
+func newSFTPLister(u *url.URL, cfg SFTPConfig) (*sftpLister, error) {
+	user := u.User.Username()
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+
+	var auth []ssh.AuthMethod
+	if password, ok := u.User.Password(); ok {
+		auth = append(auth, ssh.Password(password))
+	} else if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+	if cfg.KeyFile != "" {
+		key, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading SFTP key file: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("parsing SFTP key file: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	hostKeyCallback, err := sftpHostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	sshClient, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", host, err)
+	}
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("starting SFTP session on %s: %w", host, err)
+	}
+
+	root := u.Path
+	if root == "" {
+		root = "."
+	}
+	return &sftpLister{ssh: sshClient, client: client, root: root}, nil
+}
+
+func sftpHostKeyCallback(cfg SFTPConfig) (ssh.HostKeyCallback, error) {
+	if cfg.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	file := cfg.KnownHostsFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("locating known_hosts: %w", err)
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("loading known_hosts %s: %w", file, err)
+	}
+	return callback, nil
+}
+
+func (l *sftpLister) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	walker := l.client.Walk(l.root)
+	for walker.Step() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := walker.Err(); err != nil {
+			return nil, err
+		}
+		info := walker.Stat()
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		objects = append(objects, Object{
+			Key:     strings.TrimPrefix(strings.TrimPrefix(walker.Path(), l.root), "/"),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+	return objects, nil
+}
+
+func (l *sftpLister) Download(ctx context.Context, key, dst string) error {
+	src, err := l.client.Open(path.Join(l.root, key))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func (l *sftpLister) Close() error {
+	l.client.Close()
+	return l.ssh.Close()
+}
@@ -0,0 +1,72 @@
+// Package sandbox runs commands with a restricted profile, to limit what a
+// command can do when its template incorporates untrusted file names: no new
+// privileges, a seccomp filter that denies dangerous syscalls and,
+// optionally, a read-only filesystem except for declared paths or a chroot
+// in a private mount namespace. It's only available on Linux.
+//
+// A sandboxed command is started through gowatchrun itself, which applies
+// the profile in Init and then executes the command.
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// envProfile passes the profile to the gowatchrun process that applies it.
+const envProfile = "GOWATCHRUN_SANDBOX"
+
+// Profile is what a sandboxed command may do.
+type Profile struct {
+	Filter   bool     `json:"filter,omitempty"`    // Install the seccomp filter
+	ReadOnly bool     `json:"read_only,omitempty"` // Deny writes outside of Writable
+	Writable []string `json:"writable,omitempty"`  // Absolute paths that stay writable with ReadOnly
+
+	// Root is the absolute path of the directory commands see as /, and
+	// Binds are absolute paths mounted read-only at the same path below it.
+	Root  string   `json:"root,omitempty"`
+	Binds []string `json:"binds,omitempty"`
+}
+
+// Wrap changes cmd, before it's started, to run in profile.
+func Wrap(cmd *exec.Cmd, profile Profile) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the gowatchrun executable for the sandbox: %w", err)
+	}
+	encoded, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	cmd.Env = append(cmd.Environ(), envProfile+"="+string(encoded))
+	cmd.Args = append([]string{"gowatchrun-sandbox", cmd.Path}, cmd.Args...)
+	cmd.Path = exe
+	if profile.Root != "" {
+		isolate(cmd)
+	}
+	return nil
+}
+
+// Init applies the profile and executes the command when gowatchrun was
+// started by Wrap, and returns otherwise. It must be called first thing in
+// main.
+func Init() {
+	encoded, ok := os.LookupEnv(envProfile)
+	if !ok {
+		return
+	}
+	os.Unsetenv(envProfile)
+	var profile Profile
+	err := json.Unmarshal([]byte(encoded), &profile)
+	if err == nil {
+		if len(os.Args) < 3 {
+			err = fmt.Errorf("no command given")
+		} else {
+			err = run(profile, os.Args[1], os.Args[2:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "gowatchrun: sandbox: %v\n", err)
+	os.Exit(126) // Like a shell for a command that can't be executed
+}
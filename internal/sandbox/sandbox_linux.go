@@ -0,0 +1,292 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Check reports whether profile can be applied on this system.
+func Check(profile Profile) error {
+	if _, ok := arches[runtime.GOARCH]; !ok && profile.Filter {
+		return fmt.Errorf("the sandbox doesn't support %s", runtime.GOARCH)
+	}
+	if profile.Root != "" {
+		if err := checkRoot(profile); err != nil {
+			return err
+		}
+	}
+	if !profile.ReadOnly {
+		return nil
+	}
+	if landlockABI() < 1 {
+		return errors.New("a read-only sandbox requires Landlock (Linux 5.13 or later, enabled in the kernel's LSM list)")
+	}
+	for _, path := range profile.Writable {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("sandbox writable path: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkRoot reports whether commands can be isolated in profile.Root.
+// Unless gowatchrun runs as root, that takes an unprivileged user namespace.
+func checkRoot(profile Profile) error {
+	if info, err := os.Stat(profile.Root); err != nil {
+		return fmt.Errorf("chroot: %w", err)
+	} else if !info.IsDir() {
+		return fmt.Errorf("chroot: %s is not a directory", profile.Root)
+	}
+	for _, path := range profile.Binds {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("chroot bind: %w", err)
+		}
+	}
+	if os.Geteuid() == 0 {
+		return nil
+	}
+	for _, setting := range []struct{ path, disabled string }{
+		{"/proc/sys/user/max_user_namespaces", "0"},
+		{"/proc/sys/kernel/unprivileged_userns_clone", "0"},             // Debian
+		{"/proc/sys/kernel/apparmor_restrict_unprivileged_userns", "1"}, // Ubuntu
+	} {
+		if value, err := os.ReadFile(setting.path); err == nil && strings.TrimSpace(string(value)) == setting.disabled {
+			return fmt.Errorf("chroot requires root or unprivileged user namespaces, which %s disables", setting.path)
+		}
+	}
+	return nil
+}
+
+// isolate makes cmd start in a new mount namespace and, unless gowatchrun
+// runs as root, a user namespace in which it keeps its user and group IDs.
+func isolate(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	attr.Cloneflags |= unix.CLONE_NEWNS
+	if uid := os.Geteuid(); uid != 0 {
+		gid := os.Getegid()
+		attr.Cloneflags |= unix.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+		// Executing gowatchrun as a regular user would drop the capabilities
+		// the namespace grants; run drops them before executing the command
+		attr.AmbientCaps = []uintptr{unix.CAP_SYS_ADMIN, unix.CAP_SYS_CHROOT}
+	}
+}
+
+// run applies profile to the current thread and executes path with argv on
+// it, so the restrictions carry over to the command.
+func run(profile Profile, path string, argv []string) error {
+	runtime.LockOSThread()
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to set no new privileges: %w", err)
+	}
+	if profile.Root != "" {
+		if err := mountBinds(profile.Root, profile.Binds); err != nil {
+			return err
+		}
+	}
+	// Landlock still allows chroot, and resolves Writable on the host
+	if profile.ReadOnly {
+		if err := restrictWrites(profile.Writable); err != nil {
+			return err
+		}
+	}
+	if profile.Root != "" {
+		var err error
+		if path, err = enterRoot(profile.Root, argv[0]); err != nil {
+			return err
+		}
+	}
+	if profile.Filter {
+		if err := installFilter(); err != nil {
+			return err
+		}
+	}
+	return syscall.Exec(path, argv, os.Environ())
+}
+
+// mountBinds mounts each of binds read-only at the same path below root,
+// creating missing mount points, in the private mount namespace the command
+// was started in.
+func mountBinds(root string, binds []string) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	for _, src := range binds {
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(realRoot, src)
+		if info.IsDir() {
+			err = os.MkdirAll(target, 0o755)
+		} else if err = os.MkdirAll(filepath.Dir(target), 0o755); err == nil {
+			var f *os.File
+			if f, err = os.OpenFile(target, os.O_CREATE|os.O_RDONLY, 0o644); err == nil {
+				f.Close()
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create mount point for %s: %w", src, err)
+		}
+		// A symlink in root must not redirect the mount outside of it
+		if resolved, err := filepath.EvalSymlinks(target); err != nil || !within(realRoot, resolved) {
+			return fmt.Errorf("mount point for %s leads outside of %s", src, root)
+		}
+		if err := unix.Mount(src, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to bind %s: %w", src, err)
+		}
+		// Remounting must keep the flags the original mount locks in a user
+		// namespace
+		var stat unix.Statfs_t
+		if err := unix.Statfs(target, &stat); err != nil {
+			return err
+		}
+		flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY)
+		for st, ms := range map[int64]uintptr{unix.ST_NOSUID: unix.MS_NOSUID, unix.ST_NODEV: unix.MS_NODEV, unix.ST_NOEXEC: unix.MS_NOEXEC, unix.ST_NOATIME: unix.MS_NOATIME, unix.ST_NODIRATIME: unix.MS_NODIRATIME, unix.ST_RELATIME: unix.MS_RELATIME} {
+			if stat.Flags&st != 0 {
+				flags |= ms
+			}
+		}
+		if err := unix.Mount("", target, "", flags, ""); err != nil {
+			return fmt.Errorf("failed to make %s read-only: %w", src, err)
+		}
+	}
+	return nil
+}
+
+// enterRoot changes the root directory to root, keeping the working
+// directory when it's below root, drops the capabilities isolate kept and
+// returns the path of the command name inside root.
+func enterRoot(root, name string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	dir := "/"
+	if rel, err := filepath.Rel(realRoot, wd); err == nil && within(realRoot, wd) {
+		dir = filepath.Join("/", rel)
+	}
+	if err := unix.Chroot(realRoot); err != nil {
+		return "", fmt.Errorf("failed to chroot to %s: %w", root, err)
+	}
+	if err := unix.Chdir(dir); err != nil {
+		return "", fmt.Errorf("failed to change to %s in %s: %w", dir, root, err)
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return "", fmt.Errorf("failed to drop capabilities: %w", err)
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%w in %s", err, root)
+	}
+	return path, nil
+}
+
+// within reports whether path is dir or below it.
+func within(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
+
+// Filesystem access rights a read-only sandbox denies, by Landlock ABI
+// version. Reading and executing files stays allowed everywhere.
+const (
+	writeAccessV1 = unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	// fileAccess are the rights that apply to files rather than directories
+	fileAccess = unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+)
+
+// landlockABI returns the Landlock ABI version of the kernel, or 0 when
+// Landlock isn't available.
+func landlockABI() int {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return 0
+	}
+	return int(abi)
+}
+
+// restrictWrites denies the current thread writes outside of writable and
+// /dev, where commands write to /dev/null and the terminal.
+func restrictWrites(writable []string) error {
+	abi := landlockABI()
+	if abi < 1 {
+		return errors.New("landlock is not available")
+	}
+	handled := uint64(writeAccessV1)
+	if abi >= 2 {
+		handled |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+	if abi >= 3 {
+		handled |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("failed to create Landlock ruleset: %w", errno)
+	}
+	ruleset := int(fd)
+	defer unix.Close(ruleset)
+
+	allow := func(path string, access uint64) error {
+		fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer unix.Close(fd)
+		var stat unix.Stat_t
+		if err := unix.Fstat(fd, &stat); err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if stat.Mode&unix.S_IFMT != unix.S_IFDIR {
+			access &= fileAccess
+		}
+		rule := unix.LandlockPathBeneathAttr{Allowed_access: access & handled, Parent_fd: int32(fd)}
+		if _, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(ruleset), unix.LANDLOCK_RULE_PATH_BENEATH, uintptr(unsafe.Pointer(&rule)), 0, 0, 0); errno != 0 {
+			return fmt.Errorf("failed to allow writes to %s: %w", path, errno)
+		}
+		return nil
+	}
+	if err := allow("/dev", fileAccess); err != nil {
+		return err
+	}
+	for _, path := range writable {
+		if err := allow(path, handled); err != nil {
+			return err
+		}
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, uintptr(ruleset), 0, 0); errno != 0 {
+		return fmt.Errorf("failed to apply Landlock ruleset: %w", errno)
+	}
+	return nil
+}
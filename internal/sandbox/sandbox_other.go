@@ -0,0 +1,20 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+// Check reports whether profile can be applied on this system.
+func Check(profile Profile) error {
+	return errors.New("the sandbox is only available on Linux, not " + runtime.GOOS)
+}
+
+func run(profile Profile, path string, argv []string) error {
+	return Check(profile)
+}
+
+func isolate(cmd *exec.Cmd) {}
@@ -0,0 +1,138 @@
+package sandbox
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+type arch struct {
+	audit     uint32 // AUDIT_ARCH_* value seccomp reports for native syscalls
+	bigEndian bool
+	cloneArg  int // Argument of clone(2) holding the flags
+}
+
+var arches = map[string]arch{
+	"386":     {audit: unix.AUDIT_ARCH_I386},
+	"amd64":   {audit: unix.AUDIT_ARCH_X86_64},
+	"arm":     {audit: unix.AUDIT_ARCH_ARM},
+	"arm64":   {audit: unix.AUDIT_ARCH_AARCH64},
+	"ppc64le": {audit: unix.AUDIT_ARCH_PPC64LE},
+	"riscv64": {audit: unix.AUDIT_ARCH_RISCV64},
+	"s390x":   {audit: unix.AUDIT_ARCH_S390X, bigEndian: true, cloneArg: 1},
+}
+
+// deniedSyscalls fail with EPERM in the sandbox: they load kernel code,
+// change the system, mounts or namespaces, or inspect other processes, which
+// commands run for file events have no business doing.
+var deniedSyscalls = append([]uintptr{
+	unix.SYS_ACCT,
+	unix.SYS_ADD_KEY,
+	unix.SYS_ADJTIMEX,
+	unix.SYS_BPF,
+	unix.SYS_CHROOT,
+	unix.SYS_CLOCK_ADJTIME,
+	unix.SYS_CLOCK_SETTIME,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_FSCONFIG,
+	unix.SYS_FSMOUNT,
+	unix.SYS_FSOPEN,
+	unix.SYS_FSPICK,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_IO_URING_ENTER,
+	unix.SYS_IO_URING_REGISTER,
+	unix.SYS_IO_URING_SETUP,
+	unix.SYS_KCMP,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEYCTL,
+	unix.SYS_MOUNT,
+	unix.SYS_MOUNT_SETATTR,
+	unix.SYS_MOVE_MOUNT,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_OPEN_TREE,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_PTRACE,
+	unix.SYS_QUOTACTL,
+	unix.SYS_REBOOT,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_SETDOMAINNAME,
+	unix.SYS_SETHOSTNAME,
+	unix.SYS_SETNS,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_SWAPOFF,
+	unix.SYS_SWAPON,
+	unix.SYS_SYSLOG,
+	unix.SYS_UMOUNT2,
+	unix.SYS_UNSHARE,
+	unix.SYS_USERFAULTFD,
+	unix.SYS_VHANGUP,
+}, archDeniedSyscalls...)
+
+// namespaceFlags are the clone(2) flags that create namespaces, which would
+// let a command get around the other restrictions.
+const namespaceFlags = unix.CLONE_NEWNS | unix.CLONE_NEWUSER | unix.CLONE_NEWPID | unix.CLONE_NEWNET |
+	unix.CLONE_NEWUTS | unix.CLONE_NEWIPC | unix.CLONE_NEWCGROUP
+
+// installFilter installs the seccomp filter on the current thread. Syscalls
+// of other architectures (like x32 on amd64) kill the process.
+func installFilter() error {
+	a, ok := arches[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("the sandbox doesn't support %s", runtime.GOARCH)
+	}
+	const (
+		archOffset = 4
+		argsOffset = 16
+	)
+	ret := func(k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: k}
+	}
+	load := func(offset uint32) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: offset}
+	}
+	// jump skips skip instructions unless the accumulator matches k
+	jump := func(op uint16, k uint32, skip uint8) unix.SockFilter {
+		return unix.SockFilter{Code: unix.BPF_JMP | op | unix.BPF_K, K: k, Jf: skip}
+	}
+	deny := ret(unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM))
+
+	filter := []unix.SockFilter{
+		load(archOffset),
+		{Code: unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K, K: a.audit, Jt: 1},
+		ret(unix.SECCOMP_RET_KILL_PROCESS),
+		load(0), // Syscall number
+	}
+	if runtime.GOARCH == "amd64" {
+		// x32 syscalls have the same arch but this bit set
+		filter = append(filter, jump(unix.BPF_JGE, 0x40000000, 1), deny)
+	}
+	for _, nr := range deniedSyscalls {
+		filter = append(filter, jump(unix.BPF_JEQ, uint32(nr), 1), deny)
+	}
+	// Make the C library fall back from clone3, whose flags are out of
+	// reach of seccomp, to clone
+	filter = append(filter, jump(unix.BPF_JEQ, unix.SYS_CLONE3, 1), ret(unix.SECCOMP_RET_ERRNO|uint32(unix.ENOSYS)))
+	flags := uint32(argsOffset + 8*a.cloneArg)
+	if a.bigEndian {
+		flags += 4 // Low half of the 64-bit argument
+	}
+	filter = append(filter,
+		jump(unix.BPF_JEQ, unix.SYS_CLONE, 3),
+		load(flags),
+		unix.SockFilter{Code: unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K, K: namespaceFlags, Jf: 1},
+		deny,
+		ret(unix.SECCOMP_RET_ALLOW),
+	)
+
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&prog)), 0, 0); err != nil {
+		return fmt.Errorf("failed to install seccomp filter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,5 @@
+//go:build linux && !386 && !amd64
+
+package sandbox
+
+var archDeniedSyscalls []uintptr
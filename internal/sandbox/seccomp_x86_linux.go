@@ -0,0 +1,11 @@
+//go:build linux && (386 || amd64)
+
+package sandbox
+
+import "golang.org/x/sys/unix"
+
+var archDeniedSyscalls = []uintptr{
+	unix.SYS_IOPERM,
+	unix.SYS_IOPL,
+	unix.SYS_USELIB,
+}
@@ -0,0 +1,192 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+// Job is a node in the dependency graph.
+type Job struct {
+	Config    watcher.Config
+	DependsOn []string
+	// RunAlways makes the job run after its dependencies complete even when
+	// one of them failed. By default a failed dependency skips the job.
+	RunAlways bool
+}
+
+type node struct {
+	Job
+	dependents []string
+	downstream map[string]bool // All jobs reachable through dependents
+	mu         sync.Mutex      // Held while the job runs
+}
+
+// Scheduler runs jobs in dependency order. When a job finishes, every job
+// that depends on it (directly or transitively) runs next, in topological
+// order, with the same event data. A job triggered by its own event sources
+// first waits for any of its dependencies that are currently running.
+type Scheduler struct {
+	nodes map[string]*node
+	order []string
+	exec  watcher.ExecutorFunc
+}
+
+// New validates the dependency graph of jobs and returns a scheduler that
+// runs them with exec. Unknown dependencies and cycles are reported as errors.
+func New(jobs []Job, exec watcher.ExecutorFunc) (*Scheduler, error) {
+	s := &Scheduler{nodes: make(map[string]*node, len(jobs)), exec: exec}
+	for _, job := range jobs {
+		s.nodes[job.Config.Name] = &node{Job: job}
+	}
+	for _, job := range jobs {
+		for _, dep := range job.DependsOn {
+			parent, ok := s.nodes[dep]
+			if !ok {
+				return nil, fmt.Errorf("job '%s' depends on unknown job '%s'", job.Config.Name, dep)
+			}
+			parent.dependents = append(parent.dependents, job.Config.Name)
+		}
+	}
+
+	order, err := s.topoSort(jobs)
+	if err != nil {
+		return nil, err
+	}
+	s.order = order
+
+	for name, n := range s.nodes {
+		n.downstream = make(map[string]bool)
+		s.collectDownstream(name, n.downstream)
+	}
+	return s, nil
+}
+
+// topoSort orders the jobs so that every job comes after its dependencies,
+// keeping the config file order where the graph allows it.
+func (s *Scheduler) topoSort(jobs []Job) ([]string, error) {
+	position := make(map[string]int, len(jobs))
+	indegree := make(map[string]int, len(jobs))
+	for i, job := range jobs {
+		position[job.Config.Name] = i
+		indegree[job.Config.Name] = len(job.DependsOn)
+	}
+
+	var ready []string
+	for _, job := range jobs {
+		if indegree[job.Config.Name] == 0 {
+			ready = append(ready, job.Config.Name)
+		}
+	}
+
+	var order []string
+	for len(ready) > 0 {
+		sort.Slice(ready, func(a, b int) bool { return position[ready[a]] < position[ready[b]] })
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, dependent := range s.nodes[name].dependents {
+			indegree[dependent]--
+			if indegree[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(order) != len(jobs) {
+		var cyclic []string
+		for _, job := range jobs {
+			if indegree[job.Config.Name] > 0 {
+				cyclic = append(cyclic, job.Config.Name)
+			}
+		}
+		return nil, fmt.Errorf("job dependencies form a cycle involving: %v", cyclic)
+	}
+	return order, nil
+}
+
+func (s *Scheduler) collectDownstream(name string, seen map[string]bool) {
+	for _, dependent := range s.nodes[name].dependents {
+		if !seen[dependent] {
+			seen[dependent] = true
+			s.collectDownstream(dependent, seen)
+		}
+	}
+}
+
+// ExecutorFor returns the executor to pass to watcher.Run for the named job.
+func (s *Scheduler) ExecutorFor(name string) watcher.ExecutorFunc {
+	return func(ctx context.Context, cfg watcher.Config, data *watcher.EventData) watcher.ExecutionResult {
+		n := s.nodes[name]
+		queued := time.Now()
+		s.waitForDependencies(n)
+		result := s.run(ctx, n, cfg, data, queued)
+		if !result.Skipped {
+			s.cascade(ctx, n, result.OK(), data)
+		}
+		return result
+	}
+}
+
+// waitForDependencies blocks until none of n's dependencies are running.
+func (s *Scheduler) waitForDependencies(n *node) {
+	for _, dep := range n.DependsOn {
+		parent := s.nodes[dep]
+		parent.mu.Lock()
+		parent.mu.Unlock()
+	}
+}
+
+// run runs n once it isn't running anymore, recording the time since queued
+// as the queue span of the event's trace.
+func (s *Scheduler) run(ctx context.Context, n *node, cfg watcher.Config, data *watcher.EventData, queued time.Time) watcher.ExecutionResult {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, span := watcher.Tracer.Start(ctx, "queue",
+		trace.WithTimestamp(queued),
+		trace.WithAttributes(attribute.String("gowatchrun.job", n.Config.Name)))
+	span.End()
+	return s.exec(ctx, cfg, data)
+}
+
+// cascade runs everything downstream of root after it completed.
+func (s *Scheduler) cascade(ctx context.Context, root *node, ok bool, data *watcher.EventData) {
+	if len(root.downstream) == 0 {
+		return
+	}
+
+	results := map[string]bool{root.Config.Name: ok}
+	for _, name := range s.order {
+		if !root.downstream[name] {
+			continue
+		}
+		n := s.nodes[name]
+		logger := n.Config.Logger()
+
+		runnable := true
+		var failedDep string
+		for _, dep := range n.DependsOn {
+			depOK, inWave := results[dep]
+			if inWave && !depOK && !n.RunAlways {
+				runnable = false
+				failedDep = dep
+				break
+			}
+		}
+		if !runnable {
+			logger.Warn().Msgf("Skipping: dependency '%s' did not succeed", failedDep)
+			results[name] = false
+			continue
+		}
+
+		logger.Info().Msgf("Running after '%s' completed", root.Config.Name)
+		results[name] = s.run(ctx, n, n.Config, data, time.Now()).OK()
+	}
+}
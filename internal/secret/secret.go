@@ -0,0 +1,143 @@
+// Package secret keeps the values that must not show up in logs, the audit
+// log or API responses, and masks them wherever they appear. Commands still
+// receive the real values.
+package secret
+
+import (
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Mask replaces every secret value.
+const Mask = "***"
+
+const (
+	// MinLength is the length below which values aren't masked, since they
+	// would also mask unrelated text.
+	MinLength = 4
+	// maxRendered is how many values marked secret in templates are kept;
+	// the oldest are forgotten first.
+	maxRendered = 1024
+)
+
+var (
+	mu       sync.RWMutex
+	fixed    = map[string]bool{} // Values of secret environment variables and tokens
+	rendered []string            // Values marked with the secret template function, oldest first
+	known    = map[string]bool{} // All of the above
+	replacer = strings.NewReplacer()
+)
+
+// AddEnv marks the values of the environment variables matching any of
+// patterns (names or globs like "*_TOKEN") as secret and returns the names
+// of the variables whose values are too short to be masked.
+func AddEnv(patterns []string) (short []string) {
+	if len(patterns) == 0 {
+		return nil
+	}
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if !slices.ContainsFunc(patterns, func(pattern string) bool {
+			ok, _ := path.Match(pattern, name)
+			return ok
+		}) || value == "" {
+			continue
+		}
+		if len(value) < MinLength {
+			short = append(short, name)
+			continue
+		}
+		Add(value)
+	}
+	slices.Sort(short)
+	return short
+}
+
+// Add marks value as secret for as long as gowatchrun runs.
+func Add(value string) {
+	if len(value) < MinLength {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fixed[value] {
+		return
+	}
+	fixed[value] = true
+	if known[value] {
+		rendered = slices.DeleteFunc(rendered, func(v string) bool { return v == value })
+	}
+	known[value] = true
+	update()
+}
+
+// Rendered marks a value a template rendered as secret. Only the most
+// recent of these are remembered.
+func Rendered(value string) {
+	if len(value) < MinLength {
+		return
+	}
+	mu.RLock()
+	seen := known[value]
+	mu.RUnlock()
+	if seen {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if known[value] {
+		return
+	}
+	known[value] = true
+	rendered = append(rendered, value)
+	if len(rendered) > maxRendered {
+		delete(known, rendered[0])
+		rendered = rendered[1:]
+	}
+	update()
+}
+
+// update rebuilds the replacer, preferring longer values where secrets
+// overlap.
+func update() {
+	values := make([]string, 0, len(known))
+	for value := range known {
+		values = append(values, value)
+	}
+	slices.SortFunc(values, func(a, b string) int { return len(b) - len(a) })
+	pairs := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		pairs = append(pairs, value, Mask)
+	}
+	replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with every secret value replaced by Mask.
+func Redact(s string) string {
+	mu.RLock()
+	r := replacer
+	mu.RUnlock()
+	return r.Replace(s)
+}
+
+// Writer returns a writer that redacts everything written to it before
+// passing it on to w. Every write must be complete, like a log line, since a
+// value split across writes isn't masked.
+func Writer(w io.Writer) io.Writer {
+	return redactingWriter{w}
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
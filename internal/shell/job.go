@@ -0,0 +1,13 @@
+package shell
+
+import "os/exec"
+
+// Run starts cmd like Start and waits for it to complete.
+func Run(cmd *exec.Cmd) error {
+	job, err := Start(cmd)
+	if err != nil {
+		return err
+	}
+	defer job.Close()
+	return cmd.Wait()
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package shell
+
+import "os/exec"
+
+// Job is the process tree of a command started with Start.
+type Job struct {
+	cmd *exec.Cmd
+}
+
+// Start starts cmd. On Windows, the command and every process it starts are
+// placed in a Job Object.
+func Start(cmd *exec.Cmd) (*Job, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &Job{cmd: cmd}, nil
+}
+
+// Kill kills the command's process.
+func (j *Job) Kill() error {
+	return j.cmd.Process.Kill()
+}
+
+// Close releases the job once the command has been waited for.
+func (j *Job) Close() {}
@@ -0,0 +1,118 @@
+package shell
+
+import (
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Job is the process tree of a command started with Start: a Job Object
+// holding the command's process and every process it starts, like the
+// programs cmd.exe runs, which killing cmd.exe alone would leave behind.
+type Job struct {
+	mu     sync.Mutex
+	handle windows.Handle // 0 once closed
+}
+
+// Start starts cmd in a new Job Object. When cmd has a context (see
+// CommandContext), cancelling it kills the whole job. The process starts
+// suspended until it's part of the job, so none of its children escape it.
+func Start(cmd *exec.Cmd) (*Job, error) {
+	handle, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create job object: %w", err)
+	}
+	// Processes still in the job when its last handle is closed are
+	// killed, which includes gowatchrun exiting or crashing
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE},
+	}
+	if _, err := windows.SetInformationJobObject(handle, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		windows.CloseHandle(handle)
+		return nil, fmt.Errorf("failed to configure job object: %w", err)
+	}
+	job := &Job{handle: handle}
+
+	if cmd.Cancel != nil {
+		cmd.Cancel = job.Kill
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+	if err := cmd.Start(); err != nil {
+		job.Close()
+		return nil, err
+	}
+	if err := job.assign(uint32(cmd.Process.Pid)); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		job.Close()
+		return nil, err
+	}
+	return job, nil
+}
+
+// assign adds the suspended process pid to the job and resumes it.
+func (j *Job) assign(pid uint32) error {
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, pid)
+	if err != nil {
+		return fmt.Errorf("failed to open process %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(process)
+	if err := windows.AssignProcessToJobObject(j.handle, process); err != nil {
+		return fmt.Errorf("failed to assign process %d to job object: %w", pid, err)
+	}
+	return resume(pid)
+}
+
+// resume resumes the threads of the process pid, which was created
+// suspended and only has its main thread.
+func resume(pid uint32) error {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list threads: %w", err)
+	}
+	defer windows.CloseHandle(snapshot)
+	entry := windows.ThreadEntry32{Size: uint32(unsafe.Sizeof(windows.ThreadEntry32{}))}
+	for err = windows.Thread32First(snapshot, &entry); err == nil; err = windows.Thread32Next(snapshot, &entry) {
+		if entry.OwnerProcessID != pid {
+			continue
+		}
+		thread, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, entry.ThreadID)
+		if err != nil {
+			return fmt.Errorf("failed to open thread of process %d: %w", pid, err)
+		}
+		_, err = windows.ResumeThread(thread)
+		windows.CloseHandle(thread)
+		if err != nil {
+			return fmt.Errorf("failed to resume process %d: %w", pid, err)
+		}
+	}
+	return nil
+}
+
+// Kill kills every process in the job.
+func (j *Job) Kill() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.handle == 0 {
+		return nil
+	}
+	return windows.TerminateJobObject(j.handle, 1)
+}
+
+// Close releases the job once the command has been waited for, which kills
+// any process the command left running.
+func (j *Job) Close() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.handle != 0 {
+		windows.CloseHandle(j.handle)
+		j.handle = 0
+	}
+}
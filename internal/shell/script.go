@@ -0,0 +1,83 @@
+package shell
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// ScriptContext writes script to a temporary file and returns a command that
+// runs it: with the interpreter on its #! line when it has one, on every
+// platform, or else with shell like CommandContext. remove deletes the file
+// once the command is done.
+func ScriptContext(ctx context.Context, shell, script string) (cmd *exec.Cmd, remove func(), err error) {
+	interpreter := Shebang(script)
+	if shell == "" {
+		shell = defaultShell()
+	}
+	ext := ""
+	if interpreter == nil {
+		switch name(shell) {
+		case "pwsh", "powershell":
+			ext = ".ps1"
+		case "cmd":
+			ext = ".cmd"
+		}
+	}
+
+	f, err := os.CreateTemp("", "gowatchrun-script-*"+ext)
+	if err != nil {
+		return nil, nil, err
+	}
+	remove = func() { os.Remove(f.Name()) }
+	_, err = f.WriteString(script)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		remove()
+		return nil, nil, err
+	}
+
+	switch {
+	case interpreter != nil:
+		cmd = exec.CommandContext(ctx, interpreter[0], append(interpreter[1:], f.Name())...)
+	case name(shell) == "pwsh" || name(shell) == "powershell":
+		cmd = exec.CommandContext(ctx, shell, "-NoProfile", "-File", f.Name())
+	case name(shell) == "cmd":
+		cmd = exec.CommandContext(ctx, shell, "/C", f.Name())
+	default:
+		cmd = exec.CommandContext(ctx, shell, f.Name())
+	}
+	return cmd, remove, nil
+}
+
+// Shebang returns the interpreter on the #! line script starts with, and
+// its argument if there's one, or nil without such a line. As on Linux,
+// everything after the interpreter is a single argument, except that
+// "/usr/bin/env name" is resolved to name, and an interpreter path that
+// doesn't exist, like /usr/bin/python3 on Windows, is looked up by name.
+func Shebang(script string) []string {
+	line, _, _ := strings.Cut(script, "\n")
+	line, ok := strings.CutPrefix(strings.TrimRight(line, "\r"), "#!")
+	if !ok {
+		return nil
+	}
+	interpreter, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	if interpreter == "" {
+		return nil
+	}
+	if path.Base(interpreter) == "env" && arg != "" && !strings.HasPrefix(arg, "-") {
+		fields := strings.Fields(arg)
+		interpreter, arg = fields[0], strings.Join(fields[1:], " ")
+	} else if _, err := os.Stat(interpreter); err != nil {
+		interpreter = path.Base(interpreter)
+	}
+	if arg == "" {
+		return []string{interpreter}
+	}
+	return []string{interpreter, arg}
+}
@@ -0,0 +1,26 @@
+package shell
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Args returns the arguments shell needs in front of a command string:
+// -NoProfile -Command for PowerShell, /S /C for cmd.exe and -c for any other
+// shell.
+func Args(shell string) []string {
+	switch name(shell) {
+	case "pwsh", "powershell":
+		return []string{"-NoProfile", "-Command"}
+	case "cmd":
+		return []string{"/S", "/C"}
+	}
+	return []string{"-c"}
+}
+
+// name returns the lowercase name of shell without directory and .exe
+// extension, e.g. pwsh for C:\Program Files\PowerShell\7\pwsh.exe.
+func name(shell string) string {
+	shell = strings.ToLower(filepath.Base(strings.ReplaceAll(shell, `\`, "/")))
+	return strings.TrimSuffix(shell, ".exe")
+}
@@ -0,0 +1,29 @@
+package shell
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		shell string
+		want  []string
+	}{
+		{shell: "sh", want: []string{"-c"}},
+		{shell: "/bin/bash", want: []string{"-c"}},
+		{shell: "cmd", want: []string{"/S", "/C"}},
+		{shell: "CMD.EXE", want: []string{"/S", "/C"}},
+		{shell: `C:\Windows\System32\cmd.exe`, want: []string{"/S", "/C"}},
+		{shell: "C:/Windows/System32/cmd.exe", want: []string{"/S", "/C"}},
+		{shell: "pwsh", want: []string{"-NoProfile", "-Command"}},
+		{shell: `C:\Program Files\PowerShell\7\pwsh.exe`, want: []string{"-NoProfile", "-Command"}},
+		{shell: "PowerShell.exe", want: []string{"-NoProfile", "-Command"}},
+		{shell: `C:\tools\cmdline\bash.exe`, want: []string{"-c"}},
+	}
+	for _, tt := range tests {
+		if got := Args(tt.shell); !slices.Equal(got, tt.want) {
+			t.Errorf("Args(%q) = %q, want %q", tt.shell, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+// Package shell runs command strings through the platform's shell.
+package shell
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Command returns a command that runs command with shell, a shell's name or
+// path, or with sh -c when shell is empty.
+func Command(shell, command string) *exec.Cmd {
+	return CommandContext(context.Background(), shell, command)
+}
+
+// CommandContext is like Command, but the process is killed when ctx is done.
+func CommandContext(ctx context.Context, shell, command string) *exec.Cmd {
+	if shell == "" {
+		shell = defaultShell()
+	}
+	return exec.CommandContext(ctx, shell, append(Args(shell), command)...)
+}
+
+// defaultShell returns the shell commands run with when none is set.
+func defaultShell() string {
+	return "sh"
+}
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package shell
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		shell   string
+		command string
+		want    []string
+	}{
+		{shell: "", command: "echo hi", want: []string{"sh", "-c", "echo hi"}},
+		{shell: "bash", command: `echo "a b"`, want: []string{"bash", "-c", `echo "a b"`}},
+		{shell: "pwsh", command: "Get-Date", want: []string{"pwsh", "-NoProfile", "-Command", "Get-Date"}},
+	}
+	for _, tt := range tests {
+		if got := Command(tt.shell, tt.command).Args; !slices.Equal(got, tt.want) {
+			t.Errorf("Command(%q, %q).Args = %q, want %q", tt.shell, tt.command, got, tt.want)
+		}
+	}
+}
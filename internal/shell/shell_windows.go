@@ -0,0 +1,39 @@
+// Package shell runs command strings through the platform's shell.
+package shell
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Command returns a command that runs command with shell, a shell's name or
+// path, or with cmd.exe (or %ComSpec%) when shell is empty. For cmd.exe, the
+// command line is passed through verbatim, since cmd.exe doesn't follow the
+// quoting rules exec.Command applies to arguments.
+func Command(shell, command string) *exec.Cmd {
+	return CommandContext(context.Background(), shell, command)
+}
+
+// CommandContext is like Command, but the process is killed when ctx is done.
+func CommandContext(ctx context.Context, shell, command string) *exec.Cmd {
+	if shell == "" {
+		shell = defaultShell()
+	}
+	if name(shell) != "cmd" {
+		return exec.CommandContext(ctx, shell, append(Args(shell), command)...)
+	}
+	cmd := exec.CommandContext(ctx, shell)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: shell + ` /S /C "` + command + `"`}
+	return cmd
+}
+
+// defaultShell returns the shell commands run with when none is set:
+// %ComSpec%, or cmd.exe.
+func defaultShell() string {
+	if shell := os.Getenv("ComSpec"); shell != "" {
+		return shell
+	}
+	return "cmd.exe"
+}
@@ -0,0 +1,35 @@
+package shell
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	t.Setenv("ComSpec", `C:\Windows\system32\cmd.exe`)
+	tests := []struct {
+		shell   string
+		command string
+		cmdLine string   // For cmd.exe, which gets the command line verbatim
+		args    []string // For any other shell
+	}{
+		{shell: "", command: "echo hi", cmdLine: `C:\Windows\system32\cmd.exe /S /C "echo hi"`},
+		{shell: "cmd", command: `copy "a b.txt" c:\out`, cmdLine: `cmd /S /C "copy "a b.txt" c:\out"`},
+		{shell: `C:\Windows\System32\CMD.EXE`, command: "dir & echo done", cmdLine: `C:\Windows\System32\CMD.EXE /S /C "dir & echo done"`},
+		{shell: "pwsh", command: `Write-Host "a b"`, args: []string{"pwsh", "-NoProfile", "-Command", `Write-Host "a b"`}},
+		{shell: `C:\Program Files\PowerShell\7\pwsh.exe`, command: "Get-Date", args: []string{`C:\Program Files\PowerShell\7\pwsh.exe`, "-NoProfile", "-Command", "Get-Date"}},
+		{shell: "powershell.exe", command: "Get-Date", args: []string{"powershell.exe", "-NoProfile", "-Command", "Get-Date"}},
+	}
+	for _, tt := range tests {
+		cmd := Command(tt.shell, tt.command)
+		if tt.cmdLine != "" {
+			if cmd.SysProcAttr == nil || cmd.SysProcAttr.CmdLine != tt.cmdLine {
+				t.Errorf("Command(%q, %q) command line = %+v, want %q", tt.shell, tt.command, cmd.SysProcAttr, tt.cmdLine)
+			}
+			continue
+		}
+		if !slices.Equal(cmd.Args, tt.args) {
+			t.Errorf("Command(%q, %q).Args = %q, want %q", tt.shell, tt.command, cmd.Args, tt.args)
+		}
+	}
+}
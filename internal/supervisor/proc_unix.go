@@ -0,0 +1,27 @@
+//go:build !windows
+
+package supervisor
+
+import (
+	"os/exec"
+	"syscall"
+
+	"github.com/s0up4200/gowatchrun/internal/shell"
+)
+
+// setProcessGroup puts the process in its own group, so stopping it also
+// stops anything it spawned.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func terminate(cmd *exec.Cmd, job *shell.Job) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+func kill(cmd *exec.Cmd, job *shell.Job) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
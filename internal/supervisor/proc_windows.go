@@ -0,0 +1,21 @@
+//go:build windows
+
+package supervisor
+
+import (
+	"os/exec"
+
+	"github.com/s0up4200/gowatchrun/internal/shell"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminate kills the process's job, since Windows has no signal to ask a
+// console process tree to exit.
+func terminate(cmd *exec.Cmd, job *shell.Job) {
+	job.Kill()
+}
+
+func kill(cmd *exec.Cmd, job *shell.Job) {
+	job.Kill()
+}
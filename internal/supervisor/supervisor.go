@@ -0,0 +1,276 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/s0up4200/gowatchrun/internal/audit"
+	"github.com/s0up4200/gowatchrun/internal/sandbox"
+	"github.com/s0up4200/gowatchrun/internal/shell"
+	"github.com/s0up4200/gowatchrun/internal/wsl"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+	// A process that stayed up this long is considered healthy again, which
+	// resets the crash backoff.
+	healthyAfter = 10 * time.Second
+	stopTimeout  = 5 * time.Second
+)
+
+// Process keeps a long-running shell command alive. It is restarted with
+// exponential backoff when it exits on its own, and can be restarted or
+// stopped on demand.
+type Process struct {
+	Name    string
+	Command string
+	// Shell, when set, is the shell's name or path instead of the default.
+	Shell string
+	// WSLInterop runs the command on the other side of WSL: inside WSL on
+	// Windows, and on Windows inside WSL.
+	WSLInterop bool
+	// Prefix, when set, is written in front of every line of output.
+	Prefix string
+	// PipeStdin connects the process's stdin to WriteLine.
+	PipeStdin bool
+	// Stdout, when set, receives the process's stdout instead of os.Stdout.
+	Stdout io.Writer
+	// OnExit, when set, is called every time the process has exited.
+	OnExit func()
+	// Audit, when set, records every start and exit of the process.
+	Audit *audit.Log
+	// Sandbox, when set, restricts what the process may do.
+	Sandbox *sandbox.Profile
+
+	logger  zerolog.Logger
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	job     *shell.Job
+	stdin   io.WriteCloser
+	exited  chan struct{}
+	stopped bool
+	backoff time.Duration
+}
+
+// New creates a process that runs command through the shell.
+func New(name, command string, logger zerolog.Logger) *Process {
+	return &Process{Name: name, Command: command, logger: logger, backoff: minBackoff}
+}
+
+// Start launches the process if it isn't running yet.
+func (p *Process) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = false
+	if p.cmd != nil {
+		return nil
+	}
+	return p.startLocked()
+}
+
+// Restart stops the running process (if any) and starts it again.
+func (p *Process) Restart() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = false
+	p.stopLocked()
+	p.backoff = minBackoff
+	p.logger.Info().Msgf("Restarting: %s", p.Command)
+	return p.startLocked()
+}
+
+// Replace restarts the process with command instead of the current one. A
+// stopped process only takes the command for when it's started again.
+func (p *Process) Replace(command string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Command = command
+	if p.stopped {
+		return nil
+	}
+	p.stopLocked()
+	p.backoff = minBackoff
+	p.logger.Info().Msgf("Replacing with: %s", command)
+	return p.startLocked()
+}
+
+// Stop terminates the process and disables automatic restarts.
+func (p *Process) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stopped = true
+	p.stopLocked()
+}
+
+// WriteLine writes line, followed by a newline, to the stdin of the running
+// process. It fails when the process isn't running, e.g. while it's waiting
+// to be restarted.
+func (p *Process) WriteLine(line string) error {
+	p.mu.Lock()
+	stdin := p.stdin
+	p.mu.Unlock()
+	if stdin == nil {
+		return fmt.Errorf("process is not running")
+	}
+	_, err := io.WriteString(stdin, line+"\n")
+	return err
+}
+
+func (p *Process) startLocked() error {
+	cmd := shell.Command(p.Shell, p.Command)
+	if p.WSLInterop {
+		cmd = wsl.CommandContext(context.Background(), p.Shell, p.Command)
+	}
+	cmd.Stdin = nil
+	if p.Sandbox != nil {
+		if err := sandbox.Wrap(cmd, *p.Sandbox); err != nil {
+			p.logger.Error().Msgf("Failed to sandbox '%s': %v", p.Command, err)
+			return err
+		}
+	}
+	var stdin io.WriteCloser
+	if p.PipeStdin {
+		var err error
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return err
+		}
+	}
+	var stdout io.Writer = os.Stdout
+	if p.Stdout != nil {
+		stdout = p.Stdout
+	}
+	if p.Prefix != "" {
+		cmd.Stdout = newPrefixWriter(stdout, p.Prefix)
+		cmd.Stderr = newPrefixWriter(os.Stderr, p.Prefix)
+	} else {
+		cmd.Stdout = stdout
+		cmd.Stderr = os.Stderr
+	}
+	setProcessGroup(cmd)
+
+	job, err := shell.Start(cmd)
+	if err != nil {
+		p.logger.Error().Msgf("Failed to start '%s': %v", p.Command, err)
+		return err
+	}
+	p.logger.Info().Msgf("Started (pid %d): %s", cmd.Process.Pid, p.Command)
+	finish := p.Audit.Exec(audit.Entry{Job: p.Name, PID: cmd.Process.Pid, Command: p.Command})
+
+	exited := make(chan struct{})
+	p.cmd = cmd
+	p.job = job
+	p.stdin = stdin
+	p.exited = exited
+	startedAt := time.Now()
+
+	go func() {
+		err := cmd.Wait()
+		job.Close()
+		finish(cmd.ProcessState.ExitCode(), err) // -1 when killed by a signal
+		close(exited)
+		if p.OnExit != nil {
+			p.OnExit()
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.cmd != cmd {
+			return // Replaced by a restart
+		}
+		p.cmd = nil
+		p.job = nil
+		p.stdin = nil
+		if p.stopped {
+			return
+		}
+
+		if time.Since(startedAt) > healthyAfter {
+			p.backoff = minBackoff
+		}
+		delay := p.backoff
+		p.backoff *= 2
+		if p.backoff > maxBackoff {
+			p.backoff = maxBackoff
+		}
+		p.logger.Warn().Msgf("Exited unexpectedly (%v), restarting in %s", exitStatus(err), delay)
+
+		time.AfterFunc(delay, func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if p.cmd == nil && !p.stopped {
+				p.startLocked()
+			}
+		})
+	}()
+	return nil
+}
+
+// stopLocked terminates the current process, escalating to a kill if it
+// doesn't exit within stopTimeout.
+func (p *Process) stopLocked() {
+	cmd, job, exited := p.cmd, p.job, p.exited
+	if cmd == nil {
+		return
+	}
+	p.cmd = nil
+	p.job = nil
+
+	if p.stdin != nil {
+		p.stdin.Close()
+		p.stdin = nil
+	}
+	terminate(cmd, job)
+	select {
+	case <-exited:
+	case <-time.After(stopTimeout):
+		p.logger.Warn().Msgf("Did not exit within %s, killing it", stopTimeout)
+		kill(cmd, job)
+		<-exited
+	}
+}
+
+func exitStatus(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+	return err.Error()
+}
+
+// prefixWriter writes every line it receives to w, preceded by a prefix.
+type prefixWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: []byte(prefix)}
+}
+
+func (pw *prefixWriter) Write(b []byte) (int, error) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.buf = append(pw.buf, b...)
+	for {
+		i := bytes.IndexByte(pw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := append(append([]byte{}, pw.prefix...), pw.buf[:i+1]...)
+		if _, err := pw.w.Write(line); err != nil {
+			return len(b), err
+		}
+		pw.buf = pw.buf[i+1:]
+	}
+	return len(b), nil
+}
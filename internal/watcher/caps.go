@@ -0,0 +1,122 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Backend returns the name of the file system notification API fsnotify
+// uses on this platform, or "" when there is none.
+func Backend() string {
+	switch runtime.GOOS {
+	case "linux", "android":
+		return "inotify"
+	case "darwin", "ios", "freebsd", "openbsd", "netbsd", "dragonfly":
+		return "kqueue"
+	case "windows":
+		return "ReadDirectoryChangesW"
+	case "solaris", "illumos":
+		return "FEN"
+	default:
+		return ""
+	}
+}
+
+// EventSupport describes how well an event type works on this platform.
+type EventSupport struct {
+	Type      EventType `json:"type"`
+	Accepted  bool      `json:"accepted"`  // Valid as --event here
+	Delivered bool      `json:"delivered"` // Seen when probing a temporary directory
+	Note      string    `json:"note,omitempty"`
+}
+
+// ProbeEvents checks every event type against this platform and against a
+// temporary directory in dir (the system temp directory when empty), where
+// a file is created, written, read, changed, renamed and removed while
+// watching it.
+func ProbeEvents(dir string) ([]EventSupport, error) {
+	seen, err := probe(dir)
+	if err != nil {
+		return nil, err
+	}
+	support := make([]EventSupport, len(eventTypes))
+	for i, et := range eventTypes {
+		s := EventSupport{Type: et.typ, Delivered: seen.Has(et.op)}
+		if err := et.typ.Supported(); err != nil {
+			s.Note = err.Error()
+		} else {
+			s.Accepted = true
+			if !s.Delivered {
+				s.Note = "accepted, but not reported by the fsnotify version gowatchrun is built with"
+				if et.alternative != "" {
+					s.Note += "; " + et.alternative
+				}
+			}
+		}
+		support[i] = s
+	}
+	return support, nil
+}
+
+var (
+	probeOnce   sync.Once
+	probeResult fsnotify.Op
+	probeErr    error
+)
+
+// delivered reports whether events of type t were seen when probing the
+// system temp directory, which is only done once.
+func delivered(t EventType) bool {
+	probeOnce.Do(func() { probeResult, probeErr = probe("") })
+	return probeErr != nil || probeResult.Has(t.op())
+}
+
+// probe returns the union of the ops fsnotify reports for a file going
+// through its whole life cycle in a temporary directory.
+func probe(dir string) (fsnotify.Op, error) {
+	tmp, err := os.MkdirTemp(dir, "gowatchrun-probe-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmp)
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return 0, err
+	}
+	defer w.Close()
+	if err := w.Add(tmp); err != nil {
+		return 0, err
+	}
+
+	path := filepath.Join(tmp, "probe")
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	f.WriteString("probe")
+	f.Close()
+	os.ReadFile(path)
+	os.Chmod(path, 0o600)
+	os.Rename(path, path+"2")
+	os.Remove(path + "2")
+
+	var seen fsnotify.Op
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case event := <-w.Events:
+			seen |= event.Op
+		case <-w.Errors:
+		case <-time.After(100 * time.Millisecond):
+			return seen, nil
+		case <-timeout:
+			return seen, nil
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package watcher
+
+import "fmt"
+
+// Terminal clearing modes for --clear.
+const (
+	ClearAlways    = "always"     // Before every run
+	ClearOnSuccess = "on-success" // Unless the previous run failed, so its errors stay visible
+	ClearOnChange  = "on-change"  // Only for runs triggered by a file change, not timers, webhooks or --run-on-start
+)
+
+// ParseClearMode validates a --clear mode. "true" and "false" are accepted
+// for config files written when clear was a boolean.
+func ParseClearMode(mode string) (string, error) {
+	switch mode {
+	case "", "false":
+		return "", nil
+	case "true":
+		return ClearAlways, nil
+	case ClearAlways, ClearOnSuccess, ClearOnChange:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid clear mode '%s' (expected %s, %s or %s)", mode, ClearAlways, ClearOnSuccess, ClearOnChange)
+}
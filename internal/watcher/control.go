@@ -0,0 +1,426 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/secret"
+)
+
+const (
+	// maxPendingTriggers is how many triggered runs may wait for a job that
+	// is still busy with an earlier run.
+	maxPendingTriggers = 16
+	// subscriberBuffer is how many events a slow subscriber may fall behind
+	// before further events are dropped for it.
+	subscriberBuffer = 64
+	// maxHistory is how many finished runs History keeps.
+	maxHistory = 200
+)
+
+// ControlEvent kinds.
+const (
+	EventAccepted = "event" // An event passed the job's filters
+	RunStarted    = "run_started"
+	RunFinished   = "run_finished"
+	JobPaused     = "paused"
+	JobResumed    = "resumed"
+	RunOutput     = "output" // A line a command wrote, only for SubscribeOutput
+)
+
+// ErrUnknownJob is returned by Control for jobs whose watcher isn't running.
+var ErrUnknownJob = errors.New("no such job")
+
+// ErrRejectedPath is returned by Control.Trigger for paths outside the
+// job's watch directories or filtered out by it.
+var ErrRejectedPath = errors.New("path rejected")
+
+// ControlEvent is something a job did, as delivered to subscribers.
+type ControlEvent struct {
+	Kind   string
+	Job    string
+	Run    uint64 // ID of the run, for RunStarted and RunFinished
+	Time   time.Time
+	Data   *EventData      // The accepted event or the event of the run; nil for runs without one and for pauses
+	Result ExecutionResult // The outcome of the run, for RunFinished
+	Stream string          // "stdout" or "stderr", for RunOutput
+	Text   string          // The line without its line ending, for RunOutput
+}
+
+// Control lets an API pause and resume jobs, trigger runs and follow what
+// the jobs do. A nil *Control does nothing.
+type Control struct {
+	mu       sync.Mutex
+	paused   map[string]bool
+	triggers map[string]chan Event                            // Jobs whose watcher is running
+	accepts  map[string]func(path string) (*EventData, error) // Filters for the paths of triggered runs
+	replays  map[string]chan []Event                          // Suppressed events of these jobs, once they're resumed
+	subs     subscribers[ControlEvent]
+	output   subscribers[ControlEvent]
+	history  []ControlEvent          // Finished runs, oldest first
+	latest   map[string]ControlEvent // Last finished run of the jobs with problem matchers
+	runs     atomic.Uint64           // Last run ID
+
+	// With --pause-state, the paused jobs and the events they suppressed
+	// are kept in this file.
+	statePath  string
+	suppressed map[string][]suppressedEvent
+}
+
+// NewControl returns a Control without any jobs.
+func NewControl() *Control {
+	return &Control{
+		paused:   make(map[string]bool),
+		triggers: make(map[string]chan Event),
+		accepts:  make(map[string]func(path string) (*EventData, error)),
+		replays:  make(map[string]chan []Event),
+		subs:     make(subscribers[ControlEvent]),
+		output:   make(subscribers[ControlEvent]),
+		latest:   make(map[string]ControlEvent),
+	}
+}
+
+// Paused reports whether job ignores its events.
+func (c *Control) Paused(job string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused[job]
+}
+
+// Pause makes job ignore its events until it's resumed; runs that are
+// already pending still happen, and Trigger still works. An empty job
+// pauses every job.
+func (c *Control) Pause(job string) error {
+	return c.setPaused(job, true)
+}
+
+// Resume undoes Pause. Events that arrived while the job was paused are not
+// replayed, unless they were kept with LoadPauseState. An empty job resumes
+// every job.
+func (c *Control) Resume(job string) error {
+	return c.setPaused(job, false)
+}
+
+func (c *Control) setPaused(job string, paused bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	jobs := []string{job}
+	if job == "" {
+		jobs = nil
+		for name := range c.triggers {
+			jobs = append(jobs, name)
+		}
+		slices.Sort(jobs)
+	} else if _, ok := c.triggers[job]; !ok {
+		return fmt.Errorf("%w '%s'", ErrUnknownJob, job)
+	}
+
+	kind := JobResumed
+	if paused {
+		kind = JobPaused
+	}
+	for _, name := range jobs {
+		if c.paused[name] == paused {
+			continue
+		}
+		logger := Config{Name: name}.Logger()
+		if paused {
+			c.paused[name] = true
+			logger.Info().Msg("Paused, ignoring events until resumed")
+		} else {
+			delete(c.paused, name)
+			if events := c.takeSuppressed(name); len(events) > 0 {
+				select {
+				case c.replays[name] <- events:
+					logger.Info().Msgf("Resumed, running %d event(s) suppressed while paused", len(events))
+				default:
+					logger.Warn().Msgf("Resumed, dropping %d event(s) suppressed while paused: the job is still replaying earlier ones", len(events))
+				}
+			} else {
+				logger.Info().Msg("Resumed")
+			}
+		}
+		c.subs.publish(ControlEvent{Kind: kind, Job: name, Time: time.Now()})
+	}
+	c.saveState()
+	return nil
+}
+
+// Trigger queues a TRIGGER event for job, which runs like the events of its
+// other sources, whether or not the job is paused. path, if set, is the file
+// the run is for, which must be inside the job's watch directories and pass
+// its filters. job may be empty when only one job is running.
+func (c *Control) Trigger(job, path string) error {
+	c.mu.Lock()
+	if job == "" && len(c.triggers) == 1 {
+		for name := range c.triggers {
+			job = name
+		}
+	}
+	ch, ok := c.triggers[job]
+	accept := c.accepts[job]
+	c.mu.Unlock()
+	if !ok {
+		if job == "" {
+			return errors.New("a job name is required when running several jobs")
+		}
+		return fmt.Errorf("%w '%s'", ErrUnknownJob, job)
+	}
+
+	data := &EventData{Event: "TRIGGER"}
+	if path != "" {
+		var err error
+		if data, err = accept(path); err != nil {
+			return err
+		}
+	}
+	select {
+	case ch <- Event{Data: data}:
+		logger := Config{Name: job}.Logger()
+		if path != "" {
+			logger.Info().Msgf("Triggered run for: %s", path)
+		} else {
+			logger.Info().Msg("Triggered run")
+		}
+		return nil
+	default:
+		return fmt.Errorf("job '%s' already has %d triggered runs pending", job, maxPendingTriggers)
+	}
+}
+
+// Subscribe returns a channel that receives the events of all jobs, and a
+// function that stops the subscription. Events are dropped while the
+// channel is full.
+func (c *Control) Subscribe() (<-chan ControlEvent, func()) {
+	return subscribe(c, c.subs)
+}
+
+// SubscribeOutput is Subscribe for the output of commands: RunOutput
+// events for every line, between the RunStarted and RunFinished events of
+// their run.
+func (c *Control) SubscribeOutput() (<-chan ControlEvent, func()) {
+	return subscribe(c, c.output)
+}
+
+// subscribers are the channels of Subscribe or SubscribeOutput, guarded by
+// the lock of their Control.
+type subscribers[T any] map[chan T]bool
+
+func subscribe[T any](c *Control, subs subscribers[T]) (<-chan T, func()) {
+	ch := make(chan T, subscriberBuffer)
+	c.mu.Lock()
+	subs[ch] = true
+	c.mu.Unlock()
+	return ch, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if subs[ch] {
+			delete(subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish sends v to all subscribers, under the lock.
+func (s subscribers[T]) publish(v T) {
+	for ch := range s {
+		select {
+		case ch <- v:
+		default:
+		}
+	}
+}
+
+// accepted publishes an event that passed job's filters.
+func (c *Control) accepted(job string, data *EventData) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subs.publish(ControlEvent{Kind: EventAccepted, Job: job, Time: time.Now(), Data: data})
+}
+
+// Track wraps execFunc to publish the start and end of every run. Each run
+// gets a new ID, which is available to execFunc through RunID.
+func (c *Control) Track(execFunc ExecutorFunc) ExecutorFunc {
+	if c == nil {
+		return execFunc
+	}
+	return func(ctx context.Context, cfg Config, data *EventData) ExecutionResult {
+		run := c.runs.Add(1)
+		c.mu.Lock()
+		started := ControlEvent{Kind: RunStarted, Job: cfg.Name, Run: run, Time: time.Now(), Data: data}
+		c.subs.publish(started)
+		c.output.publish(started)
+		c.mu.Unlock()
+		result := execFunc(context.WithValue(ctx, runIDKey{}, run), cfg, data)
+		c.mu.Lock()
+		finished := ControlEvent{Kind: RunFinished, Job: cfg.Name, Run: run, Time: time.Now(), Data: data, Result: result}
+		c.subs.publish(finished)
+		c.output.publish(finished)
+		if result.Skipped {
+			c.mu.Unlock()
+			return result
+		}
+		c.history = append(c.history, finished)
+		if len(c.history) > maxHistory {
+			c.history = slices.Delete(c.history, 0, len(c.history)-maxHistory)
+		}
+		if len(cfg.ProblemMatchers) > 0 {
+			c.latest[cfg.Name] = finished
+		}
+		c.mu.Unlock()
+		return result
+	}
+}
+
+// History returns the RunFinished events of the most recent runs, oldest
+// first.
+func (c *Control) History() []ControlEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.history)
+}
+
+// Diagnostics returns the last finished run of every job with problem
+// matchers that ran, by job name, with the diagnostics in its result.
+func (c *Control) Diagnostics() []ControlEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	runs := slices.Collect(maps.Values(c.latest))
+	slices.SortFunc(runs, func(a, b ControlEvent) int { return strings.Compare(a.Job, b.Job) })
+	return runs
+}
+
+type runIDKey struct{}
+
+// RunID returns the ID Control.Track gave the run ctx belongs to, or 0.
+func RunID(ctx context.Context) uint64 {
+	run, _ := ctx.Value(runIDKey{}).(uint64)
+	return run
+}
+
+// maxOutputLine is the length at which a line of output without a newline
+// is published anyway.
+const maxOutputLine = 64 << 10
+
+// Output returns a writer that publishes what's written to it line by line
+// as the given stream of the run of ctx. Close publishes an unterminated
+// last line.
+func (c *Control) Output(ctx context.Context, job, stream string) io.WriteCloser {
+	return &outputWriter{control: c, line: ControlEvent{Kind: RunOutput, Job: job, Run: RunID(ctx), Stream: stream}}
+}
+
+type outputWriter struct {
+	control *Control
+	line    ControlEvent
+	buf     []byte
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.publish(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) >= maxOutputLine {
+		w.publish(w.buf)
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+func (w *outputWriter) Close() error {
+	if len(w.buf) > 0 {
+		w.publish(w.buf)
+		w.buf = nil
+	}
+	return nil
+}
+
+func (w *outputWriter) publish(text []byte) {
+	line := w.line
+	line.Time = time.Now()
+	line.Text = secret.Redact(string(bytes.TrimSuffix(text, []byte("\r"))))
+	w.control.mu.Lock()
+	defer w.control.mu.Unlock()
+	w.control.output.publish(line)
+}
+
+// controlSource emits the events queued by Control.Trigger for one job, and
+// the events it suppressed while paused once it's resumed.
+type controlSource struct {
+	control *Control
+	job     string
+	accept  func(path string) (*EventData, error) // Filters the paths of triggered runs
+}
+
+func (s *controlSource) Start(ctx context.Context) (<-chan Event, error) {
+	c := s.control
+	triggers := make(chan Event, maxPendingTriggers)
+	replays := make(chan []Event, 1)
+	c.mu.Lock()
+	if _, ok := c.triggers[s.job]; ok {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("job '%s' is already running", s.job)
+	}
+	c.triggers[s.job] = triggers
+	c.accepts[s.job] = s.accept
+	c.replays[s.job] = replays
+	c.mu.Unlock()
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			delete(c.triggers, s.job)
+			delete(c.accepts, s.job)
+			delete(c.replays, s.job)
+			delete(c.paused, s.job)
+		}()
+		send := func(event Event) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-triggers:
+				if !send(event) {
+					return
+				}
+			case replay := <-replays:
+				for _, event := range replay {
+					if !send(event) {
+						return
+					}
+				}
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,101 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// dedupeWindow is how close together two events for the same underlying
+// file have to be to count as one change.
+const dedupeWindow = 100 * time.Millisecond
+
+// DefaultDedupeWindow is how close together two events of the same type for
+// the same path have to be to count as one, unless --dedupe-window says
+// otherwise.
+const DefaultDedupeWindow = 10 * time.Millisecond
+
+type fileKey struct {
+	dev, ino uint64
+	op       fsnotify.Op
+}
+
+type seenEvent struct {
+	path string
+	at   time.Time
+}
+
+// inodeDeduper drops events for a file that was just reported under another
+// path, which happens when the same file is visible through hardlinks, bind
+// mounts or overlapping watch directories. Repeated events for the same path
+// are left alone.
+type inodeDeduper struct {
+	seen map[fileKey]seenEvent
+}
+
+func newInodeDeduper() *inodeDeduper {
+	return &inodeDeduper{seen: make(map[fileKey]seenEvent)}
+}
+
+// duplicate reports whether event was already seen under a different path
+// within the dedupe window, and returns that path.
+func (d *inodeDeduper) duplicate(event fsnotify.Event) (string, bool) {
+	dev, ino, ok := fileID(event.Name)
+	if !ok {
+		return "", false
+	}
+	now := time.Now()
+	key := fileKey{dev: dev, ino: ino, op: event.Op}
+	if prev, ok := d.seen[key]; ok && prev.path != event.Name && now.Sub(prev.at) < dedupeWindow {
+		return prev.path, true
+	}
+	d.seen[key] = seenEvent{path: event.Name, at: now}
+
+	if len(d.seen) > 1024 {
+		for k, v := range d.seen {
+			if now.Sub(v.at) >= dedupeWindow {
+				delete(d.seen, k)
+			}
+		}
+	}
+	return "", false
+}
+
+type pathKey struct {
+	path string
+	op   fsnotify.Op
+}
+
+// pathDeduper drops events of the same type for the same path that arrive
+// within window of the last one it let through, which some platforms
+// deliver for a single save. Unlike debouncing, it doesn't delay anything.
+type pathDeduper struct {
+	window time.Duration
+	seen   map[pathKey]time.Time
+}
+
+func newPathDeduper(window time.Duration) *pathDeduper {
+	return &pathDeduper{window: window, seen: make(map[pathKey]time.Time)}
+}
+
+// duplicate reports whether event, which happened at at, repeats an event
+// let through less than the window before.
+func (d *pathDeduper) duplicate(event fsnotify.Event, at time.Time) bool {
+	if d.window <= 0 {
+		return false
+	}
+	key := pathKey{path: event.Name, op: event.Op}
+	if prev, ok := d.seen[key]; ok && at.Sub(prev) < d.window {
+		return true
+	}
+	d.seen[key] = at
+
+	if len(d.seen) > 1024 {
+		for k, prev := range d.seen {
+			if at.Sub(prev) >= d.window {
+				delete(d.seen, k)
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package watcher
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// EventType is a kind of file system event, named as on the command line.
+type EventType string
+
+const (
+	EventCreate     EventType = "create"
+	EventWrite      EventType = "write"
+	EventRemove     EventType = "remove"
+	EventRename     EventType = "rename"
+	EventChmod      EventType = "chmod"
+	EventOpen       EventType = "open"       // Linux and FreeBSD only
+	EventRead       EventType = "read"       // Linux and FreeBSD only
+	EventCloseWrite EventType = "closewrite" // Linux and FreeBSD only
+	EventCloseRead  EventType = "closeread"  // Linux and FreeBSD only
+)
+
+// eventType ties an EventType to its fsnotify op and the name {{.Event}}
+// reports for it.
+type eventType struct {
+	typ        EventType
+	op         fsnotify.Op
+	name       string
+	unportable bool // Only delivered by fsnotify's inotify backend
+	// alternative suggests what to use where the event type isn't
+	// available
+	alternative string
+}
+
+// eventTypes lists every event type in the order they're matched, so an
+// event carrying several ops (WRITE|CHMOD) always reports the same one.
+//
+// fsnotify doesn't export the ops of the unportable events, so they're
+// spelled out as the bits fsnotify 1.9 uses. verifyEventTypes checks them
+// against fsnotify's own names, since a fsnotify upgrade that moves them
+// would otherwise make e.g. closewrite silently match something else.
+var eventTypes = []eventType{
+	{EventCreate, fsnotify.Create, "CREATE", false, ""},
+	{EventWrite, fsnotify.Write, "WRITE", false, ""},
+	{EventRemove, fsnotify.Remove, "REMOVE", false, ""},
+	{EventRename, fsnotify.Rename, "RENAME", false, ""},
+	{EventChmod, fsnotify.Chmod, "CHMOD", false, ""},
+	{EventOpen, fsnotify.Op(1 << 5), "OPEN", true, ""},                                                                    // IN_OPEN
+	{EventRead, fsnotify.Op(1 << 6), "READ", true, ""},                                                                    // IN_ACCESS
+	{EventCloseWrite, fsnotify.Op(1 << 7), "CLOSE_WRITE", true, "use write with --settle to run once a file is complete"}, // IN_CLOSE_WRITE
+	{EventCloseRead, fsnotify.Op(1 << 8), "CLOSE_READ", true, ""},                                                         // IN_CLOSE_NOWRITE
+}
+
+// errEventTypes is set when the ops above don't match the fsnotify version
+// gowatchrun was built with. The unportable events are unavailable then.
+var errEventTypes = verifyEventTypes()
+
+func verifyEventTypes() error {
+	for _, et := range eventTypes {
+		if got := et.op.String(); got != et.name {
+			return fmt.Errorf("fsnotify reports op %d as %s instead of %s; this build's fsnotify version is not supported for unportable events", uint32(et.op), got, et.name)
+		}
+	}
+	return nil
+}
+
+// ParseEventType returns the EventType named t, case-insensitively. It
+// doesn't accept "all".
+func ParseEventType(t string) (EventType, error) {
+	for _, et := range eventTypes {
+		if strings.EqualFold(t, string(et.typ)) {
+			return et.typ, nil
+		}
+	}
+	return "", fmt.Errorf("'%s': %w", t, ErrUnknownEventType)
+}
+
+// Name returns how {{.Event}} reports events of type t, e.g. CLOSE_WRITE.
+func (t EventType) Name() string {
+	if et, ok := lookupEventType(t); ok {
+		return et.name
+	}
+	return strings.ToUpper(string(t))
+}
+
+// Unportable reports whether t is only delivered on Linux and FreeBSD.
+func (t EventType) Unportable() bool {
+	et, ok := lookupEventType(t)
+	return ok && et.unportable
+}
+
+// Supported returns an error wrapping ErrUnsupportedEventType when t can't
+// be watched for on this platform.
+func (t EventType) Supported() error {
+	if !t.Unportable() {
+		return nil
+	}
+	hint := ""
+	if et, _ := lookupEventType(t); et.alternative != "" {
+		hint = " (" + et.alternative + ")"
+	}
+	if runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		return fmt.Errorf("'%s': %w%s", t, ErrUnsupportedEventType, hint)
+	}
+	if errEventTypes != nil {
+		return fmt.Errorf("'%s': %w: %v%s", t, ErrUnsupportedEventType, errEventTypes, hint)
+	}
+	return nil
+}
+
+func (t EventType) op() fsnotify.Op {
+	et, _ := lookupEventType(t)
+	return et.op
+}
+
+func lookupEventType(t EventType) (eventType, bool) {
+	for _, et := range eventTypes {
+		if et.typ == t {
+			return et, true
+		}
+	}
+	return eventType{}, false
+}
+
+// matchEventType returns the first allowed event type among the ops of
+// op, in the order of eventTypes.
+func matchEventType(op fsnotify.Op, allowed map[fsnotify.Op]bool) (eventType, bool) {
+	for _, et := range eventTypes {
+		if allowed[et.op] && op.Has(et.op) {
+			return et, true
+		}
+	}
+	return eventType{}, false
+}
@@ -0,0 +1,103 @@
+package watcher
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// excluder decides which directories are skipped when watching recursively.
+// Excludes come in three forms:
+//
+//   - Absolute paths, and paths starting with ./ or ../, name one directory
+//     (relative paths are resolved against the current directory).
+//   - Glob patterns such as **/node_modules or build/*/cache are matched
+//     against the directory path relative to each watch root.
+//   - Any other relative path (vendor, web/dist) is resolved against each
+//     watch root, and against the current directory for compatibility.
+//
+// A directory is excluded when any rule matches it or one of its parents,
+// or when the .gowatchrunignore files of its watch root ignore it.
+type excluder struct {
+	rules  []excludeRule
+	ignore *ignoreFiles
+}
+
+type excludeRule struct {
+	spec string // As given by the user
+	abs  string // Absolute path, for path rules
+	rel  string // Root-relative path in slash form, for root-relative rules
+	glob bool
+}
+
+func newExcluder(excludes []string) *excluder {
+	e := &excluder{}
+	for _, spec := range excludes {
+		rule := excludeRule{spec: spec}
+		slashed := filepath.ToSlash(spec)
+		switch {
+		case filepath.IsAbs(spec):
+			rule.abs = filepath.Clean(spec)
+		case strings.ContainsAny(slashed, "*?[{"):
+			rule.rel = strings.TrimPrefix(slashed, "./")
+			rule.glob = true
+		case slashed == "." || slashed == ".." || strings.HasPrefix(slashed, "./") || strings.HasPrefix(slashed, "../"):
+			rule.abs, _ = filepath.Abs(spec)
+		default:
+			rule.abs, _ = filepath.Abs(spec)
+			rule.rel = strings.TrimSuffix(slashed, "/")
+		}
+		e.rules = append(e.rules, rule)
+	}
+	return e
+}
+
+// excluder returns the excluder for cfg's ExcludeDirs and ignore files.
+func (cfg Config) excluder() *excluder {
+	e := newExcluder(cfg.ExcludeDirs)
+	e.ignore = cfg.ignoreFiles
+	if e.ignore == nil {
+		e.ignore = newIgnoreFiles()
+	}
+	return e
+}
+
+// excluded reports whether the directory absPath, found below the watch
+// root absRoot, is excluded, and by which rule as given by the user.
+func (e *excluder) excluded(absRoot, absPath string) (string, bool) {
+	if e == nil {
+		return "", false
+	}
+	if rule, ok := e.ignore.ignored(absRoot, absPath, true); ok {
+		return rule, true
+	}
+	if len(e.rules) == 0 {
+		return "", false
+	}
+	rel := ""
+	if r, err := filepath.Rel(absRoot, absPath); err == nil && r != "." && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		rel = filepath.ToSlash(r)
+	}
+
+	for _, rule := range e.rules {
+		if rule.abs != "" && withinDir(absPath, rule.abs) {
+			return rule.spec, true
+		}
+		if rel == "" || rule.rel == "" {
+			continue
+		}
+		if rule.glob {
+			// Check every ancestor too, so files below an excluded directory match
+			parts := strings.Split(rel, "/")
+			for i := range parts {
+				if ok, _ := doublestar.Match(rule.rel, strings.Join(parts[:i+1], "/")); ok {
+					return rule.spec, true
+				}
+			}
+		} else if withinDir(filepath.FromSlash(rel), filepath.FromSlash(rule.rel)) {
+			return rule.spec, true
+		}
+	}
+	return "", false
+}
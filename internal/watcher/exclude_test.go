@@ -0,0 +1,42 @@
+package watcher
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExcluded(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		exclude     string
+		dir         string // Below root, in slash form
+		want        bool
+		wantWindows bool
+	}{
+		{exclude: "vendor", dir: "vendor", want: true, wantWindows: true},
+		{exclude: "vendor", dir: "vendor/pkg", want: true, wantWindows: true},
+		{exclude: "vendor", dir: "vendors", want: false, wantWindows: false},
+		{exclude: "web/dist", dir: "web/dist/assets", want: true, wantWindows: true},
+		{exclude: `web\dist`, dir: "web/dist", want: false, wantWindows: true},
+		{exclude: "Vendor", dir: "vendor", want: false, wantWindows: true},
+		{exclude: "**/node_modules", dir: "app/node_modules/x", want: true, wantWindows: true},
+		{exclude: `build\*\cache`, dir: "build/linux/cache", want: false, wantWindows: true},
+		{exclude: "build/*/cache", dir: "build/linux/cache", want: true, wantWindows: true},
+		{exclude: filepath.Join(root, "out"), dir: "out/bin", want: true, wantWindows: true},
+		{exclude: strings.ToUpper(filepath.Join(root, "out")), dir: "out", want: false, wantWindows: true},
+		{exclude: filepath.Join(root, "out"), dir: "output", want: false, wantWindows: false},
+	}
+	for _, tt := range tests {
+		want := tt.want
+		if runtime.GOOS == "windows" {
+			want = tt.wantWindows
+		}
+		e := newExcluder([]string{tt.exclude})
+		path := filepath.Join(root, filepath.FromSlash(tt.dir))
+		if _, got := e.excluded(root, path); got != want {
+			t.Errorf("exclude %q: excluded(%q) = %v, want %v", tt.exclude, tt.dir, got, want)
+		}
+	}
+}
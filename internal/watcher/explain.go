@@ -0,0 +1,174 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// Step is the outcome of one filter in an Explanation.
+type Step struct {
+	Passed bool
+	Reason string
+}
+
+// Explanation describes how the filters of a job treat an event.
+type Explanation struct {
+	Accepted bool
+	Steps    []Step // In the order the filters are applied
+}
+
+// Explain reports which watch directory, exclude, event and pattern rules
+// accept or reject an event of type event (e.g. "write") for path, without
+// watching anything. All filters are evaluated, even after one rejects.
+func (cfg Config) Explain(path, event string) Explanation {
+	var e Explanation
+	add := func(passed bool, format string, args ...interface{}) {
+		e.Steps = append(e.Steps, Step{Passed: passed, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		add(false, "cannot resolve path: %v", err)
+		return e
+	}
+
+	switch root, nested := watchRootOf(cfg, absPath); {
+	case len(cfg.WatchDirs) == 0:
+		add(false, "job has no local watch directories")
+	case root == "":
+		add(false, "not inside any watch directory %v", cfg.WatchDirs)
+	case nested && !cfg.Recursive:
+		add(false, "in a subdirectory of watch directory '%s', which is only watched with --recursive", root)
+	case cfg.Recursive:
+		add(true, "inside watch directory '%s' (recursive)", root)
+	default:
+		add(true, "inside watch directory '%s'", root)
+	}
+
+	if !cfg.IncludeHidden {
+		if hidden := hiddenComponent(cfg, absPath); hidden != "" {
+			add(false, "'%s' is hidden, and hidden files and directories are ignored without --include-hidden", hidden)
+		}
+	}
+
+	if cfg.Recursive {
+		absRoot := ""
+		if root, _ := watchRootOf(cfg, absPath); root != "" {
+			absRoot, _ = filepath.Abs(root)
+		}
+		if rule, ok := cfg.excluder().excluded(absRoot, filepath.Dir(absPath)); ok {
+			add(false, "below a directory excluded by '%s'", rule)
+		} else if len(cfg.ExcludeDirs) > 0 {
+			add(true, "not below any excluded directory %v", cfg.ExcludeDirs)
+		}
+	}
+
+	if cfg.Target != "" && cfg.Target != TargetBoth {
+		info, err := os.Stat(absPath)
+		if reason := targetReason(cfg.Target, err == nil && info.IsDir()); reason != "" {
+			add(false, "%s", reason)
+		} else {
+			add(true, "matches --target %s", cfg.Target)
+		}
+	}
+
+	if cfg.ignoreFiles == nil {
+		cfg.ignoreFiles = newIgnoreFiles()
+	}
+	if rule, ok := cfg.ignoredFile(absPath); ok {
+		add(false, "ignored by %s", rule)
+	}
+
+	var op fsnotify.Op
+	if strings.EqualFold(event, "all") {
+		add(false, "explain needs a single event type, not 'all'")
+	} else if err := CheckEventType(event); err != nil {
+		add(false, "%v", err)
+	} else {
+		typ, _ := ParseEventType(event)
+		op = typ.op()
+		allowed, _ := processEventTypes(cfg.EventTypes, zerolog.Nop())
+		if cfg.IgnoreNoise && op == fsnotify.Chmod && noiseReason(fsnotify.Event{Op: op}, cfg.EventTypes) != "" {
+			add(false, "chmod-only events are ignored (--ignore-common-noise)")
+		} else if allowed[op] {
+			add(true, "event %s is allowed by %v", op, cfg.EventTypes)
+		} else {
+			add(false, "event %s is not in %v", op, cfg.EventTypes)
+		}
+	}
+
+	if cfg.IgnoreNoise {
+		if reason := noiseReason(fsnotify.Event{Name: path}, cfg.EventTypes); reason != "" {
+			add(false, "%s (--ignore-common-noise)", reason)
+		}
+	}
+
+	name := filepath.Base(path)
+	if pattern, ok := cfg.matchPattern(path); ok {
+		add(true, "'%s' matches pattern '%s'", name, pattern)
+	} else {
+		add(false, "'%s' matches none of the patterns %v", name, cfg.patternsFor(path))
+	}
+
+	if cfg.MinSize > 0 || cfg.MaxSize > 0 || cfg.MinAge > 0 {
+		if reason := fileFilterReason(cfg, fsnotify.Event{Name: path, Op: op}); reason != "" {
+			add(false, "%s", reason)
+		} else {
+			add(true, "passes the size and age filters")
+		}
+	}
+
+	if len(cfg.MimeTypes) > 0 {
+		mediaType := detectMime(path)
+		if pattern, ok := matchMime(cfg.MimeTypes, mediaType); ok {
+			add(true, "media type '%s' matches '%s'", mediaType, pattern)
+		} else {
+			add(false, "media type '%s' matches none of %v", mediaType, cfg.MimeTypes)
+		}
+	}
+
+	e.Accepted = true
+	for _, step := range e.Steps {
+		e.Accepted = e.Accepted && step.Passed
+	}
+	return e
+}
+
+// watchRootOf returns the watch directory containing absPath and whether
+// absPath is in a subdirectory of it rather than directly inside.
+func watchRootOf(cfg Config, absPath string) (root string, nested bool) {
+	for _, dir := range cfg.WatchDirs {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		if samePath(absPath, absDir) || !withinDir(absPath, absDir) {
+			continue
+		}
+		return dir, !samePath(filepath.Dir(absPath), absDir)
+	}
+	return "", false
+}
+
+// hiddenComponent returns the first hidden file or directory name in absPath
+// below its watch directory, or "" when there is none.
+func hiddenComponent(cfg Config, absPath string) string {
+	root, _ := watchRootOf(cfg, absPath)
+	rel := filepath.Base(absPath)
+	if root != "" {
+		if absRoot, err := filepath.Abs(root); err == nil {
+			rel, _ = filepath.Rel(absRoot, absPath)
+		}
+	}
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if isHidden(part) {
+			return part
+		}
+	}
+	return ""
+}
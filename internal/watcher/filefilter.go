@@ -0,0 +1,100 @@
+package watcher
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileFilterReason applies the size and age filters to the file behind
+// event and returns why it was rejected, or "" when it passes. Removed and
+// renamed files can't be inspected and always pass.
+func fileFilterReason(cfg Config, event fsnotify.Event) string {
+	if cfg.MinSize == 0 && cfg.MaxSize == 0 && cfg.MinAge == 0 {
+		return ""
+	}
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		return ""
+	}
+
+	info, err := os.Stat(event.Name)
+	if err != nil {
+		return fmt.Sprintf("cannot check size and age: %v", err)
+	}
+	if info.Size() < cfg.MinSize {
+		return fmt.Sprintf("size %d bytes is below --min-size %d", info.Size(), cfg.MinSize)
+	}
+	if cfg.MaxSize > 0 && info.Size() > cfg.MaxSize {
+		return fmt.Sprintf("size %d bytes is above --max-size %d", info.Size(), cfg.MaxSize)
+	}
+	if age := time.Since(info.ModTime()); age < cfg.MinAge {
+		return fmt.Sprintf("modified %s ago, younger than --min-age %s", age.Round(time.Millisecond), cfg.MinAge)
+	}
+	return ""
+}
+
+// needsMime reports whether events need the media type of their file,
+// which takes reading it: to match MimeTypes, for templates that use
+// {{.Mime}}, or because whole events are passed on, to a handler, plugins,
+// a Go handler, as the body of an HTTP action, or elsewhere (SniffMime).
+func (cfg Config) needsMime() bool {
+	if len(cfg.MimeTypes) > 0 || cfg.SniffMime || cfg.Handler != nil || cfg.GoHandler != "" ||
+		len(cfg.FilterPlugins) > 0 || cfg.ActionPlugin != "" || len(cfg.NotifyPlugins) > 0 ||
+		(cfg.HTTPURL != "" && cfg.HTTPBody == "") {
+		return true
+	}
+	templates := slices.Concat(
+		[]string{cfg.CommandTmpl, cfg.When, cfg.SidecarTmpl, cfg.ActionDest, cfg.HTTPURL, cfg.HTTPBody, cfg.OnFailure},
+		cfg.HTTPHeaders, slices.Collect(maps.Values(cfg.Templates)))
+	return slices.ContainsFunc(templates, func(text string) bool { return strings.Contains(text, "Mime") })
+}
+
+// detectMime returns the media type of the file at path, without
+// parameters. The type is sniffed from the file's first 512 bytes; the
+// extension is only consulted when the content is inconclusive or the file
+// can't be read (e.g. because it was removed).
+func detectMime(path string) string {
+	sniffed := ""
+	if f, err := os.Open(path); err == nil {
+		buf := make([]byte, 512)
+		n, _ := io.ReadFull(f, buf)
+		f.Close()
+		if n > 0 {
+			sniffed = http.DetectContentType(buf[:n])
+		}
+	}
+	if sniffed == "" || strings.HasPrefix(sniffed, "application/octet-stream") {
+		if byExt := mime.TypeByExtension(filepath.Ext(path)); byExt != "" {
+			sniffed = byExt
+		}
+	}
+	if sniffed == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(sniffed)
+	if err != nil {
+		return sniffed
+	}
+	return mediaType
+}
+
+// matchMime returns the first of patterns (e.g. "image/*") that matches
+// mediaType.
+func matchMime(patterns []string, mediaType string) (string, bool) {
+	for _, pattern := range patterns {
+		if match, _ := path.Match(pattern, mediaType); match {
+			return pattern, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,58 @@
+package watcher
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// fseventsState is what --fsevents-state records between runs: the ID of
+// the last FSEvents event seen, which is only meaningful for the volume's
+// event database it came from.
+type fseventsState struct {
+	Device  string `json:"device"` // UUID of the event database of the watched volume
+	EventID uint64 `json:"event_id"`
+}
+
+// loadFSEventsState reads the state file at path. A missing file returns
+// the zero state.
+func loadFSEventsState(path string) (fseventsState, error) {
+	var state fseventsState
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+	return state, json.Unmarshal(raw, &state)
+}
+
+// save replaces the state file at path.
+func (s fseventsState) save(path string) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, raw)
+}
+
+// historicalPath maps an absolute, symlink-resolved path FSEvents reported
+// to the path it has below the matching watch directory as configured, or
+// returns false when it isn't below one (or, unless recursive, directly in
+// one).
+func historicalPath(path string, roots, realRoots []string, recursive bool) (string, bool) {
+	for i, realRoot := range realRoots {
+		if !withinDir(path, realRoot) || samePath(path, realRoot) {
+			continue
+		}
+		rel, err := filepath.Rel(realRoot, path)
+		if err != nil {
+			continue
+		}
+		if !recursive && filepath.Dir(rel) != "." {
+			continue
+		}
+		return filepath.Join(roots[i], rel), true
+	}
+	return "", false
+}
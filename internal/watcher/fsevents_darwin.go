@@ -0,0 +1,269 @@
+//go:build darwin && cgo
+
+package watcher
+
+/*
+#cgo LDFLAGS: -framework CoreServices
+#include <CoreServices/CoreServices.h>
+#include <dispatch/dispatch.h>
+#include <stdlib.h>
+
+extern void gowatchrunFSEvents(ConstFSEventStreamRef stream, uintptr_t info, size_t n, char **paths, FSEventStreamEventFlags *flags, FSEventStreamEventId *ids);
+
+static FSEventStreamRef gowatchrunCreateStream(uintptr_t info, CFArrayRef paths, FSEventStreamEventId since) {
+	FSEventStreamContext context = {0, (void *)info, NULL, NULL, NULL};
+	return FSEventStreamCreate(NULL, (FSEventStreamCallback)gowatchrunFSEvents, &context, paths, since, 0.1,
+		kFSEventStreamCreateFlagFileEvents | kFSEventStreamCreateFlagNoDefer);
+}
+
+static dispatch_queue_t gowatchrunQueue(void) {
+	return dispatch_queue_create("gowatchrun.fsevents", DISPATCH_QUEUE_SERIAL);
+}
+
+static void gowatchrunReleaseQueue(dispatch_queue_t queue) {
+	dispatch_release(queue);
+}
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/cgo"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// fseventsSaveInterval is how often --fsevents-state is updated while
+// watching, in addition to when the watcher stops.
+const fseventsSaveInterval = 10 * time.Second
+
+// CheckFSEvents reports whether --fsevents-state is available in this build.
+func CheckFSEvents() error {
+	return nil
+}
+
+// fseventsSource replays the changes below the watch directories that
+// FSEvents recorded since the event ID in --fsevents-state, which covers the
+// time gowatchrun wasn't running. Once the history is done, it only keeps
+// track of the latest event ID; the fsnotify source reports live changes.
+type fseventsSource struct {
+	cfg    Config
+	logger zerolog.Logger
+
+	roots     []string // Watch directories as configured
+	realRoots []string // Their absolute, symlink-resolved paths, as FSEvents reports them
+	events    chan Event
+	done      <-chan struct{}
+
+	mu         sync.Mutex
+	state      fseventsState
+	catchingUp bool
+	replayed   int
+}
+
+func (s *fseventsSource) Start(ctx context.Context) (<-chan Event, error) {
+	path := s.cfg.FSEventsState
+	state, err := loadFSEventsState(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --fsevents-state: %w", err)
+	}
+
+	var paths []string
+	for _, dir := range s.cfg.WatchDirs {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			real, err = filepath.Abs(real)
+		}
+		if err != nil {
+			s.logger.Warn().Msgf("Not catching up on %s: %v", dir, err)
+			continue
+		}
+		s.roots = append(s.roots, dir)
+		s.realRoots = append(s.realRoots, real)
+		paths = append(paths, real)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("none of the watch directories exist for --fsevents-state")
+	}
+
+	device, err := eventDatabase(paths[0])
+	if err != nil {
+		return nil, err
+	}
+	since := C.FSEventStreamEventId(C.kFSEventStreamEventIdSinceNow)
+	switch {
+	case state.EventID == 0:
+		s.logger.Info().Msgf("No FSEvents history in %s yet, catching up from the next run", path)
+	case state.Device != device:
+		s.logger.Warn().Msgf("The event database of %s changed since the last run, not catching up", paths[0])
+	default:
+		since = C.FSEventStreamEventId(state.EventID)
+		s.catchingUp = true
+		s.logger.Info().Msgf("Catching up on changes since FSEvents event %d", state.EventID)
+	}
+	s.state = fseventsState{Device: device, EventID: uint64(C.FSEventsGetCurrentEventId())}
+	if !s.catchingUp {
+		if err := s.state.save(path); err != nil {
+			return nil, fmt.Errorf("failed to write --fsevents-state: %w", err)
+		}
+	}
+
+	cfPaths := C.CFArrayCreateMutable(C.kCFAllocatorDefault, C.CFIndex(len(paths)), &C.kCFTypeArrayCallBacks)
+	for _, p := range paths {
+		cp := C.CString(p)
+		str := C.CFStringCreateWithCString(C.kCFAllocatorDefault, cp, C.kCFStringEncodingUTF8)
+		C.free(unsafe.Pointer(cp))
+		C.CFArrayAppendValue(cfPaths, unsafe.Pointer(str))
+		C.CFRelease(C.CFTypeRef(str))
+	}
+	defer C.CFRelease(C.CFTypeRef(cfPaths))
+
+	s.events = make(chan Event)
+	s.done = ctx.Done()
+	handle := cgo.NewHandle(s)
+	stream := C.gowatchrunCreateStream(C.uintptr_t(handle), C.CFArrayRef(cfPaths), since)
+	if stream == nil {
+		handle.Delete()
+		return nil, fmt.Errorf("failed to create FSEvents stream")
+	}
+	queue := C.gowatchrunQueue()
+	C.FSEventStreamSetDispatchQueue(stream, queue)
+	if C.FSEventStreamStart(stream) == 0 {
+		C.FSEventStreamInvalidate(stream)
+		C.FSEventStreamRelease(stream)
+		C.gowatchrunReleaseQueue(queue)
+		handle.Delete()
+		return nil, fmt.Errorf("failed to start FSEvents stream")
+	}
+
+	go func() {
+		ticker := time.NewTicker(fseventsSaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.save()
+			case <-ctx.Done():
+				C.FSEventStreamStop(stream)
+				C.FSEventStreamInvalidate(stream)
+				C.FSEventStreamRelease(stream)
+				C.gowatchrunReleaseQueue(queue)
+				handle.Delete()
+				s.save()
+				close(s.events)
+				return
+			}
+		}
+	}()
+	return s.events, nil
+}
+
+// save writes the latest event ID to --fsevents-state, unless the history
+// is still being replayed: its events may not have run yet.
+func (s *fseventsSource) save() {
+	s.mu.Lock()
+	state, catchingUp := s.state, s.catchingUp
+	s.mu.Unlock()
+	if catchingUp {
+		return
+	}
+	if err := state.save(s.cfg.FSEventsState); err != nil {
+		s.logger.Warn().Msgf("Failed to write --fsevents-state: %v", err)
+	}
+}
+
+// handle processes a callback's events on the stream's dispatch queue.
+func (s *fseventsSource) handle(paths []string, flags []C.FSEventStreamEventFlags, ids []C.FSEventStreamEventId) {
+	for i, path := range paths {
+		s.mu.Lock()
+		catchingUp := s.catchingUp
+		if flags[i]&C.kFSEventStreamEventFlagHistoryDone != 0 {
+			s.catchingUp = false
+			s.logger.Info().Msgf("Caught up on %d change(s) since the last run", s.replayed)
+		} else if uint64(ids[i]) > s.state.EventID {
+			s.state.EventID = uint64(ids[i])
+		}
+		s.mu.Unlock()
+		if !catchingUp || flags[i]&C.kFSEventStreamEventFlagHistoryDone != 0 {
+			continue
+		}
+		if flags[i]&(C.kFSEventStreamEventFlagMustScanSubDirs|C.kFSEventStreamEventFlagEventIdsWrapped) != 0 {
+			s.logger.Warn().Msgf("FSEvents dropped history below %s, changes there while gowatchrun was stopped are missed", path)
+			continue
+		}
+		rel, ok := historicalPath(path, s.roots, s.realRoots, s.cfg.Recursive)
+		if !ok {
+			continue
+		}
+		event := Event{Path: rel, Op: fseventsOp(path, flags[i]), IsDir: flags[i]&C.kFSEventStreamEventFlagItemIsDir != 0}
+		select {
+		case s.events <- event:
+			s.mu.Lock()
+			s.replayed++
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// fseventsOp maps the flags FSEvents coalesced for path to the fsnotify
+// operation the rest of the pipeline filters on. A file that was created
+// or renamed and still exists is reported as created.
+func fseventsOp(path string, flags C.FSEventStreamEventFlags) fsnotify.Op {
+	_, err := os.Lstat(path)
+	exists := err == nil
+	switch {
+	case flags&C.kFSEventStreamEventFlagItemRemoved != 0 && !exists:
+		return fsnotify.Remove
+	case flags&C.kFSEventStreamEventFlagItemRenamed != 0 && !exists:
+		return fsnotify.Rename
+	case flags&(C.kFSEventStreamEventFlagItemCreated|C.kFSEventStreamEventFlagItemRenamed) != 0:
+		return fsnotify.Create
+	case flags&C.kFSEventStreamEventFlagItemModified != 0:
+		return fsnotify.Write
+	case flags&(C.kFSEventStreamEventFlagItemChangeOwner|C.kFSEventStreamEventFlagItemInodeMetaMod|C.kFSEventStreamEventFlagItemXattrMod) != 0:
+		return fsnotify.Chmod
+	}
+	return fsnotify.Write
+}
+
+// eventDatabase returns the UUID of the FSEvents database of the volume
+// holding path, which changes when the volume's history is discarded.
+func eventDatabase(path string) (string, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return "", err
+	}
+	uuid := C.FSEventsCopyUUIDForDevice(C.dev_t(stat.Dev))
+	if uuid == 0 {
+		return "", fmt.Errorf("%s is on a volume without FSEvents history", path)
+	}
+	defer C.CFRelease(C.CFTypeRef(uuid))
+	str := C.CFUUIDCreateString(C.kCFAllocatorDefault, uuid)
+	defer C.CFRelease(C.CFTypeRef(str))
+	var buf [64]C.char
+	if C.CFStringGetCString(str, &buf[0], C.CFIndex(len(buf)), C.kCFStringEncodingUTF8) == 0 {
+		return "", fmt.Errorf("failed to read the event database UUID of %s", path)
+	}
+	return C.GoString(&buf[0]), nil
+}
+
+//export gowatchrunFSEvents
+func gowatchrunFSEvents(stream C.ConstFSEventStreamRef, info C.uintptr_t, n C.size_t, cPaths **C.char, cFlags *C.FSEventStreamEventFlags, cIDs *C.FSEventStreamEventId) {
+	s := cgo.Handle(info).Value().(*fseventsSource)
+	count := int(n)
+	paths := make([]string, count)
+	for i, p := range unsafe.Slice(cPaths, count) {
+		paths[i] = C.GoString(p)
+	}
+	s.handle(paths, unsafe.Slice(cFlags, count), unsafe.Slice(cIDs, count))
+}
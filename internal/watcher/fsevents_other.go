@@ -0,0 +1,28 @@
+//go:build !darwin || !cgo
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"runtime"
+
+	"github.com/rs/zerolog"
+)
+
+// CheckFSEvents reports whether --fsevents-state is available in this build.
+func CheckFSEvents() error {
+	if runtime.GOOS == "darwin" {
+		return errors.New("--fsevents-state requires gowatchrun to be built with cgo (CGO_ENABLED=1)")
+	}
+	return errors.New("--fsevents-state is only available on macOS")
+}
+
+type fseventsSource struct {
+	cfg    Config
+	logger zerolog.Logger
+}
+
+func (s *fseventsSource) Start(ctx context.Context) (<-chan Event, error) {
+	return nil, CheckFSEvents()
+}
@@ -0,0 +1,176 @@
+package watcher
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// healthTick is how often a running watcher reports that its event loop
+	// is alive.
+	healthTick = 5 * time.Second
+	// healthTimeout is how long a watcher may go without reporting before
+	// it's considered wedged, unless it's busy executing.
+	healthTimeout = 30 * time.Second
+	// DefaultBusyTimeout is how long a watcher may be busy executing before
+	// it's considered wedged, since a command may hang.
+	DefaultBusyTimeout = time.Hour
+)
+
+// Health tracks the liveness of one or more watchers for the health endpoint
+// and heartbeat file. A nil *Health tracks nothing.
+type Health struct {
+	mu          sync.Mutex
+	started     time.Time
+	busyTimeout time.Duration // 0 lets watchers be busy for any time
+	jobs        []*JobHealth
+}
+
+// JobHealth is the state of one watcher.
+type JobHealth struct {
+	Name          string     `json:"name,omitempty"`
+	Alive         bool       `json:"alive"`
+	Running       bool       `json:"running"`                 // False once the watcher stopped
+	Busy          bool       `json:"busy"`                    // Executing the command
+	Watches       int        `json:"watches"`                 // Watched directories
+	WatchFailures int        `json:"watch_failures"`          // Directories that couldn't be watched
+	MissingRoots  []string   `json:"missing_roots,omitempty"` // Watch directories that were removed and aren't back yet
+	LastEvent     *time.Time `json:"last_event,omitempty"`
+	LastRun       *time.Time `json:"last_run,omitempty"`
+	LastResult    string     `json:"last_result,omitempty"` // "ok" or the error of the last run
+
+	lastTick  time.Time
+	busySince time.Time
+}
+
+// HealthStatus is a snapshot of Health.
+type HealthStatus struct {
+	Healthy bool        `json:"healthy"`
+	Uptime  string      `json:"uptime"`
+	Jobs    []JobHealth `json:"jobs"`
+}
+
+// NewHealth returns a tracker without any watchers, which considers a
+// watcher busy executing for longer than busyTimeout wedged (never for 0).
+func NewHealth(busyTimeout time.Duration) *Health {
+	return &Health{started: time.Now(), busyTimeout: busyTimeout}
+}
+
+// update calls fn with the state of job, under the lock.
+func (h *Health) update(job string, fn func(*JobHealth)) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, j := range h.jobs {
+		if j.Name == job {
+			fn(j)
+			return
+		}
+	}
+	j := &JobHealth{Name: job}
+	h.jobs = append(h.jobs, j)
+	fn(j)
+}
+
+func (h *Health) start(job string) {
+	h.update(job, func(j *JobHealth) {
+		j.Running = true
+		j.lastTick = time.Now()
+	})
+}
+
+func (h *Health) stop(job string) {
+	h.update(job, func(j *JobHealth) { j.Running = false })
+}
+
+func (h *Health) tick(job string) {
+	h.update(job, func(j *JobHealth) { j.lastTick = time.Now() })
+}
+
+func (h *Health) event(job string) {
+	h.update(job, func(j *JobHealth) {
+		now := time.Now()
+		j.LastEvent = &now
+		j.lastTick = now
+	})
+}
+
+func (h *Health) setWatches(job string, n int) {
+	h.update(job, func(j *JobHealth) { j.Watches = n })
+}
+
+func (h *Health) setWatchFailures(job string, n int) {
+	h.update(job, func(j *JobHealth) { j.WatchFailures = n })
+}
+
+func (h *Health) setMissingRoots(job string, roots []string) {
+	h.update(job, func(j *JobHealth) { j.MissingRoots = roots })
+}
+
+// Track wraps execFunc to record when each job last ran and how that went.
+// Skipped runs aren't recorded.
+func (h *Health) Track(execFunc ExecutorFunc) ExecutorFunc {
+	return func(ctx context.Context, cfg Config, data *EventData) ExecutionResult {
+		var lastRun *time.Time
+		h.update(cfg.Name, func(j *JobHealth) {
+			now := time.Now()
+			lastRun, j.LastRun = j.LastRun, &now
+			j.Busy, j.busySince = true, now
+		})
+		result := execFunc(ctx, cfg, data)
+		h.update(cfg.Name, func(j *JobHealth) {
+			j.Busy = false
+			j.lastTick = time.Now()
+			if result.Skipped {
+				j.LastRun = lastRun
+				return
+			}
+			j.LastResult = "ok"
+			if result.Err != nil {
+				j.LastResult = result.Err.Error()
+			}
+		})
+		return result
+	}
+}
+
+// Stalled returns the running watchers whose event loop hasn't made progress
+// for longer than timeout, including those still busy executing, with how
+// long they've been stuck.
+func (h *Health) Stalled(timeout time.Duration) map[string]time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stalled := make(map[string]time.Duration)
+	for _, j := range h.jobs {
+		if since := time.Since(j.lastTick); j.Running && since > timeout {
+			stalled[j.Name] = since
+		}
+	}
+	return stalled
+}
+
+// Status reports every watcher. It's healthy when each running watcher's
+// event loop reported recently or is busy executing the command, for no
+// longer than the busy timeout.
+func (h *Health) Status() HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	status := HealthStatus{
+		Healthy: true,
+		Uptime:  time.Since(h.started).Round(time.Second).String(),
+		Jobs:    make([]JobHealth, 0, len(h.jobs)),
+	}
+	for _, j := range h.jobs {
+		job := *j
+		busy := j.Busy && (h.busyTimeout <= 0 || time.Since(j.busySince) < h.busyTimeout)
+		job.Alive = !j.Running || busy || time.Since(j.lastTick) < healthTimeout
+		if !job.Alive {
+			status.Healthy = false
+		}
+		status.Jobs = append(status.Jobs, job)
+	}
+	return status
+}
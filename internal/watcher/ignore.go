@@ -0,0 +1,172 @@
+package watcher
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// IgnoreFileName is the file gowatchrun reads exclusions from in every
+// watched directory, using gitignore syntax.
+const IgnoreFileName = ".gowatchrunignore"
+
+// ignoreRule is one line of an ignore file.
+type ignoreRule struct {
+	source   string // File and line, for explanations
+	pattern  string
+	negate   bool // "!pattern" re-includes what an earlier rule ignored
+	dirOnly  bool // "pattern/" only matches directories
+	anchored bool // Patterns containing a slash match relative to the file's directory
+}
+
+// ignoreFiles loads and caches the ignore files of a job's directories.
+// Like gitignore, a file applies to its directory and everything below it,
+// rules in deeper files and later lines take precedence, and nothing below
+// an ignored directory can be re-included.
+type ignoreFiles struct {
+	mu    sync.Mutex
+	rules map[string][]ignoreRule // By absolute directory; nil when it has no ignore file
+}
+
+func newIgnoreFiles() *ignoreFiles {
+	return &ignoreFiles{rules: make(map[string][]ignoreRule)}
+}
+
+// forget drops the cached rules of dir, so they are read again after its
+// ignore file changed.
+func (f *ignoreFiles) forget(dir string) {
+	f.mu.Lock()
+	delete(f.rules, dir)
+	f.mu.Unlock()
+}
+
+// load returns the rules of the ignore file in dir, reading it on first use.
+func (f *ignoreFiles) load(dir string) []ignoreRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if rules, ok := f.rules[dir]; ok {
+		return rules
+	}
+	rules, _ := parseIgnoreFile(filepath.Join(dir, IgnoreFileName))
+	f.rules[dir] = rules
+	return rules
+}
+
+// parseIgnoreFile reads the rules from the ignore file at path.
+func parseIgnoreFile(path string) ([]ignoreRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{source: fmt.Sprintf("%s:%d", path, n)}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" || !doublestar.ValidatePattern(line) {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// match reports whether rule matches rel, a path in slash form relative to
+// the directory of the rule's ignore file.
+func (rule ignoreRule) match(rel string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+	if !rule.anchored {
+		rel = rel[strings.LastIndex(rel, "/")+1:]
+	}
+	ok, _ := doublestar.Match(rule.pattern, rel)
+	return ok
+}
+
+// ignored reports whether absPath, found below the watch root absRoot, is
+// ignored by the ignore files from absRoot down to its directory, and by
+// which rule.
+func (f *ignoreFiles) ignored(absRoot, absPath string, isDir bool) (string, bool) {
+	if f == nil || absRoot == "" || samePath(absRoot, absPath) || !withinDir(absPath, absRoot) {
+		return "", false
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	// An ignored parent directory hides everything below it
+	for i := range parts {
+		last := i == len(parts)-1
+		if rule, ok := f.decide(absRoot, parts[:i+1], isDir || !last); ok {
+			return rule, true
+		}
+	}
+	return "", false
+}
+
+// decide applies the rules of every ignore file between absRoot and the
+// path given by parts (relative to absRoot) to it. The last matching rule
+// wins.
+func (f *ignoreFiles) decide(absRoot string, parts []string, isDir bool) (string, bool) {
+	var decided *ignoreRule
+	dir := absRoot
+	for i := 0; i < len(parts); i++ {
+		rel := strings.Join(parts[i:], "/")
+		rules := f.load(dir)
+		for j := range rules {
+			if rules[j].match(rel, isDir) {
+				decided = &rules[j]
+			}
+		}
+		dir = filepath.Join(dir, parts[i])
+	}
+	if decided == nil || decided.negate {
+		return "", false
+	}
+	return decided.source, true
+}
+
+// ignoredFile reports whether the ignore files of its watch directory
+// ignore the file at path, and by which rule.
+func (cfg Config) ignoredFile(path string) (string, bool) {
+	if cfg.ignoreFiles == nil {
+		return "", false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	root, _ := watchRootOf(cfg, absPath)
+	if root == "" {
+		return "", false
+	}
+	absRoot, _ := filepath.Abs(root)
+	return cfg.ignoreFiles.ignored(absRoot, absPath, false)
+}
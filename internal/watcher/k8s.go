@@ -0,0 +1,102 @@
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// k8sDataLink is the symlink Kubernetes swaps atomically to update a
+// mounted ConfigMap or Secret. The visible files are symlinks through it
+// into a timestamped directory like ..2024_05_01_12_00_00.123456789.
+const k8sDataLink = "..data"
+
+// configMapEvents translates the events of Kubernetes ConfigMap and Secret
+// volume updates (--k8s-configmap): the timestamped directory and symlink
+// churn is dropped, and once ..data is swapped, one WRITE event is sent for
+// every file whose content changed. Kubernetes adds and removes the
+// visible symlinks of new and deleted keys itself, so those events pass
+// through as they are.
+func configMapEvents(ctx context.Context, cfg Config, events <-chan Event, logger zerolog.Logger) <-chan Event {
+	// Content hashes of the visible files, by directory
+	snapshots := make(map[string]map[string][sha256.Size]byte)
+	for _, dir := range cfg.WatchDirs {
+		if _, err := os.Lstat(filepath.Join(dir, k8sDataLink)); err == nil {
+			snapshots[filepath.Clean(dir)] = configMapSnapshot(dir)
+		}
+	}
+
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		send := func(event Event) bool {
+			select {
+			case out <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for event := range events {
+			name := filepath.Base(event.Path)
+			if event.Data != nil || event.Remote != "" || !strings.HasPrefix(name, "..") {
+				if snapshot, ok := snapshots[filepath.Clean(filepath.Dir(event.Path))]; ok && event.Data == nil {
+					if content, err := os.ReadFile(event.Path); err == nil {
+						snapshot[name] = sha256.Sum256(content)
+					} else {
+						delete(snapshot, name)
+					}
+				}
+				if !send(event) {
+					return
+				}
+				continue
+			}
+			if name != k8sDataLink || !event.Op.Has(fsnotify.Create) {
+				logger.Trace().Msgf("Ignoring Kubernetes volume update event %s %s", event.Op, event.Path)
+				continue
+			}
+
+			dir := filepath.Clean(filepath.Dir(event.Path))
+			before, after := snapshots[dir], configMapSnapshot(dir)
+			snapshots[dir] = after
+			logger.Debug().Msgf("Kubernetes volume %s updated", dir)
+			for _, key := range slices.Sorted(maps.Keys(after)) {
+				if old, ok := before[key]; (ok || before == nil) && old != after[key] {
+					if !send(Event{Path: filepath.Join(dir, key), Op: fsnotify.Write}) {
+						return
+					}
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// configMapSnapshot returns the content hashes of the files in a mounted
+// ConfigMap or Secret directory, leaving out Kubernetes' own entries.
+func configMapSnapshot(dir string) map[string][sha256.Size]byte {
+	snapshot := make(map[string][sha256.Size]byte)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return snapshot
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue // A directory, or a key that's being swapped out
+		}
+		snapshot[entry.Name()] = sha256.Sum256(content)
+	}
+	return snapshot
+}
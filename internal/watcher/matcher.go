@@ -0,0 +1,225 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// patternMatcher matches file names against a list of patterns. Patterns are
+// checked once when it's compiled, and the common shapes skip
+// filepath.Match: "*" and "*.*", extension-only patterns like "*.go" (a map
+// lookup) and literal names. It reports the first matching pattern in the
+// original order, like matching them one by one would.
+//
+// Patterns starting with "!" exclude the names they match, and the last
+// pattern matching a name decides (-p '*.go' -p '!*_test.go'). A list of
+// only negated patterns starts out matching everything.
+type patternMatcher struct {
+	patterns []string
+	negated  []int          // Indexes of "!" patterns
+	all      int            // Index of "*", or -1
+	anyExt   int            // Index of "*.*", or -1
+	exts     map[string]int // "*.go" is stored as ".go"
+	names    map[string]int // Patterns without any metacharacters
+	globs    []int          // Everything else, matched with filepath.Match
+
+	implicitAll bool // Only negated patterns were given
+}
+
+// compilePatterns returns a matcher for patterns. Invalid patterns never
+// match; Config.Validate reports them.
+func compilePatterns(patterns []string) *patternMatcher {
+	m := &patternMatcher{
+		patterns: patterns,
+		all:      -1,
+		anyExt:   -1,
+		exts:     make(map[string]int),
+		names:    make(map[string]int),
+	}
+	setFirst := func(index map[string]int, key string, i int) {
+		if _, ok := index[key]; !ok {
+			index[key] = i
+		}
+	}
+	for i, pattern := range patterns {
+		if CheckPattern(pattern) != nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(pattern, "!"):
+			m.negated = append(m.negated, i)
+		case pattern == "*":
+			if m.all < 0 {
+				m.all = i
+			}
+		case pattern == "*.*":
+			if m.anyExt < 0 {
+				m.anyExt = i
+			}
+		case strings.HasPrefix(pattern, "*.") && !hasMeta(pattern[1:]):
+			setFirst(m.exts, pattern[1:], i)
+		case !hasMeta(pattern):
+			setFirst(m.names, pattern, i)
+		default:
+			m.globs = append(m.globs, i)
+		}
+	}
+	if len(m.negated) == len(patterns) {
+		m.implicitAll = true
+	}
+	return m
+}
+
+// CheckPattern reports whether pattern, optionally negated with a leading
+// "!", is a valid file name pattern.
+func CheckPattern(pattern string) error {
+	if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+		if negated == "" {
+			return fmt.Errorf("'!' must be followed by a pattern")
+		}
+		pattern = negated
+	}
+	_, err := filepath.Match(pattern, "")
+	return err
+}
+
+// hasMeta reports whether pattern contains any filepath.Match
+// metacharacters.
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// match returns the pattern that includes name: the first one when no
+// negated pattern matches name, otherwise the first one after the last
+// matching negated pattern.
+func (m *patternMatcher) match(name string) (string, bool) {
+	pattern, ok := m.first(name)
+	if len(m.negated) == 0 {
+		return pattern, ok
+	}
+	lastNegated := -1
+	for _, i := range m.negated {
+		if matched, _ := filepath.Match(m.patterns[i][1:], name); matched {
+			lastNegated = i
+		}
+	}
+	if lastNegated < 0 {
+		if !ok && m.implicitAll {
+			return "*", true
+		}
+		return pattern, ok
+	}
+	// Rare: a negated pattern matched, so look for one that matches again
+	for i := lastNegated + 1; i < len(m.patterns); i++ {
+		if strings.HasPrefix(m.patterns[i], "!") {
+			continue
+		}
+		if matched, _ := filepath.Match(m.patterns[i], name); matched {
+			return m.patterns[i], true
+		}
+	}
+	return "", false
+}
+
+// first returns the first pattern that isn't negated and matches name.
+func (m *patternMatcher) first(name string) (string, bool) {
+	best := -1
+	consider := func(i int) {
+		if i >= 0 && (best < 0 || i < best) {
+			best = i
+		}
+	}
+	consider(m.all)
+	if strings.Contains(name, ".") {
+		consider(m.anyExt)
+	}
+	// "*.go" also matches "a.b.go", so every suffix starting at a dot counts
+	for i := 0; i < len(name); i++ {
+		if name[i] == '.' {
+			if idx, ok := m.exts[name[i:]]; ok {
+				consider(idx)
+			}
+		}
+	}
+	if idx, ok := m.names[name]; ok {
+		consider(idx)
+	}
+	for _, i := range m.globs {
+		if best >= 0 && i > best {
+			break
+		}
+		if ok, _ := filepath.Match(m.patterns[i], name); ok {
+			consider(i)
+			break
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return m.patterns[best], true
+}
+
+// rootMatcher holds the compiled patterns for the events below one watch
+// directory.
+type rootMatcher struct {
+	absDir   string
+	patterns []string
+	matcher  *patternMatcher
+}
+
+// compileRootPatterns compiles the per-directory patterns, ordered so the
+// deepest directory containing a path comes first.
+func compileRootPatterns(rootPatterns map[string][]string) []rootMatcher {
+	var roots []rootMatcher
+	for dir, patterns := range rootPatterns {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		roots = append(roots, rootMatcher{absDir: absDir, patterns: patterns, matcher: compilePatterns(patterns)})
+	}
+	sort.Slice(roots, func(i, j int) bool { return len(roots[i].absDir) > len(roots[j].absDir) })
+	return roots
+}
+
+// rootFor returns the per-directory patterns that apply to path, if any.
+func (cfg Config) rootFor(path string) (rootMatcher, bool) {
+	roots := cfg.rootMatchers
+	if roots == nil && len(cfg.RootPatterns) > 0 {
+		roots = compileRootPatterns(cfg.RootPatterns)
+	}
+	if len(roots) == 0 {
+		return rootMatcher{}, false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return rootMatcher{}, false
+	}
+	for _, root := range roots {
+		if withinDir(absPath, root.absDir) {
+			return root, true
+		}
+	}
+	return rootMatcher{}, false
+}
+
+// patternsFor returns the patterns that apply to path.
+func (cfg Config) patternsFor(path string) []string {
+	if root, ok := cfg.rootFor(path); ok {
+		return root.patterns
+	}
+	return cfg.Patterns
+}
+
+// matcherFor returns the compiled patterns that apply to path.
+func (cfg Config) matcherFor(path string) *patternMatcher {
+	if root, ok := cfg.rootFor(path); ok {
+		return root.matcher
+	}
+	if cfg.matcher != nil {
+		return cfg.matcher
+	}
+	return compilePatterns(cfg.Patterns)
+}
@@ -0,0 +1,45 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// noisePatterns match the temporary, swap and lock files editors and
+// operating systems leave next to the files you actually work on.
+var noisePatterns = []string{
+	"*.swp", "*.swo", "*.swx", // Vim swap files
+	"4913",                   // Vim's write test file
+	"*~",                     // Backup files
+	".#*",                    // Emacs lock files
+	"#*#",                    // Emacs auto-save files
+	"*.tmp",                  // Generic temporary files
+	".DS_Store",              // macOS Finder metadata
+	"Thumbs.db",              // Windows Explorer thumbnails
+	"*.crdownload", "*.part", // Incomplete browser downloads
+}
+
+// noiseReason reports why event is considered noise, or "" when it isn't.
+// Chmod-only events are kept when chmod was asked for explicitly.
+func noiseReason(event fsnotify.Event, eventTypes []string) string {
+	if event.Op == fsnotify.Chmod && !slices.ContainsFunc(eventTypes, func(t string) bool { return strings.EqualFold(t, "chmod") }) {
+		return "chmod-only events are ignored"
+	}
+	name := filepath.Base(event.Name)
+	for _, pattern := range noisePatterns {
+		if match, _ := filepath.Match(pattern, name); match {
+			return fmt.Sprintf("'%s' matches the editor/OS junk pattern '%s'", name, pattern)
+		}
+	}
+	return ""
+}
+
+// isHidden reports whether the base name of path starts with a dot.
+func isHidden(path string) bool {
+	name := filepath.Base(path)
+	return len(name) > 1 && name[0] == '.' && name != ".."
+}
@@ -0,0 +1,62 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// samePath reports whether the cleaned paths a and b are equal. Windows
+// paths are compared case-insensitively.
+func samePath(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// withinDir reports whether the cleaned absolute path is dir itself or lies
+// below it.
+func withinDir(path, dir string) bool {
+	if samePath(path, dir) {
+		return true
+	}
+	prefix := strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator)
+	return len(path) > len(prefix) && samePath(path[:len(prefix)], prefix)
+}
+
+// ParseWatchDir splits a watch directory given as "dir:pattern" (e.g.
+// "./src:*.go") into the directory and the pattern that applies below it.
+// A spec without a pattern, a Windows drive letter ("C:\src") and an
+// existing directory whose name contains a colon are returned as is.
+func ParseWatchDir(spec string) (dir, pattern string) {
+	i := strings.LastIndex(spec, ":")
+	if i <= 0 || (i == 1 && runtime.GOOS == "windows") {
+		return spec, ""
+	}
+	if info, err := os.Stat(spec); err == nil && info.IsDir() {
+		return spec, ""
+	}
+	return spec[:i], spec[i+1:]
+}
+
+// WriteFileAtomic replaces the file at path with data, through a temporary
+// file in the same directory so a crash doesn't leave it truncated.
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
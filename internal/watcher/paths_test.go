@@ -0,0 +1,87 @@
+package watcher
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWithinDir(t *testing.T) {
+	tests := []struct {
+		path, dir   string
+		want        bool
+		wantWindows bool // Where it differs from want
+	}{
+		{path: "/src", dir: "/src", want: true, wantWindows: true},
+		{path: "/src/app", dir: "/src", want: true, wantWindows: true},
+		{path: "/src/app/main.go", dir: "/src/", want: true, wantWindows: true},
+		{path: "/srcs", dir: "/src", want: false, wantWindows: false},
+		{path: "/", dir: "/src", want: false, wantWindows: false},
+		{path: "/SRC/app", dir: "/src", want: false, wantWindows: true},
+		{path: `C:\src\app`, dir: `C:\src`, want: false, wantWindows: true},
+		{path: `c:\SRC\App`, dir: `C:\src`, want: false, wantWindows: true},
+		{path: `C:\srcs`, dir: `C:\src`, want: false, wantWindows: false},
+		{path: `C:\src\app`, dir: `C:\src\app`, want: true, wantWindows: true},
+	}
+	for _, tt := range tests {
+		path, dir := filepath.FromSlash(tt.path), filepath.FromSlash(tt.dir)
+		want := tt.want
+		if runtime.GOOS == "windows" {
+			want = tt.wantWindows
+		}
+		if got := withinDir(path, dir); got != want {
+			t.Errorf("withinDir(%q, %q) = %v, want %v", path, dir, got, want)
+		}
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	tests := []struct {
+		a, b        string
+		want        bool
+		wantWindows bool
+	}{
+		{a: "/src", b: "/src", want: true, wantWindows: true},
+		{a: "/src", b: "/SRC", want: false, wantWindows: true},
+		{a: `C:\src`, b: `c:\Src`, want: false, wantWindows: true},
+		{a: `C:\src`, b: `C:\src\app`, want: false, wantWindows: false},
+	}
+	for _, tt := range tests {
+		want := tt.want
+		if runtime.GOOS == "windows" {
+			want = tt.wantWindows
+		}
+		if got := samePath(tt.a, tt.b); got != want {
+			t.Errorf("samePath(%q, %q) = %v, want %v", tt.a, tt.b, got, want)
+		}
+	}
+}
+
+func TestParseWatchDir(t *testing.T) {
+	windows := runtime.GOOS == "windows"
+	tests := []struct {
+		spec        string
+		dir         string
+		pattern     string
+		onlyWindows bool
+		onlyOthers  bool
+	}{
+		{spec: "./src", dir: "./src"},
+		{spec: "./src:*.go", dir: "./src", pattern: "*.go"},
+		{spec: ":*.go", dir: ":*.go"},
+		{spec: `C:\src`, dir: `C:\src`, onlyWindows: true},
+		{spec: `C:/src`, dir: `C:/src`, onlyWindows: true},
+		{spec: `C:\src`, dir: "C", pattern: `\src`, onlyOthers: true},
+		{spec: `C:\src:*.go`, dir: `C:\src`, pattern: "*.go"},
+		{spec: `C:/src:**/*.ts`, dir: `C:/src`, pattern: "**/*.ts"},
+	}
+	for _, tt := range tests {
+		if (tt.onlyWindows && !windows) || (tt.onlyOthers && windows) {
+			continue
+		}
+		dir, pattern := ParseWatchDir(tt.spec)
+		if dir != tt.dir || pattern != tt.pattern {
+			t.Errorf("ParseWatchDir(%q) = %q, %q, want %q, %q", tt.spec, dir, pattern, tt.dir, tt.pattern)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// maxSuppressed is how many events a paused job keeps for when it's resumed
+// with --pause-state; further ones are dropped.
+const maxSuppressed = 1000
+
+// pauseState is what --pause-state keeps across restarts: the paused jobs
+// and the events they suppressed, latest last.
+type pauseState struct {
+	Paused map[string][]suppressedEvent `json:"paused"`
+}
+
+// suppressedEvent is a file system event a paused job ignored.
+type suppressedEvent struct {
+	Path  string `json:"path"`
+	Event string `json:"event"` // As in {{.Event}}, e.g. WRITE
+}
+
+// LoadPauseState makes c keep the paused jobs, and the events they suppress
+// until they're resumed, in the file at path, and pauses the jobs it lists.
+// Jobs it lists that aren't among jobs, the configured ones, are dropped,
+// since they could never be resumed. A missing file is created once a job
+// is paused.
+func (c *Control) LoadPauseState(path string, jobs []string) error {
+	var state pauseState
+	raw, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(raw, &state)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the pause state %s: %w", path, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statePath = path
+	c.suppressed = make(map[string][]suppressedEvent)
+	dropped := false
+	for name, events := range state.Paused {
+		if !slices.Contains(jobs, name) {
+			log.Warn().Msgf("Dropping job '%s' from the pause state %s, since it's no longer configured", name, path)
+			dropped = true
+			continue
+		}
+		c.paused[name] = true
+		c.suppressed[name] = events
+		logger := Config{Name: name}.Logger()
+		logger.Info().Msgf("Paused before the restart, ignoring events until resumed (%d suppressed event(s) kept)", len(events))
+	}
+	if dropped {
+		c.saveState()
+	}
+	return nil
+}
+
+// StartPaused pauses jobs before their watchers start.
+func (c *Control) StartPaused(jobs []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range jobs {
+		if !c.paused[name] {
+			c.paused[name] = true
+			logger := Config{Name: name}.Logger()
+			logger.Info().Msg("Starting paused, ignoring events until resumed")
+		}
+	}
+	c.saveState()
+}
+
+// suppress records a file system event job ignored while paused, to run it
+// once the job is resumed. Nothing is recorded without LoadPauseState.
+func (c *Control) suppress(job string, data *EventData) {
+	if c == nil || data.Path == "" {
+		return
+	}
+	if _, ok := opNamed(data.Event); !ok {
+		return
+	}
+	path, err := filepath.Abs(data.Path)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.suppressed == nil {
+		return
+	}
+	events := c.suppressed[job]
+	events = slices.DeleteFunc(events, func(e suppressedEvent) bool { return e.Path == path })
+	if len(events) >= maxSuppressed {
+		return
+	}
+	c.suppressed[job] = append(events, suppressedEvent{Path: path, Event: data.Event})
+	c.saveState()
+}
+
+// takeSuppressed returns the events job suppressed as events to replay, and
+// forgets them. The lock is held.
+func (c *Control) takeSuppressed(job string) []Event {
+	var events []Event
+	for _, e := range c.suppressed[job] {
+		if op, ok := opNamed(e.Event); ok {
+			events = append(events, Event{Path: e.Path, Op: op})
+		}
+	}
+	delete(c.suppressed, job)
+	return events
+}
+
+// saveState writes the pause state, if it's kept. The lock is held.
+func (c *Control) saveState() {
+	if c.statePath == "" {
+		return
+	}
+	state := pauseState{Paused: make(map[string][]suppressedEvent)}
+	for name := range c.paused {
+		state.Paused[name] = c.suppressed[name]
+	}
+	raw, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		err = WriteFileAtomic(c.statePath, raw)
+	}
+	if err != nil {
+		log.Warn().Msgf("Failed to write the pause state %s: %v", c.statePath, err)
+	}
+}
+
+// opNamed returns the fsnotify op {{.Event}} reports as name.
+func opNamed(name string) (fsnotify.Op, bool) {
+	for _, et := range eventTypes {
+		if et.name == name {
+			return et.op, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,113 @@
+package watcher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// PauseWindow is a recurring period in which a job's events are queued or
+// dropped instead of running the command, e.g. during backups.
+type PauseWindow struct {
+	spec string
+
+	// A daily window between two times of day, in minutes after midnight.
+	// It ends the next day when end is before start.
+	start, end int
+
+	// Or a window of length starting at every activation of schedule.
+	schedule cron.Schedule
+	length   time.Duration
+}
+
+// ParsePauseWindow parses a window given as "HH:MM-HH:MM" in local time
+// (e.g. "23:30-01:00"), or as a cron expression followed by how long the
+// window lasts (e.g. "0 2 * * 0 2h" or "@daily 30m").
+func ParsePauseWindow(spec string) (PauseWindow, error) {
+	w := PauseWindow{spec: spec}
+	if from, to, ok := strings.Cut(strings.TrimSpace(spec), "-"); ok && !strings.Contains(spec, " ") {
+		start, err1 := parseTimeOfDay(from)
+		end, err2 := parseTimeOfDay(to)
+		if err1 != nil || err2 != nil || start == end {
+			return w, fmt.Errorf("invalid pause window %q: expected HH:MM-HH:MM", spec)
+		}
+		w.start, w.end = start, end
+		return w, nil
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) < 2 {
+		return w, fmt.Errorf("invalid pause window %q: expected HH:MM-HH:MM or a cron expression and a duration", spec)
+	}
+	length, err := time.ParseDuration(fields[len(fields)-1])
+	if err != nil || length <= 0 {
+		return w, fmt.Errorf("invalid pause window %q: expected a duration after the cron expression", spec)
+	}
+	schedule, err := ParseCron(strings.Join(fields[:len(fields)-1], " "))
+	if err != nil {
+		return w, fmt.Errorf("invalid pause window %q: %w", spec, err)
+	}
+	w.schedule, w.length = schedule, length
+	return w, nil
+}
+
+func (w PauseWindow) String() string {
+	return w.spec
+}
+
+// until returns when the window t falls in ends, or false when t isn't in
+// the window.
+func (w PauseWindow) until(t time.Time) (time.Time, bool) {
+	if w.schedule == nil {
+		midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		at := func(days, minutes int) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day()+days, 0, minutes, 0, 0, t.Location())
+		}
+		now := int(t.Sub(midnight) / time.Minute)
+		switch {
+		case w.start < w.end && now >= w.start && now < w.end:
+			return at(0, w.end), true
+		case w.start > w.end && now >= w.start:
+			return at(1, w.end), true
+		case w.start > w.end && now < w.end:
+			return at(0, w.end), true
+		}
+		return time.Time{}, false
+	}
+
+	// The window is active when it started less than length ago; later
+	// activations within it extend it, up to a day ahead for schedules
+	// whose windows always overlap.
+	next := w.schedule.Next(t.Add(-w.length))
+	if next.After(t) {
+		return time.Time{}, false
+	}
+	end := next.Add(w.length)
+	for next = w.schedule.Next(next); next.Before(end) && end.Sub(t) < 24*time.Hour; next = w.schedule.Next(next) {
+		end = next.Add(w.length)
+	}
+	return end, true
+}
+
+// pausedUntil returns when the last of the windows t falls in ends, or false
+// when t isn't in any of them.
+func pausedUntil(windows []PauseWindow, t time.Time) (time.Time, bool) {
+	var until time.Time
+	for _, w := range windows {
+		if end, ok := w.until(t); ok && end.After(until) {
+			until = end
+		}
+	}
+	return until, !until.IsZero()
+}
+
+// parseTimeOfDay parses "HH:MM" into minutes after midnight.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,83 @@
+package watcher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Preflight walks the watch directories the way the watcher will and
+// returns a problem for every directory that can't be listed or traversed,
+// since events below it would be missed without further notice. Hidden and
+// excluded directories are skipped like when watching, and like the
+// watcher, it stops at the --lazy-watch depth and after --max-watches
+// directories, so it doesn't walk huge trees that won't be watched.
+func (cfg Config) Preflight() []error {
+	var problems []error
+	exclude := cfg.excluder()
+	checked := 0
+	for _, root := range cfg.WatchDirs {
+		absRoot, _ := filepath.Abs(root)
+		if !cfg.Recursive {
+			if err := checkDirAccess(root); err != nil {
+				problems = append(problems, err)
+			}
+			continue
+		}
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				// Reported by checkDirAccess for the directory itself
+				if path != root || d != nil {
+					return filepath.SkipDir
+				}
+				problems = append(problems, fmt.Errorf("'%s' can't be accessed: %w", path, err))
+				return nil
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if !cfg.IncludeHidden && path != root && isHidden(path) {
+				return filepath.SkipDir
+			}
+			if absPath, err := filepath.Abs(path); err == nil {
+				if _, ok := exclude.excluded(absRoot, absPath); ok {
+					return filepath.SkipDir
+				}
+			}
+			if cfg.MaxWatches > 0 && checked >= cfg.MaxWatches {
+				return filepath.SkipAll
+			}
+			checked++
+			if err := checkDirAccess(path); err != nil {
+				problems = append(problems, err)
+				return filepath.SkipDir
+			}
+			if cfg.LazyWatch > 0 && dirDepth(root, path) >= cfg.LazyWatch {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+	return problems
+}
+
+// checkDirAccess reports an error when dir can't be listed, or its entries
+// can't be looked up (a directory without search permission).
+func checkDirAccess(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("'%s' can't be read: %w", dir, err)
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(1)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("'%s' can't be listed: %w", dir, err)
+	}
+	if len(names) > 0 {
+		if _, err := os.Lstat(filepath.Join(dir, names[0])); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("'%s' can't be traversed: %w", dir, err)
+		}
+	}
+	return nil
+}
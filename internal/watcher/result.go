@@ -0,0 +1,48 @@
+package watcher
+
+import (
+	"errors"
+	"os/exec"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/problem"
+)
+
+// ExecutionResult is the outcome of a run, as the executor reports it to
+// everything that wraps it: the trackers, the journal, the API and
+// middleware.
+type ExecutionResult struct {
+	ExitCode int           // 0 on success, the command's exit status, or 1 for other failures
+	Duration time.Duration // How long the run took
+	Output   []byte        // The end of what the command wrote to stdout and stderr
+	Err      error         // Why the run failed, nil on success
+	Skipped  bool          // Nothing ran: the files were claimed by another instance, or a handler or filter plugin rejected the event
+
+	// Diagnostics the job's problem matchers found in the output
+	Diagnostics []problem.Diagnostic
+}
+
+// OK reports whether the run succeeded. A skipped run didn't fail, but
+// recorders of runs ignore it.
+func (r ExecutionResult) OK() bool {
+	return r.Err == nil
+}
+
+// Result returns the result of a run that took duration and failed with
+// err, or succeeded for nil, for executors without output to report.
+func Result(err error, duration time.Duration) ExecutionResult {
+	return ExecutionResult{ExitCode: ExitCode(err), Duration: duration, Err: err}
+}
+
+// ExitCode returns the exit status of a failed command run: 0 for nil, the
+// process exit status for commands that ran, and 1 for any other error.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
@@ -0,0 +1,100 @@
+package watcher
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// sidecars holds the files that wait for their completion marker
+// (--require-sidecar) before their event is dispatched.
+type sidecars struct {
+	tmpl    *template.Template
+	waiting map[string]heldEvent // By marker path
+	markers map[string]string    // Data file path -> marker path
+}
+
+type heldEvent struct {
+	data *EventData
+	tr   *eventTrace
+}
+
+// newSidecars parses cfg's SidecarTmpl. It returns nil when no marker is
+// required.
+func newSidecars(cfg Config) (*sidecars, error) {
+	if cfg.SidecarTmpl == "" {
+		return nil, nil
+	}
+	tmpl, err := ParseTemplate(cfg, "sidecar", cfg.SidecarTmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sidecar template: %w", err)
+	}
+	return &sidecars{tmpl: tmpl, waiting: make(map[string]heldEvent), markers: make(map[string]string)}, nil
+}
+
+// markerFor returns the path of the marker data's file waits for. Relative
+// names are resolved against the file's directory.
+func (s *sidecars) markerFor(data *EventData) (string, error) {
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	marker := strings.TrimSpace(buf.String())
+	if marker == "" {
+		return "", fmt.Errorf("sidecar template rendered an empty path for %s", data.Path)
+	}
+	if !filepath.IsAbs(marker) {
+		marker = filepath.Join(data.Dir, marker)
+	}
+	return filepath.Clean(marker), nil
+}
+
+// hold sets data.Sidecar and reports whether the marker of data's file
+// already exists. Otherwise the event is held until release is called for
+// the marker, replacing an earlier event for the same file.
+func (s *sidecars) hold(data *EventData, tr *eventTrace) (bool, error) {
+	marker, err := s.markerFor(data)
+	if err != nil {
+		return false, err
+	}
+	data.Sidecar = marker
+	if _, err := os.Stat(marker); err == nil {
+		s.drop(data.Path)
+		return true, nil
+	}
+	if held, ok := s.waiting[marker]; ok {
+		held.tr.ignore("superseded while waiting for " + marker)
+	}
+	s.waiting[marker] = heldEvent{data: data, tr: tr}
+	s.markers[filepath.Clean(data.Path)] = marker
+	return false, nil
+}
+
+// release returns the event waiting for the marker at path, if any.
+func (s *sidecars) release(path string) (heldEvent, bool) {
+	path = filepath.Clean(path)
+	held, ok := s.waiting[path]
+	if !ok {
+		return heldEvent{}, false
+	}
+	delete(s.waiting, path)
+	delete(s.markers, filepath.Clean(held.data.Path))
+	return held, true
+}
+
+// drop forgets the event held for the file at path, which was removed.
+func (s *sidecars) drop(path string) {
+	path = filepath.Clean(path)
+	marker, ok := s.markers[path]
+	if !ok {
+		return
+	}
+	if held, ok := s.waiting[marker]; ok {
+		held.tr.ignore("removed while waiting for " + marker)
+		delete(s.waiting, marker)
+	}
+	delete(s.markers, path)
+}
@@ -0,0 +1,77 @@
+package watcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// Event is emitted by an EventSource. Path and Op describe a file system
+// change that still has to pass the pattern and event-type filters. Sources
+// that produce synthetic events (webhooks, timers) set Data instead, which is
+// dispatched as-is.
+type Event struct {
+	Path   string
+	Op     fsnotify.Op
+	Remote string    // Object key or remote path for events from remote sources
+	IsDir  bool      // The path is (or, for removals, was) a directory
+	At     time.Time // When the source got the event; zero when it doesn't know
+
+	Data *EventData
+}
+
+// EventSource produces events for the debounce/filter/execute pipeline.
+// Start must not block; the returned channel is closed once the source stops,
+// which at the latest happens when ctx is cancelled.
+type EventSource interface {
+	Start(ctx context.Context) (<-chan Event, error)
+}
+
+// configuredSources returns the built-in sources enabled by cfg.
+func configuredSources(cfg Config, logger zerolog.Logger) []EventSource {
+	var sources []EventSource
+	if len(cfg.WatchDirs) > 0 {
+		sources = append(sources, &fsnotifySource{cfg: cfg, logger: logger})
+	}
+	if cfg.FSEventsState != "" && len(cfg.WatchDirs) > 0 {
+		sources = append(sources, &fseventsSource{cfg: cfg, logger: logger})
+	}
+	if cfg.Source.URL != "" {
+		sources = append(sources, &pollSource{cfg: cfg.Source, logger: logger})
+	}
+	if cfg.WebhookAddr != "" {
+		sources = append(sources, &webhookSource{spec: cfg.WebhookAddr, auth: cfg.Auth, logger: logger})
+	}
+	if cfg.Every > 0 || cfg.Cron != "" {
+		sources = append(sources, &timerSource{every: cfg.Every, cron: cfg.Cron, logger: logger})
+	}
+	return sources
+}
+
+// merge fans the given channels into one, which is closed after all of them
+// are. Events still pending once ctx is done are dropped.
+func merge(ctx context.Context, channels []<-chan Event) <-chan Event {
+	out := make(chan Event)
+	var wg sync.WaitGroup
+	for _, ch := range channels {
+		wg.Add(1)
+		go func(ch <-chan Event) {
+			defer wg.Done()
+			for event := range ch {
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
@@ -0,0 +1,629 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// lazyPollInterval is how often the dormant directories below the
+// --lazy-watch depth limit are checked for changes.
+const lazyPollInterval = 2 * time.Second
+
+// rootPollInterval is how often the watch directories are checked for having
+// been removed, replaced or become unreachable. Missing ones are checked for
+// being back at this interval at first, backing off to maxRootBackoff.
+const (
+	rootPollInterval = 2 * time.Second
+	maxRootBackoff   = time.Minute
+)
+
+// rootStatTimeout is how long checking a watch directory may take before
+// it's considered unreachable: a stat on a network mount whose server went
+// away can hang for minutes.
+const rootStatTimeout = 5 * time.Second
+
+// maxSkippedShown is how many skipped subtrees the watch budget warning
+// lists.
+const maxSkippedShown = 10
+
+// fsnotifySource watches the configured local directories.
+type fsnotifySource struct {
+	cfg    Config
+	logger zerolog.Logger
+}
+
+func (s *fsnotifySource) Start(ctx context.Context) (<-chan Event, error) {
+	logger := s.logger
+	cfg := s.cfg
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error().Msgf("Failed to create watcher: %v", err)
+		return nil, err
+	}
+
+	logger.Info().Msgf("Starting watcher for directories: %v", cfg.WatchDirs)
+	if cfg.Recursive {
+		logger.Info().Msg("Recursive mode enabled.")
+	}
+
+	exclude := cfg.excluder()
+	if len(cfg.ExcludeDirs) > 0 {
+		logger.Info().Msgf("Excluding directories: %v", cfg.ExcludeDirs)
+	}
+
+	roots := normalizeWatchDirs(cfg, exclude, logger)
+	absRoots := make([]string, len(roots))
+	for i, dir := range roots {
+		absRoots[i], _ = filepath.Abs(dir)
+	}
+	// rootOf returns the watch root absPath was found below.
+	rootOf := func(absPath string) string {
+		for _, root := range absRoots {
+			if withinDir(absPath, root) {
+				return root
+			}
+		}
+		return ""
+	}
+
+	// frontier holds the directories at the --lazy-watch depth limit, whose
+	// subdirectories are only watched once there's activity in them: an event
+	// in the directory itself, or a change to the modification time of one of
+	// its (dormant) subdirectories, which is polled.
+	frontier := make(map[string]bool)
+	dormant := make(map[string]time.Time) // Subdirectory -> modification time
+	dormantOf := make(map[string]string)  // Subdirectory -> frontier directory
+	started := time.Now()
+
+	// Once --max-watches is reached or the system runs out of watches,
+	// directories are skipped instead of added.
+	exhausted := false
+	watches := 0 // len(watcher.WatchList()), which is too slow to call per directory
+	var skipped []string
+	failures := newWatchFailures()
+	dirs := newKnownDirs()
+	// reportSkipped logs the subtrees skipped and the directories that
+	// couldn't be watched since the last call.
+	reportSkipped := func() {
+		failures.report(logger)
+		cfg.Health.setWatchFailures(cfg.Name, failures.total)
+		if len(skipped) == 0 {
+			return
+		}
+		shown := skipped
+		more := ""
+		if len(shown) > maxSkippedShown {
+			shown = shown[:maxSkippedShown]
+			more = fmt.Sprintf(" (and %d more)", len(skipped)-maxSkippedShown)
+		}
+		if cfg.MaxWatches > 0 {
+			logger.Warn().Msgf("Watch budget of %d reached, not watching %d subtree(s): %s%s", cfg.MaxWatches, len(skipped), strings.Join(shown, ", "), more)
+		} else {
+			logger.Warn().Msgf("Out of file watches, not watching %d subtree(s): %s%s. Raise the limit (fs.inotify.max_user_watches on Linux) or use --exclude, --lazy-watch or --max-watches", len(skipped), strings.Join(shown, ", "), more)
+		}
+		skipped = nil
+	}
+	// addWatch adds a watch for path unless the budget is used up, in which
+	// case it records path as skipped and returns false.
+	addWatch := func(path string) bool {
+		if exhausted || (cfg.MaxWatches > 0 && watches >= cfg.MaxWatches) {
+			skipped = append(skipped, path)
+			return false
+		}
+		if err := watcher.Add(path); err != nil {
+			if errors.Is(err, syscall.ENOSPC) {
+				exhausted = true
+				skipped = append(skipped, path)
+			} else {
+				failures.add(path, err)
+			}
+			return false
+		}
+		watches++
+		dirs.add(path)
+		return true
+	}
+
+	// addTree watches dir and the directories below it that aren't hidden or
+	// excluded, down to depth levels when depth is set. It returns the
+	// directories it watched.
+	addTree := func(absRoot, dir string, depth int) []string {
+		var added []string
+		walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				failures.add(path, err)
+				if info != nil && info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if info.IsDir() {
+				absPath, pathErr := filepath.Abs(path)
+				if pathErr != nil {
+					logger.Warn().Msgf("Could not get absolute path for %s: %v", path, pathErr)
+					return nil
+				}
+
+				if !cfg.IncludeHidden && path != dir && isHidden(path) {
+					logger.Debug().Msgf("Skipping hidden directory: %s", path)
+					return filepath.SkipDir
+				}
+
+				if rule, ok := exclude.excluded(absRoot, absPath); ok {
+					logger.Debug().Msgf("Skipping excluded directory: %s (%s)", path, rule)
+					return filepath.SkipDir
+				}
+
+				logger.Debug().Msgf("Adding recursive watch for: %s", path)
+				if !addWatch(path) {
+					return filepath.SkipDir
+				}
+				added = append(added, path)
+				if depth > 0 && dirDepth(dir, path) >= depth {
+					frontier[path] = true
+					entries, _ := os.ReadDir(path)
+					for _, entry := range entries {
+						sub := filepath.Join(path, entry.Name())
+						if info, err := entry.Info(); err == nil && entry.IsDir() && (cfg.IncludeHidden || !isHidden(sub)) {
+							dormant[sub] = info.ModTime()
+							dormantOf[sub] = path
+						}
+					}
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		})
+		if walkErr != nil {
+			logger.Error().Msgf("Error walking the path %q: %v", dir, walkErr)
+		}
+		return added
+	}
+
+	for i, dir := range roots {
+		if cfg.Recursive {
+			addTree(absRoots[i], dir, cfg.LazyWatch)
+		} else {
+			logger.Info().Msgf("Adding watch for: %s", dir)
+			addWatch(dir)
+			dirs.addChildren(dir)
+		}
+	}
+	unwatched := failures.total + len(skipped)
+	reportSkipped()
+	if cfg.Strict && unwatched > 0 {
+		watcher.Close()
+		return nil, fmt.Errorf("%d director(ies) could not be watched (strict mode)", unwatched)
+	}
+	if cfg.Recursive && cfg.LazyWatch > 0 {
+		logger.Info().Msgf("Lazy watching: %d level(s) watched, subdirectories of %d director(ies) are watched on activity", cfg.LazyWatch, len(frontier))
+	}
+
+	cfg.Health.setWatches(cfg.Name, len(watcher.WatchList()))
+
+	// rootIDs identifies each watch directory, to notice when it's replaced
+	// by another directory of the same name (e.g. by a git checkout, or by
+	// the empty mount point when a network mount goes away).
+	// rootSeen records when each directory that was a watch directory was
+	// last checked and fine, to catch up on what changed since when it's
+	// back.
+	rootIDs := make([]rootID, len(roots))
+	rootSeen := make([]map[rootID]time.Time, len(roots))
+	probers := make([]*rootProber, len(roots))
+	for i, dir := range roots {
+		probers[i] = &rootProber{dir: dir}
+		rootIDs[i], _ = probers[i].stat()
+		rootSeen[i] = map[rootID]time.Time{rootIDs[i]: started}
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer watcher.Close()
+
+		send := func(event Event) bool {
+			// Taken before the event waits for a run in progress, so
+			// repeats of it can be told apart from later changes
+			event.At = time.Now()
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// expand watches the subdirectories below the frontier directory dir
+		// and reports what changed in them while they weren't watched.
+		expand := func(dir, reason string) bool {
+			delete(frontier, dir)
+			for sub, parent := range dormantOf {
+				if parent == dir {
+					delete(dormant, sub)
+					delete(dormantOf, sub)
+				}
+			}
+			absDir, _ := filepath.Abs(dir)
+			watches-- // dir is added again
+			added := addTree(rootOf(absDir), dir, cfg.LazyWatch)
+			reportSkipped()
+			logger.Debug().Msgf("%s, now watching %d director(ies) below %s", reason, len(added)-1, dir)
+			cfg.Health.setWatches(cfg.Name, len(watcher.WatchList()))
+			for _, sub := range added {
+				if sub == dir {
+					continue
+				}
+				entries, _ := os.ReadDir(sub)
+				for _, entry := range entries {
+					if info, err := entry.Info(); err == nil && !entry.IsDir() && info.ModTime().After(started) {
+						if !send(Event{Path: filepath.Join(sub, entry.Name()), Op: fsnotify.Write}) {
+							return false
+						}
+					}
+				}
+			}
+			return true
+		}
+
+		// missing holds the watch directories that were removed, moved away,
+		// replaced or became unreachable, until they're watched again.
+		missing := make(map[int]*missingRoot)
+		reportMissing := func() {
+			var names []string
+			for i, dir := range roots {
+				if missing[i] != nil {
+					names = append(names, dir)
+				}
+			}
+			cfg.Health.setMissingRoots(cfg.Name, names)
+		}
+		// dropRoot removes the watches below the watch directory roots[i],
+		// whose events would otherwise be reported with stale paths, and marks
+		// it as missing.
+		dropRoot := func(i int, reason string) {
+			missing[i] = &missingRoot{backoff: rootPollInterval}
+			for _, path := range watcher.WatchList() {
+				if absPath, err := filepath.Abs(path); err == nil && withinDir(absPath, absRoots[i]) {
+					watcher.Remove(path)
+				}
+			}
+			for dir := range frontier {
+				if absDir, err := filepath.Abs(dir); err == nil && withinDir(absDir, absRoots[i]) {
+					delete(frontier, dir)
+				}
+			}
+			for sub, dir := range dormantOf {
+				if !frontier[dir] {
+					delete(dormant, sub)
+					delete(dormantOf, sub)
+				}
+			}
+			watches = len(watcher.WatchList())
+			cfg.Health.setWatches(cfg.Name, watches)
+			reportMissing()
+			logger.Warn().Msgf("Watch directory %s %s, watching it again once it's back", roots[i], reason)
+		}
+		// restoreRoot watches the missing watch directory roots[i] again if
+		// it's back, and reports the files in it as created: all of them for
+		// a new directory, and those modified since it was last seen when a
+		// directory that was watched before is back (e.g. a remounted network
+		// share). Until then, it's retried with exponential backoff.
+		restoreRoot := func(i int) bool {
+			m := missing[i]
+			if time.Now().Before(m.retry) {
+				return true
+			}
+			id, err := probers[i].stat()
+			if err != nil {
+				m.retry = time.Now().Add(m.backoff)
+				m.backoff = min(m.backoff*2, maxRootBackoff)
+				logger.Debug().Msgf("Watch directory %s still missing (%v), checking again in %s", roots[i], err, m.backoff)
+				return true
+			}
+			var added []string
+			if cfg.Recursive {
+				added = addTree(absRoots[i], roots[i], cfg.LazyWatch)
+			} else if addWatch(roots[i]) {
+				dirs.addChildren(roots[i])
+				added = []string{roots[i]}
+			}
+			reportSkipped()
+			cfg.Health.setWatches(cfg.Name, len(watcher.WatchList()))
+			if len(added) == 0 {
+				m.retry = time.Now().Add(m.backoff)
+				m.backoff = min(m.backoff*2, maxRootBackoff)
+				return true
+			}
+			delete(missing, i)
+			reportMissing()
+			since := rootSeen[i][id]
+			rootIDs[i], rootSeen[i][id] = id, time.Now()
+			logger.Info().Msgf("Watch directory %s is back, watching %d director(ies) again", roots[i], len(added))
+			for _, dir := range added {
+				entries, _ := os.ReadDir(dir)
+				for _, entry := range entries {
+					if entry.IsDir() {
+						continue
+					}
+					if info, err := entry.Info(); err != nil || !info.ModTime().After(since) {
+						continue
+					}
+					if !send(Event{Path: filepath.Join(dir, entry.Name()), Op: fsnotify.Create}) {
+						return false
+					}
+				}
+			}
+			return true
+		}
+
+		var lazyPoll <-chan time.Time
+		if cfg.Recursive && cfg.LazyWatch > 0 {
+			ticker := time.NewTicker(lazyPollInterval)
+			defer ticker.Stop()
+			lazyPoll = ticker.C
+		}
+		rootPoll := time.NewTicker(rootPollInterval)
+		defer rootPoll.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if dir := filepath.Dir(event.Name); frontier[dir] {
+					if !expand(dir, "Activity in "+dir) {
+						return
+					}
+				}
+
+				if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+					// fsnotify drops the watch of a removed directory
+					watches = len(watcher.WatchList())
+					cfg.Health.setWatches(cfg.Name, watches)
+					if absPath, err := filepath.Abs(event.Name); err == nil {
+						for i := range roots {
+							if missing[i] == nil && samePath(absPath, absRoots[i]) {
+								dropRoot(i, "was removed or moved away")
+							}
+						}
+					}
+				}
+
+				if cfg.Recursive && event.Has(fsnotify.Create) {
+					info, err := os.Stat(event.Name)
+					if err == nil && info.IsDir() {
+						if !cfg.IncludeHidden && isHidden(event.Name) {
+							logger.Debug().Msgf("Not watching new hidden directory: %s", event.Name)
+							continue
+						}
+						if absPath, err := filepath.Abs(event.Name); err == nil {
+							if rule, ok := exclude.excluded(rootOf(absPath), absPath); ok {
+								logger.Debug().Msgf("Not watching new excluded directory: %s (%s)", event.Name, rule)
+								continue
+							}
+						}
+						logger.Debug().Msgf("Detected directory creation: %s. Adding watch and scanning...", event.Name)
+						// Add watch to the new directory. Continue processing other
+						// events even if adding the watch failed for this one
+						addWatch(event.Name)
+						reportSkipped()
+						cfg.Health.setWatches(cfg.Name, len(watcher.WatchList()))
+						if cfg.reportsNewDirs() && !send(Event{Path: event.Name, Op: event.Op, IsDir: true}) {
+							return
+						}
+
+						// Files may have been written before the watch was in place, so
+						// report everything already in the new directory as created.
+						entries, readErr := os.ReadDir(event.Name)
+						if readErr != nil {
+							logger.Warn().Msgf("Failed to read newly created directory %s for initial scan: %v", event.Name, readErr)
+						}
+						for _, entry := range entries {
+							if entry.IsDir() {
+								// TODO: Optionally, recursively add watch & scan for subdirs created within this new dir?
+								// For now, fsnotify should handle subsequent events within the new dir.
+								continue
+							}
+							if !send(Event{Path: filepath.Join(event.Name, entry.Name()), Op: fsnotify.Create}) {
+								return
+							}
+						}
+						// Unless --target asks for directories, skip the directory CREATE
+						// event itself; the directory name likely won't match file patterns
+						// and reporting it would double trigger.
+						continue
+					}
+					// If stat failed or it wasn't a directory, proceed as normal
+				}
+
+				gone := event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)
+				if !send(Event{Path: event.Name, Op: event.Op, IsDir: dirs.isDir(event.Name, gone)}) {
+					return
+				}
+
+			case <-rootPoll.C:
+				for i := range roots {
+					if missing[i] == nil {
+						id, err := probers[i].stat()
+						switch {
+						case errors.Is(err, errRootHung):
+							dropRoot(i, "is not responding")
+						case err != nil:
+							dropRoot(i, fmt.Sprintf("disappeared (%v)", err))
+						case rootIDs[i] != (rootID{}) && id != rootIDs[i]:
+							dropRoot(i, "was replaced")
+						default:
+							rootSeen[i][id] = time.Now()
+							continue
+						}
+					}
+					if !restoreRoot(i) {
+						return
+					}
+				}
+
+			case <-lazyPoll:
+				var changed []string
+				for sub, modTime := range dormant {
+					if info, err := os.Stat(sub); err != nil || !info.ModTime().Equal(modTime) {
+						changed = append(changed, sub)
+					}
+				}
+				for _, sub := range changed {
+					if dir, ok := dormantOf[sub]; ok && !expand(dir, "Activity in "+sub) {
+						return
+					}
+				}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error().Msgf("Watcher error: %v", err)
+			}
+		}
+	}()
+	return events, nil
+}
+
+// dirDepth returns how many levels path is below dir.
+func dirDepth(dir, path string) int {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// normalizeWatchDirs drops watch directories that are listed more than once
+// (after resolving them to absolute paths) and, in recursive mode, those
+// already reached by walking another watch directory, so nothing is watched
+// twice. A directory below an excluded or hidden directory isn't reached by
+// the walk and is kept.
+func normalizeWatchDirs(cfg Config, exclude *excluder, logger zerolog.Logger) []string {
+	dirs := cfg.WatchDirs
+	abs := make([]string, len(dirs))
+	for i, dir := range dirs {
+		a, err := filepath.Abs(dir)
+		if err != nil {
+			a = filepath.Clean(dir)
+		}
+		abs[i] = a
+	}
+
+	// reached reports whether walking root visits dir.
+	reached := func(root, dir string) bool {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false
+		}
+		path := root
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			path = filepath.Join(path, part)
+			if _, ok := exclude.excluded(root, path); ok || (!cfg.IncludeHidden && isHidden(part)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	var result []string
+	for i, dir := range dirs {
+		covered := ""
+		for j := range dirs {
+			if i == j {
+				continue
+			}
+			if samePath(abs[i], abs[j]) {
+				if j < i {
+					covered = dirs[j]
+					break
+				}
+				continue
+			}
+			if cfg.Recursive && reached(abs[j], abs[i]) {
+				covered = dirs[j]
+				break
+			}
+		}
+		if covered != "" {
+			logger.Info().Msgf("Not watching %s separately: already covered by %s", dir, covered)
+			continue
+		}
+		result = append(result, dir)
+	}
+	return result
+}
+
+// rootID identifies a watch directory by its device and inode number.
+type rootID struct{ dev, ino uint64 }
+
+// missingRoot is a watch directory that is gone or unreachable.
+type missingRoot struct {
+	retry   time.Time // When to check for it again
+	backoff time.Duration
+}
+
+var errRootHung = errors.New("not responding")
+
+// rootProber checks a watch directory with at most one check in flight.
+// A stat of an unreachable network mount can block for a long time, and
+// starting another one for every check would pile up goroutines.
+type rootProber struct {
+	dir     string
+	pending chan rootProbe // The check that gave up waiting, until it returns
+}
+
+type rootProbe struct {
+	id  rootID
+	err error
+}
+
+// stat identifies the directory, giving up after rootStatTimeout. While an
+// earlier check is still hanging, it doesn't start another one, and reports
+// the directory as not responding right away.
+func (p *rootProber) stat() (rootID, error) {
+	if p.pending != nil {
+		select {
+		case <-p.pending:
+			// Its result is stale by now
+			p.pending = nil
+		default:
+			return rootID{}, errRootHung
+		}
+	}
+	done := make(chan rootProbe, 1)
+	go func() {
+		info, err := os.Stat(p.dir)
+		if err == nil && !info.IsDir() {
+			err = fmt.Errorf("%s is not a directory", p.dir)
+		}
+		var id rootID
+		if err == nil {
+			id.dev, id.ino, _ = fileID(p.dir)
+		}
+		done <- rootProbe{id, err}
+	}()
+	select {
+	case r := <-done:
+		return r.id, r.err
+	case <-time.After(rootStatTimeout):
+		p.pending = done
+		return rootID{}, errRootHung
+	}
+}
@@ -0,0 +1,56 @@
+package watcher
+
+import (
+	"context"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+
+	"github.com/s0up4200/gowatchrun/internal/remote"
+)
+
+// pollSource polls a remote S3 prefix or SFTP directory.
+type pollSource struct {
+	cfg    remote.SourceConfig
+	logger zerolog.Logger
+}
+
+func (s *pollSource) Start(ctx context.Context) (<-chan Event, error) {
+	logger := s.logger
+	poller, err := remote.NewPoller(s.cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info().Msgf("Polling remote source %s every %s", s.cfg.URL, s.cfg.PollInterval)
+
+	changes := make(chan remote.Change)
+	go func() {
+		defer close(changes)
+		poller.Run(ctx, changes)
+	}()
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		for change := range changes {
+			select {
+			case events <- remoteEvent(change):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return events, nil
+}
+
+// remoteEvent translates a remote change into the equivalent file system
+// event for the downloaded file, so it can go through the regular filters.
+func remoteEvent(change remote.Change) Event {
+	op := fsnotify.Write
+	switch change.Op {
+	case remote.OpCreate:
+		op = fsnotify.Create
+	case remote.OpRemove:
+		op = fsnotify.Remove
+	}
+	return Event{Path: change.LocalPath, Op: op, Remote: change.Key}
+}
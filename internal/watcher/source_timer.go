@@ -0,0 +1,91 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog"
+)
+
+// ParseCron parses a standard five-field cron expression (or a descriptor
+// such as "@hourly").
+func ParseCron(expr string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return schedule, nil
+}
+
+// timerSource emits a synthetic TIMER event every interval (if non-zero)
+// and at every activation of the cron expression (if non-empty).
+type timerSource struct {
+	every  time.Duration
+	cron   string
+	logger zerolog.Logger
+}
+
+func (s *timerSource) Start(ctx context.Context) (<-chan Event, error) {
+	logger := s.logger
+	var schedule cron.Schedule
+	if s.cron != "" {
+		var err error
+		if schedule, err = ParseCron(s.cron); err != nil {
+			return nil, err
+		}
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+
+		var tickerChan <-chan time.Time
+		if s.every > 0 {
+			logger.Info().Msgf("Triggering every %s", s.every)
+			ticker := time.NewTicker(s.every)
+			defer ticker.Stop()
+			tickerChan = ticker.C
+		}
+
+		var cronTimer *time.Timer
+		var cronChan <-chan time.Time
+		if schedule != nil {
+			next := schedule.Next(time.Now())
+			logger.Info().Msgf("Triggering on cron schedule '%s' (next: %s)", s.cron, next.Format(time.RFC3339))
+			cronTimer = time.NewTimer(time.Until(next))
+			defer cronTimer.Stop()
+			cronChan = cronTimer.C
+		}
+
+		for {
+			var data *EventData
+			select {
+			case <-ctx.Done():
+				return
+			case <-tickerChan:
+				logger.Info().Msgf("Interval of %s elapsed", s.every)
+				data = timerEvent("every " + s.every.String())
+			case <-cronChan:
+				logger.Info().Msgf("Cron schedule '%s' fired", s.cron)
+				data = timerEvent(s.cron)
+				cronTimer.Reset(time.Until(schedule.Next(time.Now())))
+			}
+
+			select {
+			case events <- Event{Data: data}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func timerEvent(name string) *EventData {
+	return &EventData{
+		Name:  name,
+		Event: "TIMER",
+	}
+}
@@ -0,0 +1,134 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+
+	"github.com/s0up4200/gowatchrun/internal/auth"
+)
+
+const maxWebhookBody = 10 << 20
+
+// ParseListenAddr splits a --listen-webhook or --health-listen value like
+// ":8085/hook" into the listen address and the URL path. The path defaults
+// to "/".
+func ParseListenAddr(spec string) (addr, path string, err error) {
+	addr, path, found := strings.Cut(spec, "/")
+	path = "/" + path
+	if !found {
+		path = "/"
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return "", "", fmt.Errorf("invalid listen address %q: %w", spec, err)
+	}
+	return addr, path, nil
+}
+
+// webhookSource listens for HTTP requests and emits a synthetic WEBHOOK
+// event for every POST received on its path.
+type webhookSource struct {
+	spec   string
+	auth   auth.Config
+	logger zerolog.Logger
+}
+
+func (s *webhookSource) Start(ctx context.Context) (<-chan Event, error) {
+	logger := s.logger
+	addr, path, err := ParseListenAddr(s.spec)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	// server.Close doesn't wait for the handlers, so they hold sending for
+	// reading while they send, and events is only closed with it held for
+	// writing.
+	var (
+		sending sync.RWMutex
+		stopped bool
+	)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		var payload interface{}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &payload); err != nil {
+				logger.Debug().Msgf("Webhook body is not JSON, exposing it as .Body only: %v", err)
+				payload = nil
+			}
+		}
+
+		headers := make(map[string]string, len(r.Header))
+		for name, values := range r.Header {
+			headers[name] = strings.Join(values, ", ")
+		}
+
+		logger.Info().Msgf("Received webhook from %s on %s", r.RemoteAddr, r.URL.Path)
+		data := &EventData{
+			Path:      r.URL.Path,
+			PathSlash: r.URL.Path,
+			Name:      r.URL.Path,
+			Event:     "WEBHOOK",
+			Payload:   payload,
+			Body:      string(body),
+			Headers:   headers,
+		}
+		sending.RLock()
+		defer sending.RUnlock()
+		if stopped {
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		select {
+		case events <- Event{Data: data}:
+			w.WriteHeader(http.StatusAccepted)
+		case <-ctx.Done():
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		case <-r.Context().Done():
+		}
+	})
+
+	listener, err := s.auth.Listen(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for webhooks on %s: %w", addr, err)
+	}
+	logger.Info().Msgf("Listening for webhooks on %s://%s%s", s.auth.Scheme(), listener.Addr(), path)
+
+	server := &http.Server{Handler: s.auth.Require(mux)}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		defer func() {
+			sending.Lock()
+			defer sending.Unlock()
+			stopped = true
+			close(events)
+		}()
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error().Msgf("Webhook server stopped: %v", err)
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,170 @@
+package watcher
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stats counts what happened to the events of one or more jobs and how their
+// executions went, for the summary printed on exit. A nil *Stats counts
+// nothing.
+type Stats struct {
+	mu        sync.Mutex
+	observed  int
+	filtered  int
+	coalesced int
+	succeeded int
+	failed    int
+	durations []time.Duration
+	triggers  map[string]int // Executions by triggering file
+}
+
+// NewStats returns empty statistics.
+func NewStats() *Stats {
+	return &Stats{triggers: make(map[string]int)}
+}
+
+// count increments the counter selected by field.
+func (s *Stats) count(field func(*Stats) *int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	*field(s)++
+	s.mu.Unlock()
+}
+
+func (s *Stats) observe()  { s.count(func(s *Stats) *int { return &s.observed }) }
+func (s *Stats) filter()   { s.count(func(s *Stats) *int { return &s.filtered }) }
+func (s *Stats) coalesce() { s.count(func(s *Stats) *int { return &s.coalesced }) }
+
+// Track wraps execFunc to record the outcome and duration of every run and
+// the files that triggered it. A skipped run counts as a filtered event.
+func (s *Stats) Track(execFunc ExecutorFunc) ExecutorFunc {
+	return func(ctx context.Context, cfg Config, data *EventData) ExecutionResult {
+		result := execFunc(ctx, cfg, data)
+		if result.Skipped {
+			s.filter()
+			return result
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if result.OK() {
+			s.succeeded++
+		} else {
+			s.failed++
+		}
+		s.durations = append(s.durations, result.Duration)
+		if data != nil {
+			if len(data.Files) > 0 {
+				for _, file := range data.Files {
+					s.triggers[file.Path]++
+				}
+			} else if data.Path != "" {
+				s.triggers[data.Path]++
+			}
+		}
+		return result
+	}
+}
+
+// FileCount is the number of executions a file triggered.
+type FileCount struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// Summary is a snapshot of Stats. Durations are in milliseconds in JSON.
+type Summary struct {
+	Observed  int `json:"events_observed"`  // Events received from all sources
+	Filtered  int `json:"events_filtered"`  // Events rejected by the filters
+	Coalesced int `json:"events_coalesced"` // Events merged into another execution by debouncing, batching or deduplication
+	Runs      int `json:"runs"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Distribution
+	TopFiles []FileCount `json:"top_files"`
+}
+
+// Distribution summarizes a set of durations.
+type Distribution struct {
+	Average Millis `json:"avg_ms"`
+	P50     Millis `json:"p50_ms"`
+	P90     Millis `json:"p90_ms"`
+	P99     Millis `json:"p99_ms"`
+	Max     Millis `json:"max_ms"`
+}
+
+// NewDistribution returns the average, nearest-rank percentiles and maximum
+// of durations, all zero when there are none.
+func NewDistribution(durations []time.Duration) Distribution {
+	n := len(durations)
+	if n == 0 {
+		return Distribution{}
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	percentile := func(p int) Millis {
+		rank := (p*n + 99) / 100
+		return Millis(sorted[rank-1])
+	}
+	return Distribution{
+		Average: Millis(total / time.Duration(n)),
+		P50:     percentile(50),
+		P90:     percentile(90),
+		P99:     percentile(99),
+		Max:     Millis(sorted[n-1]),
+	}
+}
+
+// Millis is a duration that is encoded as a number of milliseconds.
+type Millis time.Duration
+
+func (m Millis) MarshalJSON() ([]byte, error) {
+	return strconv.AppendFloat(nil, float64(m)/float64(time.Millisecond), 'f', 1, 64), nil
+}
+
+func (m Millis) String() string {
+	return time.Duration(m).Round(time.Millisecond).String()
+}
+
+// Summary returns the current statistics with the top files that triggered
+// the most executions.
+func (s *Stats) Summary(top int) Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := Summary{
+		Observed:     s.observed,
+		Filtered:     s.filtered,
+		Coalesced:    s.coalesced,
+		Runs:         s.succeeded + s.failed,
+		Succeeded:    s.succeeded,
+		Failed:       s.failed,
+		Distribution: NewDistribution(s.durations),
+		TopFiles:     []FileCount{},
+	}
+
+	for path, count := range s.triggers {
+		sum.TopFiles = append(sum.TopFiles, FileCount{Path: path, Count: count})
+	}
+	sort.Slice(sum.TopFiles, func(i, j int) bool {
+		a, b := sum.TopFiles[i], sum.TopFiles[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Path < b.Path
+	})
+	if len(sum.TopFiles) > top {
+		sum.TopFiles = sum.TopFiles[:top]
+	}
+	return sum
+}
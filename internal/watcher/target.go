@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Values for Config.Target.
+const (
+	TargetFiles = "files" // Only files (anything but directories)
+	TargetDirs  = "dirs"  // Only directories
+	TargetBoth  = "both"
+)
+
+// targetReason returns why an event for a directory (isDir) or file is
+// rejected by target, or "" when it passes.
+func targetReason(target string, isDir bool) string {
+	switch {
+	case target == TargetFiles && isDir:
+		return "it's a directory (--target files)"
+	case target == TargetDirs && !isDir:
+		return "it's not a directory (--target dirs)"
+	}
+	return ""
+}
+
+// reportsNewDirs reports whether directories created in recursive mode are
+// passed on as events, rather than only being watched and scanned.
+func (cfg Config) reportsNewDirs() bool {
+	return cfg.Target == TargetDirs || cfg.Target == TargetBoth
+}
+
+// knownDirs remembers the directories a source has seen, since whether a
+// removed or renamed path was a directory can't be checked afterwards.
+type knownDirs struct {
+	dirs map[string]bool
+}
+
+func newKnownDirs() *knownDirs {
+	return &knownDirs{dirs: make(map[string]bool)}
+}
+
+func (k *knownDirs) add(path string) {
+	k.dirs[path] = true
+}
+
+// addChildren remembers the subdirectories of dir.
+func (k *knownDirs) addChildren(dir string) {
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			k.add(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
+// isDir reports whether path is a directory. For removed and renamed paths
+// (gone is set) it answers from memory; a path is remembered until it turns
+// out to be something else, since a removed directory is reported by its
+// parent's watch and its own.
+func (k *knownDirs) isDir(path string, gone bool) bool {
+	if gone {
+		return k.dirs[path]
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return k.dirs[path]
+	}
+	if !info.IsDir() {
+		delete(k.dirs, path)
+		return false
+	}
+	k.add(path)
+	return true
+}
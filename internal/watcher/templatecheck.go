@@ -0,0 +1,216 @@
+package watcher
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// eventDataType is what {{.}} refers to in command, --when and --dest
+// templates.
+var eventDataType = reflect.TypeOf(EventData{})
+
+// CheckTemplate reports the first field a parsed command, --when or --dest
+// template refers to that EventData doesn't have, such as {{.Patth}}, which
+// would otherwise only fail once an event arrives. Fields reached through
+// values whose type isn't known in advance (like {{.Payload}}) aren't
+// checked. Including a template that isn't defined is reported as well.
+func CheckTemplate(tmpl *template.Template) error {
+	if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+		return nil
+	}
+	c := templateChecker{tmpl: tmpl, tree: tmpl.Tree}
+	return c.list(tmpl.Tree.Root, eventDataType)
+}
+
+type templateChecker struct {
+	tmpl *template.Template
+	tree *parse.Tree
+}
+
+// list checks the nodes of list, where dot has type dot (nil when unknown).
+func (c templateChecker) list(list *parse.ListNode, dot reflect.Type) error {
+	if list == nil {
+		return nil
+	}
+	for _, node := range list.Nodes {
+		if err := c.node(node, dot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c templateChecker) node(node parse.Node, dot reflect.Type) error {
+	switch n := node.(type) {
+	case *parse.ActionNode:
+		_, err := c.pipe(n.Pipe, dot)
+		return err
+	case *parse.IfNode:
+		return c.branch(&n.BranchNode, dot, dot)
+	case *parse.WithNode:
+		inner, err := c.pipe(n.Pipe, dot)
+		if err != nil {
+			return err
+		}
+		return c.branch(&n.BranchNode, dot, inner)
+	case *parse.RangeNode:
+		over, err := c.pipe(n.Pipe, dot)
+		if err != nil {
+			return err
+		}
+		var elem reflect.Type
+		if over != nil && (over.Kind() == reflect.Slice || over.Kind() == reflect.Array || over.Kind() == reflect.Map) {
+			elem = over.Elem()
+		}
+		return c.branch(&n.BranchNode, dot, elem)
+	case *parse.TemplateNode:
+		if c.tmpl.Lookup(n.Name) == nil {
+			location, context := c.tree.ErrorContext(n)
+			return fmt.Errorf("%s: %s: no template %q is defined", location, context, n.Name)
+		}
+		_, err := c.pipe(n.Pipe, dot)
+		return err
+	case *parse.ListNode:
+		return c.list(n, dot)
+	}
+	return nil
+}
+
+// branch checks the body of an if, with or range with inner as dot, and its
+// else branch with the outer dot.
+func (c templateChecker) branch(n *parse.BranchNode, outer, inner reflect.Type) error {
+	if _, err := c.pipe(n.Pipe, outer); err != nil {
+		return err
+	}
+	if err := c.list(n.List, inner); err != nil {
+		return err
+	}
+	return c.list(n.ElseList, outer)
+}
+
+// pipe checks the commands of pipe and returns the type it evaluates to, if
+// it's a single field reference.
+func (c templateChecker) pipe(pipe *parse.PipeNode, dot reflect.Type) (reflect.Type, error) {
+	if pipe == nil {
+		return nil, nil
+	}
+	var result reflect.Type
+	for _, cmd := range pipe.Cmds {
+		for _, arg := range cmd.Args {
+			t, err := c.arg(arg, dot)
+			if err != nil {
+				return nil, err
+			}
+			if len(pipe.Cmds) == 1 && len(cmd.Args) == 1 {
+				result = t
+			}
+		}
+	}
+	return result, nil
+}
+
+// arg checks one argument of a command and returns its type when known.
+func (c templateChecker) arg(arg parse.Node, dot reflect.Type) (reflect.Type, error) {
+	switch a := arg.(type) {
+	case *parse.DotNode:
+		return dot, nil
+	case *parse.FieldNode:
+		return c.fields(a, dot, a.Ident)
+	case *parse.VariableNode:
+		if a.Ident[0] == "$" {
+			return c.fields(a, eventDataType, a.Ident[1:])
+		}
+		return nil, nil
+	case *parse.ChainNode:
+		base, err := c.arg(a.Node, dot)
+		if err != nil || base == nil {
+			return nil, err
+		}
+		return c.fields(a, base, a.Field)
+	case *parse.PipeNode:
+		return c.pipe(a, dot)
+	}
+	return nil, nil
+}
+
+// fields resolves the chain of field or method names idents on t.
+func (c templateChecker) fields(node parse.Node, t reflect.Type, idents []string) (reflect.Type, error) {
+	for _, ident := range idents {
+		if t == nil {
+			return nil, nil
+		}
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if method, ok := reflect.PointerTo(t).MethodByName(ident); ok {
+			if method.Type.NumOut() == 0 {
+				return nil, nil
+			}
+			t = method.Type.Out(0)
+			continue
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := t.FieldByName(ident)
+			if !ok || !field.IsExported() {
+				return nil, c.unknownField(node, t, ident)
+			}
+			t = field.Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			// Interfaces (like Payload) are only known at execution time
+			return nil, nil
+		}
+	}
+	return t, nil
+}
+
+func (c templateChecker) unknownField(node parse.Node, t reflect.Type, ident string) error {
+	location, context := c.tree.ErrorContext(node)
+	msg := fmt.Sprintf("%s: {{%s}}: %s has no field %s", location, context, t.Name(), ident)
+	if suggestion := closestField(t, ident); suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean .%s?)", suggestion)
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// closestField returns the exported field of t whose name is most similar
+// to ident, if any is similar enough to be a likely typo.
+func closestField(t reflect.Type, ident string) string {
+	best, bestDist := "", 3
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if d := editDistance(strings.ToLower(field.Name), strings.ToLower(ident)); d < bestDist {
+			best, bestDist = field.Name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
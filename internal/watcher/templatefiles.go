@@ -0,0 +1,49 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// LoadTemplates reads the *.tmpl files in dir, for templates to include with
+// {{template "name" .}}. Each file is included by its name without the
+// extension; the templates it {{define}}s can be included as well.
+func LoadTemplates(dir string) (map[string]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err == nil {
+		_, err = os.Stat(dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the templates directory: %w", err)
+	}
+	templates := make(map[string]string, len(paths))
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		templates[strings.TrimSuffix(filepath.Base(path), ".tmpl")] = string(raw)
+	}
+	return templates, nil
+}
+
+// ParseTemplate parses text with cfg's delimiters, the template functions
+// and cfg's included templates.
+func ParseTemplate(cfg Config, name, text string) (*template.Template, error) {
+	tmpl := template.New(name).Delims(cfg.TemplateDelims[0], cfg.TemplateDelims[1]).Funcs(TemplateFuncs)
+	names := make([]string, 0, len(cfg.Templates))
+	for include := range cfg.Templates {
+		names = append(names, include)
+	}
+	slices.Sort(names)
+	for _, include := range names {
+		if _, err := tmpl.New(include).Parse(cfg.Templates[include]); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl.Parse(text)
+}
@@ -0,0 +1,51 @@
+package watcher
+
+import (
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/s0up4200/gowatchrun/internal/secret"
+	"github.com/s0up4200/gowatchrun/internal/wsl"
+)
+
+// TemplateFuncs are the functions available to command, --when, --dest,
+// --http-* and sidecar templates.
+var TemplateFuncs = template.FuncMap{
+	// env returns the value of an environment variable, e.g. {{env "TOKEN"}}
+	"env": os.Getenv,
+	// secret returns its argument unchanged but masks it in logs, the audit
+	// log and API responses, e.g. {{.Payload.token | secret}}
+	"secret": func(value string) string {
+		secret.Rendered(value)
+		return value
+	},
+	// psquote quotes its argument as a PowerShell string literal, in which
+	// nothing is expanded, e.g. {{psquote .Path}}
+	"psquote": psQuote,
+	// psescape escapes its argument for use inside a double-quoted
+	// PowerShell string, e.g. "Processing {{psescape .Name}}"
+	"psescape": psEscape,
+	// wslpath converts a Windows path to the path WSL sees, e.g.
+	// C:\Users\me to /mnt/c/Users/me
+	"wslpath": wsl.ToWSL,
+	// winpath converts a WSL path to the path Windows sees, e.g.
+	// /mnt/c/Users/me to C:\Users\me
+	"winpath": wsl.ToWindows,
+}
+
+// psQuoter doubles the characters PowerShell accepts as single quotes, which
+// is how a single-quoted string contains them.
+var psQuoter = strings.NewReplacer("'", "''", "\u2018", "\u2018\u2018", "\u2019", "\u2019\u2019", "\u201a", "\u201a\u201a", "\u201b", "\u201b\u201b")
+
+func psQuote(s string) string {
+	return "'" + psQuoter.Replace(s) + "'"
+}
+
+// psEscaper puts a backtick, PowerShell's escape character, in front of the
+// characters that end or expand a double-quoted string.
+var psEscaper = strings.NewReplacer("`", "``", "$", "`$", `"`, "`\"", "\u201c", "`\u201c", "\u201d", "`\u201d", "\u201e", "`\u201e")
+
+func psEscape(s string) string {
+	return psEscaper.Replace(s)
+}
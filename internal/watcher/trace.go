@@ -0,0 +1,75 @@
+package watcher
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer creates the spans of the event pipeline. It's a no-op unless an
+// OpenTelemetry tracer provider is installed (see internal/tracing).
+var Tracer = otel.Tracer("github.com/s0up4200/gowatchrun")
+
+// Context returns the context of the event's trace, derived from parent, or
+// parent itself when the event isn't traced.
+func (d *EventData) Context(parent context.Context) context.Context {
+	if d == nil || d.ctx == nil {
+		return parent
+	}
+	return d.ctx
+}
+
+// eventTrace follows one event through filtering, the debounce wait and its
+// execution. The execution's queue and execute spans are children of span.
+type eventTrace struct {
+	ctx  context.Context
+	span trace.Span
+	wait trace.Span
+}
+
+func startEventTrace(parent context.Context, cfg Config, path, op string) *eventTrace {
+	ctx, span := Tracer.Start(parent, "event",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("gowatchrun.job", cfg.Name),
+			attribute.String("gowatchrun.path", path),
+			attribute.String("gowatchrun.event", op),
+		))
+	return &eventTrace{ctx: ctx, span: span}
+}
+
+// filter records the outcome of the filters.
+func (t *eventTrace) filter(accepted bool, reason string) {
+	_, span := Tracer.Start(t.ctx, "filter")
+	span.SetAttributes(attribute.Bool("gowatchrun.accepted", accepted), attribute.String("gowatchrun.reason", reason))
+	span.End()
+}
+
+// ignore ends the trace of an event that won't trigger an execution.
+func (t *eventTrace) ignore(reason string) {
+	t.endWait()
+	t.span.SetAttributes(attribute.String("gowatchrun.ignored", reason))
+	t.span.End()
+}
+
+func (t *eventTrace) startWait(name string) {
+	_, t.wait = Tracer.Start(t.ctx, name)
+}
+
+func (t *eventTrace) endWait() {
+	if t.wait != nil {
+		t.wait.End()
+		t.wait = nil
+	}
+}
+
+// finish ends the trace once the execution the event triggered is done.
+func (t *eventTrace) finish(err error) {
+	if err != nil {
+		t.span.SetStatus(codes.Error, err.Error())
+	}
+	t.span.End()
+}
@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// trendWindow is how many of a job's recent successful runs the median
+	// duration is taken over.
+	trendWindow = 20
+	// minTrendRuns is how many runs a job needs before its runs are compared
+	// with the median.
+	minTrendRuns = 5
+	// minSlowdown is how much longer than the median a run must take to be
+	// reported, so a 2ms command taking 5ms isn't.
+	minSlowdown = 100 * time.Millisecond
+)
+
+// Trends keeps the durations of the recent successful runs of every job and
+// warns about runs that take SlowFactor times longer than the median of
+// them (--slow-factor), to catch build time regressions early.
+type Trends struct {
+	mu   sync.Mutex
+	runs map[string][]time.Duration // Recent durations by job, oldest first
+}
+
+// NewTrends returns Trends without any history.
+func NewTrends() *Trends {
+	return &Trends{runs: make(map[string][]time.Duration)}
+}
+
+// Seed adds an earlier successful run of job that took d, e.g. from the
+// journal.
+func (t *Trends) Seed(job string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.add(job, d)
+}
+
+// Track wraps execFunc to compare the duration of every successful run of
+// jobs with a SlowFactor with the median of their recent runs.
+func (t *Trends) Track(execFunc ExecutorFunc) ExecutorFunc {
+	return func(ctx context.Context, cfg Config, data *EventData) ExecutionResult {
+		result := execFunc(ctx, cfg, data)
+		if cfg.SlowFactor <= 0 || !result.OK() || result.Skipped {
+			// Failed runs often stop early and would drag the median down
+			return result
+		}
+
+		t.mu.Lock()
+		recent := t.runs[cfg.Name]
+		var median time.Duration
+		if len(recent) >= minTrendRuns {
+			median = medianDuration(recent)
+		}
+		t.add(cfg.Name, result.Duration)
+		t.mu.Unlock()
+
+		if median > 0 && float64(result.Duration) > cfg.SlowFactor*float64(median) && result.Duration-median >= minSlowdown {
+			logger := cfg.Logger()
+			logger.Warn().Msgf("Slow run: took %s, %.1fx the median of the last %d runs (%s)",
+				result.Duration.Round(time.Millisecond), float64(result.Duration)/float64(median), len(recent), median.Round(time.Millisecond))
+		}
+		return result
+	}
+}
+
+// add records a run of job that took d. t.mu must be held.
+func (t *Trends) add(job string, d time.Duration) {
+	recent := append(t.runs[job], d)
+	if len(recent) > trendWindow {
+		recent = slices.Delete(recent, 0, len(recent)-trendWindow)
+	}
+	t.runs[job] = recent
+}
+
+func medianDuration(durations []time.Duration) time.Duration {
+	sorted := slices.Sorted(slices.Values(durations))
+	if n := len(sorted); n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	return sorted[len(sorted)/2]
+}
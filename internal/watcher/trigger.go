@@ -0,0 +1,57 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// triggerData returns the data of a TRIGGER event for the file at path, as
+// Control.Trigger asks for it. Like the file's own events, it must be inside
+// one of cfg's watch directories, also once symlinks are resolved, and pass
+// the job's excludes and filters, so an API client can't make the job run
+// for (or move) any file on the host.
+func triggerData(cfg Config, path string, logger zerolog.Logger) (*EventData, error) {
+	reject := func(format string, args ...interface{}) (*EventData, error) {
+		return nil, fmt.Errorf("%w: %s", ErrRejectedPath, fmt.Sprintf(format, args...))
+	}
+	absPath, err := filepath.Abs(normalizeUnicode(cfg.UnicodeForm, path))
+	if err != nil {
+		return reject("%v", err)
+	}
+
+	root, nested := watchRootOf(cfg, absPath)
+	switch {
+	case len(cfg.WatchDirs) == 0:
+		return reject("the job has no watch directories")
+	case root == "":
+		return reject("%s is not inside any watch directory %v", path, cfg.WatchDirs)
+	case nested && !cfg.Recursive:
+		return reject("%s is in a subdirectory of watch directory '%s', which is only watched with --recursive", path, root)
+	}
+	absRoot, _ := filepath.Abs(root)
+	if realRoot, err := filepath.EvalSymlinks(absRoot); err == nil {
+		if realDir, err := filepath.EvalSymlinks(filepath.Dir(absPath)); err == nil && !withinDir(realDir, realRoot) {
+			return reject("%s leads outside watch directory '%s' through a symlink", path, root)
+		}
+	}
+	if !cfg.IncludeHidden {
+		if hidden := hiddenComponent(cfg, absPath); hidden != "" {
+			return reject("'%s' is hidden, and hidden files are ignored without --include-hidden", hidden)
+		}
+	}
+	if rule, ok := cfg.excluder().excluded(absRoot, filepath.Dir(absPath)); ok {
+		return reject("%s is below a directory excluded by '%s'", path, rule)
+	}
+
+	info, err := os.Stat(absPath)
+	isDir := err == nil && info.IsDir()
+	data, reason := filterFile(fsnotify.Event{Name: absPath, Op: fsnotify.Write}, "TRIGGER", isDir, cfg, logger)
+	if data == nil {
+		return reject("%s", reason)
+	}
+	return data, nil
+}
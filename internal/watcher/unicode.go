@@ -0,0 +1,39 @@
+package watcher
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ParseUnicodeForm parses a --unicode-normalize value: nfc, nfd or none.
+// An empty value picks NFC on macOS, whose filesystems report decomposed
+// (NFD) names, and no normalization elsewhere.
+func ParseUnicodeForm(s string) (form string, err error) {
+	switch strings.ToLower(s) {
+	case "":
+		if runtime.GOOS == "darwin" {
+			return "nfc", nil
+		}
+		return "none", nil
+	case "nfc", "nfd", "none":
+		return strings.ToLower(s), nil
+	default:
+		return "", fmt.Errorf("invalid unicode normalization '%s' (expected nfc, nfd or none)", s)
+	}
+}
+
+// normalizeUnicode converts s to the normalization form ("nfc", "nfd" or
+// "none").
+func normalizeUnicode(form, s string) string {
+	switch form {
+	case "nfc":
+		return norm.NFC.String(s)
+	case "nfd":
+		return norm.NFD.String(s)
+	default:
+		return s
+	}
+}
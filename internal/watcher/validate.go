@@ -0,0 +1,133 @@
+package watcher
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ValidationError lists every problem Config.Validate found.
+type ValidationError struct {
+	Name     string // Job name, if any
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	if e.Name != "" {
+		fmt.Fprintf(&b, "job '%s': ", e.Name)
+	}
+	fmt.Fprintf(&b, "invalid configuration (%d problem(s)):", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the individual problems, so errors.Is can look for e.g.
+// ErrUnsupportedEventType.
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// Validate checks cfg before it's run and reports all problems at once as a
+// *ValidationError: a missing command, watch directories that don't exist,
+// invalid patterns, unknown or unsupported event types and settings that
+// contradict each other.
+func (cfg Config) Validate() error {
+	var problems []error
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if strings.TrimSpace(cfg.CommandTmpl) == "" && cfg.Action == "" && cfg.SignalPIDFile == "" && cfg.HTTPURL == "" && cfg.ActionPlugin == "" && cfg.GoHandler == "" {
+		add("no command or action configured")
+	}
+
+	for _, dir := range cfg.WatchDirs {
+		if info, err := os.Stat(dir); err != nil {
+			add("watch directory '%s' does not exist", dir)
+		} else if !info.IsDir() {
+			add("watch path '%s' is not a directory", dir)
+		}
+	}
+
+	for _, pattern := range cfg.Patterns {
+		if err := CheckPattern(pattern); err != nil {
+			add("invalid pattern '%s': %v", pattern, err)
+		}
+	}
+	for dir, patterns := range cfg.RootPatterns {
+		for _, pattern := range patterns {
+			if err := CheckPattern(pattern); err != nil {
+				add("invalid pattern '%s' for %s: %v", pattern, dir, err)
+			}
+		}
+	}
+	switch cfg.Target {
+	case "", TargetFiles, TargetDirs, TargetBoth:
+	default:
+		add("invalid target '%s' (expected %s, %s or %s)", cfg.Target, TargetFiles, TargetDirs, TargetBoth)
+	}
+	for _, pattern := range cfg.MimeTypes {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			add("invalid media type pattern '%s': %v", pattern, err)
+		}
+	}
+
+	if len(cfg.EventTypes) > 1 && slices.ContainsFunc(cfg.EventTypes, func(t string) bool { return strings.EqualFold(t, "all") }) {
+		add("event type 'all' can't be combined with other event types %v", cfg.EventTypes)
+	}
+	var inotifyOnly []string
+	for _, t := range cfg.EventTypes {
+		if err := CheckEventType(t); err != nil {
+			problems = append(problems, err)
+		} else if typ, _ := ParseEventType(t); typ.Unportable() {
+			inotifyOnly = append(inotifyOnly, t)
+		}
+	}
+	if len(inotifyOnly) > 0 && len(cfg.WatchDirs) == 0 && cfg.Source.URL != "" {
+		add("event type(s) %v are never reported when polling %s; use create or write", inotifyOnly, cfg.Source.URL)
+	}
+
+	if cfg.MaxSize > 0 && cfg.MinSize > cfg.MaxSize {
+		add("minimum size %d is larger than the maximum size %d", cfg.MinSize, cfg.MaxSize)
+	}
+	if cfg.BatchSize > 0 && !cfg.Batch {
+		add("batch size is set but batching is disabled")
+	}
+	if cfg.LockWait && cfg.LockDir == "" && cfg.Coordinator == "" {
+		add("lock wait is set but no lock directory or coordinator")
+	}
+	if cfg.LockDir != "" && cfg.Coordinator != "" {
+		add("a lock directory and a coordinator are mutually exclusive")
+	}
+	if cfg.Coordinator != "" {
+		if u, err := url.Parse(cfg.Coordinator); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") {
+			add("invalid coordinator '%s' (expected a redis:// or rediss:// URL)", cfg.Coordinator)
+		}
+	}
+	if cfg.Manifest && cfg.Batch {
+		add("manifest mode can't be combined with batching")
+	}
+	if cfg.GroupBy != "" && cfg.GroupBy != GroupByDir {
+		add("invalid group-by '%s' (expected %s)", cfg.GroupBy, GroupByDir)
+	} else if cfg.GroupBy != "" && !cfg.Batch {
+		add("group-by is set but batching is disabled")
+	}
+	if cfg.LazyWatch > 0 && !cfg.Recursive {
+		add("lazy watching only applies to recursive watching")
+	}
+	if cfg.Action != "" && cfg.CommandTmpl != "" {
+		add("a command and an action are mutually exclusive")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Name: cfg.Name, Problems: problems}
+}
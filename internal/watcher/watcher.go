@@ -1,373 +1,829 @@
 package watcher
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
-	"runtime"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+
+	"github.com/s0up4200/gowatchrun/internal/audit"
+	"github.com/s0up4200/gowatchrun/internal/auth"
+	"github.com/s0up4200/gowatchrun/internal/handler"
+	"github.com/s0up4200/gowatchrun/internal/problem"
+	"github.com/s0up4200/gowatchrun/internal/remote"
+	"github.com/s0up4200/gowatchrun/internal/sandbox"
 )
 
 type EventData struct {
-	Path     string
-	Name     string
-	Event    string
-	Ext      string
-	Dir      string
-	BaseName string
+	Path      string
+	PathSlash string // Path with forward slashes, for tools that expect them on every platform
+	Name      string
+	Event     string
+	Ext       string
+	Dir       string
+	BaseName  string
+	Size      int64  // File size in bytes, 0 when the file is gone
+	Mime      string // Media type sniffed from the file's content, e.g. image/png
+	Remote    string // Object key or remote path when the event came from a remote source
+	IsDir     bool   // The path is (or, for removals, was) a directory
+	Sidecar   string // Completion marker the event waited for (--require-sidecar)
+
+	// MatchedPattern is the pattern the file name matched, e.g. *.go; empty
+	// for events that aren't matched against patterns, like webhooks.
+	MatchedPattern string
+
+	// Payloads holds the files listed in the manifest that triggered the
+	// event (--manifest), in the manifest's order.
+	Payloads []EventData
+
+	// Files holds the events of a batch (--batch), oldest first. The other
+	// fields describe the last of them.
+	Files []EventData
+
+	// Run information, filled in for every execution
+	RunNumber    int           // 1 for the first run of the job
+	LastRunAt    time.Time     // Start of the previous run; zero for the first run
+	SinceLastRun time.Duration // Time since the previous run; 0 for the first run
+	Hostname     string
+	Rule         string // Name of the job the run is for
+
+	// Set for --on-failure hooks only
+	ExitCode   int    // Exit code of the failed run
+	OutputTail string // The end of what the failed run's command wrote to stdout and stderr
+
+	// Set for WEBHOOK events only
+	Payload interface{}       // Request body decoded as JSON, if it was valid JSON
+	Body    string            // Raw request body
+	Headers map[string]string // Request headers, multiple values joined with ", "
+
+	ctx context.Context // Trace context of the triggering event, see Context
 }
 
 // ExecutorFunc defines the function signature for executing commands based on events and config.
-type ExecutorFunc func(cfg Config, data *EventData)
+// ctx is cancelled when the execution should be aborted. The returned result
+// reports whether and how the execution failed.
+type ExecutorFunc func(ctx context.Context, cfg Config, data *EventData) ExecutionResult
+
+// Middleware wraps an ExecutorFunc to add behavior around every run, like
+// the Track methods of Stats, Health and Control.
+type Middleware func(next ExecutorFunc) ExecutorFunc
+
+// Chain wraps execFunc in middleware, the first one outermost, so it sees
+// every run first and its outcome last.
+func Chain(execFunc ExecutorFunc, middleware ...Middleware) ExecutorFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		execFunc = middleware[i](execFunc)
+	}
+	return execFunc
+}
 
 type Config struct {
-	WatchDirs     []string
-	ExcludeDirs   []string
-	Patterns      []string
-	EventTypes    []string
-	CommandTmpl   string
-	Recursive     bool
-	DebounceDelay time.Duration
-	ClearTerminal bool // Add field for terminal clearing
+	Name           string // Job name, added to every log line when set
+	WatchDirs      []string
+	ExcludeDirs    []string
+	Patterns       []string
+	RootPatterns   map[string][]string // Patterns for events below a watch directory, replacing Patterns there
+	EventTypes     []string
+	CommandTmpl    string
+	Script         bool              // CommandTmpl is a script, run from a temporary file instead of through the shell
+	Shell          string            // Shell name or path commands run with; the platform's default when empty
+	WSLInterop     bool              // Run commands on the other side of WSL, with the event paths translated
+	When           string            // Template that must render to "true" for an event to run the command
+	SidecarTmpl    string            // Template for a marker file that must exist before a file's event runs the command
+	TemplateDelims [2]string         // Replace the {{ and }} template delimiters when set
+	Templates      map[string]string // Templates to include with {{template "name" .}}, by name
+	TemplatesDir   string            // Where Templates were loaded from
+	Action         string
+	ActionDest     string
+	Signal         string // Send this signal to the process in SignalPIDFile instead of running a command
+	SignalPIDFile  string
+	HTTPURL        string // Send an HTTP request to this URL template instead of running a command
+	HTTPMethod     string
+	HTTPHeaders    []string // "Name: value" templates
+	HTTPBody       string   // Body template; the event as JSON when empty
+	S3             remote.S3Config
+	Source         remote.SourceConfig
+	WebhookAddr    string
+	Auth           auth.Config // Authenticates webhook clients
+	Every          time.Duration
+	Cron           string
+	Recursive      bool
+	MaxWatches     int           // Stop adding watches once this many are in place; 0 means no limit
+	LazyWatch      int           // In recursive mode, only watch this many levels at startup and deeper ones on activity; 0 watches everything
+	FSEventsState  string        // File keeping the last FSEvents event ID, to replay the changes made while stopped (macOS)
+	Sources        []EventSource // Additional event sources, started alongside the built-in ones
+	DebounceDelay  time.Duration
+	DedupeWindow   time.Duration // Repeats of an event for the same path within this window are dropped
+	SettleDelay    time.Duration
+	Clear          string        // When to clear the terminal before a run, see ParseClearMode
+	Strict         bool          // Fail to start when a directory can't be watched instead of warning
+	Why            bool          // Log why every event was accepted or ignored
+	IncludeHidden  bool          // Watch dot-directories and match dotfiles
+	K8sConfigMap   bool          // Turn Kubernetes ConfigMap/Secret volume updates into WRITE events
+	Target         string        // TargetFiles, TargetDirs or TargetBoth; empty reports both, except new directories in recursive mode
+	IgnoreNoise    bool          // Drop chmod-only events and editor/OS junk files
+	MinSize        int64         // Ignore files smaller than this many bytes
+	MaxSize        int64         // Ignore files larger than this many bytes; 0 means no limit
+	MinAge         time.Duration // Ignore files modified more recently than this
+	MimeTypes      []string      // Media type patterns files must match, e.g. image/*
+	SniffMime      bool          // Sniff the media type of files for Mime even when no template uses it, for consumers of whole events like workers
+	UnicodeForm    string        // Normalize event paths and patterns: "nfc", "nfd" or "none"
+	Batch          bool          // Run once per batch of events collected during the debounce delay
+	BatchSize      int           // Split batches into chunks of at most this many files; 0 means no limit
+	GroupBy        string        // GroupByDir runs a batch once per directory
+	MaxTriggers    int           // Stop after this many executions; 0 means no limit
+	OkExitCodes    []int
+	SlowFactor     float64       // Warn about successful runs this many times slower than the median of the recent ones; 0 disables
+	WorkerTimeout  time.Duration // How long to wait for a --worker handler's response; 0 means no limit
+	GoHandler      string        // Go file compiled into a handler the events are sent to, like a --worker
+	EventLog       io.Writer     // When set, every accepted event is written to it as one compact line
+	Stats          *Stats        // Counts events for the exit summary when set
+	Health         *Health       // Tracks the watcher's liveness when set
+	Control        *Control      // Lets an API pause the job and trigger runs when set
+	PauseDuring    []PauseWindow // Hold events in these windows and run them once the windows end
+	PauseDrop      bool          // Drop events in PauseDuring windows instead of holding them
+	Audit          *audit.Log    // Records every execution when set
+
+	// Sandbox, when set, restricts what commands may do.
+	Sandbox *sandbox.Profile
+
+	// Plugins, by name (see package plugin): filters that decide whether a
+	// run goes ahead, an action run instead of a command, and notifiers told
+	// about the outcome of every run.
+	FilterPlugins []string
+	ActionPlugin  string
+	NotifyPlugins []string
+
+	// Middleware wraps every run of the job, the first one outermost
+	// (--middleware, see package middleware).
+	Middleware []Middleware
+
+	// Handler, when set, decides for every run whether it goes ahead, and
+	// may change its path, command and environment (--handler).
+	Handler *handler.Handler
+
+	// Manifest treats matched files as manifests and waits for the payloads
+	// they list, found under ManifestKey, for up to ManifestTimeout (0 waits
+	// forever). Payloads must be in the manifest's directory or WatchDirs.
+	Manifest        bool
+	ManifestKey     string
+	ManifestTimeout time.Duration
+
+	// GrepOutput, when set, hides the lines of command output that don't
+	// match it, and Highlight marks its matches when output goes to a
+	// terminal.
+	GrepOutput *regexp.Regexp
+	Highlight  *regexp.Regexp
+	// FailFastOutput, when set, stops showing command output shortly after
+	// the first line that matches it (--fail-fast-output), so the failure
+	// stays at the bottom of the screen.
+	FailFastOutput *regexp.Regexp
+
+	// OnFailure is a command template run after every failed run, with
+	// {{.ExitCode}} and {{.OutputTail}} filled in.
+	OnFailure string
+	// OutputTail is how many bytes of a command's output its result keeps.
+	// When set, output is kept even when it goes to a terminal.
+	OutputTail int64
+
+	// ProblemMatchers extract diagnostics from the output of every command
+	// (--problem-matcher), which are summarized after the run and written
+	// to DiagnosticsFile as JSON when it's set.
+	ProblemMatchers []problem.Matcher
+	DiagnosticsFile string
+
+	// OnSuccessMove and OnFailureMove are directories the files of a run are
+	// moved to once it succeeded or failed.
+	OnSuccessMove string
+	OnFailureMove string
+
+	// LockDir holds lock files that keep instances sharing a folder from
+	// processing the same file, and Coordinator is a Redis URL holding them
+	// instead; with LockWait, a locked file is processed once its lock is
+	// released instead of being skipped.
+	LockDir     string
+	Coordinator string
+	LockWait    bool
+
+	matcher      *patternMatcher // Compiled Patterns, set by Run
+	rootMatchers []rootMatcher   // Compiled RootPatterns, deepest directory first, set by Run
+	ignoreFiles  *ignoreFiles    // Cached .gowatchrunignore files, set by Run
+	mime         bool            // Whether events need the media type of their file, set by Run
 }
 
-func Run(cfg Config, execFunc ExecutorFunc) error {
-	watcher, err := fsnotify.NewWatcher()
+// HasSources reports whether cfg enables any event source.
+func (cfg Config) HasSources() bool {
+	return len(configuredSources(cfg, cfg.Logger())) > 0 || len(cfg.Sources) > 0
+}
+
+// Logger returns the logger for this configuration, tagged with the job
+// name when one is set.
+func (cfg Config) Logger() zerolog.Logger {
+	if cfg.Name == "" {
+		return log.Logger
+	}
+	return log.With().Str("job", cfg.Name).Logger()
+}
+
+// logEvent writes the compact --log-events-only line for an accepted event.
+func (cfg Config) logEvent(data *EventData) {
+	if cfg.EventLog == nil {
+		return
+	}
+	job := ""
+	if cfg.Name != "" {
+		job = "[" + cfg.Name + "] "
+	}
+	fmt.Fprintf(cfg.EventLog, "%s %s%-6s %s\n", time.Now().Format("15:04:05"), job, data.Event, data.Path)
+}
+
+// Run watches for events until ctx is cancelled or all event sources are
+// exhausted, and calls execFunc for the events that pass the filters. The
+// context passed to execFunc is derived from ctx.
+func Run(ctx context.Context, cfg Config, execFunc ExecutorFunc) error {
+	logger := cfg.Logger()
+	if cfg.DebounceDelay > 0 {
+		logger.Info().Msgf("Debounce delay set to: %s", cfg.DebounceDelay)
+	}
+
+	allowedEvents, err := processEventTypes(cfg.EventTypes, logger)
 	if err != nil {
-		log.Fatal().Msgf("Failed to create watcher: %v", err)
 		return err
 	}
-	defer watcher.Close()
+	for _, t := range cfg.EventTypes {
+		if typ, err := ParseEventType(t); err == nil && typ.Unportable() && !delivered(typ) {
+			hint := ""
+			if et, _ := lookupEventType(typ); et.alternative != "" {
+				hint = "; " + et.alternative
+			}
+			logger.Warn().Msgf("Event type '%s' is not reported by this build's fsnotify and will never trigger%s (see 'gowatchrun caps')", t, hint)
+		}
+	}
+	patterns := make([]string, len(cfg.Patterns))
+	for i, pattern := range cfg.Patterns {
+		patterns[i] = normalizeUnicode(cfg.UnicodeForm, pattern)
+	}
+	cfg.Patterns = patterns
+	cfg.matcher = compilePatterns(patterns)
+	cfg.ignoreFiles = newIgnoreFiles()
+	cfg.mime = cfg.needsMime()
+	if len(cfg.RootPatterns) > 0 {
+		rootPatterns := make(map[string][]string, len(cfg.RootPatterns))
+		for _, dir := range slices.Sorted(maps.Keys(cfg.RootPatterns)) {
+			for _, pattern := range cfg.RootPatterns[dir] {
+				rootPatterns[dir] = append(rootPatterns[dir], normalizeUnicode(cfg.UnicodeForm, pattern))
+			}
+			logger.Info().Msgf("Watching for patterns below %s: %v", dir, rootPatterns[dir])
+		}
+		cfg.RootPatterns = rootPatterns
+		cfg.rootMatchers = compileRootPatterns(rootPatterns)
+	}
 
-	if cfg.DebounceDelay > 0 {
-		log.Info().Msgf("Debounce delay set to: %s", cfg.DebounceDelay)
+	logger.Info().Msgf("Watching for patterns: %v", cfg.Patterns)
+	logger.Info().Msgf("Triggering on events: %v", cfg.EventTypes)
+	if cfg.HTTPURL != "" {
+		logger.Info().Msgf("HTTP action configured: %s %s", cfg.HTTPMethod, cfg.HTTPURL)
+	} else if cfg.SignalPIDFile != "" {
+		logger.Info().Msgf("Signal configured: SIG%s to the process in %s", strings.TrimPrefix(strings.ToUpper(cfg.Signal), "SIG"), cfg.SignalPIDFile)
+	} else if cfg.Action != "" {
+		logger.Info().Msgf("Action configured: %s", cfg.Action)
+	} else if cfg.GoHandler != "" {
+		logger.Info().Msgf("Go handler configured: %s", cfg.GoHandler)
+	} else if cfg.ActionPlugin != "" {
+		logger.Info().Msgf("Action plugin configured: %s", cfg.ActionPlugin)
+	} else if cfg.Script {
+		logger.Info().Msgf("Script configured (%d lines)", strings.Count(strings.TrimRight(cfg.CommandTmpl, "\n"), "\n")+1)
+	} else {
+		logger.Info().Msgf("Command template configured: %s", cfg.CommandTmpl)
 	}
 
-	allowedEvents := processEventTypes(cfg.EventTypes)
+	sidecars, err := newSidecars(cfg)
+	if err != nil {
+		return err
+	}
+	if sidecars != nil {
+		logger.Info().Msgf("Waiting for sidecar files: %s", cfg.SidecarTmpl)
+	}
 
-	done := make(chan bool)
-	go func() {
-		defer close(done)
-		var debounceTimer *time.Timer
-		var lastEventData *EventData
-		var timerChan <-chan time.Time
+	when, err := newWhenFilter(cfg)
+	if err != nil {
+		return err
+	}
 
-		for {
-			if debounceTimer != nil {
-				timerChan = debounceTimer.C
-			} else {
-				timerChan = nil
-			}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
+	sources := append(configuredSources(cfg, logger), cfg.Sources...)
+	if cfg.Control != nil {
+		sources = append(sources, &controlSource{control: cfg.Control, job: cfg.Name, accept: func(path string) (*EventData, error) {
+			return triggerData(cfg, path, logger)
+		}})
+	}
+	var channels []<-chan Event
+	for _, source := range sources {
+		ch, err := source.Start(ctx)
+		if err != nil {
+			return err
+		}
+		channels = append(channels, ch)
+	}
+	events := merge(ctx, channels)
+	if cfg.K8sConfigMap {
+		events = configMapEvents(ctx, cfg, events, logger)
+	}
+
+	var debounceTimer *time.Timer
+	var lastEventData *EventData
+	var timerChan <-chan time.Time
+
+	var healthChan <-chan time.Time
+	if cfg.Health != nil {
+		cfg.Health.start(cfg.Name)
+		defer cfg.Health.stop(cfg.Name)
+		ticker := time.NewTicker(healthTick)
+		defer ticker.Stop()
+		healthChan = ticker.C
+	}
 
-				if cfg.Recursive && event.Has(fsnotify.Create) {
-					info, err := os.Stat(event.Name)
-					if err == nil && info.IsDir() {
-						log.Debug().Msgf("Detected directory creation: %s. Adding watch and scanning...", event.Name)
-						// Add watch to the new directory
-						if watchErr := watcher.Add(event.Name); watchErr != nil {
-							log.Warn().Msgf("Failed to add recursive watch for newly created directory %s: %v", event.Name, watchErr)
-							// Continue processing other events even if adding watch failed for this one
-						}
-
-						// Scan the new directory for matching files
-						entries, readErr := os.ReadDir(event.Name)
-						if readErr != nil {
-							log.Warn().Msgf("Failed to read newly created directory %s for initial scan: %v", event.Name, readErr)
-						} else {
-							for _, entry := range entries {
-								if !entry.IsDir() {
-									fileName := entry.Name()
-									filePath := filepath.Join(event.Name, fileName)
-									// Check against patterns
-									for _, pattern := range cfg.Patterns {
-										match, matchErr := filepath.Match(pattern, fileName)
-										if matchErr != nil {
-											log.Error().Msgf("Error matching pattern '%s' with file '%s': %v", pattern, fileName, matchErr)
-											continue // Try next pattern
-										}
-										if match {
-											log.Info().Msgf("Detected matching file in new directory: %s", filePath)
-											// Construct event data for the file
-											ext := filepath.Ext(fileName)
-											fileEventData := &EventData{
-												Path:     filePath,
-												Name:     fileName,
-												Event:    "CREATE", // Treat as CREATE event
-												Ext:      ext,
-												Dir:      event.Name, // Directory where it was found
-												BaseName: strings.TrimSuffix(fileName, ext),
-											}
-											// Trigger command immediately for this file (or handle debounce)
-											if cfg.DebounceDelay > 0 {
-												// If debouncing, update lastEventData and reset timer
-												lastEventData = fileEventData
-												log.Debug().Msgf("Debouncing event for %s (found in new dir)", fileEventData.Path)
-												if debounceTimer == nil {
-													debounceTimer = time.NewTimer(cfg.DebounceDelay)
-												} else {
-													if !debounceTimer.Stop() {
-														select {
-														case <-debounceTimer.C:
-														default:
-														}
-													}
-													debounceTimer.Reset(cfg.DebounceDelay)
-												}
-											} else {
-												execFunc(cfg, fileEventData)
-											}
-											break
-										}
-									}
-								}
-								// TODO: Optionally, recursively add watch & scan for subdirs created within this new dir?
-								// For now, fsnotify should handle subsequent events within the new dir.
-							}
-						}
-						// Skip further processing of the original directory CREATE event itself
-						// if patterns are active, as the directory name likely won't match file patterns.
-						// If no patterns, let it proceed? For now, always skip to avoid double triggers.
-						continue
+	// dispatch executes the command for eventData, or (re)starts the
+	// debounce timer when a delay is configured.
+	triggers := 0
+	deduper := newInodeDeduper()
+	repeats := newPathDeduper(cfg.DedupeWindow)
+	execute := func(eventData *EventData) error {
+		result := execFunc(eventData.Context(ctx), cfg, eventData)
+		if !result.Skipped {
+			triggers++
+		}
+		return result.Err
+	}
+	// traces holds the traces of the events the pending execution covers.
+	// runTraced ends them once run completes.
+	var traces []*eventTrace
+	runTraced := func(run func() error) {
+		for _, t := range traces {
+			t.endWait()
+		}
+		err := run()
+		for _, t := range traces {
+			t.finish(err)
+		}
+		traces = nil
+	}
+	// In batch mode, events are collected until the debounce timer fires and
+	// the command runs once per chunk of up to BatchSize files (of a
+	// directory, with GroupBy).
+	var pending []EventData
+	executeBatch := func() {
+		runTraced(func() error {
+			var errs []error
+			for _, group := range groupEvents(pending, cfg.GroupBy) {
+				for _, chunk := range chunkEvents(group, cfg.BatchSize) {
+					batch := chunk[len(chunk)-1]
+					batch.Files = chunk
+					errs = append(errs, execute(&batch))
+				}
+			}
+			pending = nil
+			return errors.Join(errs...)
+		})
+	}
+	// Events in a --pause-during window are held until it ends, keeping the
+	// latest one per path.
+	var paused []heldEvent
+	var pauseTimer *time.Timer
+	var pauseChan <-chan time.Time
+	dispatch := func(eventData *EventData, tr *eventTrace) {
+		if ok, cond, err := when.accepts(cfg, eventData); err != nil {
+			logger.Error().Msgf("Error rendering --when template for %s: %v", eventData.Path, err)
+			tr.ignore("when template failed")
+			cfg.Stats.filter()
+			return
+		} else if !ok {
+			if cfg.Why {
+				logger.Info().Msgf("Ignored %s %s: --when rendered '%s'", eventData.Event, eventData.Path, cond)
+			}
+			logger.Debug().Msgf("Skipping %s: --when rendered '%s'", eventData.Path, cond)
+			tr.ignore("when")
+			cfg.Stats.filter()
+			return
+		}
+		if eventData.Event != "TRIGGER" && cfg.Control.Paused(cfg.Name) {
+			logger.Debug().Msgf("Ignoring %s %s: job is paused", eventData.Event, eventData.Path)
+			tr.ignore("paused")
+			cfg.Stats.filter()
+			cfg.Control.suppress(cfg.Name, eventData)
+			return
+		}
+		if until, ok := pausedUntil(cfg.PauseDuring, time.Now()); ok && eventData.Event != "TRIGGER" {
+			if cfg.PauseDrop {
+				logger.Info().Msgf("Ignoring %s %s: paused until %s", eventData.Event, eventData.Path, until.Format("15:04"))
+				tr.ignore("pause window")
+				cfg.Stats.filter()
+				return
+			}
+			if pauseTimer == nil {
+				logger.Info().Msgf("Paused until %s, holding events until then", until.Format("15:04"))
+				pauseTimer = time.NewTimer(time.Until(until))
+				pauseChan = pauseTimer.C
+			}
+			tr.startWait("pause window")
+			for i, held := range paused {
+				if held.data.Path == eventData.Path {
+					held.tr.ignore("coalesced")
+					cfg.Stats.coalesce()
+					paused = slices.Delete(paused, i, i+1)
+					break
+				}
+			}
+			paused = append(paused, heldEvent{data: eventData, tr: tr})
+			return
+		}
+		cfg.Control.accepted(cfg.Name, eventData)
+		if debounceTimer != nil || len(pending) > 0 {
+			cfg.Stats.coalesce() // Joins the execution that's already pending
+		}
+		if !cfg.Batch {
+			for _, t := range traces {
+				t.ignore("coalesced") // Replaced by eventData
+			}
+			traces = nil
+		}
+		eventData.ctx = tr.ctx
+		cfg.Health.event(cfg.Name)
+		traces = append(traces, tr)
+		lastEventData = eventData
+		if cfg.Batch {
+			pending = addToBatch(pending, *eventData)
+		}
+		if cfg.DebounceDelay > 0 {
+			logger.Debug().Msgf("Debouncing event for %s", eventData.Path)
+			tr.startWait("debounce")
+			if debounceTimer == nil {
+				debounceTimer = time.NewTimer(cfg.DebounceDelay)
+			} else {
+				if !debounceTimer.Stop() {
+					select {
+					case <-debounceTimer.C:
+					default:
 					}
-					// If stat failed or it wasn't a directory, proceed as normal
 				}
+				debounceTimer.Reset(cfg.DebounceDelay)
+			}
+		} else if cfg.Batch {
+			executeBatch()
+		} else {
+			runTraced(func() error { return execute(eventData) })
+		}
+	}
 
-				eventData := filterEvent(event, allowedEvents, cfg.Patterns)
-				if eventData == nil {
-					continue // Event didn't match filters
-				}
+	for {
+		if cfg.MaxTriggers > 0 && triggers >= cfg.MaxTriggers {
+			logger.Info().Msgf("Reached the maximum of %d trigger(s), stopping watcher.", cfg.MaxTriggers)
+			return nil
+		}
+		if debounceTimer != nil {
+			timerChan = debounceTimer.C
+		} else {
+			timerChan = nil
+		}
 
-				// Debounce or execute immediately
-				lastEventData = eventData
-				if cfg.DebounceDelay > 0 {
-					log.Debug().Msgf("Debouncing event for %s", eventData.Path)
-					if debounceTimer == nil {
-						debounceTimer = time.NewTimer(cfg.DebounceDelay)
-					} else {
-						if !debounceTimer.Stop() {
-							select {
-							case <-debounceTimer.C:
-							default:
-							}
-						}
-						debounceTimer.Reset(cfg.DebounceDelay)
+		select {
+		case <-ctx.Done():
+			logger.Info().Msg("Watcher stopped.")
+			return nil
+
+		case event, ok := <-events:
+			if !ok {
+				logger.Info().Msg("Watcher stopped.")
+				return nil
+			}
+			cfg.Stats.observe()
+
+			if event.Data != nil {
+				// Synthetic events bypass the pattern and event-type filters;
+				// triggered paths were filtered by triggerData
+				tr := startEventTrace(ctx, cfg, event.Data.Path, event.Data.Event)
+				cfg.logEvent(event.Data)
+				dispatch(event.Data, tr)
+				continue
+			}
+
+			event.Path = normalizeUnicode(cfg.UnicodeForm, event.Path)
+			fsEvent := fsnotify.Event{Name: event.Path, Op: event.Op}
+			if sidecars != nil && !event.Op.Has(fsnotify.Remove) && !event.Op.Has(fsnotify.Rename) {
+				// A marker releases the file waiting for it, whether or not it
+				// passes the filters itself
+				if held, ok := sidecars.release(event.Path); ok {
+					logger.Info().Msgf("Sidecar %s appeared for: %s", event.Path, held.data.Path)
+					cfg.logEvent(held.data)
+					dispatch(held.data, held.tr)
+					continue
+				}
+			}
+			tr := startEventTrace(ctx, cfg, event.Path, event.Op.String())
+			if filepath.Base(event.Path) == IgnoreFileName {
+				if absDir, err := filepath.Abs(filepath.Dir(event.Path)); err == nil {
+					cfg.ignoreFiles.forget(absDir)
+				}
+			}
+			if !cfg.IncludeHidden && isHidden(event.Path) {
+				if cfg.Why {
+					logger.Info().Msgf("Ignored %s %s: hidden files are ignored without --include-hidden", event.Op, event.Path)
+				}
+				tr.ignore("hidden")
+				cfg.Stats.filter()
+				continue
+			}
+			if cfg.IgnoreNoise {
+				if reason := noiseReason(fsEvent, cfg.EventTypes); reason != "" {
+					if cfg.Why {
+						logger.Info().Msgf("Ignored %s %s: %s", event.Op, event.Path, reason)
 					}
-				} else {
-					// Pass the full config now
-					execFunc(cfg, eventData)
+					logger.Trace().Msgf("Ignoring %s %s: %s", event.Op, event.Path, reason)
+					tr.ignore(reason)
+					cfg.Stats.filter()
+					continue
 				}
+			}
 
-			case <-timerChan:
-				log.Debug().Msg("Debounce timer fired.")
-				if lastEventData != nil {
-					// Pass the full config now
-					execFunc(cfg, lastEventData)
-					lastEventData = nil
+			at := event.At
+			if at.IsZero() {
+				at = time.Now()
+			}
+			if repeats.duplicate(fsEvent, at) {
+				if cfg.Why {
+					logger.Info().Msgf("Ignored %s %s: repeated within %s", event.Op, event.Path, cfg.DedupeWindow)
 				}
-				debounceTimer = nil
+				logger.Debug().Msgf("Ignoring %s %s: repeated within %s", event.Op, event.Path, cfg.DedupeWindow)
+				tr.ignore("repeated event")
+				cfg.Stats.coalesce()
+				continue
+			}
 
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
+			eventData, reason := filterEvent(fsEvent, event.IsDir, allowedEvents, cfg, logger)
+			tr.filter(eventData != nil, reason)
+			if cfg.Why {
+				if eventData != nil {
+					logger.Info().Msgf("Accepted %s %s: %s", event.Op, event.Path, reason)
+				} else {
+					logger.Info().Msgf("Ignored %s %s: %s", event.Op, event.Path, reason)
 				}
-				log.Error().Msgf("Watcher error: %v", err)
 			}
-		}
-	}()
-
-	log.Info().Msgf("Starting watcher for directories: %v", cfg.WatchDirs)
-	if cfg.Recursive {
-		log.Info().Msg("Recursive mode enabled.")
-	}
-	log.Info().Msgf("Watching for patterns: %v", cfg.Patterns)
-	log.Info().Msgf("Triggering on events: %v", cfg.EventTypes)
-	log.Info().Msgf("Command template configured: %s", cfg.CommandTmpl)
-
-	absExcludedDirs := make(map[string]bool)
-	if len(cfg.ExcludeDirs) > 0 {
-		log.Info().Msgf("Excluding directories: %v", cfg.ExcludeDirs)
-		for _, exDir := range cfg.ExcludeDirs {
-			absExDir, err := filepath.Abs(exDir)
-			if err != nil {
-				log.Warn().Msgf("Could not get absolute path for excluded directory %s: %v", exDir, err)
+			if eventData == nil {
+				tr.ignore(reason)
+				cfg.Stats.filter()
+				continue // Event didn't match filters
+			}
+			if other, dup := deduper.duplicate(fsEvent); dup {
+				logger.Debug().Msgf("Ignoring %s %s: same file as %s", event.Op, event.Path, other)
+				tr.ignore("duplicate of " + other)
+				cfg.Stats.coalesce()
 				continue
 			}
-			absExcludedDirs[absExDir] = true
-		}
-	}
-
-	for _, dir := range cfg.WatchDirs {
-		if cfg.Recursive {
-			walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					log.Warn().Msgf("Error accessing path %q: %v", path, err)
-					return err
+			eventData.Remote = event.Remote
+			if sidecars != nil {
+				if event.Op.Has(fsnotify.Remove) || event.Op.Has(fsnotify.Rename) {
+					sidecars.drop(event.Path)
+				} else if ready, err := sidecars.hold(eventData, tr); err != nil {
+					logger.Warn().Msgf("Ignoring %s: %v", event.Path, err)
+					tr.ignore("sidecar template failed")
+					continue
+				} else if !ready {
+					logger.Info().Msgf("Waiting for sidecar %s before running for: %s", eventData.Sidecar, event.Path)
+					continue
 				}
+			}
+			cfg.logEvent(eventData)
 
-				if info.IsDir() {
-					absPath, pathErr := filepath.Abs(path)
-					if pathErr != nil {
-						log.Warn().Msgf("Could not get absolute path for %s: %v", path, pathErr)
-						return nil
-					}
+			// Debounce or execute immediately
+			dispatch(eventData, tr)
 
-					for exPath := range absExcludedDirs {
-						if strings.HasPrefix(absPath+string(filepath.Separator), exPath+string(filepath.Separator)) {
-							log.Debug().Msgf("Skipping excluded directory: %s", path)
-							return filepath.SkipDir
-						}
-					}
+		case <-healthChan:
+			cfg.Health.tick(cfg.Name)
 
-					log.Debug().Msgf("Adding recursive watch for: %s", path)
-					if watchErr := watcher.Add(path); watchErr != nil {
-						log.Warn().Msgf("Failed to add recursive watch for %s: %v", path, watchErr)
-					}
-				}
-				return nil
-			})
-			if walkErr != nil {
-				log.Error().Msgf("Error walking the path %q: %v", dir, walkErr)
+		case <-pauseChan:
+			pauseTimer, pauseChan = nil, nil
+			held := paused
+			paused = nil
+			if len(held) > 0 {
+				logger.Info().Msgf("Pause window over, running %d held event(s)", len(held))
 			}
-		} else {
-			log.Info().Msgf("Adding watch for: %s", dir)
-			if err = watcher.Add(dir); err != nil {
-				log.Warn().Msgf("Failed to add watch for %s: %v", dir, err)
+			for _, h := range held {
+				h.tr.endWait()
+				dispatch(h.data, h.tr) // Held again if another window started
 			}
+
+		case <-timerChan:
+			logger.Debug().Msg("Debounce timer fired.")
+			if cfg.Batch && len(pending) > 0 {
+				executeBatch()
+				lastEventData = nil
+			} else if lastEventData != nil {
+				runTraced(func() error { return execute(lastEventData) })
+				lastEventData = nil
+			}
+			debounceTimer = nil
 		}
 	}
-
-	<-done
-	log.Info().Msg("Watcher stopped.")
-	return nil
 }
 
-func processEventTypes(types []string) map[fsnotify.Op]bool {
+// Errors returned for invalid event types.
+var (
+	ErrUnknownEventType     = errors.New("unknown event type")
+	ErrUnsupportedEventType = errors.New("event type is only supported on Linux and FreeBSD")
+)
+
+// processEventTypes returns the ops to trigger on for the given event types.
+// Unknown types are ignored with a warning; types that aren't available on
+// this platform are an error wrapping ErrUnsupportedEventType.
+func processEventTypes(types []string, logger zerolog.Logger) (map[fsnotify.Op]bool, error) {
 	lookup := make(map[fsnotify.Op]bool)
-	hasAll := false
 	for _, t := range types {
 		if strings.ToLower(t) == "all" {
-			hasAll = true
-			break
+			for _, et := range eventTypes {
+				if et.typ.Supported() == nil {
+					lookup[et.op] = true
+				}
+			}
+			return lookup, nil
 		}
 	}
 
-	isUnportableSupported := func() bool {
-		return runtime.GOOS == "linux" || runtime.GOOS == "freebsd"
+	for _, t := range types {
+		typ, err := ParseEventType(t)
+		if err != nil {
+			logger.Warn().Msgf("Warning: Unknown event type '%s' ignored.", t)
+			continue
+		}
+		if err := typ.Supported(); err != nil {
+			return nil, err
+		}
+		lookup[typ.op()] = true
 	}
+	return lookup, nil
+}
 
-	if hasAll {
-		lookup[fsnotify.Create] = true
-		lookup[fsnotify.Write] = true
-		lookup[fsnotify.Remove] = true
-		lookup[fsnotify.Rename] = true
-		lookup[fsnotify.Chmod] = true
-		if isUnportableSupported() {
-			lookup[fsnotify.Op(1<<5)] = true // Inotify: IN_OPEN
-			lookup[fsnotify.Op(1<<6)] = true // Inotify: IN_ACCESS
-			lookup[fsnotify.Op(1<<7)] = true // Inotify: IN_CLOSE_WRITE
-			lookup[fsnotify.Op(1<<8)] = true // Inotify: IN_CLOSE_NOWRITE
-		}
-		return lookup
+// filterEvent applies the event type, target, pattern and file filters to
+// event, whose path is a directory when isDir is set. It returns nil when
+// the event is filtered out, along with the reason for the decision either
+// way.
+func filterEvent(event fsnotify.Event, isDir bool, allowedEvents map[fsnotify.Op]bool, cfg Config, logger zerolog.Logger) (*EventData, string) {
+	et, triggered := matchEventType(event.Op, allowedEvents)
+	if !triggered {
+		logger.Trace().Msgf("Ignoring event type %s for %s", event.Op.String(), event.Name)
+		return nil, fmt.Sprintf("event type %s is not allowed", event.Op.String())
 	}
+	return filterFile(event, et.name, isDir, cfg, logger)
+}
 
-	for _, t := range types {
-		switch strings.ToLower(t) {
-		case "create":
-			lookup[fsnotify.Create] = true
-		case "write":
-			lookup[fsnotify.Write] = true
-		case "remove":
-			lookup[fsnotify.Remove] = true
-		case "rename":
-			lookup[fsnotify.Rename] = true
-		case "chmod":
-			lookup[fsnotify.Chmod] = true
-		case "open":
-			if isUnportableSupported() {
-				lookup[fsnotify.Op(1<<5)] = true
-			} else {
-				log.Error().Msg("'open' event is only supported on Linux and FreeBSD; exiting.")
-				os.Exit(1)
-			}
-		case "read":
-			if isUnportableSupported() {
-				lookup[fsnotify.Op(1<<6)] = true
-			} else {
-				log.Error().Msg("'read' event is only supported on Linux and FreeBSD; exiting.")
-				os.Exit(1)
-			}
-		case "closewrite":
-			if isUnportableSupported() {
-				lookup[fsnotify.Op(1<<7)] = true
-			} else {
-				log.Error().Msg("'closewrite' event is only supported on Linux and FreeBSD; exiting.")
-				os.Exit(1)
-			}
-		case "closeread":
-			if isUnportableSupported() {
-				lookup[fsnotify.Op(1<<8)] = true
-			} else {
-				log.Error().Msg("'closeread' event is only supported on Linux and FreeBSD; exiting.")
-				os.Exit(1)
-			}
-		default:
-			log.Warn().Msgf("Warning: Unknown event type '%s' ignored.", t)
+// filterFile is filterEvent without the event type filter, for an event
+// reported as eventStr, e.g. TRIGGER.
+func filterFile(event fsnotify.Event, eventStr string, isDir bool, cfg Config, logger zerolog.Logger) (*EventData, string) {
+	if reason := targetReason(cfg.Target, isDir); reason != "" {
+		logger.Trace().Msgf("Ignoring %s (%s)", event.Name, reason)
+		return nil, reason
+	}
+
+	fileName := filepath.Base(event.Name)
+	pattern, matched := cfg.matchPattern(event.Name)
+	if !matched {
+		logger.Trace().Msgf("Ignoring file %s (no pattern match)", event.Name)
+		return nil, fmt.Sprintf("'%s' matches none of the patterns %v", fileName, cfg.patternsFor(event.Name))
+	}
+
+	if rule, ok := cfg.ignoredFile(event.Name); ok {
+		logger.Trace().Msgf("Ignoring file %s (%s)", event.Name, rule)
+		return nil, fmt.Sprintf("'%s' is ignored by %s", fileName, rule)
+	}
+
+	if reason := fileFilterReason(cfg, event); reason != "" {
+		logger.Trace().Msgf("Ignoring file %s (%s)", event.Name, reason)
+		return nil, reason
+	}
+
+	// Sniffing reads the file, which may still be being written
+	var mediaType string
+	if cfg.mime {
+		mediaType = detectMime(event.Name)
+	}
+	if len(cfg.MimeTypes) > 0 {
+		if _, ok := matchMime(cfg.MimeTypes, mediaType); !ok {
+			logger.Trace().Msgf("Ignoring file %s (media type %s)", event.Name, mediaType)
+			return nil, fmt.Sprintf("media type '%s' matches none of %v", mediaType, cfg.MimeTypes)
 		}
 	}
-	return lookup
+
+	logger.Info().Str("pattern", pattern).Msgf("Detected %s event for: %s", eventStr, event.Name)
+
+	data := fileData(event.Name, eventStr, mediaType)
+	data.IsDir = isDir
+	data.MatchedPattern = pattern
+	return &data, fmt.Sprintf("%s matches pattern '%s'", eventStr, pattern)
 }
 
-func filterEvent(event fsnotify.Event, allowedEvents map[fsnotify.Op]bool, patterns []string) *EventData {
-	triggered := false
-	var eventStr string
-	for op, allowed := range allowedEvents {
-		if allowed && event.Has(op) {
-			triggered = true
-			eventStr = op.String()
-			break
-		}
+// FileData returns the event data for an event of type event on the file
+// at path.
+func FileData(path, event string) EventData {
+	return fileData(path, event, detectMime(path))
+}
+
+func fileData(path, event, mediaType string) EventData {
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
 	}
-	if !triggered {
-		log.Trace().Msgf("Ignoring event type %s for %s", event.Op.String(), event.Name)
-		return nil
+	fileName := filepath.Base(path)
+	ext := filepath.Ext(fileName)
+	return EventData{
+		Path:      path,
+		PathSlash: filepath.ToSlash(path),
+		Name:      fileName,
+		Event:     event,
+		Ext:       ext,
+		Dir:       filepath.Dir(path),
+		BaseName:  strings.TrimSuffix(fileName, ext),
+		Size:      size,
+		Mime:      mediaType,
 	}
+}
 
-	matchedPattern := false
-	fileName := filepath.Base(event.Name)
-	for _, pattern := range patterns {
-		match, err := filepath.Match(pattern, fileName)
-		if err != nil {
-			log.Error().Msgf("Error matching pattern '%s' with file '%s': %v", pattern, fileName, err)
-			continue
-		}
-		if match {
-			matchedPattern = true
+// addToBatch adds data to a pending batch. A file that is already part of
+// the batch is moved to the end with its latest event.
+func addToBatch(pending []EventData, data EventData) []EventData {
+	for i := range pending {
+		if pending[i].Path == data.Path {
+			pending = append(pending[:i], pending[i+1:]...)
 			break
 		}
 	}
-	if !matchedPattern {
-		log.Trace().Msgf("Ignoring file %s (no pattern match)", event.Name)
-		return nil
+	return append(pending, data)
+}
+
+// GroupByDir is the Config.GroupBy value that batches events per directory.
+const GroupByDir = "dir"
+
+// groupEvents splits events into one group per directory when by is
+// GroupByDir, ordered by each directory's first event. Otherwise all events
+// form one group.
+func groupEvents(events []EventData, by string) [][]EventData {
+	if by != GroupByDir {
+		return [][]EventData{events}
+	}
+	var groups [][]EventData
+	index := make(map[string]int)
+	for _, event := range events {
+		i, ok := index[event.Dir]
+		if !ok {
+			i = len(groups)
+			index[event.Dir] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], event)
+	}
+	return groups
+}
+
+// chunkEvents splits events into chunks of at most size events (all of
+// them in one chunk when size is 0).
+func chunkEvents(events []EventData, size int) [][]EventData {
+	if size <= 0 || size >= len(events) {
+		return [][]EventData{events}
+	}
+	var chunks [][]EventData
+	for start := 0; start < len(events); start += size {
+		end := min(start+size, len(events))
+		chunks = append(chunks, events[start:end])
 	}
+	return chunks
+}
 
-	log.Info().Msgf("Detected %s event for: %s", eventStr, event.Name)
+// matchPattern returns the first pattern that applies to path and matches
+// its file name.
+func (cfg Config) matchPattern(path string) (string, bool) {
+	return cfg.matcherFor(path).match(filepath.Base(path))
+}
 
-	ext := filepath.Ext(fileName)
-	return &EventData{
-		Path:     event.Name,
-		Name:     fileName,
-		Event:    eventStr,
-		Ext:      ext,
-		Dir:      filepath.Dir(event.Name),
-		BaseName: strings.TrimSuffix(fileName, ext),
+// CheckEventType reports an error when t is not a known event type or is not
+// supported on this platform.
+func CheckEventType(t string) error {
+	if strings.EqualFold(t, "all") {
+		return nil
+	}
+	typ, err := ParseEventType(t)
+	if err != nil {
+		return err
 	}
+	return typ.Supported()
 }
@@ -0,0 +1,78 @@
+package watcher
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"syscall"
+
+	"github.com/rs/zerolog"
+)
+
+// maxFailureExamples is how many paths a watch failure warning lists per
+// reason.
+const maxFailureExamples = 3
+
+// watchFailures collects the directories that couldn't be watched, grouped
+// by reason, so they're reported as one warning per reason instead of one
+// per directory.
+type watchFailures struct {
+	total   int
+	reasons []string            // In order of first occurrence
+	pending map[string][]string // Reason -> paths since the last report
+	seen    map[string]bool     // Paths already counted
+}
+
+func newWatchFailures() *watchFailures {
+	return &watchFailures{pending: make(map[string][]string), seen: make(map[string]bool)}
+}
+
+// add records that path couldn't be watched or read because of err.
+func (f *watchFailures) add(path string, err error) {
+	if f.seen[path] {
+		return
+	}
+	f.seen[path] = true
+	f.total++
+	reason := failureReason(err)
+	if _, ok := f.pending[reason]; !ok {
+		f.reasons = append(f.reasons, reason)
+	}
+	f.pending[reason] = append(f.pending[reason], path)
+}
+
+// report logs the failures recorded since the last call.
+func (f *watchFailures) report(logger zerolog.Logger) {
+	for _, reason := range f.reasons {
+		paths := f.pending[reason]
+		examples := paths
+		if len(examples) > maxFailureExamples {
+			examples = examples[:maxFailureExamples]
+		}
+		more := ""
+		if len(paths) > len(examples) {
+			more = ", ..."
+		}
+		logger.Warn().Msgf("Could not watch %d director(ies) (%s), e.g. %s%s", len(paths), reason, strings.Join(examples, ", "), more)
+	}
+	f.reasons = nil
+	clear(f.pending)
+}
+
+// failureReason describes err without the path, so failures with the same
+// cause are grouped.
+func failureReason(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return "permission denied"
+	case errors.Is(err, fs.ErrNotExist):
+		return "no longer exists"
+	case errors.Is(err, syscall.EMFILE):
+		return "too many open files"
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err.Error()
+	}
+	return err.Error()
+}
@@ -0,0 +1,43 @@
+package watcher
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// whenFilter is cfg's --when template, which must render to "true" for an
+// event to be dispatched. It's checked before debouncing, so every event of
+// a batch is checked, and rejected events don't count as runs.
+type whenFilter struct {
+	tmpl *template.Template
+}
+
+// newWhenFilter parses cfg's When. It returns nil when it isn't set.
+func newWhenFilter(cfg Config) (*whenFilter, error) {
+	if cfg.When == "" {
+		return nil, nil
+	}
+	tmpl, err := ParseTemplate(cfg, "when", cfg.When)
+	if err != nil {
+		return nil, fmt.Errorf("invalid when template: %w", err)
+	}
+	return &whenFilter{tmpl: tmpl}, nil
+}
+
+// accepts reports whether data passes the filter, and what the template
+// rendered.
+func (w *whenFilter) accepts(cfg Config, data *EventData) (bool, string, error) {
+	if w == nil {
+		return true, "", nil
+	}
+	whenData := *data
+	whenData.Rule = cfg.Name
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, &whenData); err != nil {
+		return false, "", err
+	}
+	cond := strings.TrimSpace(buf.String())
+	return cond == "true", cond, nil
+}
@@ -0,0 +1,213 @@
+// Package worker hands events to a long-running handler process over a
+// JSON-RPC 2.0 protocol on the handler's stdin and stdout, so no process has
+// to be spawned per event.
+//
+// Every event is sent as one line:
+//
+//	{"jsonrpc":"2.0","id":1,"method":"event","params":{"path":"./a.txt","event":"WRITE",...}}
+//
+// and the handler acknowledges it with a line carrying the same id and either
+// a result (success) or an error (failure):
+//
+//	{"jsonrpc":"2.0","id":1,"result":"ok"}
+//	{"jsonrpc":"2.0","id":1,"error":{"code":1,"message":"conversion failed"}}
+//
+// Any other stdout output of the handler is passed through.
+package worker
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/s0up4200/gowatchrun/internal/supervisor"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int         `json:"id"`
+	Method  string      `json:"method"`
+	Params  EventParams `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int            `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// EventParams are the placeholders of the command template in snake case,
+// sent as the params of every request (and as the default body of
+// --http-action requests).
+type EventParams struct {
+	Path      string            `json:"path"`
+	PathSlash string            `json:"path_slash"`
+	Name      string            `json:"name"`
+	Event     string            `json:"event"`
+	Ext       string            `json:"ext"`
+	Dir       string            `json:"dir"`
+	BaseName  string            `json:"base_name"`
+	Size      int64             `json:"size"`
+	Mime      string            `json:"mime,omitempty"`
+	Remote    string            `json:"remote,omitempty"`
+	Files     []EventParams     `json:"files,omitempty"`
+	RunNumber int               `json:"run_number,omitempty"`
+	Hostname  string            `json:"hostname,omitempty"`
+	Payload   interface{}       `json:"payload,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+
+	MatchedPattern string `json:"matched_pattern,omitempty"`
+	Rule           string `json:"rule,omitempty"`
+}
+
+// Params returns the params describing data.
+func Params(data watcher.EventData) EventParams {
+	params := EventParams{
+		Path:      data.Path,
+		PathSlash: data.PathSlash,
+		Name:      data.Name,
+		Event:     data.Event,
+		Ext:       data.Ext,
+		Dir:       data.Dir,
+		BaseName:  data.BaseName,
+		Size:      data.Size,
+		Mime:      data.Mime,
+		Remote:    data.Remote,
+		RunNumber: data.RunNumber,
+		Hostname:  data.Hostname,
+		Payload:   data.Payload,
+		Body:      data.Body,
+		Headers:   data.Headers,
+
+		MatchedPattern: data.MatchedPattern,
+		Rule:           data.Rule,
+	}
+	for _, file := range data.Files {
+		params.Files = append(params.Files, Params(file))
+	}
+	return params
+}
+
+// Worker is a handler process that is started once and restarted with
+// backoff when it exits.
+type Worker struct {
+	proc    *supervisor.Process
+	timeout time.Duration
+	logger  zerolog.Logger
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan response
+}
+
+// New creates a worker that runs command through the shell. A timeout of 0
+// waits for every response indefinitely.
+func New(name, command string, timeout time.Duration, logger zerolog.Logger) *Worker {
+	w := &Worker{
+		proc:    supervisor.New(name, command, logger),
+		timeout: timeout,
+		logger:  logger,
+		pending: make(map[int]chan response),
+	}
+	stdout, out := io.Pipe()
+	w.proc.PipeStdin = true
+	w.proc.Stdout = out
+	w.proc.OnExit = w.failPending
+	go w.readResponses(stdout)
+	return w
+}
+
+// Process returns the supervised handler process.
+func (w *Worker) Process() *supervisor.Process {
+	return w.proc
+}
+
+// Call sends data to the handler and waits for its acknowledgment. The
+// returned error reports a failure response, a timeout or a handler that
+// exited before answering.
+func (w *Worker) Call(data watcher.EventData) error {
+	w.mu.Lock()
+	w.nextID++
+	id := w.nextID
+	reply := make(chan response, 1)
+	w.pending[id] = reply
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.pending, id)
+		w.mu.Unlock()
+	}()
+
+	line, err := json.Marshal(request{JSONRPC: "2.0", ID: id, Method: "event", Params: Params(data)})
+	if err != nil {
+		return err
+	}
+	if err := w.proc.WriteLine(string(line)); err != nil {
+		return err
+	}
+
+	var timeout <-chan time.Time
+	if w.timeout > 0 {
+		timer := time.NewTimer(w.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case resp := <-reply:
+		if resp.Error != nil {
+			return fmt.Errorf("handler error %d: %s", resp.Error.Code, resp.Error.Message)
+		}
+		return nil
+	case <-timeout:
+		return fmt.Errorf("no response from handler within %s", w.timeout)
+	}
+}
+
+// readResponses dispatches the responses on the handler's stdout to the
+// waiting calls and passes every other line through.
+func (w *Worker) readResponses(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var resp response
+		if err := json.Unmarshal(line, &resp); err != nil || resp.JSONRPC != "2.0" || resp.ID == nil {
+			os.Stdout.Write(append(line, '\n'))
+			continue
+		}
+		w.mu.Lock()
+		reply, ok := w.pending[*resp.ID]
+		delete(w.pending, *resp.ID)
+		w.mu.Unlock()
+		if !ok {
+			w.logger.Warn().Msgf("Ignoring handler response for unknown id %d", *resp.ID)
+			continue
+		}
+		reply <- resp // Buffered, and removed from pending so sent only once
+	}
+}
+
+// failPending fails all calls still waiting for a response once the handler
+// has exited.
+func (w *Worker) failPending() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, reply := range w.pending {
+		reply <- response{Error: &rpcError{Code: -32000, Message: "handler exited before responding"}}
+		delete(w.pending, id)
+	}
+}
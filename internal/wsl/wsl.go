@@ -0,0 +1,59 @@
+// Package wsl translates paths between Windows and the Windows Subsystem for
+// Linux (WSL), and runs commands on the other side: inside WSL when
+// gowatchrun runs on Windows, and on Windows when it runs inside WSL.
+package wsl
+
+import (
+	"os"
+	"strings"
+)
+
+// ToWSL returns the WSL path of a Windows path: drive paths like
+// C:\Users\me map to /mnt/c/Users/me, and paths of a distribution's files
+// like \\wsl$\Ubuntu\home\me (or \\wsl.localhost\...) to /home/me. Other
+// paths, like relative ones, only get forward slashes.
+func ToWSL(path string) string {
+	slashed := strings.ReplaceAll(path, `\`, "/")
+	if len(slashed) >= 2 && slashed[1] == ':' && isLetter(slashed[0]) && (len(slashed) == 2 || slashed[2] == '/') {
+		return "/mnt/" + strings.ToLower(slashed[:1]) + slashed[2:]
+	}
+	lower := strings.ToLower(slashed)
+	for _, prefix := range []string{"//wsl$/", "//wsl.localhost/"} {
+		if strings.HasPrefix(lower, prefix) {
+			rest := slashed[len(prefix):]
+			if _, inDistro, ok := strings.Cut(rest, "/"); ok {
+				return "/" + inDistro
+			}
+			return "/"
+		}
+	}
+	return slashed
+}
+
+// ToWindows returns the Windows path of a WSL path: /mnt/c/Users/me maps to
+// C:\Users\me, and other absolute paths to the distribution's share, like
+// \\wsl.localhost\Ubuntu\home\me. Relative paths only get backslashes.
+func ToWindows(path string) string {
+	if rest, ok := strings.CutPrefix(path, "/mnt/"); ok && len(rest) >= 1 && isLetter(rest[0]) && (len(rest) == 1 || rest[1] == '/') {
+		path = strings.ToUpper(rest[:1]) + ":" + rest[1:]
+		if len(rest) == 1 {
+			path += "/"
+		}
+	} else if strings.HasPrefix(path, "/") {
+		path = "//wsl.localhost/" + os.Getenv("WSL_DISTRO_NAME") + path
+	}
+	return strings.ReplaceAll(path, "/", `\`)
+}
+
+// Translate returns path as the other side sees it: the WSL path on Windows,
+// and the Windows path elsewhere.
+func Translate(path string) string {
+	if onWindows {
+		return ToWSL(path)
+	}
+	return ToWindows(path)
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package wsl
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/s0up4200/gowatchrun/internal/shell"
+)
+
+const onWindows = false
+
+// Check reports whether gowatchrun runs inside WSL with Windows interop, so
+// commands can run on Windows.
+func Check() error {
+	if _, err := os.Stat("/proc/sys/fs/binfmt_misc/WSLInterop"); err != nil && os.Getenv("WSL_DISTRO_NAME") == "" {
+		return errors.New("not running inside WSL")
+	}
+	_, err := exec.LookPath("cmd.exe")
+	return err
+}
+
+// CommandContext returns a command that runs command with shell (cmd.exe
+// when empty) on Windows. It starts in the Windows path of the working
+// directory, which cmd.exe only supports below a drive's /mnt directory.
+func CommandContext(ctx context.Context, sh, command string) *exec.Cmd {
+	if sh == "" {
+		sh = "cmd.exe"
+	}
+	return exec.CommandContext(ctx, sh, append(shell.Args(sh), command)...)
+}
@@ -0,0 +1,27 @@
+package wsl
+
+import (
+	"context"
+	"os/exec"
+
+	"github.com/s0up4200/gowatchrun/internal/shell"
+)
+
+const onWindows = true
+
+// Check reports whether commands can run inside WSL.
+func Check() error {
+	_, err := exec.LookPath("wsl.exe")
+	return err
+}
+
+// CommandContext returns a command that runs command with shell (sh when
+// empty) inside the default WSL distribution. It starts in the WSL path of
+// the working directory.
+func CommandContext(ctx context.Context, sh, command string) *exec.Cmd {
+	if sh == "" {
+		sh = "sh"
+	}
+	args := append([]string{"--exec", sh}, shell.Args(sh)...)
+	return exec.CommandContext(ctx, "wsl.exe", append(args, command)...)
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/s0up4200/gowatchrun/cmd"
+	"github.com/s0up4200/gowatchrun/internal/sandbox"
 )
 
 func main() {
+	sandbox.Init() // Doesn't return in the process that starts a sandboxed command
 	if err := cmd.Execute(); err != nil {
+		var exit cmd.ExitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.Code)
+		}
 		os.Exit(1)
 	}
 }
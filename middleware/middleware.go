@@ -0,0 +1,261 @@
+// Package middleware composes behavior around the runs of a job: every run
+// goes through a chain of Middleware, each wrapping the next one, down to
+// the command, action or handler the job runs.
+//
+// Jobs pick middleware with --middleware name[=argument] (middleware in a
+// config file), in order, the first one outermost:
+//
+//	--middleware log --middleware retry=3,2s --middleware timeout=5m
+//
+// logs every run, and retries runs that fail or take longer than five
+// minutes. Programs that embed gowatchrun can add their own middleware with
+// Register before they call cmd.Execute.
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/s0up4200/gowatchrun/internal/shell"
+	"github.com/s0up4200/gowatchrun/internal/watcher"
+)
+
+type (
+	// ExecutorFunc runs a job for an event (nil for runs that weren't
+	// triggered by one) and reports its outcome.
+	ExecutorFunc = watcher.ExecutorFunc
+	// Middleware wraps an ExecutorFunc.
+	Middleware = watcher.Middleware
+	// Config is the configuration of a job.
+	Config = watcher.Config
+	// EventData describes the event a run is for.
+	EventData = watcher.EventData
+	// ExecutionResult is the outcome of a run.
+	ExecutionResult = watcher.ExecutionResult
+)
+
+// Factory creates a middleware from the argument after the '=' of its
+// --middleware value, empty without one.
+type Factory func(arg string) (Middleware, error)
+
+// notifyTimeout is how long a notify command may take.
+const notifyTimeout = 30 * time.Second
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{
+		"log":     func(arg string) (Middleware, error) { return Logging(), noArg("log", arg) },
+		"timeout": parseTimeout,
+		"retry":   parseRetry,
+		"notify":  parseNotify,
+	}
+)
+
+// Chain wraps execFunc in middleware, the first one outermost.
+func Chain(execFunc ExecutorFunc, middleware ...Middleware) ExecutorFunc {
+	return watcher.Chain(execFunc, middleware...)
+}
+
+// Register makes the middleware factory creates available to --middleware
+// as name, replacing a built-in one of the same name.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// Names returns the names --middleware accepts, sorted.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Parse creates the middleware a --middleware value, name or name=argument,
+// selects.
+func Parse(spec string) (Middleware, error) {
+	name, arg, _ := strings.Cut(spec, "=")
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown middleware '%s' (valid: %s)", name, strings.Join(Names(), ", "))
+	}
+	m, err := factory(arg)
+	if err != nil {
+		return nil, fmt.Errorf("middleware '%s': %w", name, err)
+	}
+	return m, nil
+}
+
+// Logging logs the start and the outcome of every run.
+func Logging() Middleware {
+	return func(next ExecutorFunc) ExecutorFunc {
+		return func(ctx context.Context, cfg Config, data *EventData) ExecutionResult {
+			logger := cfg.Logger()
+			if data != nil && data.Path != "" {
+				logger.Info().Msgf("Run started for %s %s", data.Event, data.Path)
+			} else {
+				logger.Info().Msg("Run started")
+			}
+			result := next(ctx, cfg, data)
+			duration := result.Duration.Round(time.Millisecond)
+			if result.OK() {
+				logger.Info().Msgf("Run succeeded in %s", duration)
+			} else {
+				logger.Info().Msgf("Run failed after %s (exit code %d): %v", duration, result.ExitCode, result.Err)
+			}
+			return result
+		}
+	}
+}
+
+// Metrics calls observe with the result of every run.
+func Metrics(observe func(cfg Config, result ExecutionResult)) Middleware {
+	return func(next ExecutorFunc) ExecutorFunc {
+		return func(ctx context.Context, cfg Config, data *EventData) ExecutionResult {
+			result := next(ctx, cfg, data)
+			observe(cfg, result)
+			return result
+		}
+	}
+}
+
+// Timeout fails runs that take longer than d, cancelling their context,
+// which kills the command.
+func Timeout(d time.Duration) Middleware {
+	return func(next ExecutorFunc) ExecutorFunc {
+		return func(ctx context.Context, cfg Config, data *EventData) ExecutionResult {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			result := next(ctx, cfg, data)
+			if result.Err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				result.Err = fmt.Errorf("timed out after %s: %w", d, result.Err)
+			}
+			return result
+		}
+	}
+}
+
+// Retry runs failed runs again, up to retries times, waiting delay before
+// the first retry and twice as long before every further one. The result
+// is the last attempt's, with the duration of all of them.
+func Retry(retries int, delay time.Duration) Middleware {
+	return func(next ExecutorFunc) ExecutorFunc {
+		return func(ctx context.Context, cfg Config, data *EventData) ExecutionResult {
+			start := time.Now()
+			wait := delay
+			for attempt := 1; ; attempt++ {
+				result := next(ctx, cfg, data)
+				if result.OK() || attempt > retries {
+					result.Duration = time.Since(start)
+					return result
+				}
+				logger := cfg.Logger()
+				logger.Warn().Msgf("Run failed, retrying in %s (%d/%d): %v", wait, attempt, retries, result.Err)
+				select {
+				case <-ctx.Done():
+					result.Duration = time.Since(start)
+					return result
+				case <-time.After(wait):
+				}
+				wait *= 2
+			}
+		}
+	}
+}
+
+// Notify calls notify with the result of every run once it completed.
+func Notify(notify func(ctx context.Context, cfg Config, data *EventData, result ExecutionResult)) Middleware {
+	return func(next ExecutorFunc) ExecutorFunc {
+		return func(ctx context.Context, cfg Config, data *EventData) ExecutionResult {
+			result := next(ctx, cfg, data)
+			notify(ctx, cfg, data, result)
+			return result
+		}
+	}
+}
+
+// NotifyCommand runs command through the job's shell after every run, with
+// the outcome in GOWATCHRUN_* environment variables.
+func NotifyCommand(command string) Middleware {
+	return Notify(func(ctx context.Context, cfg Config, data *EventData, result ExecutionResult) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+		defer cancel()
+		status := "success"
+		if !result.OK() {
+			status = "failure"
+		}
+		env := []string{
+			"GOWATCHRUN_JOB=" + cfg.Name,
+			"GOWATCHRUN_STATUS=" + status,
+			"GOWATCHRUN_EXIT_CODE=" + strconv.Itoa(result.ExitCode),
+			"GOWATCHRUN_DURATION_MS=" + strconv.FormatInt(result.Duration.Milliseconds(), 10),
+		}
+		if result.Err != nil {
+			env = append(env, "GOWATCHRUN_ERROR="+result.Err.Error(), "GOWATCHRUN_OUTPUT_TAIL="+string(result.Output))
+		}
+		if data != nil {
+			env = append(env, "GOWATCHRUN_EVENT="+data.Event, "GOWATCHRUN_PATH="+data.Path)
+		}
+		cmd := shell.CommandContext(ctx, cfg.Shell, command)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := shell.Run(cmd); err != nil {
+			logger := cfg.Logger()
+			logger.Warn().Msgf("Notify command failed: %v", err)
+		}
+	})
+}
+
+func parseTimeout(arg string) (Middleware, error) {
+	d, err := time.ParseDuration(arg)
+	if err != nil || d <= 0 {
+		return nil, fmt.Errorf("invalid timeout '%s' (expected a positive duration, e.g. timeout=5m)", arg)
+	}
+	return Timeout(d), nil
+}
+
+// parseRetry parses retries[,delay]; the delay defaults to a second.
+func parseRetry(arg string) (Middleware, error) {
+	count, delayArg, hasDelay := strings.Cut(arg, ",")
+	retries, err := strconv.Atoi(count)
+	if err != nil || retries < 1 {
+		return nil, fmt.Errorf("invalid retry count '%s' (expected e.g. retry=3 or retry=3,2s)", count)
+	}
+	delay := time.Second
+	if hasDelay {
+		if delay, err = time.ParseDuration(delayArg); err != nil || delay < 0 {
+			return nil, fmt.Errorf("invalid retry delay '%s'", delayArg)
+		}
+	}
+	return Retry(retries, delay), nil
+}
+
+func parseNotify(arg string) (Middleware, error) {
+	if strings.TrimSpace(arg) == "" {
+		return nil, fmt.Errorf("a command is required, e.g. notify='notify-send \"$GOWATCHRUN_JOB $GOWATCHRUN_STATUS\"'")
+	}
+	return NotifyCommand(arg), nil
+}
+
+// noArg fails for middleware that take no argument when arg is set.
+func noArg(name, arg string) error {
+	if arg != "" {
+		return fmt.Errorf("takes no argument, use '%s'", name)
+	}
+	return nil
+}